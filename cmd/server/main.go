@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,6 +16,15 @@ import (
 	"github.com/kringen/go-mcp-server/internal/search"
 	"github.com/kringen/go-mcp-server/internal/server"
 	"github.com/kringen/go-mcp-server/internal/tools"
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// Set at build time, e.g. by make build:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.buildTime=2024-05-01T12:00:00Z"
+var (
+	version   = "dev"
+	buildTime = ""
 )
 
 func main() {
@@ -34,18 +46,204 @@ func main() {
 	
 	defaultDebug := os.Getenv("DEBUG") == "true"
 
+	defaultMaxConnections := envInt("MAX_CONNECTIONS", 0)
+	defaultSessionTTL := envDuration("SESSION_TTL", server.DefaultConfig().SessionTTL)
+	defaultIdleTimeout := envDuration("IDLE_TIMEOUT", server.DefaultConfig().IdleTimeout)
+	defaultWriteTimeout := envDuration("WRITE_TIMEOUT", server.DefaultConfig().WriteTimeout)
+	defaultShutdownTimeout := envDuration("SHUTDOWN_TIMEOUT", server.DefaultConfig().ShutdownTimeout)
+	defaultToolTimeout := envDuration("TOOL_TIMEOUT", server.DefaultConfig().DefaultToolTimeout)
+	defaultToolTimeouts := os.Getenv("TOOL_TIMEOUTS")
+	defaultExternalURL := os.Getenv("EXTERNAL_URL")
+	defaultTrustProxyHeaders := os.Getenv("TRUST_PROXY_HEADERS") == "true"
+	defaultTCPAddr := os.Getenv("TCP_ADDR")
+	defaultTLSCert := os.Getenv("TLS_CERT")
+	defaultTLSKey := os.Getenv("TLS_KEY")
+	defaultMaxConcurrentRequests := envInt("MAX_CONCURRENT_REQUESTS", server.DefaultConfig().MaxConcurrentRequests)
+	defaultNotificationBuffer := envInt("NOTIFICATION_BUFFER", server.DefaultConfig().NotificationBuffer)
+	defaultToolsPageSize := envInt("TOOLS_PAGE_SIZE", server.DefaultConfig().ToolsPageSize)
+	defaultAllowNullID := os.Getenv("ALLOW_NULL_ID") == "true"
+	defaultPreciseNumbers := os.Getenv("PRECISE_NUMBERS") != "false"
+	defaultRequireInitialized := os.Getenv("REQUIRE_INITIALIZED") != "false"
+	defaultCompressResponses := os.Getenv("COMPRESS_RESPONSES") != "false"
+	defaultLogRequests := os.Getenv("LOG_REQUESTS") == "true"
+	defaultResponseMeta := os.Getenv("RESPONSE_META") == "true"
+	defaultPrettyJSON := os.Getenv("PRETTY_JSON") == "true"
+	defaultAPIKeys := os.Getenv("API_KEYS")
+	defaultCollectionAccess := os.Getenv("COLLECTION_ACCESS")
+	defaultAllowedOrigins := os.Getenv("ALLOWED_ORIGINS")
+	defaultSubprotocols := os.Getenv("SUBPROTOCOLS")
+	if defaultSubprotocols == "" {
+		defaultSubprotocols = strings.Join(server.DefaultConfig().Subprotocols, ",")
+	}
+	defaultSlowCallThreshold := envDuration("SLOW_CALL_THRESHOLD", server.DefaultConfig().SlowCallThreshold)
+	defaultToolCacheTTL := envDuration("TOOL_CACHE_TTL", 0)
+	defaultStatusInterval := envDuration("STATUS_INTERVAL", server.DefaultConfig().StatusInterval)
+	defaultLargeResponseThreshold := envInt("LARGE_RESPONSE_THRESHOLD", server.DefaultConfig().LargeResponseThreshold)
+	defaultToolProfiles := os.Getenv("TOOL_PROFILES")
+	defaultToolProfile := os.Getenv("TOOL_PROFILE")
+	defaultToolNameConflicts := os.Getenv("TOOL_NAME_CONFLICTS")
+	if defaultToolNameConflicts == "" {
+		defaultToolNameConflicts = server.ToolConflictStrict
+	}
+
+	defaultWriteConcern := os.Getenv("MONGO_WRITE_CONCERN")
+	defaultReadPreference := os.Getenv("MONGO_READ_PREFERENCE")
+	defaultMaxCollectionNameLength := envInt("MAX_COLLECTION_NAME_LENGTH", 0)
+	defaultMaxQueryLimit := envInt("MAX_QUERY_LIMIT", database.DefaultConfig().MaxQueryLimit)
+	defaultMaxTags := envInt("MAX_TAGS", database.DefaultConfig().MaxTags)
+	defaultMaxTagLength := envInt("MAX_TAG_LENGTH", database.DefaultConfig().MaxTagLength)
+	defaultMaxMetadataBytes := envInt("MAX_METADATA_BYTES", database.DefaultConfig().MaxMetadataBytes)
+	defaultCompressContentAbove := envInt("COMPRESS_CONTENT_ABOVE", 0)
+	defaultIndexedCollections := os.Getenv("INDEXED_COLLECTIONS")
+	if defaultIndexedCollections == "" {
+		defaultIndexedCollections = strings.Join(database.DefaultConfig().IndexedCollections, ",")
+	}
+	defaultIndexOnFirstWrite := os.Getenv("INDEX_ON_FIRST_WRITE") == "true"
+	defaultMaxContentBytes := envInt("MAX_CONTENT_BYTES", search.DefaultConfig().MaxContentBytes)
+	defaultSearchProbeURL := os.Getenv("SEARCH_PROBE_URL")
+	if defaultSearchProbeURL == "" {
+		defaultSearchProbeURL = search.DefaultConfig().HealthCheckURL
+	}
+	defaultAuditCollection := os.Getenv("AUDIT_COLLECTION")
+	defaultAuditRedactKeys := os.Getenv("AUDIT_REDACT_KEYS")
+	if defaultAuditRedactKeys == "" {
+		defaultAuditRedactKeys = strings.Join(server.DefaultAuditRedactKeys, ",")
+	}
+	defaultCollectionSchemas := os.Getenv("COLLECTION_SCHEMAS")
+	defaultCoerceFilterValues := os.Getenv("COERCE_FILTER_VALUES") == "true"
+	defaultAllowedQueryOperators := os.Getenv("ALLOWED_QUERY_OPERATORS")
+	defaultRecentCollections := os.Getenv("RECENT_COLLECTIONS")
+	if defaultRecentCollections == "" {
+		defaultRecentCollections = "documents,knowledgebase"
+	}
+	defaultExportTTL := envDuration("EXPORT_TTL", 15*time.Minute)
+	defaultMaxExportBytes := envInt("MAX_EXPORT_BYTES", 64<<20)
+	defaultIDStrategy := os.Getenv("ID_STRATEGY")
+	if defaultIDStrategy == "" {
+		defaultIDStrategy = "objectid"
+	}
+
 	// Command line flags
 	var (
-		addr       = flag.String("addr", defaultAddr, "Server address")
-		mongoURI   = flag.String("mongo-uri", defaultMongoURI, "MongoDB connection URI")
-		dbName     = flag.String("db-name", defaultDBName, "MongoDB database name")
-		debug      = flag.Bool("debug", defaultDebug, "Enable debug mode")
+		addr        = flag.String("addr", defaultAddr, "Server address")
+		tcpAddr     = flag.String("tcp-addr", defaultTCPAddr, "Address for newline-delimited JSON-RPC over plain TCP (empty = disabled)")
+		tlsCert     = flag.String("tls-cert", defaultTLSCert, "PEM certificate file; with -tls-key, serve HTTPS and wss:// (empty = plain HTTP)")
+		tlsKey      = flag.String("tls-key", defaultTLSKey, "PEM private key file for -tls-cert")
+		externalURL = flag.String("external-url", defaultExternalURL, "Base URL clients use to reach the server, e.g. behind an ingress (defaults to http://<addr>, or https://<addr> with TLS)")
+		trustProxy  = flag.Bool("trust-proxy-headers", defaultTrustProxyHeaders, "Derive advertised endpoints from X-Forwarded-Proto and X-Forwarded-Host when -external-url is unset")
+		mongoURI    = flag.String("mongo-uri", defaultMongoURI, "MongoDB connection URI")
+		dbName      = flag.String("db-name", defaultDBName, "MongoDB database name")
+		debug       = flag.Bool("debug", defaultDebug, "Enable debug mode")
+		selfTest    = flag.Bool("selftest", false, "Run a self-test of the database, search and math tools, print a report and exit (non-zero on failure) without serving")
+
+		maxConnections = flag.Int("max-connections", defaultMaxConnections, "Maximum concurrent WebSocket connections (0 = unlimited)")
+		sessionTTL     = flag.Duration("session-ttl", defaultSessionTTL, "How long a disconnected client can resume its session (0 = disabled)")
+		idleTimeout    = flag.Duration("idle-timeout", defaultIdleTimeout, "Close WebSocket connections that send no message for this long (0 = disabled)")
+		writeTimeout   = flag.Duration("write-timeout", defaultWriteTimeout, "Close connections whose client takes longer than this to accept a response (0 = disabled)")
+		shutdownTimeout = flag.Duration("shutdown-timeout", defaultShutdownTimeout, "How long shutdown waits for in-flight requests before closing connections anyway")
+		toolTimeout     = flag.Duration("tool-timeout", defaultToolTimeout, "How long a tool call may run before it is cancelled (0 = no limit)")
+		toolTimeouts    = flag.String("tool-timeouts", defaultToolTimeouts, "Per-tool timeouts overriding -tool-timeout, as name=duration pairs (e.g. web_search=30s,db_count_documents=2s)")
+		maxConcurrentRequests = flag.Int("max-concurrent-requests", defaultMaxConcurrentRequests, "Requests handled at once per connection (1 = strictly in order)")
+		notificationBuffer    = flag.Int("notification-buffer", defaultNotificationBuffer, "Server notifications buffered per connection for slow clients")
+		toolsPageSize  = flag.Int("tools-page-size", defaultToolsPageSize, "Tools returned per tools/list page (0 = no pagination)")
+		allowNullID    = flag.Bool("allow-null-id", defaultAllowNullID, "Treat requests with a null id as notifications instead of rejecting them")
+		requireInitialized = flag.Bool("require-initialized", defaultRequireInitialized, "Reject requests until the client sends the initialized notification; false serves clients that call tools right away")
+		preciseNumbers = flag.Bool("precise-numbers", defaultPreciseNumbers, "Decode request numbers exactly instead of as float64, keeping large integer ids and arguments intact")
+		compressResponses = flag.Bool("compress-responses", defaultCompressResponses, "Gzip HTTP endpoint responses such as /tools and /export for clients that accept it")
+		prettyJSON     = flag.Bool("pretty-json", defaultPrettyJSON, "Indent the JSON embedded in tool results instead of keeping it compact")
+		logRequests    = flag.Bool("log-requests", defaultLogRequests, "Log every request with its correlation id and add the id to error responses")
+		responseMeta   = flag.Bool("response-meta", defaultResponseMeta, "Add a _meta object with the tool name, duration and timestamp to every tool call response")
+
+		toolNameConflicts = flag.String("tool-name-conflicts", defaultToolNameConflicts, "How duplicate tool names across providers are handled: strict (fail) or prefix (rename as <provider>_<tool>)")
+		toolProfiles      = flag.String("tool-profiles", defaultToolProfiles, "Named tool subsets clients can select, as name=patterns entries with patterns separated by | (e.g. db-admin=db_*|describe_tool); full and readonly are built in")
+		toolProfile       = flag.String("tool-profile", defaultToolProfile, "Tool profile for clients that do not select one at initialize (empty = every tool)")
+		apiKeys           = flag.String("api-keys", defaultAPIKeys, "Comma-separated API keys accepted by authenticated endpoints such as /export (empty = those endpoints are disabled)")
+		collectionAccess  = flag.String("collection-access", defaultCollectionAccess, "JSON file mapping API keys to the collections their clients may access, e.g. {\"key-a\": [\"tenant_a\", \"shared_*\"]}; WebSocket clients must then present a key")
+		allowedOrigins    = flag.String("allowed-origins", defaultAllowedOrigins, "Comma-separated browser origins allowed to open WebSocket connections (empty = any origin)")
+		subprotocols      = flag.String("subprotocols", defaultSubprotocols, "Comma-separated WebSocket subprotocols the server accepts, in order of preference; the first one a client offers is echoed in the handshake")
+
+		slowCallThreshold      = flag.Duration("slow-call-threshold", defaultSlowCallThreshold, "Log tool calls that take longer than this (0 = disabled)")
+		toolCacheTTL           = flag.Duration("tool-cache-ttl", defaultToolCacheTTL, "Cache results of read-only tools for this long (0 = disabled)")
+		largeResponseThreshold = flag.Int("large-response-threshold", defaultLargeResponseThreshold, "Log tool responses larger than this many bytes (0 = disabled)")
+		statusInterval         = flag.Duration("status-interval", defaultStatusInterval, "How often /status/stream sends the server status")
+
+		writeConcern   = flag.String("mongo-write-concern", defaultWriteConcern, "MongoDB write concern (e.g. majority, 1)")
+		readPreference = flag.String("mongo-read-preference", defaultReadPreference, "MongoDB read preference (e.g. primary, secondaryPreferred)")
+
+		maxCollectionNameLength = flag.Int("max-collection-name-length", defaultMaxCollectionNameLength, "Maximum collection name length accepted from clients (0 = MongoDB namespace limit)")
+		maxQueryLimit           = flag.Int("max-query-limit", defaultMaxQueryLimit, "Maximum documents a single database query returns, whatever limit is requested (0 = no cap)")
+		maxTags                 = flag.Int("max-tags", defaultMaxTags, "Maximum tags per document (0 = unlimited)")
+		maxTagLength            = flag.Int("max-tag-length", defaultMaxTagLength, "Maximum bytes per document tag (0 = unlimited)")
+		maxMetadataBytes        = flag.Int("max-metadata-bytes", defaultMaxMetadataBytes, "Maximum size of a document's metadata, encoded as JSON (0 = unlimited)")
+		compressContentAbove    = flag.Int("compress-content-above", defaultCompressContentAbove, "Store document content longer than this many bytes gzip-compressed (0 = never)")
+		collectionSchemas       = flag.String("collection-schemas", defaultCollectionSchemas, "JSON file mapping collections to the fields their documents require, e.g. {\"knowledgebase\": {\"category\": \"string\"}}")
+		allowedQueryOperators   = flag.String("allowed-query-operators", defaultAllowedQueryOperators, "Comma-separated operators client filters may use, at any depth, e.g. $and,$or,$in,$eq (empty = all but those running server-side code)")
+		coerceFilterValues      = flag.Bool("coerce-filter-values", defaultCoerceFilterValues, "Convert filter values sent as strings to numbers or booleans when they look like them")
+		recentCollections       = flag.String("recent-collections", defaultRecentCollections, "Comma-separated collections db_recent_documents lists when called without a collection")
+		exportTTL               = flag.Duration("export-ttl", defaultExportTTL, "How long a collection exported by db_export_collection stays readable as a resource (0 = until newer exports evict it)")
+		maxExportBytes          = flag.Int("max-export-bytes", defaultMaxExportBytes, "Largest collection export db_export_collection keeps in memory (0 = unlimited)")
+		idStrategy              = flag.String("id-strategy", defaultIDStrategy, "ID generated for documents created without one: objectid or uuid")
+		indexedCollections      = flag.String("indexed-collections", defaultIndexedCollections, "Comma-separated collections whose indexes are created at startup")
+		indexOnFirstWrite       = flag.Bool("index-on-first-write", defaultIndexOnFirstWrite, "Create the indexes of any other collection the first time a document is written to it")
+
+		maxContentBytes = flag.Int("max-content-bytes", defaultMaxContentBytes, "Maximum bytes of page text returned per search result or fetched page (0 = unlimited)")
+		searchProbeURL  = flag.String("search-probe-url", defaultSearchProbeURL, "URL requested by the search health check")
+
+		auditCollection = flag.String("audit-collection", defaultAuditCollection, "MongoDB collection for the tool-call audit log (empty = disabled)")
+		auditRedactKeys = flag.String("audit-redact-keys", defaultAuditRedactKeys, "Comma-separated argument keys redacted in the audit log")
 	)
 	flag.Parse()
 
+	if _, err := server.LoadTLSConfig(*tlsCert, *tlsKey); err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
+	tlsEnabled := *tlsCert != "" && *tlsKey != ""
+	if tlsEnabled && *tcpAddr != "" {
+		log.Fatalf("Invalid TLS configuration: -tcp-addr serves cleartext and cannot be combined with -tls-cert")
+	}
+
+	advertisedURL := *externalURL
+	if advertisedURL == "" {
+		if tlsEnabled {
+			advertisedURL = "https://" + *addr
+		} else {
+			advertisedURL = "http://" + *addr
+		}
+	}
+	endpoints, err := server.NewEndpoints(advertisedURL)
+	if err != nil {
+		log.Fatalf("Invalid external URL: %v", err)
+	}
+
+	toolTimeoutOverrides, err := server.ParseToolTimeouts(*toolTimeouts)
+	if err != nil {
+		log.Fatalf("Invalid tool timeouts: %v", err)
+	}
+
+	switch *toolNameConflicts {
+	case server.ToolConflictStrict, server.ToolConflictPrefix:
+	default:
+		log.Fatalf("Invalid tool name conflict policy %q: expected %s or %s", *toolNameConflicts, server.ToolConflictStrict, server.ToolConflictPrefix)
+	}
+
+	toolProfileSubsets, err := server.ParseToolProfiles(*toolProfiles)
+	if err != nil {
+		log.Fatalf("Invalid tool profiles: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	idGenerator, err := database.IDGeneratorByName(*idStrategy)
+	if err != nil {
+		log.Fatalf("Invalid ID strategy: %v", err)
+	}
+
+	schemas, err := tools.LoadCollectionSchemas(*collectionSchemas)
+	if err != nil {
+		log.Fatalf("Invalid collection schemas: %v", err)
+	}
+
 	// Initialize MongoDB
 	log.Println("Connecting to MongoDB...")
 	dbConfig := database.Config{
@@ -53,6 +251,24 @@ func main() {
 		Database:       *dbName,
 		ConnectTimeout: 10 * time.Second,
 		QueryTimeout:   30 * time.Second,
+		WriteConcern:   *writeConcern,
+		ReadPreference: *readPreference,
+
+		MaxCollectionNameLength: *maxCollectionNameLength,
+		MaxQueryLimit:           *maxQueryLimit,
+		MaxTags:                 *maxTags,
+		MaxTagLength:            *maxTagLength,
+		MaxMetadataBytes:        *maxMetadataBytes,
+		CompressContentAbove:    *compressContentAbove,
+		IDGenerator:             idGenerator,
+		IndexedCollections:      splitList(*indexedCollections),
+		IndexOnFirstWrite:       *indexOnFirstWrite,
+		AllowedQueryOperators:   splitList(*allowedQueryOperators),
+	}
+	for _, operator := range dbConfig.AllowedQueryOperators {
+		if !strings.HasPrefix(operator, "$") {
+			log.Fatalf("Invalid allowed query operator %q: operators start with $", operator)
+		}
 	}
 
 	db, err := database.NewMongoDB(dbConfig)
@@ -73,27 +289,130 @@ func main() {
 
 	// Initialize web searcher
 	log.Println("Initializing web search service...")
-	searchConfig := search.DefaultConfig()
+	searchConfig, err := search.ConfigFromEnv(search.DefaultConfig())
+	if err != nil {
+		log.Fatalf("Invalid search configuration: %v", err)
+	}
 	searchConfig.EnableDebug = *debug
+	searchConfig.MaxContentBytes = *maxContentBytes
+	searchConfig.HealthCheckURL = *searchProbeURL
 	searcher := search.NewCollySearcher(searchConfig)
 
 	// Create and configure the MCP server
 	log.Println("Creating MCP server...")
-	mcpServer := server.NewMCPServer()
+	serverConfig := server.DefaultConfig()
+	serverConfig.Addr = *addr
+	serverConfig.MaxConnections = *maxConnections
+	serverConfig.SessionTTL = *sessionTTL
+	serverConfig.IdleTimeout = *idleTimeout
+	serverConfig.WriteTimeout = *writeTimeout
+	serverConfig.ShutdownTimeout = *shutdownTimeout
+	serverConfig.DefaultToolTimeout = *toolTimeout
+	serverConfig.ToolTimeouts = toolTimeoutOverrides
+	serverConfig.ToolsPageSize = *toolsPageSize
+	serverConfig.MaxConcurrentRequests = *maxConcurrentRequests
+	serverConfig.NotificationBuffer = *notificationBuffer
+	serverConfig.ExternalURL = *externalURL
+	serverConfig.TrustProxyHeaders = *trustProxy
+	serverConfig.TLSCertFile = *tlsCert
+	serverConfig.TLSKeyFile = *tlsKey
+	serverConfig.AllowNullID = *allowNullID
+	serverConfig.RequireInitialized = *requireInitialized
+	serverConfig.PreciseNumbers = *preciseNumbers
+	serverConfig.LogRequests = *logRequests
+	serverConfig.ResponseMeta = *responseMeta
+	serverConfig.PrettyJSON = *prettyJSON
+	serverConfig.CompressResponses = *compressResponses
+	serverConfig.ToolNameConflicts = *toolNameConflicts
+	serverConfig.ToolProfiles = toolProfileSubsets
+	serverConfig.DefaultToolProfile = *toolProfile
+	if *toolProfile != "" && !serverConfig.HasToolProfile(*toolProfile) {
+		log.Fatalf("Invalid tool profile %q: not built in or defined by -tool-profiles", *toolProfile)
+	}
+	serverConfig.APIKeys = splitList(*apiKeys)
+	serverConfig.CollectionAccess, err = server.LoadCollectionAccess(*collectionAccess)
+	if err != nil {
+		log.Fatalf("Invalid collection access: %v", err)
+	}
+	serverConfig.AllowedOrigins = splitList(*allowedOrigins)
+	serverConfig.Subprotocols = splitList(*subprotocols)
+	serverConfig.SlowCallThreshold = *slowCallThreshold
+	serverConfig.LargeResponseThreshold = *largeResponseThreshold
+	serverConfig.ToolCacheTTL = *toolCacheTTL
+	serverConfig.StatusInterval = *statusInterval
+	serverConfig.Debug = *debug
+	serverConfig.Version = version
+	serverConfig.BuildTime = buildTime
+	mcpServer := server.NewServerWithConfig(serverConfig)
+	mcpServer.SetCollectionExporter(db)
+	mcpServer.AddHealthCheck("database", db)
+	mcpServer.AddHealthCheck("search", searcher)
+
+	if *auditCollection != "" {
+		if err := db.ValidateCollectionName(*auditCollection); err != nil {
+			log.Fatalf("Invalid audit collection: %v", err)
+		}
+		auditConfig := server.DefaultAuditConfig()
+		auditConfig.Collection = *auditCollection
+		auditConfig.RedactKeys = splitList(*auditRedactKeys)
+		mcpServer.UseToolMiddleware(server.NewAuditLogger(db, auditConfig).Middleware())
+		log.Printf("Auditing tool calls to collection %s", *auditCollection)
+	}
 	
 	// Add tool providers
-	mcpServer.RegisterToolProvider(tools.NewMathToolProvider())
-	mcpServer.RegisterToolProvider(tools.NewSearchTool(searcher))
-	mcpServer.RegisterToolProvider(tools.NewDatabaseTool(db))
+	databaseTool := tools.NewDatabaseTool(db)
+	databaseTool.SetCollectionSchemas(schemas)
+	databaseTool.SetCoerceFilterValues(*coerceFilterValues)
+	databaseTool.SetRecentCollections(splitList(*recentCollections))
+	exportStore := tools.NewExportStore(db, *exportTTL, *maxExportBytes)
+	databaseTool.SetExportStore(exportStore)
+	toolProviders := []mcp.ToolProvider{
+		tools.NewMathToolProvider(),
+		tools.NewSearchTool(searcher),
+		databaseTool,
+	}
+	for _, provider := range toolProviders {
+		if err := mcpServer.RegisterToolProvider(provider); err != nil {
+			log.Fatalf("Failed to register tools: %v", err)
+		}
+	}
+	mcpServer.RegisterCompletionProvider(databaseTool)
+	mcpServer.RegisterResourceProvider(tools.NewDocumentResourceProvider(db))
+	mcpServer.RegisterResourceProvider(exportStore)
+
+	// In self-test mode, exercise the tools and exit instead of serving
+	if *selfTest {
+		log.Println("Running self-test...")
+		report := mcpServer.SelfTest(ctx)
+		fmt.Print(report)
+
+		code := 0
+		if !report.Passed() {
+			code = 1
+		}
+		// os.Exit skips deferred calls, so close the database here
+		if err := db.Close(context.Background()); err != nil {
+			log.Printf("Error closing database: %v", err)
+		}
+		os.Exit(code)
+	}
 	
 	// Start the server
-	log.Printf("Starting MCP server on %s...", *addr)
+	log.Printf("Starting MCP server %s on %s...", version, *addr)
 	go func() {
-		if err := mcpServer.Start(ctx, *addr); err != nil {
+		if err := mcpServer.Start(ctx, serverConfig.Addr); err != nil {
 			log.Printf("Server error: %v", err)
 			cancel()
 		}
 	}()
+	if *tcpAddr != "" {
+		go func() {
+			if err := mcpServer.StartTCP(ctx, *tcpAddr); err != nil {
+				log.Printf("TCP transport error: %v", err)
+				cancel()
+			}
+		}()
+	}
 
 	// Test connectivity
 	log.Println("Testing service connectivity...")
@@ -113,16 +432,26 @@ func main() {
 	}
 
 	log.Printf("✓ MCP Server is ready!")
-	log.Printf("  - WebSocket endpoint: ws://%s/mcp", *addr)
-	log.Printf("  - Health check: http://%s/health", *addr)
-	log.Printf("  - Web interface: http://%s/", *addr)
+	log.Printf("  - WebSocket endpoint: %s", endpoints.MCP)
+	log.Printf("  - Health check: %s", endpoints.Health)
+	log.Printf("  - Tool catalog: %s", endpoints.Tools)
+	log.Printf("  - Status stream: %s", endpoints.Status)
+	log.Printf("  - Web interface: %s", endpoints.Web)
+	if *tcpAddr != "" {
+		log.Printf("  - TCP endpoint: %s", *tcpAddr)
+	}
 	log.Println()
 	log.Println("Available tools:")
 	log.Println("  Math: add, multiply, divide, power")
-	log.Println("  Search: web_search, search_health_check")
-	log.Println("  Database: db_create_document, db_get_document, db_update_document,")
-	log.Println("           db_delete_document, db_query_documents, db_search_documents,")
-	log.Println("           db_count_documents, db_health_check")
+	log.Println("  Search: web_search, summarize_search, search_clear_cache, search_health_check")
+	log.Println("  Database: db_create_document, db_get_document, db_get_documents, db_document_exists,")
+	log.Println("           db_update_document, db_update_many, db_delete_document, db_clear_collection,")
+	log.Println("           db_move_document, db_query_documents, db_explain_query, db_find_by_tags,")
+	log.Println("           db_search_documents, db_related_documents, db_find_by_title, db_recent_documents,")
+	log.Println("           db_diff_documents, db_export_collection, db_ensure_text_index, db_list_indexes,")
+	log.Println("           db_count_documents, db_field_stats, db_distinct_count, db_time_histogram,")
+	log.Println("           db_validate_filter, db_health_check")
+	log.Println("  Server: describe_tool, export_schema, server_info, batch")
 	log.Println()
 	log.Println("To start MongoDB: make mongo-up")
 	log.Println("To stop the server: Ctrl+C")
@@ -138,8 +467,8 @@ func main() {
 		log.Println("Context cancelled, shutting down...")
 	}
 
-	// Graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Graceful shutdown: in-flight requests get up to the shutdown timeout to finish
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
 	defer shutdownCancel()
 
 	if err := mcpServer.Stop(shutdownCtx); err != nil {
@@ -148,3 +477,42 @@ func main() {
 
 	log.Println("Server stopped")
 }
+
+// envInt reads an integer environment variable, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: ignoring invalid %s=%q: %v", key, value, err)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a duration environment variable (e.g. "30s"), falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: ignoring invalid %s=%q: %v", key, value, err)
+		return def
+	}
+	return d
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
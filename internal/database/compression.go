@@ -0,0 +1,166 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ContentEncodingGzip marks a stored document whose content is gzip-compressed
+const ContentEncodingGzip = "gzip"
+
+// compressedDocument is the stored form of a document with compressed
+// content: Content shadows the embedded document's text
+type compressedDocument struct {
+	mcp.Document    `bson:",inline"`
+	Content         []byte `bson:"content"`
+	ContentEncoding string `bson:"content_encoding"`
+}
+
+// storedContent returns the value content is stored as and its encoding:
+// gzip-compressed bytes when Config.CompressContentAbove is set, content is
+// longer and compressing it saves space, the content itself with no encoding
+// otherwise
+func (m *MongoDB) storedContent(content string) (interface{}, string, error) {
+	limit := m.config.CompressContentAbove
+	if limit <= 0 || len(content) <= limit {
+		return content, "", nil
+	}
+
+	compressed, err := gzipContent(content)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to compress content: %w", err)
+	}
+	if len(compressed) >= len(content) {
+		return content, "", nil
+	}
+	return compressed, ContentEncodingGzip, nil
+}
+
+// storedDocument returns the value to insert for doc, with its content
+// compressed when storedContent decides so
+func (m *MongoDB) storedDocument(doc *mcp.Document) (interface{}, error) {
+	content, encoding, err := m.storedContent(doc.Content)
+	if err != nil {
+		return nil, err
+	}
+	if encoding == "" {
+		return doc, nil
+	}
+	return &compressedDocument{Document: *doc, Content: content.([]byte), ContentEncoding: encoding}, nil
+}
+
+// decodeDocument decodes a stored document, restoring compressed content
+func decodeDocument(raw bson.Raw) (*mcp.Document, error) {
+	encoding, _ := raw.Lookup("content_encoding").StringValueOK()
+	switch encoding {
+	case "":
+		var doc mcp.Document
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		return &doc, nil
+	case ContentEncodingGzip:
+		var stored compressedDocument
+		if err := bson.Unmarshal(raw, &stored); err != nil {
+			return nil, err
+		}
+		content, err := gunzipContent(stored.Content)
+		if err != nil {
+			return nil, err
+		}
+		stored.Document.Content = content
+		return &stored.Document, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// decodeContent returns the text of a content field decoded into a bson.M,
+// given the document's content_encoding
+func decodeContent(value, encoding interface{}) (string, error) {
+	switch encoding {
+	case nil, "":
+		content, _ := value.(string)
+		return content, nil
+	case ContentEncodingGzip:
+		switch v := value.(type) {
+		case bson.Binary:
+			return gunzipContent(v.Data)
+		case []byte:
+			return gunzipContent(v)
+		default:
+			return "", fmt.Errorf("compressed content has unexpected type %T", value)
+		}
+	default:
+		return "", fmt.Errorf("unsupported content encoding %v", encoding)
+	}
+}
+
+// decompressedExport returns a stored document as exported: compressed
+// content is restored to text and its marker dropped, so exports never depend
+// on how the documents were stored
+func decompressedExport(raw bson.Raw) (interface{}, error) {
+	if _, ok := raw.Lookup("content_encoding").StringValueOK(); !ok {
+		return raw, nil
+	}
+
+	var fields bson.D
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	var encoding interface{}
+	for _, field := range fields {
+		if field.Key == "content_encoding" {
+			encoding = field.Value
+		}
+	}
+
+	restored := make(bson.D, 0, len(fields))
+	for _, field := range fields {
+		switch field.Key {
+		case "content_encoding":
+			continue
+		case "content":
+			content, err := decodeContent(field.Value, encoding)
+			if err != nil {
+				return nil, err
+			}
+			field.Value = content
+		}
+		restored = append(restored, field)
+	}
+	return restored, nil
+}
+
+// gzipContent compresses content
+func gzipContent(content string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(writer, content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipContent decompresses content stored by gzipContent
+func gunzipContent(data []byte) (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress content: %w", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress content: %w", err)
+	}
+	return string(content), nil
+}
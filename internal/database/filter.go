@@ -0,0 +1,282 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// disallowedOperators run server-side JavaScript or otherwise reach beyond
+// matching documents, so client-supplied filters may not use them
+var disallowedOperators = map[string]bool{
+	"$where":       true,
+	"$function":    true,
+	"$accumulator": true,
+}
+
+// protectedFields are maintained by the store and cannot be set by callers
+var protectedFields = map[string]bool{
+	"_id":        true,
+	"version":    true,
+	"created_at": true,
+	"updated_at": true,
+
+	"content_encoding": true,
+}
+
+// queryOperators are the operators a filter may use. Operators under $expr and
+// $jsonSchema belong to other languages and are not checked against it.
+var queryOperators = map[string]bool{
+	"$eq": true, "$ne": true, "$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$in": true, "$nin": true, "$and": true, "$or": true, "$nor": true, "$not": true,
+	"$exists": true, "$type": true, "$regex": true, "$options": true, "$mod": true,
+	"$all": true, "$elemMatch": true, "$size": true, "$expr": true, "$jsonSchema": true,
+	"$text": true, "$search": true, "$language": true, "$caseSensitive": true,
+	"$diacriticSensitive": true, "$comment": true,
+	"$geoWithin": true, "$geoIntersects": true, "$near": true, "$nearSphere": true,
+	"$geometry": true, "$box": true, "$center": true, "$centerSphere": true,
+	"$polygon": true, "$maxDistance": true, "$minDistance": true,
+	"$bitsAllSet": true, "$bitsAnySet": true, "$bitsAllClear": true, "$bitsAnyClear": true,
+}
+
+// FilterCheck is the outcome of checking a client-supplied filter
+type FilterCheck struct {
+	// RejectedOperators are the disallowed operators the filter uses, such as
+	// $where or, with Config.AllowedQueryOperators, any operator not on the
+	// list, each listed once
+	RejectedOperators []string `json:"rejected_operators"`
+	// Problems describe what makes the filter malformed, such as unknown
+	// operators or a $or that is not a list of filters
+	Problems []string `json:"problems"`
+
+	allowed map[string]bool // the only operators allowed; nil allows all but disallowedOperators
+}
+
+// Valid reports whether the filter is well-formed and safe to run
+func (c FilterCheck) Valid() bool {
+	return len(c.RejectedOperators) == 0 && len(c.Problems) == 0
+}
+
+// Err returns the first reason the filter cannot run, or nil when it is valid
+func (c FilterCheck) Err() error {
+	if len(c.RejectedOperators) > 0 {
+		return fmt.Errorf("filter operator %s is not allowed", c.RejectedOperators[0])
+	}
+	if len(c.Problems) > 0 {
+		return fmt.Errorf("invalid filter: %s", c.Problems[0])
+	}
+	return nil
+}
+
+// CheckFilter checks a client-supplied filter without running it: it must not
+// use operators which execute code on the server, at any depth, and must be
+// structurally sound
+func CheckFilter(filter map[string]interface{}) FilterCheck {
+	return checkFilter(filter, nil)
+}
+
+// CheckFilter checks a client-supplied filter like the package-level
+// CheckFilter and, when AllowedQueryOperators is set, also rejects every
+// operator not on that list, at any depth including inside $expr
+func (c Config) CheckFilter(filter map[string]interface{}) FilterCheck {
+	var allowed map[string]bool
+	if len(c.AllowedQueryOperators) > 0 {
+		allowed = make(map[string]bool, len(c.AllowedQueryOperators))
+		for _, operator := range c.AllowedQueryOperators {
+			allowed[operator] = true
+		}
+	}
+	return checkFilter(filter, allowed)
+}
+
+// checkFilter checks filter, allowing only the operators in allowed unless it is nil
+func checkFilter(filter map[string]interface{}, allowed map[string]bool) FilterCheck {
+	check := FilterCheck{RejectedOperators: []string{}, Problems: []string{}, allowed: allowed}
+	check.walk(filter, true)
+	return check
+}
+
+// ValidateFilter rejects client-supplied filters that CheckFilter finds
+// unsafe or malformed
+func ValidateFilter(filter map[string]interface{}) error {
+	return CheckFilter(filter).Err()
+}
+
+// MatchesEverything reports whether filter selects every document: it has no
+// predicate besides annotations such as $comment, or only $and clauses that
+// match everything, or an $or clause that does
+func MatchesEverything(filter map[string]interface{}) bool {
+	for key, value := range filter {
+		switch key {
+		case "$comment":
+			continue
+		case "$and":
+			if clauses, ok := asList(value); ok && allMatchEverything(clauses) {
+				continue
+			}
+		case "$or":
+			if clauses, ok := asList(value); ok && anyMatchesEverything(clauses) {
+				continue
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func allMatchEverything(clauses []interface{}) bool {
+	for _, clause := range clauses {
+		if filter, ok := asFilter(clause); !ok || !MatchesEverything(filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func anyMatchesEverything(clauses []interface{}) bool {
+	for _, clause := range clauses {
+		if filter, ok := asFilter(clause); ok && MatchesEverything(filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// asFilter returns value as a filter document, when it is one
+func asFilter(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case bson.M:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// walk checks value and everything nested in it. Operators are only checked
+// against queryOperators while structural is set.
+func (c *FilterCheck) walk(value interface{}, structural bool) {
+	switch v := value.(type) {
+	case bson.M:
+		c.walk(map[string]interface{}(v), structural)
+	case bson.A:
+		c.walk([]interface{}(v), structural)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			nested := v[key]
+			if disallowedOperators[key] || c.notAllowed(key) {
+				c.reject(key)
+			} else if structural {
+				c.checkKey(key, nested)
+			}
+			c.walk(nested, structural && key != "$expr" && key != "$jsonSchema")
+		}
+	case []interface{}:
+		for _, nested := range v {
+			c.walk(nested, structural)
+		}
+	}
+}
+
+// checkKey checks a field name or operator and the shape of its value
+func (c *FilterCheck) checkKey(key string, value interface{}) {
+	switch {
+	case key == "":
+		c.Problems = append(c.Problems, "empty field name")
+	case !strings.HasPrefix(key, "$"):
+	case !queryOperators[key]:
+		c.Problems = append(c.Problems, fmt.Sprintf("unknown operator %s", key))
+	case key == "$and" || key == "$or" || key == "$nor":
+		clauses, ok := asList(value)
+		if !ok || len(clauses) == 0 {
+			c.Problems = append(c.Problems, fmt.Sprintf("%s must be a non-empty list of filters", key))
+			return
+		}
+		for _, clause := range clauses {
+			switch clause.(type) {
+			case map[string]interface{}, bson.M:
+			default:
+				c.Problems = append(c.Problems, fmt.Sprintf("%s must be a non-empty list of filters", key))
+				return
+			}
+		}
+	case key == "$in" || key == "$nin" || key == "$all":
+		if _, ok := asList(value); !ok {
+			c.Problems = append(c.Problems, fmt.Sprintf("%s must be a list", key))
+		}
+	}
+}
+
+// notAllowed reports whether key is an operator missing from the allowlist
+func (c *FilterCheck) notAllowed(key string) bool {
+	return c.allowed != nil && strings.HasPrefix(key, "$") && !c.allowed[key]
+}
+
+func (c *FilterCheck) reject(operator string) {
+	for _, rejected := range c.RejectedOperators {
+		if rejected == operator {
+			return
+		}
+	}
+	c.RejectedOperators = append(c.RejectedOperators, operator)
+}
+
+// asList returns value as a list, when it is one
+func asList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case bson.A:
+		return v, true
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list, true
+	default:
+		return nil, false
+	}
+}
+
+// ValidateSetFields checks the fields of a bulk $set: they must be plain field
+// paths (no operators) and must not touch the ID, version or timestamps
+func ValidateSetFields(fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return errors.New("no fields to set")
+	}
+	for field := range fields {
+		if field == "" || strings.Contains(field, "$") {
+			return fmt.Errorf("invalid field name %q", field)
+		}
+		root := strings.SplitN(field, ".", 2)[0]
+		if protectedFields[root] {
+			return fmt.Errorf("field %s cannot be modified", root)
+		}
+	}
+	return nil
+}
+
+// ValidateFieldPath checks a client-supplied field path such as
+// "metadata.words": it must be a dotted path of non-empty names without "$"
+func ValidateFieldPath(field string) error {
+	if field == "" {
+		return errors.New("field is required")
+	}
+	for _, name := range strings.Split(field, ".") {
+		if name == "" || strings.Contains(name, "$") {
+			return fmt.Errorf("invalid field path %q", field)
+		}
+	}
+	return nil
+}
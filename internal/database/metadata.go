@@ -0,0 +1,56 @@
+package database
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// nativeMetadata returns metadata as decoded by the driver with every value,
+// at any depth, converted by nativeValue. It returns nil for nil metadata.
+func nativeMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	native := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		native[k] = nativeValue(v)
+	}
+	return native
+}
+
+// nativeValue converts a value decoded by the driver into the plain Go types
+// encoding/json handles: embedded documents (bson.D or bson.M) become
+// map[string]interface{}, arrays become []interface{}, ObjectIDs their hex
+// string and dates time.Time in UTC, recursively. Other values are returned as is.
+func nativeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		return nativeMetadata(v)
+	case map[string]interface{}:
+		return nativeMetadata(v)
+	case bson.D:
+		native := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			native[e.Key] = nativeValue(e.Value)
+		}
+		return native
+	case bson.A:
+		return nativeSlice(v)
+	case []interface{}:
+		return nativeSlice(v)
+	case bson.ObjectID:
+		return v.Hex()
+	case bson.DateTime:
+		return v.Time().UTC()
+	default:
+		return value
+	}
+}
+
+// nativeSlice converts each element of an array with nativeValue
+func nativeSlice(values []interface{}) []interface{} {
+	native := make([]interface{}, len(values))
+	for i, v := range values {
+		native[i] = nativeValue(v)
+	}
+	return native
+}
@@ -2,20 +2,74 @@ package database
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
 )
 
+// ErrTextIndexRequired is returned by SearchDocuments when the collection has no text index
+var ErrTextIndexRequired = errors.New("text index required for text search")
+
+// ErrDuplicateID is returned by CreateDocument when a document with the same ID already exists
+var ErrDuplicateID = errors.New("document ID already exists")
+
+// DocumentStore defines the document operations used by the MCP tools
+type DocumentStore interface {
+	CreateDocument(ctx context.Context, collection string, doc *mcp.Document) error
+	GetDocument(ctx context.Context, collection, id string) (*mcp.Document, error)
+	GetDocuments(ctx context.Context, collection string, ids []string) ([]*mcp.Document, error)
+	DocumentExists(ctx context.Context, collection, id string) (bool, error)
+	UpdateDocument(ctx context.Context, collection string, doc *mcp.Document) error
+	UpdateMany(ctx context.Context, collection string, filter, fields map[string]interface{}) (int64, error)
+	DeleteDocument(ctx context.Context, collection, id string) error
+	MoveDocument(ctx context.Context, from, to, id string) error
+	DropCollection(ctx context.Context, collection string) error
+	QueryDocuments(ctx context.Context, query mcp.DatabaseQuery) ([]*mcp.Document, error)
+	ExplainQuery(ctx context.Context, query mcp.DatabaseQuery) (map[string]interface{}, error)
+	SearchDocuments(ctx context.Context, collection, searchText string, filter map[string]interface{}, limit int) ([]*mcp.Document, error)
+	SubstringSearch(ctx context.Context, collection, searchText string, filter map[string]interface{}, limit int) ([]*mcp.Document, error)
+	CountDocuments(ctx context.Context, collection string, filter map[string]interface{}) (int64, error)
+	FieldStats(ctx context.Context, collection, field string, filter map[string]interface{}) (*mcp.FieldStats, error)
+	DistinctCount(ctx context.Context, collection, field string, filter map[string]interface{}) (int64, error)
+	TimeHistogram(ctx context.Context, collection, field, bucket string) (*mcp.TimeHistogram, error)
+	ListCollections(ctx context.Context) ([]string, error)
+	DistinctValues(ctx context.Context, collection, field string) ([]interface{}, error)
+	EnsureTextIndex(ctx context.Context, collection string) error
+	ListIndexes(ctx context.Context, collection string) ([]map[string]interface{}, error)
+	ValidateCollectionName(name string) error
+	ValidateDocument(doc *mcp.Document) error
+	CheckFilter(filter map[string]interface{}) FilterCheck
+	HealthCheck(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+var _ DocumentStore = (*MongoDB)(nil)
+
 // MongoDB implements database operations
 type MongoDB struct {
 	client   *mongo.Client
 	database *mongo.Database
 	config   Config
+
+	// indexed holds the collections whose indexes were already ensured, so
+	// IndexOnFirstWrite creates them at most once per collection
+	indexed sync.Map
 }
 
 // Config holds MongoDB configuration
@@ -24,8 +78,54 @@ type Config struct {
 	Database       string        `json:"database"`
 	ConnectTimeout time.Duration `json:"connect_timeout"`
 	QueryTimeout   time.Duration `json:"query_timeout"`
+	WriteConcern   string        `json:"write_concern,omitempty"`   // e.g. "majority", "1"; empty uses the driver default
+	ReadPreference string        `json:"read_preference,omitempty"` // e.g. "primary", "secondaryPreferred"; empty uses the driver default
+
+	// MaxCollectionNameLength caps collection names accepted from clients.
+	// Zero applies only MongoDB's namespace limit.
+	MaxCollectionNameLength int `json:"max_collection_name_length,omitempty"`
+
+	// IDGenerator produces IDs for documents created without one. Nil uses ObjectIDHex.
+	IDGenerator func() string `json:"-"`
+
+	// MaxQueryLimit caps the documents QueryDocuments returns, whatever limit
+	// the caller asks for (including none). Zero means no cap.
+	MaxQueryLimit int `json:"max_query_limit,omitempty"`
+
+	// MaxTags, MaxTagLength and MaxMetadataBytes bound what a client can attach
+	// to a document: the number of tags, the length of each tag in bytes, and
+	// the size of the metadata encoded as JSON. Zero means no limit.
+	MaxTags          int `json:"max_tags,omitempty"`
+	MaxTagLength     int `json:"max_tag_length,omitempty"`
+	MaxMetadataBytes int `json:"max_metadata_bytes,omitempty"`
+
+	// CompressContentAbove stores the content of documents longer than this
+	// many bytes gzip-compressed, marked with content_encoding; reads restore
+	// it transparently. Compressed content is not covered by the text index or
+	// by filters on content. Zero disables compression.
+	CompressContentAbove int `json:"compress_content_above,omitempty"`
+
+	// IndexedCollections lists the collections CreateIndexes indexes. With
+	// IndexOnFirstWrite, any other collection is indexed the first time a
+	// document is created in or moved to it.
+	IndexedCollections []string `json:"indexed_collections,omitempty"`
+	IndexOnFirstWrite  bool     `json:"index_on_first_write,omitempty"`
+
+	// AllowedQueryOperators, when set, are the only operators client-supplied
+	// filters may use, at any depth: a filter using any other operator is
+	// rejected. Operators that run code on the server, such as $where, are
+	// rejected even when listed. Empty allows every query operator but those.
+	// Only CheckFilter applies it: the store's queries run filters the server
+	// builds too, such as a $regex title match, and check only for those.
+	AllowedQueryOperators []string `json:"allowed_query_operators,omitempty"`
 }
 
+// MongoDB namespace ("<database>.<collection>") and database name limits
+const (
+	maxNamespaceLength    = 255
+	maxDatabaseNameLength = 63
+)
+
 // DefaultConfig returns a default MongoDB configuration
 func DefaultConfig() Config {
 	return Config{
@@ -33,15 +133,161 @@ func DefaultConfig() Config {
 		Database:       "mcp_server",
 		ConnectTimeout: 10 * time.Second,
 		QueryTimeout:   30 * time.Second,
+		IDGenerator:    ObjectIDHex,
+		MaxQueryLimit:  1000,
+
+		MaxTags:          50,
+		MaxTagLength:     100,
+		MaxMetadataBytes: 64 * 1024,
+
+		IndexedCollections: []string{"documents", "search_cache", "knowledgebase"},
+	}
+}
+
+// ValidateDocument checks a document's tags and metadata against the
+// configured limits
+func (c Config) ValidateDocument(doc *mcp.Document) error {
+	if c.MaxTags > 0 && len(doc.Tags) > c.MaxTags {
+		return fmt.Errorf("document has %d tags, more than the limit of %d", len(doc.Tags), c.MaxTags)
+	}
+	if c.MaxTagLength > 0 {
+		for i, tag := range doc.Tags {
+			if len(tag) > c.MaxTagLength {
+				return fmt.Errorf("tag %d is %d bytes long, more than the limit of %d", i+1, len(tag), c.MaxTagLength)
+			}
+		}
+	}
+	if c.MaxMetadataBytes > 0 && len(doc.Metadata) > 0 {
+		data, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("invalid metadata: %w", err)
+		}
+		if len(data) > c.MaxMetadataBytes {
+			return fmt.Errorf("metadata is %d bytes, more than the limit of %d", len(data), c.MaxMetadataBytes)
+		}
+	}
+	return nil
+}
+
+// ValidateSetFields checks the fields of a bulk $set like the package-level
+// ValidateSetFields, then checks tags and metadata set by it against the
+// configured limits. A metadata entry set on its own, such as
+// "metadata.notes", is checked by itself, as the rest is not read.
+func (c Config) ValidateSetFields(fields map[string]interface{}) error {
+	if err := ValidateSetFields(fields); err != nil {
+		return err
+	}
+
+	for field, value := range fields {
+		root, key, nested := strings.Cut(field, ".")
+		switch {
+		case field == "tags":
+			list, ok := asList(value)
+			if !ok {
+				return errors.New("tags must be a list of strings")
+			}
+			tags := make([]string, len(list))
+			for i, tag := range list {
+				if tags[i], ok = tag.(string); !ok {
+					return errors.New("tags must be a list of strings")
+				}
+			}
+			if err := c.ValidateDocument(&mcp.Document{Tags: tags}); err != nil {
+				return err
+			}
+		case root == "tags" && nested:
+			// Setting a tag past the end of the list pads it, so it counts too
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 {
+				return fmt.Errorf("invalid field name %q", field)
+			}
+			if c.MaxTags > 0 && index >= c.MaxTags {
+				return fmt.Errorf("document would have %d tags, more than the limit of %d", index+1, c.MaxTags)
+			}
+			tag, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s must be a string", field)
+			}
+			if c.MaxTagLength > 0 && len(tag) > c.MaxTagLength {
+				return fmt.Errorf("tag %d is %d bytes long, more than the limit of %d", index+1, len(tag), c.MaxTagLength)
+			}
+		case field == "metadata":
+			metadata, ok := value.(map[string]interface{})
+			if !ok {
+				return errors.New("metadata must be an object")
+			}
+			if err := c.ValidateDocument(&mcp.Document{Metadata: metadata}); err != nil {
+				return err
+			}
+		case root == "metadata" && nested && c.MaxMetadataBytes > 0:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", field, err)
+			}
+			if len(data) > c.MaxMetadataBytes {
+				return fmt.Errorf("%s is %d bytes, more than the metadata limit of %d", field, len(data), c.MaxMetadataBytes)
+			}
+		}
+	}
+	return nil
+}
+
+// ObjectIDHex generates a hex-encoded ObjectID; it is the default IDGenerator
+func ObjectIDHex() string {
+	return bson.NewObjectID().Hex()
+}
+
+// UUIDv4 generates a random RFC 4122 version 4 UUID for use as an IDGenerator
+func UUIDv4() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand failing is not recoverable in a meaningful way
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// IDGeneratorByName returns the ID generator for a strategy name: "objectid" or "uuid"
+func IDGeneratorByName(name string) (func() string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "objectid":
+		return ObjectIDHex, nil
+	case "uuid":
+		return UUIDv4, nil
+	default:
+		return nil, fmt.Errorf("invalid ID strategy %q: expected objectid or uuid", name)
 	}
 }
 
 // NewMongoDB creates a new MongoDB client
 func NewMongoDB(config Config) (*MongoDB, error) {
+	if err := ValidateDatabaseName(config.Database); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
 	defer cancel()
 
 	clientOptions := options.Client().ApplyURI(config.URI)
+
+	writeConcern, err := parseWriteConcern(config.WriteConcern)
+	if err != nil {
+		return nil, err
+	}
+	if writeConcern != nil {
+		clientOptions.SetWriteConcern(writeConcern)
+	}
+
+	readPreference, err := parseReadPreference(config.ReadPreference)
+	if err != nil {
+		return nil, err
+	}
+	if readPreference != nil {
+		clientOptions.SetReadPreference(readPreference)
+	}
+
 	client, err := mongo.Connect(clientOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
@@ -54,6 +300,10 @@ func NewMongoDB(config Config) (*MongoDB, error) {
 
 	database := client.Database(config.Database)
 
+	if config.IDGenerator == nil {
+		config.IDGenerator = ObjectIDHex
+	}
+
 	return &MongoDB{
 		client:   client,
 		database: database,
@@ -61,6 +311,108 @@ func NewMongoDB(config Config) (*MongoDB, error) {
 	}, nil
 }
 
+// ValidateDatabaseName checks name against MongoDB's database naming rules
+func ValidateDatabaseName(name string) error {
+	if name == "" {
+		return errors.New("database name must not be empty")
+	}
+	if len(name) > maxDatabaseNameLength {
+		return fmt.Errorf("database name %q exceeds %d characters", name, maxDatabaseNameLength)
+	}
+	if i := strings.IndexAny(name, "/\\. \"$*<>:|?\x00"); i >= 0 {
+		return fmt.Errorf("database name %q contains invalid character %q", name, name[i])
+	}
+	return nil
+}
+
+// ValidateCollectionName checks name against MongoDB's collection naming rules,
+// rejecting names longer than maxLength bytes
+func ValidateCollectionName(name string, maxLength int) error {
+	if name == "" {
+		return errors.New("collection name must not be empty")
+	}
+	if strings.Contains(name, "$") {
+		return fmt.Errorf("collection name %q must not contain '$'", name)
+	}
+	if strings.ContainsRune(name, 0) {
+		return errors.New("collection name must not contain null characters")
+	}
+	if strings.HasPrefix(name, "system.") {
+		return fmt.Errorf("collection name %q is reserved", name)
+	}
+	if len(name) > maxLength {
+		return fmt.Errorf("collection name exceeds %d characters", maxLength)
+	}
+	return nil
+}
+
+// ValidateCollectionName checks a client-supplied collection name before it reaches the driver
+func (m *MongoDB) ValidateCollectionName(name string) error {
+	return ValidateCollectionName(name, m.maxCollectionNameLength())
+}
+
+// CheckFilter checks a client-supplied filter against the operator rules and
+// the configured allowlist, without running it
+func (m *MongoDB) CheckFilter(filter map[string]interface{}) FilterCheck {
+	return m.config.CheckFilter(filter)
+}
+
+// ValidateDocument checks a client-supplied document against the tag and
+// metadata limits before it is written
+func (m *MongoDB) ValidateDocument(doc *mcp.Document) error {
+	return m.config.ValidateDocument(doc)
+}
+
+// maxCollectionNameLength returns the configured limit, bounded by the space left
+// in the namespace after the database name
+func (m *MongoDB) maxCollectionNameLength() int {
+	limit := maxNamespaceLength - len(m.config.Database) - 1
+	if m.config.MaxCollectionNameLength > 0 && m.config.MaxCollectionNameLength < limit {
+		limit = m.config.MaxCollectionNameLength
+	}
+	return limit
+}
+
+// parseWriteConcern maps a write concern string to driver options.
+// An empty string returns nil so the driver default is kept.
+func parseWriteConcern(value string) (*writeconcern.WriteConcern, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if strings.EqualFold(value, "majority") {
+		return writeconcern.Majority(), nil
+	}
+
+	w, err := strconv.Atoi(value)
+	if err != nil || w < 0 {
+		return nil, fmt.Errorf("invalid write concern %q: expected \"majority\" or a non-negative integer", value)
+	}
+	return &writeconcern.WriteConcern{W: w}, nil
+}
+
+// parseReadPreference maps a read preference mode string to driver options.
+// An empty string returns nil so the driver default is kept.
+func parseReadPreference(value string) (*readpref.ReadPref, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return nil, nil
+	case "primary":
+		return readpref.Primary(), nil
+	case "primarypreferred":
+		return readpref.PrimaryPreferred(), nil
+	case "secondary":
+		return readpref.Secondary(), nil
+	case "secondarypreferred":
+		return readpref.SecondaryPreferred(), nil
+	case "nearest":
+		return readpref.Nearest(), nil
+	default:
+		return nil, fmt.Errorf("invalid read preference %q", value)
+	}
+}
+
 // Close closes the MongoDB connection
 func (m *MongoDB) Close(ctx context.Context) error {
 	return m.client.Disconnect(ctx)
@@ -71,22 +423,43 @@ func (m *MongoDB) CreateDocument(ctx context.Context, collection string, doc *mc
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
 	defer cancel()
 
+	if err := m.ValidateDocument(doc); err != nil {
+		return err
+	}
+	m.indexOnFirstWrite(ctx, collection)
+
 	if doc.ID == "" {
-		doc.ID = bson.NewObjectID().Hex()
+		doc.ID = m.newID()
 	}
 	doc.CreatedAt = time.Now()
 	doc.UpdatedAt = time.Now()
 	doc.Version = 1
 
+	stored, err := m.storedDocument(doc)
+	if err != nil {
+		return err
+	}
+
 	coll := m.database.Collection(collection)
-	_, err := coll.InsertOne(ctx, doc)
+	_, err = coll.InsertOne(ctx, stored)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("document %s: %w", doc.ID, ErrDuplicateID)
+		}
 		return fmt.Errorf("failed to create document: %w", err)
 	}
 
 	return nil
 }
 
+// newID generates an ID for a document created without one
+func (m *MongoDB) newID() string {
+	if m.config.IDGenerator == nil {
+		return ObjectIDHex()
+	}
+	return m.config.IDGenerator()
+}
+
 // GetDocument retrieves a document by ID
 func (m *MongoDB) GetDocument(ctx context.Context, collection, id string) (*mcp.Document, error) {
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
@@ -94,8 +467,7 @@ func (m *MongoDB) GetDocument(ctx context.Context, collection, id string) (*mcp.
 
 	coll := m.database.Collection(collection)
 	
-	var doc mcp.Document
-	err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	raw, err := coll.FindOne(ctx, bson.M{"_id": id}).Raw()
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, fmt.Errorf("document not found")
@@ -103,7 +475,73 @@ func (m *MongoDB) GetDocument(ctx context.Context, collection, id string) (*mcp.
 		return nil, fmt.Errorf("failed to get document: %w", err)
 	}
 
-	return &doc, nil
+	doc, err := decodeDocument(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+	doc.Metadata = nativeMetadata(doc.Metadata)
+	return doc, nil
+}
+
+// GetDocuments retrieves the documents with the given IDs in one query. They are
+// returned in the order of ids, without the IDs that do not exist; repeated IDs
+// yield the document once.
+func (m *MongoDB) GetDocuments(ctx context.Context, collection string, ids []string) ([]*mcp.Document, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	coll := m.database.Collection(collection)
+
+	cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	byID := make(map[string]*mcp.Document, len(ids))
+	for cursor.Next(ctx) {
+		var rawDoc bson.M
+		if err := cursor.Decode(&rawDoc); err != nil {
+			return nil, fmt.Errorf("failed to decode raw document: %w", err)
+		}
+		doc, err := m.convertToDocument(rawDoc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert document: %w", err)
+		}
+		byID[doc.ID] = doc
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return orderByIDs(byID, ids), nil
+}
+
+// orderByIDs returns the documents of byID in the order of ids, each once
+func orderByIDs(byID map[string]*mcp.Document, ids []string) []*mcp.Document {
+	documents := make([]*mcp.Document, 0, len(byID))
+	for _, id := range ids {
+		if doc, ok := byID[id]; ok {
+			documents = append(documents, doc)
+			delete(byID, id)
+		}
+	}
+	return documents
+}
+
+// DocumentExists reports whether a document with the given ID exists, without fetching it
+func (m *MongoDB) DocumentExists(ctx context.Context, collection, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	coll := m.database.Collection(collection)
+
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("failed to check document: %w", err)
+	}
+
+	return count > 0, nil
 }
 
 // UpdateDocument updates an existing document
@@ -111,19 +549,32 @@ func (m *MongoDB) UpdateDocument(ctx context.Context, collection string, doc *mc
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
 	defer cancel()
 
+	if err := m.ValidateDocument(doc); err != nil {
+		return err
+	}
+
+	content, encoding, err := m.storedContent(doc.Content)
+	if err != nil {
+		return err
+	}
+
 	doc.UpdatedAt = time.Now()
 	doc.Version++
 
 	coll := m.database.Collection(collection)
-	update := bson.M{
-		"$set": bson.M{
-			"title":      doc.Title,
-			"content":    doc.Content,
-			"tags":       doc.Tags,
-			"metadata":   doc.Metadata,
-			"updated_at": doc.UpdatedAt,
-			"version":    doc.Version,
-		},
+	set := bson.M{
+		"title":      doc.Title,
+		"content":    content,
+		"tags":       doc.Tags,
+		"metadata":   doc.Metadata,
+		"updated_at": doc.UpdatedAt,
+		"version":    doc.Version,
+	}
+	update := bson.M{"$set": set}
+	if encoding != "" {
+		set["content_encoding"] = encoding
+	} else {
+		update["$unset"] = bson.M{"content_encoding": ""}
 	}
 
 	result, err := coll.UpdateOne(ctx, bson.M{"_id": doc.ID}, update)
@@ -138,6 +589,46 @@ func (m *MongoDB) UpdateDocument(ctx context.Context, collection string, doc *mc
 	return nil
 }
 
+// UpdateMany sets fields on every document matching filter, bumping each
+// document's version and updated_at, and returns how many were modified.
+// Tags and metadata it sets are held to the same limits as ValidateDocument.
+func (m *MongoDB) UpdateMany(ctx context.Context, collection string, filter, fields map[string]interface{}) (int64, error) {
+	if err := ValidateFilter(filter); err != nil {
+		return 0, err
+	}
+	if err := m.config.ValidateSetFields(fields); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	set := bson.M{"updated_at": time.Now()}
+	for field, value := range fields {
+		set[field] = value
+	}
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"version": 1},
+	}
+	// New content is stored as given, so it is no longer compressed
+	if _, ok := fields["content"]; ok {
+		update["$unset"] = bson.M{"content_encoding": ""}
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	coll := m.database.Collection(collection)
+	result, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update documents: %w", err)
+	}
+
+	return result.ModifiedCount, nil
+}
+
 // DeleteDocument deletes a document by ID
 func (m *MongoDB) DeleteDocument(ctx context.Context, collection, id string) error {
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
@@ -156,6 +647,103 @@ func (m *MongoDB) DeleteDocument(ctx context.Context, collection, id string) err
 	return nil
 }
 
+// MoveDocument moves a document to another collection, keeping its ID and
+// timestamps and bumping its version. If the target already holds a document
+// with the same ID, nothing is moved and ErrDuplicateID is returned. The copy
+// and delete run in a transaction where the deployment supports one; on a
+// standalone server they run in sequence and the copy is removed again if the
+// delete fails.
+func (m *MongoDB) MoveDocument(ctx context.Context, from, to, id string) error {
+	if from == to {
+		return fmt.Errorf("source and target collection are both %q", from)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	// Indexes cannot be created inside the transaction
+	m.indexOnFirstWrite(ctx, to)
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, m.moveDocument(ctx, from, to, id)
+	})
+	if err != nil && transactionsUnsupported(err) {
+		return m.moveDocumentWithoutTransaction(ctx, from, to, id)
+	}
+	return err
+}
+
+// moveDocument copies the document into the target collection and deletes the original
+func (m *MongoDB) moveDocument(ctx context.Context, from, to, id string) error {
+	raw, err := m.database.Collection(from).FindOne(ctx, bson.M{"_id": id}).Raw()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return fmt.Errorf("document not found")
+		}
+		return fmt.Errorf("failed to get document: %w", err)
+	}
+	doc, err := decodeDocument(raw)
+	if err != nil {
+		return fmt.Errorf("failed to decode document: %w", err)
+	}
+	doc.Version++
+
+	stored, err := m.storedDocument(doc)
+	if err != nil {
+		return err
+	}
+	if _, err := m.database.Collection(to).InsertOne(ctx, stored); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("document %s in collection %s: %w", id, to, ErrDuplicateID)
+		}
+		return fmt.Errorf("failed to copy document: %w", err)
+	}
+
+	result, err := m.database.Collection(from).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete original document: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("document not found")
+	}
+
+	return nil
+}
+
+// moveDocumentWithoutTransaction runs moveDocument outside a transaction,
+// removing the copy if the original could not be deleted
+func (m *MongoDB) moveDocumentWithoutTransaction(ctx context.Context, from, to, id string) error {
+	err := m.moveDocument(ctx, from, to, id)
+	if err == nil || errors.Is(err, ErrDuplicateID) {
+		return err
+	}
+
+	// The copy is only there if the insert succeeded; deleting a missing copy is harmless
+	if exists, _ := m.DocumentExists(ctx, from, id); exists {
+		_, _ = m.database.Collection(to).DeleteOne(ctx, bson.M{"_id": id})
+	}
+	return err
+}
+
+// illegalOperationCode is the server error returned for transactions on a standalone server
+const illegalOperationCode = 20
+
+// transactionsUnsupported reports whether err means the deployment cannot run
+// transactions, as is the case for standalone servers
+func transactionsUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorCode(illegalOperationCode)
+	}
+	return false
+}
+
 // QueryDocuments performs a query on the specified collection
 func (m *MongoDB) QueryDocuments(ctx context.Context, query mcp.DatabaseQuery) ([]*mcp.Document, error) {
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
@@ -163,21 +751,29 @@ func (m *MongoDB) QueryDocuments(ctx context.Context, query mcp.DatabaseQuery) (
 
 	coll := m.database.Collection(query.Collection)
 
+	limit, clamped := clampLimit(query.Limit, m.config.MaxQueryLimit)
+	if clamped {
+		log.Printf("Query on %s requested limit %d; clamped to the maximum of %d", query.Collection, query.Limit, limit)
+	}
+
 	// Build find options
 	findOptions := options.Find()
-	if query.Limit > 0 {
-		findOptions.SetLimit(int64(query.Limit))
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
 	}
 	if query.Skip > 0 {
 		findOptions.SetSkip(int64(query.Skip))
 	}
-	if query.Sort != nil && len(query.Sort) > 0 {
-		findOptions.SetSort(query.Sort)
+	if sort := buildSort(query); sort != nil {
+		findOptions.SetSort(sort)
 	}
 
 	// Build filter
 	filter := bson.M{}
 	if query.Filter != nil {
+		if err := ValidateFilter(query.Filter); err != nil {
+			return nil, err
+		}
 		filter = query.Filter
 	}
 
@@ -211,46 +807,186 @@ func (m *MongoDB) QueryDocuments(ctx context.Context, query mcp.DatabaseQuery) (
 	return documents, nil
 }
 
-// SearchDocuments performs a text search on documents
-func (m *MongoDB) SearchDocuments(ctx context.Context, collection, searchText string, limit int) ([]*mcp.Document, error) {
+// ExplainQuery runs query through MongoDB's explain with execution statistics
+// and summarizes how it was executed: see summarizeExplain for the fields. The
+// query runs in full, so it takes as long as the query itself.
+func (m *MongoDB) ExplainQuery(ctx context.Context, query mcp.DatabaseQuery) (map[string]interface{}, error) {
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
 	defer cancel()
 
-	coll := m.database.Collection(collection)
+	filter := bson.M{}
+	if query.Filter != nil {
+		if err := ValidateFilter(query.Filter); err != nil {
+			return nil, err
+		}
+		filter = query.Filter
+	}
 
-	// Create text search filter
-	filter := bson.M{
-		"$text": bson.M{
-			"$search": searchText,
-		},
+	find := bson.D{{Key: "find", Value: query.Collection}, {Key: "filter", Value: filter}}
+	if sort := buildSort(query); sort != nil {
+		find = append(find, bson.E{Key: "sort", Value: sort})
+	}
+	if limit, _ := clampLimit(query.Limit, m.config.MaxQueryLimit); limit > 0 {
+		find = append(find, bson.E{Key: "limit", Value: int64(limit)})
+	}
+	if query.Skip > 0 {
+		find = append(find, bson.E{Key: "skip", Value: int64(query.Skip)})
 	}
 
-	findOptions := options.Find()
-	if limit > 0 {
-		findOptions.SetLimit(int64(limit))
+	command := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "executionStats"}}
+	raw, err := m.database.RunCommand(ctx, command).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
 	}
-	// Sort by text score
-	findOptions.SetSort(bson.M{"score": bson.M{"$meta": "textScore"}})
 
-	cursor, err := coll.Find(ctx, filter, findOptions)
+	// Relaxed extended JSON turns the nested plan into plain maps and numbers
+	data, err := bson.MarshalExtJSON(raw, false, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute search: %w", err)
+		return nil, fmt.Errorf("failed to decode explain output: %w", err)
+	}
+	var explain map[string]interface{}
+	if err := json.Unmarshal(data, &explain); err != nil {
+		return nil, fmt.Errorf("failed to decode explain output: %w", err)
 	}
-	defer cursor.Close(ctx)
 
-	var documents []*mcp.Document
-	for cursor.Next(ctx) {
-		// Decode to bson.M first to handle ObjectID properly
-		var rawDoc bson.M
-		if err := cursor.Decode(&rawDoc); err != nil {
-			return nil, fmt.Errorf("failed to decode raw document: %w", err)
-		}
+	return summarizeExplain(explain), nil
+}
+
+// summarizeExplain reduces the output of an executionStats explain to:
+// collection_scan (whether any stage scanned the whole collection), index (the
+// index used, or nil), stages (the winning plan, outermost stage first),
+// docs_examined, keys_examined, docs_returned and execution_time_ms.
+func summarizeExplain(explain map[string]interface{}) map[string]interface{} {
+	var stages, indexes []string
+	if planner, ok := explain["queryPlanner"].(map[string]interface{}); ok {
+		collectPlanStages(planner["winningPlan"], &stages, &indexes)
+	}
+
+	summary := map[string]interface{}{
+		"collection_scan": false,
+		"index":           nil,
+		"stages":          strings.Join(stages, " > "),
+	}
+	for _, stage := range stages {
+		if stage == "COLLSCAN" {
+			summary["collection_scan"] = true
+		}
+	}
+	if len(indexes) > 0 {
+		summary["index"] = strings.Join(indexes, ", ")
+	}
+
+	stats, _ := explain["executionStats"].(map[string]interface{})
+	summary["docs_examined"] = int64(toFloat(stats["totalDocsExamined"]))
+	summary["keys_examined"] = int64(toFloat(stats["totalKeysExamined"]))
+	summary["docs_returned"] = int64(toFloat(stats["nReturned"]))
+	summary["execution_time_ms"] = int64(toFloat(stats["executionTimeMillis"]))
+	return summary
+}
+
+// collectPlanStages walks a query plan depth first, appending each stage name
+// and the name of each index scanned
+func collectPlanStages(plan interface{}, stages, indexes *[]string) {
+	node, ok := plan.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	// Plans run by the slot-based engine nest the classic plan under queryPlan
+	if queryPlan, ok := node["queryPlan"]; ok {
+		collectPlanStages(queryPlan, stages, indexes)
+		return
+	}
+
+	if stage, ok := node["stage"].(string); ok {
+		*stages = append(*stages, stage)
+	}
+	if index, ok := node["indexName"].(string); ok {
+		*indexes = append(*indexes, index)
+	}
+	collectPlanStages(node["inputStage"], stages, indexes)
+	if inputs, ok := node["inputStages"].([]interface{}); ok {
+		for _, input := range inputs {
+			collectPlanStages(input, stages, indexes)
+		}
+	}
+}
+
+// buildSort returns the sort document for a query, preferring the ordered form
+// because Go maps (and JSON objects) do not preserve key order
+func buildSort(query mcp.DatabaseQuery) interface{} {
+	if len(query.OrderedSort) > 0 {
+		sort := bson.D{}
+		for _, field := range query.OrderedSort {
+			sort = append(sort, bson.E{Key: field.Field, Value: field.Direction})
+		}
+		return sort
+	}
+	if len(query.Sort) > 0 {
+		return query.Sort
+	}
+	return nil
+}
+
+// SearchDocuments performs a text search on documents. A non-empty filter
+// narrows the matches: it is combined with the $text clause using $and.
+func (m *MongoDB) SearchDocuments(ctx context.Context, collection, searchText string, filter map[string]interface{}, limit int) ([]*mcp.Document, error) {
+	if err := ValidateFilter(filter); err != nil {
+		return nil, err
+	}
+	if _, ok := filter["$text"]; ok {
+		return nil, errors.New("filter must not contain $text: the search text is the query's only text clause")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	coll := m.database.Collection(collection)
+
+	// Create text search filter
+	query := bson.M{
+		"$text": bson.M{
+			"$search": searchText,
+		},
+	}
+	if len(filter) > 0 {
+		query = bson.M{"$and": bson.A{query, filter}}
+	}
+
+	findOptions := options.Find()
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	// Sort by text score, and return it with each document
+	textScore := bson.M{"score": bson.M{"$meta": "textScore"}}
+	findOptions.SetSort(textScore)
+	findOptions.SetProjection(textScore)
+
+	cursor, err := coll.Find(ctx, query, findOptions)
+	if err != nil {
+		if isTextIndexMissing(err) {
+			return nil, fmt.Errorf("collection %s: %w", collection, ErrTextIndexRequired)
+		}
+		return nil, fmt.Errorf("failed to execute search: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var documents []*mcp.Document
+	for cursor.Next(ctx) {
+		// Decode to bson.M first to handle ObjectID properly
+		var rawDoc bson.M
+		if err := cursor.Decode(&rawDoc); err != nil {
+			return nil, fmt.Errorf("failed to decode raw document: %w", err)
+		}
 
 		// Convert to Document struct with ObjectID handling
 		doc, err := m.convertToDocument(rawDoc)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert document: %w", err)
 		}
+		if score, ok := rawDoc["score"].(float64); ok {
+			doc.Score = score
+		}
 		
 		documents = append(documents, doc)
 	}
@@ -262,6 +998,29 @@ func (m *MongoDB) SearchDocuments(ctx context.Context, collection, searchText st
 	return documents, nil
 }
 
+// InsertRecord writes an arbitrary record, such as an audit entry, to collection
+func (m *MongoDB) InsertRecord(ctx context.Context, collection string, record interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	if _, err := m.database.Collection(collection).InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("failed to insert record: %w", err)
+	}
+	return nil
+}
+
+// DropCollection removes a collection with all its documents and indexes.
+// Dropping a collection that does not exist is not an error.
+func (m *MongoDB) DropCollection(ctx context.Context, collection string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	if err := m.database.Collection(collection).Drop(ctx); err != nil {
+		return fmt.Errorf("failed to drop collection: %w", err)
+	}
+	return nil
+}
+
 // CountDocuments counts documents matching the filter
 func (m *MongoDB) CountDocuments(ctx context.Context, collection string, filter map[string]interface{}) (int64, error) {
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
@@ -272,6 +1031,9 @@ func (m *MongoDB) CountDocuments(ctx context.Context, collection string, filter
 	if filter == nil {
 		filter = bson.M{}
 	}
+	if err := ValidateFilter(filter); err != nil {
+		return 0, err
+	}
 
 	count, err := coll.CountDocuments(ctx, filter)
 	if err != nil {
@@ -281,51 +1043,521 @@ func (m *MongoDB) CountDocuments(ctx context.Context, collection string, filter
 	return count, nil
 }
 
-// CreateIndexes creates indexes for better performance
-func (m *MongoDB) CreateIndexes(ctx context.Context) error {
+// FieldStats computes count, sum, average, minimum and maximum of a numeric
+// field over the documents matching filter, in a single $group aggregation.
+// Missing and non-numeric values are ignored.
+func (m *MongoDB) FieldStats(ctx context.Context, collection, field string, filter map[string]interface{}) (*mcp.FieldStats, error) {
+	if err := ValidateFieldPath(field); err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if err := ValidateFilter(filter); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
 	defer cancel()
 
-	// Create text indexes for search
-	collections := []string{"documents", "search_cache"}
-	
-	for _, collName := range collections {
-		coll := m.database.Collection(collName)
-		
-		// Text index for search
-		textIndex := mongo.IndexModel{
-			Keys: bson.M{
-				"title":   "text",
-				"content": "text",
-			},
+	// $min and $max compare across BSON types, so anything that is not a
+	// number is removed before it reaches an accumulator
+	value := "$" + field
+	numeric := bson.M{"$cond": bson.A{bson.M{"$isNumber": value}, value, "$$REMOVE"}}
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$group": bson.M{
+			"_id":     nil,
+			"matched": bson.M{"$sum": 1},
+			"count":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$isNumber": value}, 1, 0}}},
+			"sum":     bson.M{"$sum": numeric},
+			"avg":     bson.M{"$avg": numeric},
+			"min":     bson.M{"$min": numeric},
+			"max":     bson.M{"$max": numeric},
+		}},
+	}
+
+	cursor, err := m.database.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate field stats: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	stats := &mcp.FieldStats{Field: field}
+	if cursor.Next(ctx) {
+		var raw bson.M
+		if err := cursor.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to decode field stats: %w", err)
 		}
-		
-		// Other useful indexes
-		indexes := []mongo.IndexModel{
-			textIndex,
-			{Keys: bson.M{"created_at": -1}},
-			{Keys: bson.M{"updated_at": -1}},
-			{Keys: bson.M{"tags": 1}},
+		stats.Matched = int64(toFloat(raw["matched"]))
+		stats.Count = int64(toFloat(raw["count"]))
+		if stats.Count > 0 {
+			stats.Sum = toFloat(raw["sum"])
+			stats.Avg = toFloat(raw["avg"])
+			stats.Min = toFloat(raw["min"])
+			stats.Max = toFloat(raw["max"])
 		}
-		
-		if collName == "search_cache" {
-			// TTL index for search cache expiration
-			ttlIndex := mongo.IndexModel{
-				Keys:    bson.M{"timestamp": 1},
-				Options: options.Index().SetExpireAfterSeconds(3600), // 1 hour
-			}
-			indexes = append(indexes, ttlIndex)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read field stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// DistinctCount returns the number of distinct values of field over the
+// documents matching filter, grouping by the field and counting the groups
+// rather than returning the values. As with DistinctValues, array fields such
+// as tags contribute each of their elements; missing and null values are not
+// counted.
+func (m *MongoDB) DistinctCount(ctx context.Context, collection, field string, filter map[string]interface{}) (int64, error) {
+	if err := ValidateFieldPath(field); err != nil {
+		return 0, err
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if err := ValidateFilter(filter); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	value := "$" + field
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		// $unwind splits arrays into their elements, passes other values
+		// through and drops documents where the field is missing or null
+		bson.M{"$unwind": value},
+		bson.M{"$group": bson.M{"_id": value}},
+		bson.M{"$count": "count"},
+	}
+
+	cursor, err := m.database.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate distinct count: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	// $count emits nothing when no group was formed
+	var count int64
+	if cursor.Next(ctx) {
+		var raw bson.M
+		if err := cursor.Decode(&raw); err != nil {
+			return 0, fmt.Errorf("failed to decode distinct count: %w", err)
 		}
-		
-		_, err := coll.Indexes().CreateMany(ctx, indexes)
+		count = int64(toFloat(raw["count"]))
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read distinct count: %w", err)
+	}
+
+	return count, nil
+}
+
+// Time buckets accepted by TimeHistogram. Weeks start on Monday.
+const (
+	TimeBucketDay   = "day"
+	TimeBucketWeek  = "week"
+	TimeBucketMonth = "month"
+)
+
+// ValidateTimeBucket checks a TimeHistogram bucket
+func ValidateTimeBucket(bucket string) error {
+	switch bucket {
+	case TimeBucketDay, TimeBucketWeek, TimeBucketMonth:
+		return nil
+	}
+	return fmt.Errorf("invalid bucket %q: expected %s, %s or %s", bucket, TimeBucketDay, TimeBucketWeek, TimeBucketMonth)
+}
+
+// TimeHistogram counts the documents of a collection per day, week or month
+// of a date field such as created_at, truncating dates in UTC with $dateTrunc.
+// Documents whose field is missing or not a date are left out.
+func (m *MongoDB) TimeHistogram(ctx context.Context, collection, field, bucket string) (*mcp.TimeHistogram, error) {
+	if err := ValidateFieldPath(field); err != nil {
+		return nil, err
+	}
+	if err := ValidateTimeBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	truncate := bson.M{"date": "$" + field, "unit": bucket}
+	if bucket == TimeBucketWeek {
+		truncate["startOfWeek"] = "monday"
+	}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{field: bson.M{"$type": "date"}}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$dateTrunc": truncate},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := m.database.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate time histogram: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	histogram := &mcp.TimeHistogram{Field: field, Bucket: bucket, Counts: []mcp.TimeBucketCount{}}
+	for cursor.Next(ctx) {
+		var raw struct {
+			Bucket time.Time `bson:"_id"`
+			Count  int64     `bson:"count"`
+		}
+		if err := cursor.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to decode time histogram: %w", err)
+		}
+		histogram.Counts = append(histogram.Counts, mcp.TimeBucketCount{Bucket: raw.Bucket.UTC(), Count: raw.Count})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read time histogram: %w", err)
+	}
+
+	return histogram, nil
+}
+
+// clampLimit applies the max cap to a requested limit, where zero means
+// unlimited for both. It reports whether the request was reduced.
+func clampLimit(requested, max int) (int, bool) {
+	if max <= 0 {
+		return requested, false
+	}
+	if requested <= 0 || requested > max {
+		return max, requested > max
+	}
+	return requested, false
+}
+
+// ListCollections returns the names of the database's collections, sorted and
+// without MongoDB's internal system.* collections
+func (m *MongoDB) ListCollections(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	names, err := m.database.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	collections := make([]string, 0, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, "system.") {
+			collections = append(collections, name)
+		}
+	}
+	sort.Strings(collections)
+
+	return collections, nil
+}
+
+// DistinctValues returns the distinct values of field across the collection.
+// Array fields such as tags contribute each of their elements.
+func (m *MongoDB) DistinctValues(ctx context.Context, collection, field string) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	coll := m.database.Collection(collection)
+
+	var values []interface{}
+	if err := coll.Distinct(ctx, field, bson.M{}).Decode(&values); err != nil {
+		return nil, fmt.Errorf("failed to get distinct values: %w", err)
+	}
+
+	return values, nil
+}
+
+// ExportCollection writes every document in the collection to w as
+// newline-delimited relaxed Extended JSON, reading through a cursor so the
+// collection is never held in memory. Compressed content is exported as text.
+// No query timeout is applied; large exports are bounded by ctx only.
+func (m *MongoDB) ExportCollection(ctx context.Context, collection string, w io.Writer) error {
+	coll := m.database.Collection(collection)
+
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to export collection: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		exported, err := decompressedExport(cursor.Current)
+		if err != nil {
+			return fmt.Errorf("failed to decode document: %w", err)
+		}
+		line, err := bson.MarshalExtJSON(exported, false, false)
+		if err != nil {
+			return fmt.Errorf("failed to encode document: %w", err)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write document: %w", err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to export collection: %w", err)
+	}
+
+	return nil
+}
+
+// CreateIndexes creates the indexes of every collection in
+// Config.IndexedCollections
+func (m *MongoDB) CreateIndexes(ctx context.Context) error {
+	for _, collection := range m.config.IndexedCollections {
+		if err := m.createIndexes(ctx, collection); err != nil {
+			return err
+		}
+		m.indexed.Store(collection, true)
+	}
+
+	return nil
+}
+
+// createIndexes creates a text index on title and content, unless the
+// collection already has a text index, and indexes on the fields documents
+// are commonly sorted and filtered by. search_cache also gets a TTL index
+// expiring cached results.
+func (m *MongoDB) createIndexes(ctx context.Context, collection string) error {
+	if err := m.EnsureTextIndex(ctx, collection); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.M{"created_at": -1}},
+		{Keys: bson.M{"updated_at": -1}},
+		{Keys: bson.M{"tags": 1}},
+		{Keys: bson.M{"category": 1}},
+	}
+
+	if collection == "search_cache" {
+		// TTL index for search cache expiration
+		ttlIndex := mongo.IndexModel{
+			Keys:    bson.M{"timestamp": 1},
+			Options: options.Index().SetExpireAfterSeconds(3600), // 1 hour
+		}
+		indexes = append(indexes, ttlIndex)
+	}
+
+	if _, err := m.database.Collection(collection).Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("failed to create indexes for %s: %w", collection, err)
+	}
+
+	return nil
+}
+
+// indexOnFirstWrite creates the indexes of a collection the first time it is
+// written to, when Config.IndexOnFirstWrite is set. A failure is logged rather
+// than failing the write, and is not retried.
+func (m *MongoDB) indexOnFirstWrite(ctx context.Context, collection string) {
+	if !m.config.IndexOnFirstWrite {
+		return
+	}
+	if _, done := m.indexed.LoadOrStore(collection, true); done {
+		return
+	}
+	if err := m.createIndexes(ctx, collection); err != nil {
+		log.Printf("Warning: failed to index collection %s on first write: %v", collection, err)
+	}
+}
+
+// SubstringSearch finds documents whose title or content contains searchText,
+// ignoring case. Unlike SearchDocuments it needs no text index, but it scans
+// the collection, matches the text as a whole rather than by words, does not
+// score results and cannot see inside compressed content. Matches are
+// returned newest first; a non-empty filter narrows them as in SearchDocuments.
+func (m *MongoDB) SubstringSearch(ctx context.Context, collection, searchText string, filter map[string]interface{}, limit int) ([]*mcp.Document, error) {
+	if err := ValidateFilter(filter); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	pattern := bson.Regex{Pattern: regexp.QuoteMeta(searchText), Options: "i"}
+	query := bson.M{
+		"$or": bson.A{
+			bson.M{"title": pattern},
+			bson.M{"content": pattern},
+		},
+	}
+	if len(filter) > 0 {
+		query = bson.M{"$and": bson.A{query, filter}}
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+
+	cursor, err := m.database.Collection(collection).Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute substring search: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var documents []*mcp.Document
+	for cursor.Next(ctx) {
+		var rawDoc bson.M
+		if err := cursor.Decode(&rawDoc); err != nil {
+			return nil, fmt.Errorf("failed to decode raw document: %w", err)
+		}
+		doc, err := m.convertToDocument(rawDoc)
 		if err != nil {
-			return fmt.Errorf("failed to create indexes for %s: %w", collName, err)
+			return nil, fmt.Errorf("failed to convert document: %w", err)
+		}
+		documents = append(documents, doc)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return documents, nil
+}
+
+// EnsureTextIndex creates a text index on title and content unless the collection
+// already has one. MongoDB allows a single text index per collection, so an
+// existing text index (e.g. one with custom weights) is left untouched.
+func (m *MongoDB) EnsureTextIndex(ctx context.Context, collection string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	coll := m.database.Collection(collection)
+
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	if err != nil && !isNamespaceNotFound(err) {
+		return fmt.Errorf("failed to list indexes for %s: %w", collection, err)
+	}
+	for _, spec := range specs {
+		if kind, ok := spec.KeysDocument.Lookup("_fts").StringValueOK(); ok && kind == "text" {
+			return nil
 		}
 	}
 
+	textIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "content", Value: "text"},
+		},
+	}
+	if _, err := coll.Indexes().CreateOne(ctx, textIndex); err != nil {
+		return fmt.Errorf("failed to create text index for %s: %w", collection, err)
+	}
+
 	return nil
 }
 
+// ListIndexes describes the indexes of a collection, in the order MongoDB
+// reports them. Each has its name; keys, a list of {field, type} in index order,
+// where type is 1 or -1 for ascending or descending fields, or "text" for the
+// fields of a text index; and text, ttl and unique flags, with
+// expire_after_seconds for TTL indexes. A collection that does not exist has
+// no indexes.
+func (m *MongoDB) ListIndexes(ctx context.Context, collection string) ([]map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.QueryTimeout)
+	defer cancel()
+
+	coll := m.database.Collection(collection)
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		if isNamespaceNotFound(err) {
+			return []map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("failed to list indexes for %s: %w", collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	indexes := []map[string]interface{}{}
+	for cursor.Next(ctx) {
+		var spec struct {
+			Name               string `bson:"name"`
+			Key                bson.D `bson:"key"`
+			Unique             bool   `bson:"unique"`
+			ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+			Weights            bson.M `bson:"weights"`
+		}
+		if err := cursor.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("failed to decode index: %w", err)
+		}
+		indexes = append(indexes, describeIndex(spec.Name, spec.Key, spec.Weights, spec.Unique, spec.ExpireAfterSeconds))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return indexes, nil
+}
+
+// describeIndex builds the ListIndexes description of one index. A text index
+// is stored with the internal _fts and _ftsx keys; its fields are listed from
+// its weights instead, between any ordinary fields it was created with.
+func describeIndex(name string, key bson.D, weights bson.M, unique bool, expireAfterSeconds *int64) map[string]interface{} {
+	keys := []map[string]interface{}{}
+	text := false
+	for _, e := range key {
+		switch e.Key {
+		case "_fts":
+			text = true
+			fields := make([]string, 0, len(weights))
+			for field := range weights {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				keys = append(keys, map[string]interface{}{"field": field, "type": "text"})
+			}
+		case "_ftsx":
+		default:
+			var direction interface{} = int64(toFloat(e.Value))
+			if s, ok := e.Value.(string); ok {
+				direction = s // e.g. "2dsphere" or "hashed"
+			}
+			keys = append(keys, map[string]interface{}{"field": e.Key, "type": direction})
+		}
+	}
+
+	index := map[string]interface{}{
+		"name":   name,
+		"keys":   keys,
+		"text":   text,
+		"ttl":    expireAfterSeconds != nil,
+		"unique": unique,
+	}
+	if expireAfterSeconds != nil {
+		index["expire_after_seconds"] = *expireAfterSeconds
+	}
+	return index
+}
+
+// isTextIndexMissing reports whether err is the server error for a $text query without a text index
+func isTextIndexMissing(err error) bool {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorMessage("text index required")
+	}
+	return false
+}
+
+// isNamespaceNotFound reports whether err is the server error for a collection that does not exist
+func isNamespaceNotFound(err error) bool {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorCode(26)
+	}
+	return false
+}
+
 // HealthCheck performs a health check on the database
 func (m *MongoDB) HealthCheck(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -334,7 +1566,8 @@ func (m *MongoDB) HealthCheck(ctx context.Context) error {
 	return m.client.Ping(ctx, nil)
 }
 
-// convertToDocument converts a bson.M to a Document struct with proper ObjectID handling
+// convertToDocument converts a bson.M to a Document struct with proper ObjectID
+// handling; metadata is converted to plain Go values by nativeValue
 func (m *MongoDB) convertToDocument(rawDoc bson.M) (*mcp.Document, error) {
 	doc := &mcp.Document{}
 	
@@ -354,9 +1587,11 @@ func (m *MongoDB) convertToDocument(rawDoc bson.M) (*mcp.Document, error) {
 	if title, ok := rawDoc["title"].(string); ok {
 		doc.Title = title
 	}
-	if content, ok := rawDoc["content"].(string); ok {
-		doc.Content = content
+	content, err := decodeContent(rawDoc["content"], rawDoc["content_encoding"])
+	if err != nil {
+		return nil, err
 	}
+	doc.Content = content
 	if category, ok := rawDoc["category"].(string); ok {
 		doc.Category = category
 	}
@@ -367,21 +1602,70 @@ func (m *MongoDB) convertToDocument(rawDoc bson.M) (*mcp.Document, error) {
 			}
 		}
 	}
-	if metadata, ok := rawDoc["metadata"].(bson.M); ok {
-		doc.Metadata = make(map[string]interface{})
-		for k, v := range metadata {
-			doc.Metadata[k] = v
-		}
+	if metadata, ok := nativeValue(rawDoc["metadata"]).(map[string]interface{}); ok {
+		doc.Metadata = metadata
 	}
-	if createdAt, ok := rawDoc["created_at"].(time.Time); ok {
+	if createdAt, ok := toTime(rawDoc["created_at"]); ok {
 		doc.CreatedAt = createdAt
 	}
-	if updatedAt, ok := rawDoc["updated_at"].(time.Time); ok {
+	if updatedAt, ok := toTime(rawDoc["updated_at"]); ok {
 		doc.UpdatedAt = updatedAt
 	}
-	if version, ok := rawDoc["version"].(int32); ok {
-		doc.Version = int(version)
+	if version, ok := toVersion(rawDoc["version"]); ok {
+		doc.Version = version
 	}
 	
 	return doc, nil
 }
+
+// toTime converts the date representations produced by the driver to time.Time.
+// Integer values are interpreted as milliseconds since the Unix epoch.
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case bson.DateTime:
+		return v.Time(), true
+	case int64:
+		return time.UnixMilli(v), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// toFloat converts the numeric representations produced by the driver to a
+// float64, returning 0 for anything else
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case bson.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return 0
+		}
+		return f
+	default:
+		return 0
+	}
+}
+
+// toVersion converts the numeric representations produced by the driver to an int
+func toVersion(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
@@ -1,13 +1,18 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 )
 
 // TestMongoDB_Integration runs integration tests against a real MongoDB instance
@@ -63,6 +68,11 @@ func TestMongoDB_Integration(t *testing.T) {
 		assert.False(t, doc.UpdatedAt.IsZero())
 		assert.Equal(t, 1, doc.Version)
 
+		// Creating another document with the same ID is rejected
+		duplicate := &mcp.Document{ID: doc.ID, Title: "Duplicate", Content: "Duplicate content"}
+		err = db.CreateDocument(ctx, collection, duplicate)
+		assert.ErrorIs(t, err, ErrDuplicateID)
+
 		// Get document
 		retrieved, err := db.GetDocument(ctx, collection, doc.ID)
 		require.NoError(t, err)
@@ -72,6 +82,15 @@ func TestMongoDB_Integration(t *testing.T) {
 		assert.Equal(t, doc.Tags, retrieved.Tags)
 		assert.Equal(t, doc.Version, retrieved.Version)
 
+		// Check existence
+		exists, err := db.DocumentExists(ctx, collection, doc.ID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		exists, err = db.DocumentExists(ctx, collection, "missing-id")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
 		// Update document
 		retrieved.Title = "Updated Test Document"
 		retrieved.Content = "This document has been updated."
@@ -97,6 +116,367 @@ func TestMongoDB_Integration(t *testing.T) {
 		_, err = db.GetDocument(ctx, collection, doc.ID)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "document not found")
+
+		exists, err = db.DocumentExists(ctx, collection, doc.ID)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("UpdateMany", func(t *testing.T) {
+		collection := "test_update_many"
+
+		docs := []*mcp.Document{
+			{Title: "Pods", Category: "Kubernetes"},
+			{Title: "Services", Category: "Kubernetes"},
+			{Title: "Images", Category: "Docker"},
+		}
+		for _, doc := range docs {
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+			defer db.DeleteDocument(ctx, collection, doc.ID)
+		}
+
+		modified, err := db.UpdateMany(ctx, collection,
+			map[string]interface{}{"category": "Kubernetes"},
+			map[string]interface{}{"category": "Orchestration"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), modified)
+
+		updated, err := db.GetDocument(ctx, collection, docs[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Orchestration", updated.Category)
+		assert.Equal(t, 2, updated.Version)
+		assert.True(t, updated.UpdatedAt.After(docs[0].UpdatedAt))
+
+		untouched, err := db.GetDocument(ctx, collection, docs[2].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, untouched.Version)
+
+		modified, err = db.UpdateMany(ctx, collection,
+			map[string]interface{}{"category": "Networking"},
+			map[string]interface{}{"category": "Orchestration"})
+		require.NoError(t, err)
+		assert.Zero(t, modified)
+
+		_, err = db.UpdateMany(ctx, collection,
+			map[string]interface{}{"category": "Docker"},
+			map[string]interface{}{"version": 10})
+		assert.Error(t, err)
+	})
+
+	t.Run("FieldStats", func(t *testing.T) {
+		collection := "test_field_stats"
+
+		docs := []*mcp.Document{
+			{Title: "Short", Category: "guides", Metadata: map[string]interface{}{"words": 120}},
+			{Title: "Long", Category: "guides", Metadata: map[string]interface{}{"words": 300.5}},
+			{Title: "Odd", Category: "guides", Metadata: map[string]interface{}{"words": "many"}},
+			{Title: "Bare", Category: "guides"},
+			{Title: "Other", Category: "notes", Metadata: map[string]interface{}{"words": 5000}},
+		}
+		for _, doc := range docs {
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+			defer db.DeleteDocument(ctx, collection, doc.ID)
+		}
+
+		stats, err := db.FieldStats(ctx, collection, "metadata.words", map[string]interface{}{"category": "guides"})
+		require.NoError(t, err)
+		assert.Equal(t, &mcp.FieldStats{
+			Field: "metadata.words", Matched: 4, Count: 2, Sum: 420.5, Avg: 210.25, Min: 120, Max: 300.5,
+		}, stats)
+
+		stats, err = db.FieldStats(ctx, collection, "version", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), stats.Count)
+		assert.Equal(t, 1.0, stats.Avg)
+
+		stats, err = db.FieldStats(ctx, collection, "metadata.words", map[string]interface{}{"category": "none"})
+		require.NoError(t, err)
+		assert.Equal(t, &mcp.FieldStats{Field: "metadata.words"}, stats)
+
+		_, err = db.FieldStats(ctx, collection, "$where", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("DistinctCount", func(t *testing.T) {
+		collection := "test_distinct_count"
+		defer db.DropCollection(ctx, collection)
+
+		docs := []*mcp.Document{
+			{Title: "A", Category: "guides", Tags: []string{"go", "mongodb"}},
+			{Title: "B", Category: "guides", Tags: []string{"go"}},
+			{Title: "C", Category: "notes", Tags: []string{"mcp"}},
+			{Title: "D", Category: "reference", Metadata: map[string]interface{}{"author": nil}},
+			{Title: "E", Category: "faq", Metadata: map[string]interface{}{"author": "ann"}},
+		}
+		for _, doc := range docs {
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+		}
+
+		count, err := db.DistinctCount(ctx, collection, "category", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+
+		count, err = db.DistinctCount(ctx, collection, "tags", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+
+		count, err = db.DistinctCount(ctx, collection, "tags", map[string]interface{}{"category": "guides"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		// Null and missing values are not counted
+		count, err = db.DistinctCount(ctx, collection, "metadata.author", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, err = db.DistinctCount(ctx, collection, "category", map[string]interface{}{"category": "none"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		_, err = db.DistinctCount(ctx, collection, "$where", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("TimeHistogram", func(t *testing.T) {
+		collection := "test_time_histogram"
+		defer db.DropCollection(ctx, collection)
+
+		// Inserted directly, since CreateDocument stamps created_at with the current time
+		date := func(month time.Month, day, hour int) time.Time {
+			return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+		}
+		_, err := db.database.Collection(collection).InsertMany(ctx, []interface{}{
+			bson.M{"_id": "1", "created_at": date(time.March, 4, 9)},
+			bson.M{"_id": "2", "created_at": date(time.March, 4, 23)},
+			bson.M{"_id": "3", "created_at": date(time.March, 10, 12)},
+			bson.M{"_id": "4", "created_at": date(time.March, 11, 0)},
+			bson.M{"_id": "5", "created_at": date(time.April, 3, 8)},
+			bson.M{"_id": "6", "created_at": "2024-03-04"},
+			bson.M{"_id": "7"},
+		})
+		require.NoError(t, err)
+
+		histogram, err := db.TimeHistogram(ctx, collection, "created_at", TimeBucketDay)
+		require.NoError(t, err)
+		assert.Equal(t, &mcp.TimeHistogram{Field: "created_at", Bucket: "day", Counts: []mcp.TimeBucketCount{
+			{Bucket: date(time.March, 4, 0), Count: 2},
+			{Bucket: date(time.March, 10, 0), Count: 1},
+			{Bucket: date(time.March, 11, 0), Count: 1},
+			{Bucket: date(time.April, 3, 0), Count: 1},
+		}}, histogram)
+
+		histogram, err = db.TimeHistogram(ctx, collection, "created_at", TimeBucketWeek)
+		require.NoError(t, err)
+		assert.Equal(t, []mcp.TimeBucketCount{
+			{Bucket: date(time.March, 4, 0), Count: 3},
+			{Bucket: date(time.March, 11, 0), Count: 1},
+			{Bucket: date(time.April, 1, 0), Count: 1},
+		}, histogram.Counts)
+
+		histogram, err = db.TimeHistogram(ctx, collection, "created_at", TimeBucketMonth)
+		require.NoError(t, err)
+		assert.Equal(t, []mcp.TimeBucketCount{
+			{Bucket: date(time.March, 1, 0), Count: 4},
+			{Bucket: date(time.April, 1, 0), Count: 1},
+		}, histogram.Counts)
+
+		histogram, err = db.TimeHistogram(ctx, collection, "updated_at", TimeBucketDay)
+		require.NoError(t, err)
+		assert.Empty(t, histogram.Counts)
+
+		_, err = db.TimeHistogram(ctx, collection, "created_at", "year")
+		assert.Error(t, err)
+	})
+
+	t.Run("MoveDocument", func(t *testing.T) {
+		from, to := "test_move_source", "test_move_target"
+
+		doc := &mcp.Document{Title: "Moved Document", Content: "Moves between collections"}
+		require.NoError(t, db.CreateDocument(ctx, from, doc))
+		defer db.DeleteDocument(ctx, to, doc.ID)
+
+		require.NoError(t, db.MoveDocument(ctx, from, to, doc.ID))
+
+		exists, err := db.DocumentExists(ctx, from, doc.ID)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		moved, err := db.GetDocument(ctx, to, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, doc.Title, moved.Title)
+		assert.WithinDuration(t, doc.CreatedAt, moved.CreatedAt, time.Millisecond)
+		assert.Equal(t, doc.Version+1, moved.Version)
+
+		// A document with the same ID in the target blocks the move and stays untouched
+		clash := &mcp.Document{ID: doc.ID, Title: "Clashing Document"}
+		require.NoError(t, db.CreateDocument(ctx, from, clash))
+		defer db.DeleteDocument(ctx, from, clash.ID)
+
+		err = db.MoveDocument(ctx, from, to, doc.ID)
+		assert.ErrorIs(t, err, ErrDuplicateID)
+
+		exists, err = db.DocumentExists(ctx, from, doc.ID)
+		require.NoError(t, err)
+		assert.True(t, exists)
+		kept, err := db.GetDocument(ctx, to, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Moved Document", kept.Title)
+	})
+
+	t.Run("ContentCompression", func(t *testing.T) {
+		compressing := config
+		compressing.CompressContentAbove = 64
+		cdb, err := NewMongoDB(compressing)
+		require.NoError(t, err)
+		defer cdb.Close(ctx)
+
+		collection := "test_compression"
+		large := strings.Repeat("Compressible article text. ", 100)
+
+		doc := &mcp.Document{Title: "Compressed Document", Content: large}
+		require.NoError(t, cdb.CreateDocument(ctx, collection, doc))
+		defer cdb.DeleteDocument(ctx, collection, doc.ID)
+
+		raw, err := cdb.database.Collection(collection).FindOne(ctx, bson.M{"_id": doc.ID}).Raw()
+		require.NoError(t, err)
+		assert.Equal(t, ContentEncodingGzip, raw.Lookup("content_encoding").StringValue())
+
+		// Reads restore the content, with or without compression configured
+		for _, reader := range []*MongoDB{cdb, db} {
+			retrieved, err := reader.GetDocument(ctx, collection, doc.ID)
+			require.NoError(t, err)
+			assert.Equal(t, large, retrieved.Content)
+
+			docs, err := reader.QueryDocuments(ctx, mcp.DatabaseQuery{Collection: collection, Filter: map[string]interface{}{"_id": doc.ID}})
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			assert.Equal(t, large, docs[0].Content)
+		}
+
+		// Content shrinking below the threshold is stored as text again
+		doc.Content = "Short now."
+		require.NoError(t, cdb.UpdateDocument(ctx, collection, doc))
+		raw, err = cdb.database.Collection(collection).FindOne(ctx, bson.M{"_id": doc.ID}).Raw()
+		require.NoError(t, err)
+		assert.Equal(t, "Short now.", raw.Lookup("content").StringValue())
+		_, compressed := raw.Lookup("content_encoding").StringValueOK()
+		assert.False(t, compressed)
+	})
+
+	t.Run("GetDocuments", func(t *testing.T) {
+		collection := "test_get_documents"
+		var ids []string
+		for _, title := range []string{"First", "Second", "Third"} {
+			doc := &mcp.Document{Title: title, Content: "Batch lookup"}
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+			defer db.DeleteDocument(ctx, collection, doc.ID)
+			ids = append(ids, doc.ID)
+		}
+
+		docs, err := db.GetDocuments(ctx, collection, []string{ids[2], "missing", ids[0], ids[2]})
+		require.NoError(t, err)
+		require.Len(t, docs, 2)
+		assert.Equal(t, "Third", docs[0].Title)
+		assert.Equal(t, "First", docs[1].Title)
+
+		docs, err = db.GetDocuments(ctx, collection, []string{"missing"})
+		require.NoError(t, err)
+		assert.Empty(t, docs)
+	})
+
+	t.Run("ExplainQuery", func(t *testing.T) {
+		collection := "test_explain"
+		for _, title := range []string{"Pods", "Services", "Ingress"} {
+			doc := &mcp.Document{Title: title, Content: "Explained"}
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+			defer db.DeleteDocument(ctx, collection, doc.ID)
+		}
+
+		summary, err := db.ExplainQuery(ctx, mcp.DatabaseQuery{Collection: collection, Filter: map[string]interface{}{"title": "Pods"}})
+		require.NoError(t, err)
+		for _, field := range []string{"collection_scan", "index", "stages", "docs_examined", "keys_examined", "docs_returned", "execution_time_ms"} {
+			assert.Contains(t, summary, field)
+		}
+		assert.Equal(t, true, summary["collection_scan"])
+		assert.Nil(t, summary["index"])
+		assert.Equal(t, int64(3), summary["docs_examined"])
+		assert.Equal(t, int64(1), summary["docs_returned"])
+
+		// Looking up by _id uses its index
+		summary, err = db.ExplainQuery(ctx, mcp.DatabaseQuery{Collection: collection, Filter: map[string]interface{}{"_id": "missing"}})
+		require.NoError(t, err)
+		assert.Equal(t, false, summary["collection_scan"])
+		assert.Equal(t, int64(0), summary["docs_returned"])
+	})
+
+	t.Run("ListIndexes", func(t *testing.T) {
+		collection := "test_list_indexes"
+		doc := &mcp.Document{Title: "Indexed", Content: "Listing indexes"}
+		require.NoError(t, db.CreateDocument(ctx, collection, doc))
+		defer db.DropCollection(ctx, collection)
+		require.NoError(t, db.EnsureTextIndex(ctx, collection))
+
+		indexes, err := db.ListIndexes(ctx, collection)
+		require.NoError(t, err)
+		require.Len(t, indexes, 2)
+
+		assert.Equal(t, "_id_", indexes[0]["name"])
+		assert.Equal(t, []map[string]interface{}{{"field": "_id", "type": int64(1)}}, indexes[0]["keys"])
+		assert.Equal(t, false, indexes[0]["text"])
+		assert.Equal(t, false, indexes[0]["ttl"])
+
+		assert.Equal(t, true, indexes[1]["text"])
+		assert.Equal(t, []map[string]interface{}{
+			{"field": "content", "type": "text"},
+			{"field": "title", "type": "text"},
+		}, indexes[1]["keys"])
+
+		indexes, err = db.ListIndexes(ctx, "test_no_such_collection")
+		require.NoError(t, err)
+		assert.Empty(t, indexes)
+	})
+
+	t.Run("NestedMetadata", func(t *testing.T) {
+		collection := "test_nested_metadata"
+		defer db.DropCollection(ctx, collection)
+		ownerID := bson.NewObjectID()
+		doc := &mcp.Document{Title: "Nested", Content: "Metadata with BSON types", Metadata: map[string]interface{}{
+			"owner":   ownerID,
+			"history": []interface{}{map[string]interface{}{"by": ownerID, "step": int32(1)}},
+		}}
+		require.NoError(t, db.CreateDocument(ctx, collection, doc))
+
+		expected := map[string]interface{}{
+			"owner":   ownerID.Hex(),
+			"history": []interface{}{map[string]interface{}{"by": ownerID.Hex(), "step": int32(1)}},
+		}
+		got, err := db.GetDocument(ctx, collection, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, got.Metadata)
+
+		docs, err := db.QueryDocuments(ctx, mcp.DatabaseQuery{Collection: collection})
+		require.NoError(t, err)
+		require.Len(t, docs, 1)
+		assert.Equal(t, expected, docs[0].Metadata)
+	})
+
+	t.Run("DropCollection", func(t *testing.T) {
+		collection := "test_drop"
+		for i := 0; i < 3; i++ {
+			require.NoError(t, db.CreateDocument(ctx, collection, &mcp.Document{Title: "Scratch", Content: "Dropped"}))
+		}
+
+		require.NoError(t, db.DropCollection(ctx, collection))
+
+		count, err := db.CountDocuments(ctx, collection, nil)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		collections, err := db.ListCollections(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, collections, collection)
+
+		// Dropping a missing collection succeeds
+		assert.NoError(t, db.DropCollection(ctx, collection))
 	})
 
 	// Test query operations
@@ -154,6 +534,27 @@ func TestMongoDB_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.LessOrEqual(t, len(results), 2)
 
+		// Test the configured maximum overrides a larger requested limit
+		db.config.MaxQueryLimit = 2
+		query.Limit = 10
+		results, err = db.QueryDocuments(ctx, query)
+		db.config.MaxQueryLimit = 0
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		// Test the operator allowlist leaves filters the server builds alone
+		db.config.AllowedQueryOperators = []string{"$eq"}
+		results, err = db.QueryDocuments(ctx, mcp.DatabaseQuery{
+			Collection: collection,
+			Filter: map[string]interface{}{
+				"title": map[string]interface{}{"$regex": "^document 1$", "$options": "i"},
+				"tags":  map[string]interface{}{"$in": []interface{}{"first"}},
+			},
+		})
+		db.config.AllowedQueryOperators = nil
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+
 		// Test count
 		count, err := db.CountDocuments(ctx, collection, map[string]interface{}{
 			"tags": "test",
@@ -161,6 +562,26 @@ func TestMongoDB_Integration(t *testing.T) {
 		require.NoError(t, err)
 		assert.GreaterOrEqual(t, count, int64(3))
 
+		// Test distinct values flatten array fields
+		tags, err := db.DistinctValues(ctx, collection, "tags")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []interface{}{"first", "second", "third", "test"}, tags)
+
+		collections, err := db.ListCollections(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, collections, collection)
+
+		// Test export streams one JSON line per document
+		var exported bytes.Buffer
+		require.NoError(t, db.ExportCollection(ctx, collection, &exported))
+		lines := strings.Split(strings.TrimSuffix(exported.String(), "\n"), "\n")
+		assert.Len(t, lines, int(count))
+		for _, line := range lines {
+			var doc map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &doc))
+			assert.Contains(t, doc, "_id")
+		}
+
 		// Clean up
 		for _, doc := range docs {
 			_ = db.DeleteDocument(ctx, collection, doc.ID)
@@ -169,8 +590,130 @@ func TestMongoDB_Integration(t *testing.T) {
 
 	// Test index creation
 	t.Run("IndexCreation", func(t *testing.T) {
-		err := db.CreateIndexes(ctx)
-		assert.NoError(t, err)
+		// indexFields lists the first field of each index of a collection
+		indexFields := func(collection string) []interface{} {
+			indexes, err := db.ListIndexes(ctx, collection)
+			require.NoError(t, err)
+			var fields []interface{}
+			for _, index := range indexes {
+				fields = append(fields, index["keys"].([]map[string]interface{})[0]["field"])
+			}
+			return fields
+		}
+
+		collection := "test_indexed_collection"
+		defer db.DropCollection(ctx, collection)
+		db.config.IndexedCollections = []string{collection}
+		defer func() { db.config.IndexedCollections = nil }()
+
+		require.NoError(t, db.CreateIndexes(ctx))
+		fields := indexFields(collection)
+		for _, field := range []string{"_id", "content", "created_at", "updated_at", "tags", "category"} {
+			assert.Contains(t, fields, field)
+		}
+		assert.NotContains(t, fields, "timestamp")
+
+		// Running it again leaves the indexes as they are
+		require.NoError(t, db.CreateIndexes(ctx))
+		assert.Equal(t, fields, indexFields(collection))
+
+		t.Run("OnFirstWrite", func(t *testing.T) {
+			lazy := "test_index_on_first_write"
+			defer db.DropCollection(ctx, lazy)
+
+			require.NoError(t, db.CreateDocument(ctx, lazy, &mcp.Document{Title: "Unindexed", Content: "Only _id"}))
+			assert.Equal(t, []interface{}{"_id"}, indexFields(lazy))
+			require.NoError(t, db.DropCollection(ctx, lazy))
+
+			db.config.IndexOnFirstWrite = true
+			defer func() { db.config.IndexOnFirstWrite = false }()
+			require.NoError(t, db.CreateDocument(ctx, lazy, &mcp.Document{Title: "Indexed", Content: "All indexes"}))
+			assert.Contains(t, indexFields(lazy), "category")
+		})
+	})
+
+	t.Run("SearchScores", func(t *testing.T) {
+		collection := "test_search_scores"
+		require.NoError(t, db.EnsureTextIndex(ctx, collection))
+
+		docs := []*mcp.Document{
+			{Title: "Kubernetes networking", Content: "Kubernetes pods, Kubernetes services and Kubernetes ingress"},
+			{Title: "Container basics", Content: "Containers can be scheduled by Kubernetes"},
+		}
+		for _, doc := range docs {
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+			defer db.DeleteDocument(ctx, collection, doc.ID)
+		}
+
+		results, err := db.SearchDocuments(ctx, collection, "kubernetes", nil, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, docs[0].ID, results[0].ID)
+		assert.Greater(t, results[1].Score, 0.0)
+		assert.Greater(t, results[0].Score, results[1].Score)
+
+		// The score is never stored with the document
+		stored, err := db.GetDocument(ctx, collection, docs[0].ID)
+		require.NoError(t, err)
+		assert.Zero(t, stored.Score)
+	})
+
+	t.Run("SearchWithFilter", func(t *testing.T) {
+		collection := "test_search_filter"
+		require.NoError(t, db.EnsureTextIndex(ctx, collection))
+
+		docs := []*mcp.Document{
+			{Title: "Kubernetes RBAC", Content: "Kubernetes role-based access control", Category: "Security", Tags: []string{"rbac"}},
+			{Title: "Kubernetes networking", Content: "Kubernetes services and ingress", Category: "Networking"},
+			{Title: "Firewall rules", Content: "Hardening hosts with firewall rules", Category: "Security"},
+		}
+		for _, doc := range docs {
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+			defer db.DeleteDocument(ctx, collection, doc.ID)
+		}
+
+		results, err := db.SearchDocuments(ctx, collection, "kubernetes", map[string]interface{}{"category": "Security"}, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, docs[0].ID, results[0].ID)
+		assert.Greater(t, results[0].Score, 0.0)
+
+		results, err = db.SearchDocuments(ctx, collection, "kubernetes", map[string]interface{}{"tags": map[string]interface{}{"$in": []interface{}{"ingress"}}}, 10)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("SubstringSearch", func(t *testing.T) {
+		collection := "test_substring_search"
+		defer db.DropCollection(ctx, collection)
+
+		docs := []*mcp.Document{
+			{Title: "Kubernetes RBAC", Content: "Role-based access control", Category: "Security"},
+			{Title: "Networking", Content: "Services in KUBERNETES clusters", Category: "Networking"},
+			{Title: "Regex (a+b)", Content: "Literal pattern characters", Category: "Security"},
+		}
+		for _, doc := range docs {
+			require.NoError(t, db.CreateDocument(ctx, collection, doc))
+		}
+
+		// No text index is needed, and case is ignored
+		_, err := db.SearchDocuments(ctx, collection, "kubernetes", nil, 10)
+		assert.ErrorIs(t, err, ErrTextIndexRequired)
+
+		results, err := db.SubstringSearch(ctx, collection, "kubernetes", nil, 10)
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		results, err = db.SubstringSearch(ctx, collection, "bernet", map[string]interface{}{"category": "Security"}, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, docs[0].ID, results[0].ID)
+
+		// The search text is matched literally, not as a pattern
+		results, err = db.SubstringSearch(ctx, collection, "(a+b)", nil, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, docs[2].ID, results[0].ID)
 	})
 }
 
@@ -182,6 +725,238 @@ func TestMongoDB_Unit(t *testing.T) {
 		assert.Equal(t, "mcp_server", config.Database)
 		assert.Equal(t, 10*time.Second, config.ConnectTimeout)
 		assert.Equal(t, 30*time.Second, config.QueryTimeout)
+		assert.Equal(t, 1000, config.MaxQueryLimit)
+		assert.Equal(t, 50, config.MaxTags)
+		assert.Equal(t, 100, config.MaxTagLength)
+		assert.Equal(t, 64*1024, config.MaxMetadataBytes)
+		assert.Contains(t, config.IndexedCollections, "knowledgebase")
+		assert.False(t, config.IndexOnFirstWrite)
+	})
+
+	t.Run("IndexOnFirstWrite", func(t *testing.T) {
+		// With no database, reaching index creation would panic
+		m := &MongoDB{config: DefaultConfig()}
+		m.indexOnFirstWrite(context.Background(), "knowledgebase")
+
+		m.config.IndexOnFirstWrite = true
+		m.indexed.Store("knowledgebase", true)
+		m.indexOnFirstWrite(context.Background(), "knowledgebase")
+	})
+
+	t.Run("ValidateFilter", func(t *testing.T) {
+		valid := []map[string]interface{}{
+			nil,
+			{"category": "Kubernetes"},
+			{"tags": map[string]interface{}{"$in": []interface{}{"go", "mcp"}}},
+			{"$or": []interface{}{map[string]interface{}{"title": "a"}, map[string]interface{}{"title": "b"}}},
+		}
+		for _, filter := range valid {
+			assert.NoError(t, ValidateFilter(filter), "%v", filter)
+		}
+
+		invalid := []map[string]interface{}{
+			{"$where": "this.title.length > 3"},
+			{"$and": []interface{}{map[string]interface{}{"$where": "true"}}},
+			{"$expr": map[string]interface{}{"$function": map[string]interface{}{"body": "return true"}}},
+			{"nested": bson.M{"$accumulator": bson.M{}}},
+			{"title": map[string]interface{}{"$contains": "Go"}},
+			{"$or": []interface{}{}},
+			{"$and": map[string]interface{}{"title": "a"}},
+			{"tags": map[string]interface{}{"$nin": "go"}},
+			{"": "empty"},
+		}
+		for _, filter := range invalid {
+			assert.Error(t, ValidateFilter(filter), "%v", filter)
+		}
+	})
+
+	t.Run("CheckFilter", func(t *testing.T) {
+		check := CheckFilter(map[string]interface{}{
+			"$where": "true",
+			"$or": []interface{}{
+				map[string]interface{}{"$where": "false"},
+				"title",
+			},
+			"title": map[string]interface{}{"$like": "Go%"},
+			// Aggregation operators are only allowed inside $expr
+			"$expr": map[string]interface{}{"$gt": []interface{}{"$version", map[string]interface{}{"$add": []interface{}{1, 2}}}},
+		})
+		assert.False(t, check.Valid())
+		assert.Equal(t, []string{"$where"}, check.RejectedOperators)
+		assert.Equal(t, []string{"$or must be a non-empty list of filters", "unknown operator $like"}, check.Problems)
+		assert.EqualError(t, check.Err(), "filter operator $where is not allowed")
+
+		check = CheckFilter(map[string]interface{}{"title": map[string]interface{}{"$like": "Go%"}})
+		assert.EqualError(t, check.Err(), "invalid filter: unknown operator $like")
+
+		check = CheckFilter(nil)
+		assert.True(t, check.Valid())
+		assert.NoError(t, check.Err())
+		assert.NotNil(t, check.Problems)
+	})
+
+	t.Run("AllowedQueryOperators", func(t *testing.T) {
+		nested := map[string]interface{}{
+			"$and": []interface{}{
+				map[string]interface{}{"category": "guides"},
+				map[string]interface{}{"$or": []interface{}{
+					map[string]interface{}{"tags": map[string]interface{}{"$in": []interface{}{"go"}}},
+					map[string]interface{}{"title": map[string]interface{}{"$regex": "^Go", "$options": "i"}},
+				}},
+			},
+		}
+
+		// Without an allowlist every query operator but the denylisted ones may be used
+		config := DefaultConfig()
+		assert.True(t, config.CheckFilter(nested).Valid())
+		check := config.CheckFilter(map[string]interface{}{
+			"$or": []interface{}{map[string]interface{}{"$and": []interface{}{map[string]interface{}{"$where": "true"}}}},
+		})
+		assert.Equal(t, []string{"$where"}, check.RejectedOperators)
+
+		config.AllowedQueryOperators = []string{"$and", "$or", "$in", "$eq", "$where"}
+		check = config.CheckFilter(nested)
+		assert.False(t, check.Valid())
+		assert.Equal(t, []string{"$options", "$regex"}, check.RejectedOperators)
+		assert.Empty(t, check.Problems)
+		assert.EqualError(t, config.CheckFilter(nested).Err(), "filter operator $options is not allowed")
+
+		assert.NoError(t, config.CheckFilter(map[string]interface{}{
+			"$or": []interface{}{
+				map[string]interface{}{"tags": map[string]interface{}{"$in": []interface{}{"go"}}},
+				map[string]interface{}{"category": map[string]interface{}{"$eq": "guides"}},
+			},
+		}).Err())
+		assert.NoError(t, config.CheckFilter(map[string]interface{}{"category": "guides"}).Err())
+
+		// The allowlist reaches into $expr, and never admits denylisted operators
+		check = config.CheckFilter(map[string]interface{}{
+			"$expr": map[string]interface{}{"$gt": []interface{}{"$version", 1}},
+			"$and":  []interface{}{map[string]interface{}{"$where": "true"}},
+		})
+		assert.Equal(t, []string{"$where", "$expr", "$gt"}, check.RejectedOperators)
+
+		// The store's CheckFilter, used on client filters, applies its
+		// configuration; its queries only reject the denylisted operators, as
+		// the filters they run may be built by the server
+		m := &MongoDB{config: config}
+		assert.Equal(t, []string{"$options", "$regex"}, m.CheckFilter(nested).RejectedOperators)
+		_, err := m.SearchDocuments(context.Background(), "docs", "kubernetes", map[string]interface{}{"$where": "true"}, 10)
+		assert.ErrorContains(t, err, "$where")
+	})
+
+	t.Run("SearchDocuments_InvalidFilter", func(t *testing.T) {
+		m := &MongoDB{}
+		_, err := m.SearchDocuments(context.Background(), "docs", "kubernetes", map[string]interface{}{"$where": "true"}, 10)
+		assert.ErrorContains(t, err, "$where")
+
+		_, err = m.SearchDocuments(context.Background(), "docs", "kubernetes", map[string]interface{}{"$text": map[string]interface{}{"$search": "go"}}, 10)
+		assert.ErrorContains(t, err, "must not contain $text")
+	})
+
+	t.Run("SubstringSearch_InvalidFilter", func(t *testing.T) {
+		m := &MongoDB{}
+		_, err := m.SubstringSearch(context.Background(), "docs", "kubernetes", map[string]interface{}{"$where": "true"}, 10)
+		assert.ErrorContains(t, err, "$where")
+	})
+
+	t.Run("ValidateSetFields", func(t *testing.T) {
+		assert.NoError(t, ValidateSetFields(map[string]interface{}{"category": "Docker", "metadata.reviewed": true}))
+
+		invalid := []map[string]interface{}{
+			{},
+			{"_id": "new"},
+			{"version": 7},
+			{"updated_at": "yesterday"},
+			{"created_at.year": 2020},
+			{"$inc": map[string]interface{}{"version": 1}},
+			{"": "empty"},
+		}
+		for _, fields := range invalid {
+			assert.Error(t, ValidateSetFields(fields), "%v", fields)
+		}
+	})
+
+	t.Run("MatchesEverything", func(t *testing.T) {
+		everything := []map[string]interface{}{
+			nil,
+			{},
+			{"$comment": "all of them"},
+			{"$and": []interface{}{map[string]interface{}{}, bson.M{"$comment": "x"}}},
+			{"$or": bson.A{bson.M{"category": "Docker"}, bson.M{}}},
+		}
+		for _, filter := range everything {
+			assert.True(t, MatchesEverything(filter), "%v", filter)
+		}
+
+		selective := []map[string]interface{}{
+			{"category": "Docker"},
+			{"$comment": "docker only", "category": "Docker"},
+			{"$and": []interface{}{map[string]interface{}{}, map[string]interface{}{"category": "Docker"}}},
+			{"$or": []interface{}{map[string]interface{}{"category": "Docker"}}},
+			{"$nor": []interface{}{map[string]interface{}{}}},
+			{"$and": "invalid"},
+		}
+		for _, filter := range selective {
+			assert.False(t, MatchesEverything(filter), "%v", filter)
+		}
+	})
+
+	t.Run("ValidateFieldPath", func(t *testing.T) {
+		assert.NoError(t, ValidateFieldPath("version"))
+		assert.NoError(t, ValidateFieldPath("metadata.words"))
+
+		for _, field := range []string{"", "$version", "metadata.$size", "metadata..words", ".version", "version."} {
+			assert.Error(t, ValidateFieldPath(field), "%q", field)
+		}
+	})
+
+	t.Run("ValidateTimeBucket", func(t *testing.T) {
+		for _, bucket := range []string{TimeBucketDay, TimeBucketWeek, TimeBucketMonth} {
+			assert.NoError(t, ValidateTimeBucket(bucket))
+		}
+		for _, bucket := range []string{"", "year", "Day", "hour"} {
+			assert.Error(t, ValidateTimeBucket(bucket), "%q", bucket)
+		}
+	})
+
+	t.Run("ToFloat", func(t *testing.T) {
+		decimal, err := bson.ParseDecimal128("2.5")
+		require.NoError(t, err)
+
+		assert.Equal(t, 3.0, toFloat(int32(3)))
+		assert.Equal(t, 4.0, toFloat(int64(4)))
+		assert.Equal(t, 1.5, toFloat(1.5))
+		assert.Equal(t, 2.5, toFloat(decimal))
+		assert.Zero(t, toFloat("7"))
+		assert.Zero(t, toFloat(nil))
+	})
+
+	t.Run("OrderByIDs", func(t *testing.T) {
+		a, b := &mcp.Document{ID: "a"}, &mcp.Document{ID: "b"}
+		byID := map[string]*mcp.Document{"a": a, "b": b}
+		assert.Equal(t, []*mcp.Document{b, a}, orderByIDs(byID, []string{"b", "x", "a", "b"}))
+		assert.Empty(t, orderByIDs(map[string]*mcp.Document{}, []string{"a"}))
+	})
+
+	t.Run("ClampLimit", func(t *testing.T) {
+		tests := []struct {
+			requested, max int
+			want           int
+			clamped        bool
+		}{
+			{requested: 10, max: 100, want: 10},
+			{requested: 100, max: 100, want: 100},
+			{requested: 500, max: 100, want: 100, clamped: true},
+			{requested: 0, max: 100, want: 100},
+			{requested: 500, max: 0, want: 500},
+			{requested: 0, max: 0, want: 0},
+		}
+		for _, tt := range tests {
+			got, clamped := clampLimit(tt.requested, tt.max)
+			assert.Equal(t, tt.want, got, "clampLimit(%d, %d)", tt.requested, tt.max)
+			assert.Equal(t, tt.clamped, clamped, "clampLimit(%d, %d)", tt.requested, tt.max)
+		}
 	})
 
 	t.Run("NewMongoDB_InvalidURI", func(t *testing.T) {
@@ -195,6 +970,491 @@ func TestMongoDB_Unit(t *testing.T) {
 		_, err := NewMongoDB(config)
 		assert.Error(t, err)
 	})
+
+	t.Run("ParseWriteConcern", func(t *testing.T) {
+		testCases := []struct {
+			input    string
+			expected interface{}
+			isNil    bool
+			hasError bool
+		}{
+			{input: "", isNil: true},
+			{input: "majority", expected: "majority"},
+			{input: "MAJORITY", expected: "majority"},
+			{input: "0", expected: 0},
+			{input: "1", expected: 1},
+			{input: "3", expected: 3},
+			{input: "-1", hasError: true},
+			{input: "all", hasError: true},
+		}
+
+		for _, tc := range testCases {
+			wc, err := parseWriteConcern(tc.input)
+			if tc.hasError {
+				assert.Error(t, err, tc.input)
+				continue
+			}
+			require.NoError(t, err, tc.input)
+			if tc.isNil {
+				assert.Nil(t, wc)
+				continue
+			}
+			require.NotNil(t, wc)
+			assert.Equal(t, tc.expected, wc.W, tc.input)
+		}
+	})
+
+	t.Run("ParseReadPreference", func(t *testing.T) {
+		testCases := []struct {
+			input    string
+			expected readpref.Mode
+			isNil    bool
+			hasError bool
+		}{
+			{input: "", isNil: true},
+			{input: "primary", expected: readpref.PrimaryMode},
+			{input: "primaryPreferred", expected: readpref.PrimaryPreferredMode},
+			{input: "secondary", expected: readpref.SecondaryMode},
+			{input: "secondaryPreferred", expected: readpref.SecondaryPreferredMode},
+			{input: "nearest", expected: readpref.NearestMode},
+			{input: "fastest", hasError: true},
+		}
+
+		for _, tc := range testCases {
+			rp, err := parseReadPreference(tc.input)
+			if tc.hasError {
+				assert.Error(t, err, tc.input)
+				continue
+			}
+			require.NoError(t, err, tc.input)
+			if tc.isNil {
+				assert.Nil(t, rp)
+				continue
+			}
+			require.NotNil(t, rp)
+			assert.Equal(t, tc.expected, rp.Mode(), tc.input)
+		}
+	})
+
+	t.Run("ConvertToDocument_Timestamps", func(t *testing.T) {
+		m := &MongoDB{}
+		expected := time.Date(2025, 7, 26, 12, 30, 0, 0, time.UTC)
+
+		testCases := []struct {
+			name  string
+			value interface{}
+		}{
+			{"time.Time", expected},
+			{"bson.DateTime", bson.NewDateTimeFromTime(expected)},
+			{"int64 epoch millis", expected.UnixMilli()},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				doc, err := m.convertToDocument(bson.M{
+					"_id":        "doc-1",
+					"created_at": tc.value,
+					"updated_at": tc.value,
+				})
+				require.NoError(t, err)
+				assert.True(t, expected.Equal(doc.CreatedAt), "created_at: got %v", doc.CreatedAt)
+				assert.True(t, expected.Equal(doc.UpdatedAt), "updated_at: got %v", doc.UpdatedAt)
+			})
+		}
+
+		t.Run("unsupported type", func(t *testing.T) {
+			doc, err := m.convertToDocument(bson.M{"created_at": "yesterday"})
+			require.NoError(t, err)
+			assert.True(t, doc.CreatedAt.IsZero())
+		})
+	})
+
+	t.Run("ConvertToDocument_Metadata", func(t *testing.T) {
+		m := &MongoDB{}
+		authorID := bson.NewObjectID()
+		reviewerID := bson.NewObjectID()
+		published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		reviewed := published.Add(48 * time.Hour)
+
+		// Round trip through BSON, so values come back as the driver decodes them
+		data, err := bson.Marshal(bson.M{
+			"_id": "doc-1",
+			"metadata": bson.M{
+				"author":    authorID,
+				"published": published,
+				"source":    bson.D{{Key: "name", Value: "wiki"}, {Key: "rank", Value: int32(2)}},
+				"reviews": bson.A{
+					bson.M{"by": reviewerID, "at": reviewed, "notes": bson.A{"typo", bson.M{"line": int64(12)}}},
+				},
+				"nested": bson.M{"deeper": bson.M{"deepest": bson.M{"id": authorID, "dates": bson.A{published}}}},
+			},
+		})
+		require.NoError(t, err)
+		var rawDoc bson.M
+		require.NoError(t, bson.Unmarshal(data, &rawDoc))
+
+		doc, err := m.convertToDocument(rawDoc)
+		require.NoError(t, err)
+		expected := map[string]interface{}{
+			"author":    authorID.Hex(),
+			"published": published,
+			"source":    map[string]interface{}{"name": "wiki", "rank": int32(2)},
+			"reviews": []interface{}{
+				map[string]interface{}{"by": reviewerID.Hex(), "at": reviewed, "notes": []interface{}{"typo", map[string]interface{}{"line": int64(12)}}},
+			},
+			"nested": map[string]interface{}{"deeper": map[string]interface{}{"deepest": map[string]interface{}{"id": authorID.Hex(), "dates": []interface{}{published}}}},
+		}
+		assert.Equal(t, expected, doc.Metadata)
+		assert.IsType(t, time.Time{}, doc.Metadata["published"])
+		assert.IsType(t, map[string]interface{}{}, doc.Metadata["source"])
+
+		doc, err = m.convertToDocument(bson.M{"_id": "doc-2", "metadata": "not a document"})
+		require.NoError(t, err)
+		assert.Nil(t, doc.Metadata)
+	})
+
+	t.Run("ConvertToDocument_Version", func(t *testing.T) {
+		m := &MongoDB{}
+
+		testCases := []struct {
+			name     string
+			value    interface{}
+			expected int
+		}{
+			{"int32", int32(3), 3},
+			{"int64", int64(4), 4},
+			{"float64", float64(5), 5},
+			{"missing", nil, 0},
+			{"string", "6", 0},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				raw := bson.M{"_id": "doc-1"}
+				if tc.value != nil {
+					raw["version"] = tc.value
+				}
+				doc, err := m.convertToDocument(raw)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, doc.Version)
+			})
+		}
+	})
+
+	t.Run("ContentCompression", func(t *testing.T) {
+		m := &MongoDB{config: Config{CompressContentAbove: 64}}
+		large := strings.Repeat("Compressible article text. ", 100)
+
+		t.Run("Compressed", func(t *testing.T) {
+			stored, err := m.storedDocument(&mcp.Document{ID: "doc-1", Title: "Large", Content: large})
+			require.NoError(t, err)
+			data, err := bson.Marshal(stored)
+			require.NoError(t, err)
+			raw := bson.Raw(data)
+
+			assert.Equal(t, ContentEncodingGzip, raw.Lookup("content_encoding").StringValue())
+			assert.Equal(t, bson.TypeBinary, raw.Lookup("content").Type)
+			assert.Less(t, len(data), len(large))
+
+			doc, err := decodeDocument(raw)
+			require.NoError(t, err)
+			assert.Equal(t, "Large", doc.Title)
+			assert.Equal(t, large, doc.Content)
+
+			var rawDoc bson.M
+			require.NoError(t, bson.Unmarshal(raw, &rawDoc))
+			doc, err = m.convertToDocument(rawDoc)
+			require.NoError(t, err)
+			assert.Equal(t, large, doc.Content)
+
+			exported, err := decompressedExport(raw)
+			require.NoError(t, err)
+			line, err := bson.MarshalExtJSON(exported, false, false)
+			require.NoError(t, err)
+			assert.Contains(t, string(line), `"content":"Compressible article text.`)
+			assert.NotContains(t, string(line), "content_encoding")
+		})
+
+		t.Run("Uncompressed", func(t *testing.T) {
+			for name, m := range map[string]*MongoDB{
+				"BelowThreshold": m,
+				"Disabled":       {config: Config{}},
+			} {
+				t.Run(name, func(t *testing.T) {
+					content := "Short article text."
+					if name == "Disabled" {
+						content = large
+					}
+					stored, err := m.storedDocument(&mcp.Document{ID: "doc-1", Content: content})
+					require.NoError(t, err)
+					data, err := bson.Marshal(stored)
+					require.NoError(t, err)
+					raw := bson.Raw(data)
+
+					assert.Equal(t, content, raw.Lookup("content").StringValue())
+					_, hasEncoding := raw.Lookup("content_encoding").StringValueOK()
+					assert.False(t, hasEncoding)
+
+					doc, err := decodeDocument(raw)
+					require.NoError(t, err)
+					assert.Equal(t, content, doc.Content)
+
+					exported, err := decompressedExport(raw)
+					require.NoError(t, err)
+					assert.Equal(t, raw, exported)
+				})
+			}
+		})
+
+		t.Run("UnknownEncoding", func(t *testing.T) {
+			data, err := bson.Marshal(bson.M{"_id": "doc-1", "content": "x", "content_encoding": "br"})
+			require.NoError(t, err)
+			_, err = decodeDocument(data)
+			assert.Error(t, err)
+
+			_, err = m.convertToDocument(bson.M{"_id": "doc-1", "content": "x", "content_encoding": "br"})
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("BuildSort", func(t *testing.T) {
+		assert.Nil(t, buildSort(mcp.DatabaseQuery{}))
+
+		mapSort := buildSort(mcp.DatabaseQuery{Sort: map[string]interface{}{"title": 1}})
+		assert.Equal(t, map[string]interface{}{"title": 1}, mapSort)
+
+		ordered := buildSort(mcp.DatabaseQuery{
+			Sort: map[string]interface{}{"ignored": 1},
+			OrderedSort: []mcp.SortField{
+				{Field: "category", Direction: 1},
+				{Field: "created_at", Direction: -1},
+				{Field: "title", Direction: 1},
+			},
+		})
+		assert.Equal(t, bson.D{
+			{Key: "category", Value: 1},
+			{Key: "created_at", Value: -1},
+			{Key: "title", Value: 1},
+		}, ordered)
+	})
+
+	t.Run("SummarizeExplain", func(t *testing.T) {
+		summary := summarizeExplain(map[string]interface{}{
+			"queryPlanner": map[string]interface{}{
+				"winningPlan": map[string]interface{}{
+					"stage": "LIMIT",
+					"inputStage": map[string]interface{}{
+						"stage": "FETCH",
+						"inputStage": map[string]interface{}{
+							"stage":     "IXSCAN",
+							"indexName": "title_1",
+						},
+					},
+				},
+			},
+			"executionStats": map[string]interface{}{
+				"nReturned":           float64(1),
+				"executionTimeMillis": float64(2),
+				"totalKeysExamined":   float64(1),
+				"totalDocsExamined":   float64(1),
+			},
+		})
+		assert.Equal(t, map[string]interface{}{
+			"collection_scan":   false,
+			"index":             "title_1",
+			"stages":            "LIMIT > FETCH > IXSCAN",
+			"docs_examined":     int64(1),
+			"keys_examined":     int64(1),
+			"docs_returned":     int64(1),
+			"execution_time_ms": int64(2),
+		}, summary)
+
+		// Plans from the slot-based engine nest the plan under queryPlan
+		summary = summarizeExplain(map[string]interface{}{
+			"queryPlanner": map[string]interface{}{
+				"winningPlan": map[string]interface{}{
+					"queryPlan": map[string]interface{}{"stage": "COLLSCAN"},
+				},
+			},
+			"executionStats": map[string]interface{}{"nReturned": float64(3), "totalDocsExamined": float64(500)},
+		})
+		assert.Equal(t, true, summary["collection_scan"])
+		assert.Nil(t, summary["index"])
+		assert.Equal(t, int64(500), summary["docs_examined"])
+		assert.Equal(t, int64(3), summary["docs_returned"])
+	})
+
+	t.Run("DescribeIndex", func(t *testing.T) {
+		ttl := int64(3600)
+		index := describeIndex("created_at_1", bson.D{{Key: "created_at", Value: int32(1)}}, nil, false, &ttl)
+		assert.Equal(t, map[string]interface{}{
+			"name":                 "created_at_1",
+			"keys":                 []map[string]interface{}{{"field": "created_at", "type": int64(1)}},
+			"text":                 false,
+			"ttl":                  true,
+			"unique":               false,
+			"expire_after_seconds": int64(3600),
+		}, index)
+
+		// A compound text index lists its text fields in place of _fts and _ftsx
+		index = describeIndex("category_1_title_text",
+			bson.D{{Key: "category", Value: int32(-1)}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+			bson.M{"title": int32(1)}, true, nil)
+		assert.Equal(t, []map[string]interface{}{
+			{"field": "category", "type": int64(-1)},
+			{"field": "title", "type": "text"},
+		}, index["keys"])
+		assert.Equal(t, true, index["text"])
+		assert.Equal(t, true, index["unique"])
+		assert.NotContains(t, index, "expire_after_seconds")
+	})
+
+	t.Run("NewMongoDB_InvalidWriteConcern", func(t *testing.T) {
+		config := DefaultConfig()
+		config.WriteConcern = "sometimes"
+
+		_, err := NewMongoDB(config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid write concern")
+	})
+
+	t.Run("NewMongoDB_InvalidReadPreference", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ReadPreference = "fastest"
+
+		_, err := NewMongoDB(config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid read preference")
+	})
+
+	t.Run("ValidateCollectionName", func(t *testing.T) {
+		valid := []string{"knowledgebase", "articles.v2", "user_notes", "a"}
+		for _, name := range valid {
+			assert.NoError(t, ValidateCollectionName(name, 100), name)
+		}
+
+		invalid := map[string]string{
+			"empty":         "",
+			"dollar":        "orders$tmp",
+			"operator":      "$where",
+			"null byte":     "bad\x00name",
+			"system prefix": "system.indexes",
+			"system users":  "system.users",
+			"too long":      strings.Repeat("c", 101),
+		}
+		for label, name := range invalid {
+			assert.Error(t, ValidateCollectionName(name, 100), label)
+		}
+	})
+
+	t.Run("MaxCollectionNameLength", func(t *testing.T) {
+		m := &MongoDB{config: Config{Database: "mcp_server"}}
+		assert.Equal(t, maxNamespaceLength-len("mcp_server")-1, m.maxCollectionNameLength())
+		assert.NoError(t, m.ValidateCollectionName(strings.Repeat("c", 200)))
+
+		m.config.MaxCollectionNameLength = 20
+		assert.Equal(t, 20, m.maxCollectionNameLength())
+		assert.Error(t, m.ValidateCollectionName(strings.Repeat("c", 21)))
+	})
+
+	t.Run("ValidateDocument", func(t *testing.T) {
+		config := Config{MaxTags: 2, MaxTagLength: 5, MaxMetadataBytes: 20}
+		assert.NoError(t, config.ValidateDocument(&mcp.Document{
+			Tags:     []string{"go", "mcp"},
+			Metadata: map[string]interface{}{"a": 1},
+		}))
+
+		err := config.ValidateDocument(&mcp.Document{Tags: []string{"a", "b", "c"}})
+		assert.EqualError(t, err, "document has 3 tags, more than the limit of 2")
+
+		err = config.ValidateDocument(&mcp.Document{Tags: []string{"go", "kubernetes"}})
+		assert.EqualError(t, err, "tag 2 is 10 bytes long, more than the limit of 5")
+
+		err = config.ValidateDocument(&mcp.Document{Metadata: map[string]interface{}{"author": "someone!"}})
+		assert.EqualError(t, err, "metadata is 21 bytes, more than the limit of 20")
+
+		// Zero limits accept anything
+		assert.NoError(t, Config{}.ValidateDocument(&mcp.Document{
+			Tags:     []string{strings.Repeat("t", 1000)},
+			Metadata: map[string]interface{}{"blob": strings.Repeat("x", 1<<20)},
+		}))
+	})
+
+	t.Run("ValidateSetFieldsLimits", func(t *testing.T) {
+		config := Config{MaxTags: 2, MaxTagLength: 5, MaxMetadataBytes: 20}
+		assert.NoError(t, config.ValidateSetFields(map[string]interface{}{
+			"tags":            []interface{}{"go", "mcp"},
+			"tags.1":          "grpc",
+			"metadata":        map[string]interface{}{"a": 1},
+			"metadata.author": "someone",
+		}))
+
+		rejected := []struct {
+			fields  map[string]interface{}
+			message string
+		}{
+			{map[string]interface{}{"tags": []interface{}{"a", "b", "c"}}, "document has 3 tags, more than the limit of 2"},
+			{map[string]interface{}{"tags": []string{"go", "kubernetes"}}, "tag 2 is 10 bytes long, more than the limit of 5"},
+			{map[string]interface{}{"tags": []interface{}{"go", 7}}, "tags must be a list of strings"},
+			{map[string]interface{}{"tags": "go"}, "tags must be a list of strings"},
+			{map[string]interface{}{"tags.2": "go"}, "document would have 3 tags, more than the limit of 2"},
+			{map[string]interface{}{"tags.0": "kubernetes"}, "tag 1 is 10 bytes long, more than the limit of 5"},
+			{map[string]interface{}{"metadata": map[string]interface{}{"author": "someone!"}}, "metadata is 21 bytes, more than the limit of 20"},
+			{map[string]interface{}{"metadata": "notes"}, "metadata must be an object"},
+			{map[string]interface{}{"metadata.notes": strings.Repeat("x", 20)}, "metadata.notes is 22 bytes, more than the metadata limit of 20"},
+		}
+		for _, c := range rejected {
+			assert.EqualError(t, config.ValidateSetFields(c.fields), c.message, "%v", c.fields)
+		}
+
+		// The store checks them before writing
+		m := &MongoDB{config: config}
+		_, err := m.UpdateMany(context.Background(), "docs", map[string]interface{}{"category": "go"}, map[string]interface{}{"tags": []interface{}{"a", "b", "c"}})
+		assert.EqualError(t, err, "document has 3 tags, more than the limit of 2")
+	})
+
+	t.Run("ValidateDatabaseName", func(t *testing.T) {
+		assert.NoError(t, ValidateDatabaseName("mcp_server"))
+
+		for _, name := range []string{"", "my db", "my.db", "a/b", "db$", strings.Repeat("d", 64)} {
+			assert.Error(t, ValidateDatabaseName(name), name)
+		}
+	})
+
+	t.Run("IDGenerators", func(t *testing.T) {
+		assert.Regexp(t, `^[0-9a-f]{24}$`, ObjectIDHex())
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, UUIDv4())
+		assert.NotEqual(t, UUIDv4(), UUIDv4())
+
+		for name, expected := range map[string]string{"": ObjectIDHex(), "objectid": ObjectIDHex(), "UUID": UUIDv4()} {
+			generator, err := IDGeneratorByName(name)
+			require.NoError(t, err, name)
+			assert.Len(t, generator(), len(expected), name)
+		}
+
+		_, err := IDGeneratorByName("sequential")
+		assert.Error(t, err)
+	})
+
+	t.Run("NewID", func(t *testing.T) {
+		m := &MongoDB{config: DefaultConfig()}
+		assert.Len(t, m.newID(), 24)
+
+		m.config.IDGenerator = func() string { return "fixed-id" }
+		assert.Equal(t, "fixed-id", m.newID())
+
+		m.config.IDGenerator = nil
+		assert.Len(t, m.newID(), 24)
+	})
+
+	t.Run("NewMongoDB_InvalidDatabaseName", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Database = "bad.name"
+
+		_, err := NewMongoDB(config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid character")
+	})
 }
 
 // Benchmark tests
@@ -0,0 +1,144 @@
+package search
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxCachedSearches bounds the result cache; once full, new pages are only
+// cached after expired entries make room
+const maxCachedSearches = 500
+
+// CacheClearer is implemented by searchers that cache results and let callers
+// evict them
+type CacheClearer interface {
+	// ClearCache evicts the cached results of query, compared without regard
+	// to case or surrounding spaces, or every cached result when query is
+	// empty, and returns how many entries were evicted
+	ClearCache(query string) int
+}
+
+// resultCacheEntry is one cached page of results
+type resultCacheEntry struct {
+	query   string // normalized query text, for evicting by query
+	page    *mcp.SearchPage
+	expires time.Time
+}
+
+// resultCache memoizes search result pages for a TTL, keyed by every field of
+// the query that affects the results
+type resultCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]resultCacheEntry
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]resultCacheEntry),
+	}
+}
+
+// normalizeQuery is the form of the query text compared by the cache
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.TrimSpace(query))
+}
+
+// resultCacheKey identifies the results of query. NoCache only changes how the
+// cache is used, so it is not part of the key.
+func resultCacheKey(query mcp.SearchQuery) string {
+	query.Query = normalizeQuery(query.Query)
+	query.NoCache = false
+	key, _ := json.Marshal(query)
+	return string(key)
+}
+
+// page returns the cached page for query when there is one, unless the query
+// asks for fresh results. Otherwise fetch runs and its page replaces the entry.
+func (c *resultCache) page(query mcp.SearchQuery, fetch func() (*mcp.SearchPage, error)) (*mcp.SearchPage, error) {
+	key := resultCacheKey(query)
+	if !query.NoCache {
+		if page, ok := c.get(key); ok {
+			return page, nil
+		}
+	}
+
+	page, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, normalizeQuery(query.Query), page)
+	return copyPage(page), nil
+}
+
+func (c *resultCache) get(key string) (*mcp.SearchPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return copyPage(entry.page), true
+}
+
+func (c *resultCache) put(key, query string, page *mcp.SearchPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedSearches {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedSearches {
+			return
+		}
+	}
+	c.entries[key] = resultCacheEntry{query: query, page: copyPage(page), expires: now.Add(c.ttl)}
+}
+
+// clear evicts the entries for query, or every entry when query is empty
+func (c *resultCache) clear(query string) int {
+	query = normalizeQuery(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evicted := 0
+	for key, entry := range c.entries {
+		if query == "" || entry.query == query {
+			delete(c.entries, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// copyPage copies a page and its results, so that callers adding content to
+// the results they get cannot change what the cache holds
+func copyPage(page *mcp.SearchPage) *mcp.SearchPage {
+	copied := &mcp.SearchPage{NextPage: page.NextPage}
+	if page.Results != nil {
+		copied.Results = make([]*mcp.SearchResult, len(page.Results))
+		for i, result := range page.Results {
+			r := *result
+			copied.Results[i] = &r
+		}
+	}
+	return copied
+}
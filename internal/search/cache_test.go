@@ -0,0 +1,140 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollySearcher_Cache(t *testing.T) {
+	// setup returns a caching searcher, a fetch function whose pages are
+	// numbered by how often it ran, and search, which returns the title of the
+	// page the cache serves for a query
+	setup := func(t *testing.T) (*CollySearcher, *int, func() (*mcp.SearchPage, error), func(query mcp.SearchQuery) string) {
+		config := DefaultConfig()
+		config.CacheTTL = time.Minute
+		searcher := NewCollySearcher(config)
+		require.NotNil(t, searcher.cache)
+
+		fetches := 0
+		fetch := func() (*mcp.SearchPage, error) {
+			fetches++
+			return &mcp.SearchPage{Results: []*mcp.SearchResult{
+				{Title: fmt.Sprintf("result %d", fetches), URL: "https://example.com"},
+			}}, nil
+		}
+		search := func(query mcp.SearchQuery) string {
+			page, err := searcher.cache.page(query, fetch)
+			require.NoError(t, err)
+			require.Len(t, page.Results, 1)
+			return page.Results[0].Title
+		}
+		return searcher, &fetches, fetch, search
+	}
+	golang := mcp.SearchQuery{Query: "golang", MaxResults: 5}
+
+	t.Run("WarmCacheIsServed", func(t *testing.T) {
+		_, fetches, _, search := setup(t)
+
+		assert.Equal(t, "result 1", search(golang))
+		assert.Equal(t, "result 1", search(mcp.SearchQuery{Query: " GoLang ", MaxResults: 5}))
+		assert.Equal(t, 1, *fetches)
+
+		// Other parameters are other results
+		assert.Equal(t, "result 2", search(mcp.SearchQuery{Query: "golang", MaxResults: 5, Region: "de-de"}))
+		assert.Equal(t, "result 3", search(mcp.SearchQuery{Query: "golang", MaxResults: 5, Offset: 5}))
+	})
+
+	t.Run("NoCacheBypassesAndRepopulates", func(t *testing.T) {
+		_, fetches, _, search := setup(t)
+		assert.Equal(t, "result 1", search(golang))
+
+		fresh := golang
+		fresh.NoCache = true
+		assert.Equal(t, "result 2", search(fresh))
+		assert.Equal(t, 2, *fetches)
+
+		// Later cached reads get the refreshed page
+		assert.Equal(t, "result 2", search(golang))
+		assert.Equal(t, 2, *fetches)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		searcher, fetches, fetch, search := setup(t)
+		now := time.Now()
+		searcher.cache.now = func() time.Time { return now }
+
+		searcher.cache.page(golang, fetch)
+		now = now.Add(59 * time.Second)
+		searcher.cache.page(golang, fetch)
+		assert.Equal(t, 1, *fetches)
+
+		now = now.Add(time.Second)
+		assert.Equal(t, "result 2", search(golang))
+	})
+
+	t.Run("ErrorsAreNotCached", func(t *testing.T) {
+		searcher, fetches, _, search := setup(t)
+
+		_, err := searcher.cache.page(golang, func() (*mcp.SearchPage, error) {
+			return nil, errors.New("engine unavailable")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, "result 1", search(golang))
+		assert.Equal(t, 1, *fetches)
+	})
+
+	t.Run("CallersGetCopies", func(t *testing.T) {
+		searcher, _, fetch, _ := setup(t)
+
+		page, err := searcher.cache.page(golang, fetch)
+		require.NoError(t, err)
+		page.Results[0].Content = "added by the caller"
+
+		page, err = searcher.cache.page(golang, fetch)
+		require.NoError(t, err)
+		assert.Empty(t, page.Results[0].Content)
+	})
+
+	t.Run("ClearCache", func(t *testing.T) {
+		searcher, fetches, fetch, _ := setup(t)
+		searcher.cache.page(golang, fetch)
+		searcher.cache.page(mcp.SearchQuery{Query: "golang", MaxResults: 10}, fetch)
+		searcher.cache.page(mcp.SearchQuery{Query: "rust"}, fetch)
+
+		assert.Equal(t, 0, searcher.ClearCache("python"))
+		assert.Equal(t, 2, searcher.ClearCache("GOLANG"))
+		searcher.cache.page(golang, fetch)
+		assert.Equal(t, 4, *fetches)
+
+		assert.Equal(t, 2, searcher.ClearCache(""))
+		searcher.cache.page(mcp.SearchQuery{Query: "rust"}, fetch)
+		assert.Equal(t, 5, *fetches)
+	})
+
+	t.Run("DomainChangesClearCache", func(t *testing.T) {
+		searcher, fetches, fetch, _ := setup(t)
+
+		searcher.cache.page(golang, fetch)
+		searcher.AddBlockedDomain("example.com")
+		searcher.cache.page(golang, fetch)
+		assert.Equal(t, 2, *fetches)
+
+		searcher.SetAllowedDomains([]string{"go.dev"})
+		searcher.cache.page(golang, fetch)
+		assert.Equal(t, 3, *fetches)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		config := DefaultConfig()
+		config.CacheResults = false
+		searcher := NewCollySearcher(config)
+		assert.Nil(t, searcher.cache)
+		assert.Equal(t, 0, searcher.ClearCache(""))
+	})
+}
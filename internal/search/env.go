@@ -0,0 +1,212 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Environment variables read by ConfigFromEnv
+const (
+	EnvTimeout          = "SEARCH_TIMEOUT"                 // duration, e.g. "20s"
+	EnvMaxResults       = "SEARCH_MAX_RESULTS"             // positive integer
+	EnvMaxPages         = "SEARCH_MAX_PAGES"               // positive integer; engine result pages scraped per search
+	EnvBudget           = "SEARCH_BUDGET"                  // duration one search may spend on engine requests; 0 disables the budget
+	EnvMaxURLs          = "SEARCH_MAX_URLS"                // non-negative integer; engine URLs visited per search, 0 for no cap
+	EnvDelay            = "SEARCH_DELAY"                   // duration between requests to the same engine
+	EnvMaxPageBytes     = "SEARCH_MAX_PAGE_BYTES"          // non-negative integer; bytes read per result page when fetching content, 0 for the default 10MB
+	EnvMaxContentBudget = "SEARCH_MAX_TOTAL_CONTENT_BYTES" // non-negative integer; bytes read across one search's result pages, 0 for no cap
+	EnvDomainDelays     = "SEARCH_DOMAIN_DELAYS"           // comma-separated glob=duration overrides of the delay, e.g. "*.duckduckgo.com=200ms"
+	EnvBlockedDomains   = "SEARCH_BLOCKED_DOMAINS"         // comma-separated; replaces the default list, "none" clears it
+	EnvCacheTTL         = "SEARCH_CACHE_TTL"               // duration; 0 disables result caching
+	EnvDeduplicate      = "SEARCH_DEDUPLICATE"             // boolean; whether concurrent identical searches share one scrape
+	EnvDefaultRegion    = "SEARCH_DEFAULT_REGION"          // region used when a query sets none, e.g. "us-en"
+)
+
+// ConfigFromEnv returns base with the settings given in SEARCH_* environment
+// variables applied. Unset variables keep base's value; a malformed or out of
+// range value is an error, so a typo is not silently ignored.
+func ConfigFromEnv(base Config) (Config, error) {
+	config := base
+
+	if value, ok := lookupEnv(EnvTimeout); ok {
+		timeout, err := parsePositiveDuration(EnvTimeout, value)
+		if err != nil {
+			return base, err
+		}
+		config.Timeout = timeout
+	}
+
+	if value, ok := lookupEnv(EnvMaxResults); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return base, fmt.Errorf("invalid %s=%q: expected a positive integer", EnvMaxResults, value)
+		}
+		config.MaxResults = n
+	}
+
+	if value, ok := lookupEnv(EnvMaxPages); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return base, fmt.Errorf("invalid %s=%q: expected a positive integer", EnvMaxPages, value)
+		}
+		config.MaxPages = n
+	}
+
+	if value, ok := lookupEnv(EnvBudget); ok {
+		budget, err := parseDuration(EnvBudget, value)
+		if err != nil {
+			return base, err
+		}
+		config.SearchBudget = budget
+	}
+
+	if value, ok := lookupEnv(EnvMaxURLs); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return base, fmt.Errorf("invalid %s=%q: expected a non-negative integer", EnvMaxURLs, value)
+		}
+		config.MaxSearchURLs = n
+	}
+
+	if value, ok := lookupEnv(EnvMaxPageBytes); ok {
+		n, err := parseByteCount(EnvMaxPageBytes, value)
+		if err != nil {
+			return base, err
+		}
+		config.MaxPageBytes = n
+	}
+
+	if value, ok := lookupEnv(EnvMaxContentBudget); ok {
+		n, err := parseByteCount(EnvMaxContentBudget, value)
+		if err != nil {
+			return base, err
+		}
+		config.MaxTotalContentBytes = n
+	}
+
+	if value, ok := lookupEnv(EnvDelay); ok {
+		delay, err := parseDuration(EnvDelay, value)
+		if err != nil {
+			return base, err
+		}
+		config.Delay = delay
+	}
+
+	if value, ok := lookupEnv(EnvDomainDelays); ok {
+		delays, err := parseDomainDelays(value)
+		if err != nil {
+			return base, err
+		}
+		config.DomainDelays = delays
+	}
+
+	if value, ok := lookupEnv(EnvBlockedDomains); ok {
+		config.BlockedDomains = parseDomainList(value)
+	}
+
+	if value, ok := lookupEnv(EnvCacheTTL); ok {
+		ttl, err := parseDuration(EnvCacheTTL, value)
+		if err != nil {
+			return base, err
+		}
+		config.CacheTTL = ttl
+		config.CacheResults = ttl > 0
+	}
+
+	if value, ok := lookupEnv(EnvDeduplicate); ok {
+		deduplicate, err := strconv.ParseBool(value)
+		if err != nil {
+			return base, fmt.Errorf("invalid %s=%q: expected true or false", EnvDeduplicate, value)
+		}
+		config.DeduplicateSearches = deduplicate
+	}
+
+	if value, ok := lookupEnv(EnvDefaultRegion); ok {
+		config.DefaultRegion = value
+	}
+
+	return config, nil
+}
+
+// lookupEnv returns the trimmed value of key, treating an empty value as unset
+func lookupEnv(key string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(key))
+	return value, value != ""
+}
+
+// parseByteCount parses a non-negative number of bytes
+func parseByteCount(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s=%q: expected a non-negative number of bytes", key, value)
+	}
+	return n, nil
+}
+
+// parseDuration parses a non-negative duration such as "1500ms"
+func parseDuration(key, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s=%q: expected a non-negative duration such as 30s", key, value)
+	}
+	return d, nil
+}
+
+// parsePositiveDuration parses a duration that must be greater than zero
+func parsePositiveDuration(key, value string) (time.Duration, error) {
+	d, err := parseDuration(key, value)
+	if err != nil {
+		return 0, err
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("invalid %s=%q: must be greater than zero", key, value)
+	}
+	return d, nil
+}
+
+// parseDomainDelays parses comma-separated glob=duration pairs
+func parseDomainDelays(value string) (map[string]time.Duration, error) {
+	delays := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		glob, rawDelay, ok := strings.Cut(entry, "=")
+		glob = strings.ToLower(strings.TrimSpace(glob))
+		if !ok || glob == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected glob=duration", EnvDomainDelays, entry)
+		}
+		if err := (&colly.LimitRule{DomainGlob: glob}).Init(); err != nil {
+			return nil, fmt.Errorf("invalid %s glob %q: %v", EnvDomainDelays, glob, err)
+		}
+		delay, err := parseDuration(EnvDomainDelays, strings.TrimSpace(rawDelay))
+		if err != nil {
+			return nil, err
+		}
+		delays[glob] = delay
+	}
+	return delays, nil
+}
+
+// parseDomainList splits a comma-separated domain list, normalizing entries
+// and dropping empty ones; "none" yields an empty list
+func parseDomainList(value string) []string {
+	domains := []string{}
+	if strings.EqualFold(value, "none") {
+		return domains
+	}
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("UnsetKeepsBase", func(t *testing.T) {
+		config, err := ConfigFromEnv(DefaultConfig())
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), config)
+	})
+
+	t.Run("AllSettings", func(t *testing.T) {
+		t.Setenv(EnvTimeout, "20s")
+		t.Setenv(EnvMaxResults, " 25 ")
+		t.Setenv(EnvMaxPages, "3")
+		t.Setenv(EnvBudget, "10s")
+		t.Setenv(EnvMaxURLs, "4")
+		t.Setenv(EnvMaxPageBytes, "65536")
+		t.Setenv(EnvMaxContentBudget, "0")
+		t.Setenv(EnvDelay, "250ms")
+		t.Setenv(EnvDomainDelays, "*.DuckDuckGo.com=100ms, ,startpage.com = 2s")
+		t.Setenv(EnvBlockedDomains, "Example.com, ,ads.test")
+		t.Setenv(EnvCacheTTL, "15m")
+		t.Setenv(EnvDeduplicate, "false")
+		t.Setenv(EnvDefaultRegion, "de-de")
+
+		config, err := ConfigFromEnv(DefaultConfig())
+		require.NoError(t, err)
+		assert.Equal(t, 20*time.Second, config.Timeout)
+		assert.Equal(t, 25, config.MaxResults)
+		assert.Equal(t, 3, config.MaxPages)
+		assert.Equal(t, 10*time.Second, config.SearchBudget)
+		assert.Equal(t, 4, config.MaxSearchURLs)
+		assert.Equal(t, 65536, config.MaxPageBytes)
+		assert.Equal(t, 0, config.MaxTotalContentBytes)
+		assert.Equal(t, 250*time.Millisecond, config.Delay)
+		assert.Equal(t, map[string]time.Duration{
+			"*.duckduckgo.com": 100 * time.Millisecond,
+			"startpage.com":    2 * time.Second,
+		}, config.DomainDelays)
+		assert.Equal(t, []string{"example.com", "ads.test"}, config.BlockedDomains)
+		assert.Equal(t, 15*time.Minute, config.CacheTTL)
+		assert.True(t, config.CacheResults)
+		assert.False(t, config.DeduplicateSearches)
+		assert.Equal(t, "de-de", config.DefaultRegion)
+
+		// Settings without a variable are untouched
+		assert.Equal(t, DefaultConfig().UserAgent, config.UserAgent)
+	})
+
+	t.Run("ZeroCacheTTLDisablesCaching", func(t *testing.T) {
+		t.Setenv(EnvCacheTTL, "0s")
+
+		config, err := ConfigFromEnv(DefaultConfig())
+		require.NoError(t, err)
+		assert.False(t, config.CacheResults)
+	})
+
+	t.Run("NoneClearsBlockedDomains", func(t *testing.T) {
+		t.Setenv(EnvBlockedDomains, "none")
+
+		config, err := ConfigFromEnv(DefaultConfig())
+		require.NoError(t, err)
+		assert.Empty(t, config.BlockedDomains)
+	})
+
+	t.Run("InvalidValues", func(t *testing.T) {
+		invalid := map[string][]string{
+			EnvTimeout:          {"soon", "0s", "-5s"},
+			EnvMaxResults:       {"many", "0", "-3"},
+			EnvMaxPages:         {"all", "0"},
+			EnvBudget:           {"brief", "-2s"},
+			EnvMaxURLs:          {"few", "-1"},
+			EnvMaxPageBytes:     {"1MB", "-1"},
+			EnvMaxContentBudget: {"lots", "-10"},
+			EnvDelay:            {"1 second", "-1s"},
+			EnvDomainDelays:     {"startpage.com", "=1s", "startpage.com=fast", "[bad=1s"},
+			EnvCacheTTL:         {"forever", "-1h"},
+			EnvDeduplicate:      {"sometimes"},
+		}
+		for key, values := range invalid {
+			for _, value := range values {
+				t.Setenv(key, value)
+				_, err := ConfigFromEnv(DefaultConfig())
+				assert.Error(t, err, "%s=%q", key, value)
+				if err != nil {
+					assert.Contains(t, err.Error(), key)
+				}
+			}
+			t.Setenv(key, "")
+		}
+	})
+}
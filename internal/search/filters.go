@@ -0,0 +1,111 @@
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// Supported mcp.SearchQuery.Filters keys. Domain filters are regular expressions
+// matched against the lower-cased result host, unanchored so "golang\.org" also
+// matches "pkg.golang.org"; use ^ and $ to match a host exactly.
+const (
+	// FilterIncludeDomain keeps only results whose host matches the pattern
+	FilterIncludeDomain = "include_domain"
+	// FilterExcludeDomain drops results whose host matches the pattern
+	FilterExcludeDomain = "exclude_domain"
+	// FilterTitleContains keeps only results whose title contains the text, ignoring case
+	FilterTitleContains = "title_contains"
+)
+
+// resultFilter is the compiled form of mcp.SearchQuery.Filters
+type resultFilter struct {
+	includeDomain *regexp.Regexp
+	excludeDomain *regexp.Regexp
+	titleContains string
+}
+
+// newResultFilter compiles filters, rejecting unknown keys and invalid patterns
+func newResultFilter(filters map[string]string) (*resultFilter, error) {
+	f := &resultFilter{}
+
+	// Sort keys so the first reported error does not depend on map order
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := filters[key]
+		switch key {
+		case FilterIncludeDomain, FilterExcludeDomain:
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s filter %q: %w", key, value, err)
+			}
+			if key == FilterIncludeDomain {
+				f.includeDomain = pattern
+			} else {
+				f.excludeDomain = pattern
+			}
+		case FilterTitleContains:
+			f.titleContains = strings.ToLower(value)
+		default:
+			return nil, fmt.Errorf("unsupported filter %q (supported: %s, %s, %s)",
+				key, FilterIncludeDomain, FilterExcludeDomain, FilterTitleContains)
+		}
+	}
+
+	return f, nil
+}
+
+// matches reports whether result passes every configured filter
+func (f *resultFilter) matches(result *mcp.SearchResult) bool {
+	if f.includeDomain != nil || f.excludeDomain != nil {
+		parsedURL, err := url.Parse(result.URL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(parsedURL.Hostname())
+
+		if f.includeDomain != nil && !f.includeDomain.MatchString(host) {
+			return false
+		}
+		if f.excludeDomain != nil && f.excludeDomain.MatchString(host) {
+			return false
+		}
+	}
+
+	if f.titleContains != "" && !strings.Contains(strings.ToLower(result.Title), f.titleContains) {
+		return false
+	}
+
+	return true
+}
+
+// ValidateFilters checks that filters only uses supported keys with valid patterns
+func ValidateFilters(filters map[string]string) error {
+	_, err := newResultFilter(filters)
+	return err
+}
+
+// FilterResults returns the results that pass filters, preserving their order
+func FilterResults(results []*mcp.SearchResult, filters map[string]string) ([]*mcp.SearchResult, error) {
+	f, err := newResultFilter(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*mcp.SearchResult, 0, len(results))
+	for _, result := range results {
+		if f.matches(result) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered, nil
+}
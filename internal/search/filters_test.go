@@ -0,0 +1,96 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterResults(t *testing.T) {
+	results := []*mcp.SearchResult{
+		{Title: "The Go Programming Language", URL: "https://golang.org/doc"},
+		{Title: "Go Packages", URL: "https://pkg.go.dev/std"},
+		{Title: "Effective Go", URL: "https://go.dev/doc/effective_go"},
+		{Title: "Go on Stack Overflow", URL: "https://stackoverflow.com/questions/tagged/go"},
+		{Title: "Golang Blog Mirror", URL: "https://blog.GOLANG.org/"},
+	}
+
+	titles := func(results []*mcp.SearchResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Title)
+		}
+		return out
+	}
+
+	t.Run("NoFilters", func(t *testing.T) {
+		filtered, err := FilterResults(results, nil)
+		require.NoError(t, err)
+		assert.Equal(t, titles(results), titles(filtered))
+	})
+
+	t.Run("IncludeDomain", func(t *testing.T) {
+		filtered, err := FilterResults(results, map[string]string{FilterIncludeDomain: `golang\.org$`})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"The Go Programming Language", "Golang Blog Mirror"}, titles(filtered))
+	})
+
+	t.Run("IncludeDomainAnchored", func(t *testing.T) {
+		filtered, err := FilterResults(results, map[string]string{FilterIncludeDomain: `^go\.dev$`})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Effective Go"}, titles(filtered))
+	})
+
+	t.Run("ExcludeDomain", func(t *testing.T) {
+		filtered, err := FilterResults(results, map[string]string{FilterExcludeDomain: `go\.dev|stackoverflow`})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"The Go Programming Language", "Golang Blog Mirror"}, titles(filtered))
+	})
+
+	t.Run("TitleContains", func(t *testing.T) {
+		filtered, err := FilterResults(results, map[string]string{FilterTitleContains: "GO P"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"The Go Programming Language", "Go Packages"}, titles(filtered))
+	})
+
+	t.Run("Combined", func(t *testing.T) {
+		filtered, err := FilterResults(results, map[string]string{
+			FilterIncludeDomain: `go`,
+			FilterExcludeDomain: `^pkg\.`,
+			FilterTitleContains: "go",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"The Go Programming Language", "Effective Go", "Golang Blog Mirror"}, titles(filtered))
+	})
+
+	t.Run("UnparseableURL", func(t *testing.T) {
+		filtered, err := FilterResults([]*mcp.SearchResult{{Title: "Bad", URL: "://bad"}},
+			map[string]string{FilterExcludeDomain: `example`})
+		require.NoError(t, err)
+		assert.Empty(t, filtered)
+	})
+
+	t.Run("InvalidFilters", func(t *testing.T) {
+		_, err := FilterResults(results, map[string]string{FilterIncludeDomain: `(`})
+		assert.Error(t, err)
+
+		err = ValidateFilters(map[string]string{"language": "en"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported filter")
+	})
+
+	t.Run("MockSearcherAppliesFilters", func(t *testing.T) {
+		searcher := NewMockSearcher(results, nil)
+
+		filtered, err := searcher.Search(context.Background(), mcp.SearchQuery{
+			Query:      "go",
+			MaxResults: 1,
+			Filters:    map[string]string{FilterIncludeDomain: `go\.dev`},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Go Packages"}, titles(filtered))
+	})
+}
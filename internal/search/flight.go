@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// flight is a scrape in progress, shared by every caller searching the same query
+type flight struct {
+	done    chan struct{} // closed once page and err are set
+	page    *mcp.SearchPage
+	err     error
+	waiters int // callers sharing the scrape besides the one running it; guarded by flightGroup.mu
+}
+
+// flightGroup deduplicates concurrent identical searches: while a query is
+// being scraped, other searches for it wait for that scrape instead of
+// starting their own
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// page runs fetch for key unless a fetch for key is already running, in which
+// case it waits for that one and returns a copy of its page. A caller that
+// stops waiting when its own context ends does not affect the others; when the
+// shared fetch fails because the caller running it went away, a waiter whose
+// context is still live runs the fetch itself.
+func (g *flightGroup) page(ctx context.Context, key string, fetch func() (*mcp.SearchPage, error)) (*mcp.SearchPage, error) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		f.waiters++
+		g.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if f.err != nil {
+			if isContextError(f.err) && ctx.Err() == nil {
+				return g.page(ctx, key, fetch)
+			}
+			return nil, f.err
+		}
+		return copyPage(f.page), nil
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.page, f.err = fetch()
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	// Waiters copy f.page, so the caller gets its own copy to modify
+	return copyPage(f.page), nil
+}
+
+// isContextError reports whether err comes from a cancelled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
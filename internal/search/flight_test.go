@@ -0,0 +1,182 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waiting returns how many callers are waiting for the fetch of key
+func (g *flightGroup) waiting(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.waiters
+	}
+	return 0
+}
+
+func TestFlightGroup(t *testing.T) {
+	// blockingFetch returns a fetch that counts its runs and blocks until
+	// release is closed
+	blockingFetch := func(fetches *int32, release chan struct{}, err error) func() (*mcp.SearchPage, error) {
+		return func() (*mcp.SearchPage, error) {
+			n := atomic.AddInt32(fetches, 1)
+			<-release
+			if err != nil {
+				return nil, err
+			}
+			return &mcp.SearchPage{Results: []*mcp.SearchResult{
+				{Title: fmt.Sprintf("result %d", n), URL: "https://example.com"},
+			}}, nil
+		}
+	}
+	key := resultCacheKey(mcp.SearchQuery{Query: "golang"})
+
+	t.Run("ConcurrentSearchesShareOneFetch", func(t *testing.T) {
+		const searches = 8
+		group := newFlightGroup()
+		var fetches int32
+		release := make(chan struct{})
+		fetch := blockingFetch(&fetches, release, nil)
+
+		pages := make([]*mcp.SearchPage, searches)
+		errs := make([]error, searches)
+		var wg sync.WaitGroup
+		for i := 0; i < searches; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				pages[i], errs[i] = group.page(context.Background(), key, fetch)
+			}(i)
+		}
+
+		require.Eventually(t, func() bool { return group.waiting(key) == searches-1 }, 2*time.Second, 5*time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+		for i := range pages {
+			require.NoError(t, errs[i])
+			require.Len(t, pages[i].Results, 1)
+			assert.Equal(t, "result 1", pages[i].Results[0].Title)
+		}
+		// Every caller gets its own copy to modify
+		pages[0].Results[0].Content = "fetched content"
+		assert.Empty(t, pages[1].Results[0].Content)
+
+		// The finished flight is forgotten, so a later search fetches again
+		page, err := group.page(context.Background(), key, func() (*mcp.SearchPage, error) {
+			return &mcp.SearchPage{}, nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, page.Results)
+	})
+
+	t.Run("DifferentQueriesAreNotShared", func(t *testing.T) {
+		group := newFlightGroup()
+		var fetches int32
+		release := make(chan struct{})
+		close(release)
+		fetch := blockingFetch(&fetches, release, nil)
+
+		_, err := group.page(context.Background(), key, fetch)
+		require.NoError(t, err)
+		_, err = group.page(context.Background(), resultCacheKey(mcp.SearchQuery{Query: "golang", Region: "de-de"}), fetch)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+	})
+
+	t.Run("ErrorIsShared", func(t *testing.T) {
+		group := newFlightGroup()
+		var fetches int32
+		release := make(chan struct{})
+		failure := errors.New("engine unavailable")
+		fetch := blockingFetch(&fetches, release, failure)
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := group.page(context.Background(), key, fetch)
+				errs <- err
+			}()
+		}
+		require.Eventually(t, func() bool { return group.waiting(key) == 1 }, 2*time.Second, 5*time.Millisecond)
+		close(release)
+
+		assert.ErrorIs(t, <-errs, failure)
+		assert.ErrorIs(t, <-errs, failure)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+
+	t.Run("WaiterContext", func(t *testing.T) {
+		group := newFlightGroup()
+		var fetches int32
+		release := make(chan struct{})
+		fetch := blockingFetch(&fetches, release, nil)
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := group.page(context.Background(), key, fetch)
+			done <- err
+		}()
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+		// A waiter that gives up does not disturb the running fetch
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := group.page(ctx, key, fetch)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+
+	t.Run("RetriesWhenTheFetchingCallerGoesAway", func(t *testing.T) {
+		group := newFlightGroup()
+		release := make(chan struct{})
+		leader := func() (*mcp.SearchPage, error) {
+			<-release
+			return nil, context.Canceled
+		}
+		go group.page(context.Background(), key, leader)
+		require.Eventually(t, func() bool {
+			group.mu.Lock()
+			defer group.mu.Unlock()
+			return group.flights[key] != nil
+		}, 2*time.Second, 5*time.Millisecond)
+
+		result := make(chan *mcp.SearchPage, 1)
+		go func() {
+			page, err := group.page(context.Background(), key, func() (*mcp.SearchPage, error) {
+				return &mcp.SearchPage{Results: []*mcp.SearchResult{{Title: "retried"}}}, nil
+			})
+			assert.NoError(t, err)
+			result <- page
+		}()
+		require.Eventually(t, func() bool { return group.waiting(key) == 1 }, 2*time.Second, 5*time.Millisecond)
+		close(release)
+
+		page := <-result
+		require.Len(t, page.Results, 1)
+		assert.Equal(t, "retried", page.Results[0].Title)
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		assert.True(t, DefaultConfig().DeduplicateSearches)
+		assert.NotNil(t, NewCollySearcher(DefaultConfig()).flights)
+
+		config := DefaultConfig()
+		config.DeduplicateSearches = false
+		assert.Nil(t, NewCollySearcher(config).flights)
+	})
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// PagedSearcher is implemented by searchers that can return later pages of
+// results along with a token for the page after them
+type PagedSearcher interface {
+	SearchPage(ctx context.Context, query mcp.SearchQuery) (*mcp.SearchPage, error)
+}
+
+// errPageTokenMismatch is returned for a page token of another query
+var errPageTokenMismatch = errors.New("page token belongs to a different query")
+
+// EncodePageToken turns a result offset into the opaque token handed to
+// clients. The token carries a hash of query so that it is only accepted for
+// the same search.
+func EncodePageToken(query mcp.SearchQuery, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset) + ":" + queryHash(query)))
+}
+
+// DecodePageToken parses a token produced by EncodePageToken for query; an
+// empty token is offset 0
+func DecodePageToken(query mcp.SearchQuery, token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("malformed page token: %w", err)
+	}
+	rawOffset, hash, ok := strings.Cut(string(raw), ":")
+	offset, err := strconv.Atoi(rawOffset)
+	if !ok || err != nil || offset < 0 {
+		return 0, fmt.Errorf("malformed page token %q", token)
+	}
+	if hash != queryHash(query) {
+		return 0, errPageTokenMismatch
+	}
+	return offset, nil
+}
+
+// queryHash identifies the results query pages through. The page position and
+// size and whether the cache is used do not change them.
+func queryHash(query mcp.SearchQuery) string {
+	query.Offset = 0
+	query.MaxResults = 0
+	sum := sha256.Sum256([]byte(resultCacheKey(query)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// pageOf returns the results of results starting at offset, at most limit of
+// them, and the token for the following page of query when results holds more
+func pageOf(query mcp.SearchQuery, results []*mcp.SearchResult, offset, limit int) *mcp.SearchPage {
+	if offset < 0 {
+		offset = 0
+	}
+	page := &mcp.SearchPage{Results: []*mcp.SearchResult{}}
+	if offset < len(results) {
+		end := offset + limit
+		if end > len(results) {
+			end = len(results)
+		}
+		page.Results = results[offset:end]
+	}
+	if len(results) > offset+limit {
+		page.NextPage = EncodePageToken(query, offset+limit)
+	}
+	return page
+}
@@ -2,10 +2,19 @@ package search
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 	"github.com/gocolly/colly/v2"
@@ -21,6 +30,13 @@ type WebSearcher interface {
 // CollySearcher implements WebSearcher using Colly web scraper
 type CollySearcher struct {
 	config Config
+
+	// domainsMu guards config.AllowedDomains and config.BlockedDomains, which can
+	// be changed at runtime
+	domainsMu sync.RWMutex
+
+	cache   *resultCache // nil when results are not cached
+	flights *flightGroup // nil when concurrent identical searches are not deduplicated
 }
 
 // Config holds search configuration
@@ -29,13 +45,45 @@ type Config struct {
 	Timeout         time.Duration `json:"timeout"`
 	Delay           time.Duration `json:"delay"`
 	RandomDelay     time.Duration `json:"random_delay"`
+	// DomainDelays overrides Delay for the domains matching each glob, such as
+	// "*.duckduckgo.com", without random delay; other domains keep Delay and
+	// RandomDelay. When several globs match, the longest wins.
+	DomainDelays map[string]time.Duration `json:"domain_delays,omitempty"`
 	MaxDepth        int           `json:"max_depth"`
 	MaxResults      int           `json:"max_results"`
+	MaxPages        int           `json:"max_pages"` // result pages scraped per engine to reach a query's offset and limit
+	// SearchBudget caps the time one search spends on engine requests. What is
+	// left of it is divided among the engine URLs about to be visited, and once
+	// it runs out the results gathered so far are returned; 0 means no budget.
+	SearchBudget    time.Duration `json:"search_budget"`
+	MaxSearchURLs   int           `json:"max_search_urls"` // engine URLs visited per search, across pages; 0 means no cap
 	EnableDebug     bool          `json:"enable_debug"`
 	AllowedDomains  []string      `json:"allowed_domains"`
 	BlockedDomains  []string      `json:"blocked_domains"`
 	CacheResults    bool          `json:"cache_results"`
 	CacheTTL        time.Duration `json:"cache_ttl"`
+	// DeduplicateSearches makes searches for a query that is already being
+	// scraped wait for that scrape and share its results, rather than hitting
+	// the engines again. Queries are compared as by the result cache.
+	DeduplicateSearches bool          `json:"deduplicate_searches"`
+	MaxContentBytes int           `json:"max_content_bytes"` // limit for text extracted from a result page
+	ContentTimeout  time.Duration `json:"content_timeout"`   // request timeout when fetching a result page; 0 uses Timeout
+	// MaxPageBytes caps the bytes of each result page body read when fetching
+	// content, and MaxTotalContentBytes the bytes read across all pages of one
+	// SearchWithContent call. Once the total is reached the remaining results
+	// are left without content. 0 disables the total limit and leaves pages
+	// to colly's default limit of 10MB.
+	MaxPageBytes         int `json:"max_page_bytes"`
+	MaxTotalContentBytes int `json:"max_total_content_bytes"`
+
+	MaxDescriptionBytes int `json:"max_description_bytes"` // limit for result descriptions; 0 means unlimited
+
+	// DefaultRegion is used for queries that do not set a region, so a
+	// deployment can favor local results (e.g. "us-en", "de-de"); empty means global
+	DefaultRegion string `json:"default_region"`
+
+	HealthCheckURL     string        `json:"health_check_url"`     // endpoint probed by HealthCheck
+	HealthCheckTimeout time.Duration `json:"health_check_timeout"` // timeout for the probe; 0 uses Timeout
 }
 
 // DefaultConfig returns a default search configuration
@@ -47,6 +95,8 @@ func DefaultConfig() Config {
 		RandomDelay:    500 * time.Millisecond,
 		MaxDepth:       2,
 		MaxResults:     10,
+		MaxPages:       5,
+		SearchBudget:   20 * time.Second,
 		EnableDebug:    false,
 		AllowedDomains: []string{},
 		BlockedDomains: []string{
@@ -55,29 +105,193 @@ func DefaultConfig() Config {
 			"instagram.com",
 			"tiktok.com",
 		},
-		CacheResults: true,
-		CacheTTL:     1 * time.Hour,
+		CacheResults:    true,
+		CacheTTL:        1 * time.Hour,
+
+		DeduplicateSearches: true,
+
+		MaxContentBytes: 5000,
+		ContentTimeout:  15 * time.Second,
+
+		MaxPageBytes:         2 << 20,
+		MaxTotalContentBytes: 8 << 20,
+
+		MaxDescriptionBytes: 200,
+
+		HealthCheckURL:     "https://html.duckduckgo.com/",
+		HealthCheckTimeout: 5 * time.Second,
 	}
 }
 
-// NewCollySearcher creates a new CollySearcher
+// NewCollySearcher creates a new CollySearcher. Result pages are cached for
+// CacheTTL when CacheResults is set, and concurrent identical searches share
+// one scrape when DeduplicateSearches is set.
 func NewCollySearcher(config Config) *CollySearcher {
-	return &CollySearcher{
+	s := &CollySearcher{
 		config: config,
 	}
+	if config.CacheResults && config.CacheTTL > 0 {
+		s.cache = newResultCache(config.CacheTTL)
+	}
+	if config.DeduplicateSearches {
+		s.flights = newFlightGroup()
+	}
+	return s
 }
 
 // Search performs a web search using the provided query
 func (s *CollySearcher) Search(ctx context.Context, query mcp.SearchQuery) ([]*mcp.SearchResult, error) {
+	page, err := s.SearchPage(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+// SearchPage performs a web search and returns the results from query.Offset
+// on, with a token for the next page when more results are available. When
+// the first page of engine results does not hold enough, the following pages
+// are scraped, up to MaxPages. Cached pages are served unless query.NoCache
+// is set, which scrapes afresh and replaces the cached page. Searches for the
+// same query made while it is being scraped share that scrape.
+func (s *CollySearcher) SearchPage(ctx context.Context, query mcp.SearchQuery) (*mcp.SearchPage, error) {
+	fetch := func() (*mcp.SearchPage, error) {
+		return s.searchPages(ctx, query, func(page int) []string {
+			return s.buildSearchURLs(query, page)
+		})
+	}
+	if s.flights != nil {
+		scrape := fetch
+		fetch = func() (*mcp.SearchPage, error) {
+			return s.flights.page(ctx, resultCacheKey(query), scrape)
+		}
+	}
+	if s.cache == nil {
+		return fetch()
+	}
+	return s.cache.page(query, fetch)
+}
+
+// ClearCache evicts the cached results of query, or every cached result when
+// query is empty, and returns how many pages were evicted
+func (s *CollySearcher) ClearCache(query string) int {
+	if s.cache == nil {
+		return 0
+	}
+	return s.cache.clear(query)
+}
+
+// searchPages scrapes the result pages returned by pageURLs, in order, until
+// it has the results up to the query's offset and limit. It stops early, with
+// the results it has, when MaxSearchURLs engine URLs were visited or the
+// SearchBudget ran out.
+func (s *CollySearcher) searchPages(ctx context.Context, query mcp.SearchQuery, pageURLs func(page int) []string) (*mcp.SearchPage, error) {
+	limit := s.getMaxResults(query.MaxResults)
+	offset := query.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	// One result beyond the requested ones tells whether there is a next page
+	wanted := offset + limit + 1
+
+	maxPages := s.config.MaxPages
+	if maxPages < 1 {
+		maxPages = 1
+	}
+
+	var deadline time.Time
+	if s.config.SearchBudget > 0 {
+		deadline = time.Now().Add(s.config.SearchBudget)
+	}
+
+	seen := make(map[string]bool)
+	visited := 0
+	var results []*mcp.SearchResult
+	for page := 0; page < maxPages && len(results) < wanted; page++ {
+		urls := pageURLs(page)
+		if max := s.config.MaxSearchURLs; max > 0 && len(urls) > max-visited {
+			urls = urls[:max-visited]
+		}
+		if len(urls) == 0 {
+			break
+		}
+		timeout, ok := s.engineTimeout(deadline, len(urls))
+		if !ok {
+			break
+		}
+		visited += len(urls)
+
+		pageResults, err := s.searchURLs(ctx, query, urls, wanted-len(results), seen, timeout)
+		if err != nil {
+			// Running out of budget is not a failure: the search ends with what it has
+			if page == 0 && !(timeout < s.config.Timeout && isTimeout(err)) {
+				return nil, err
+			}
+			// A failing later page only ends pagination early
+			break
+		}
+		if len(pageResults) == 0 {
+			break
+		}
+		results = append(results, pageResults...)
+	}
+
+	return pageOf(query, results, offset, limit), nil
+}
+
+// engineTimeout returns the request timeout for each of n engine URLs: Timeout,
+// or an equal share of what is left of the search budget when that is less. It
+// reports false once the budget is spent.
+func (s *CollySearcher) engineTimeout(deadline time.Time, n int) (time.Duration, bool) {
+	timeout := s.config.Timeout
+	if deadline.IsZero() {
+		return timeout, true
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return 0, false
+	}
+	if share := remaining / time.Duration(n); timeout <= 0 || share < timeout {
+		timeout = share
+	}
+	return timeout, true
+}
+
+// isTimeout reports whether err is a request that timed out
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// searchURLs scrapes up to limit result links from the given search pages,
+// skipping links already in seen and adding the ones it returns. Each request
+// times out after timeout, or Timeout when it is 0.
+func (s *CollySearcher) searchURLs(ctx context.Context, query mcp.SearchQuery, searchURLs []string, limit int, seen map[string]bool, timeout time.Duration) ([]*mcp.SearchResult, error) {
+	filter, err := newResultFilter(query.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+
 	// Create a new collector for this search
 	c := s.createCollector()
+	if timeout > 0 {
+		c.SetRequestTimeout(timeout)
+	}
 
+	// The collector is asynchronous, so callbacks may run concurrently
+	var mu sync.Mutex
 	var results []*mcp.SearchResult
 	var searchErrors []error
 
 	// Configure the collector to extract search results
 	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		if len(results) >= s.getMaxResults(query.MaxResults) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(results) >= limit {
 			return
 		}
 
@@ -89,8 +303,8 @@ func (s *CollySearcher) Search(ctx context.Context, query mcp.SearchQuery) ([]*m
 			return
 		}
 
-		// Skip blocked domains
-		if s.isBlockedDomain(link) {
+		// Skip blocked domains, and links an earlier page or engine returned
+		if s.isBlockedDomain(link) || seen[link] {
 			return
 		}
 
@@ -108,31 +322,36 @@ func (s *CollySearcher) Search(ctx context.Context, query mcp.SearchQuery) ([]*m
 			},
 		}
 
+		if !filter.matches(result) {
+			return
+		}
+
+		seen[link] = true
 		results = append(results, result)
 	})
 
 	// Error handling
 	c.OnError(func(r *colly.Response, err error) {
+		mu.Lock()
+		defer mu.Unlock()
 		searchErrors = append(searchErrors, fmt.Errorf("request to %s failed: %w", r.Request.URL, err))
 	})
 
 	// Start searching with multiple search engines/strategies
-	searchURLs := s.buildSearchURLs(query)
-	
 	for _, searchURL := range searchURLs {
-		if len(results) >= s.getMaxResults(query.MaxResults) {
-			break
-		}
-		
 		select {
 		case <-ctx.Done():
+			c.Wait()
 			return results, ctx.Err()
 		default:
 			if err := c.Visit(searchURL); err != nil {
+				mu.Lock()
 				searchErrors = append(searchErrors, fmt.Errorf("failed to visit %s: %w", searchURL, err))
+				mu.Unlock()
 			}
 		}
 	}
+	c.Wait()
 
 	// If we have results, return them even if there were some errors
 	if len(results) > 0 {
@@ -153,34 +372,79 @@ func (s *CollySearcher) SearchWithContent(ctx context.Context, query mcp.SearchQ
 	if err != nil {
 		return nil, err
 	}
+	return results, s.AddContent(ctx, results)
+}
 
-	// Fetch content for each result
+// AddContent fetches the content and preview image of each result's page, in
+// order, until MaxTotalContentBytes have been read; results after that keep
+// no content
+func (s *CollySearcher) AddContent(ctx context.Context, results []*mcp.SearchResult) error {
+	budget := s.config.MaxTotalContentBytes
+	fetched := 0
 	for _, result := range results {
+		if budget > 0 && fetched >= budget {
+			break
+		}
 		select {
 		case <-ctx.Done():
-			return results, ctx.Err()
+			return ctx.Err()
 		default:
-			content, err := s.extractContent(ctx, result.URL)
+			limit := s.config.MaxPageBytes
+			if remaining := budget - fetched; budget > 0 && (limit <= 0 || remaining < limit) {
+				limit = remaining
+			}
+			p, err := s.fetchPage(ctx, result.URL, limit)
 			if err == nil {
-				result.Content = content
+				result.Content = p.Content
+				result.ImageURL = p.ImageURL
+				fetched += p.Size
 			}
 			// Continue even if content extraction fails
 		}
 	}
-
-	return results, nil
+	return nil
 }
 
-// HealthCheck verifies that the search service is working
+// HealthCheck verifies that the search endpoint is reachable. It sends a single
+// HEAD request to HealthCheckURL rather than running a search, so it is cheap
+// enough for readiness probes and does not count against engine rate limits.
 func (s *CollySearcher) HealthCheck(ctx context.Context) error {
-	// Simple connectivity test
-	query := mcp.SearchQuery{
-		Query:      "test",
-		MaxResults: 1,
+	timeout := s.config.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = s.config.Timeout
 	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	_, err := s.Search(ctx, query)
-	return err
+	status, err := s.probe(ctx, http.MethodHead)
+	if err == nil && status == http.StatusMethodNotAllowed {
+		status, err = s.probe(ctx, http.MethodGet)
+	}
+	if err != nil {
+		return fmt.Errorf("search endpoint unreachable: %w", err)
+	}
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("search endpoint %s returned status %d", s.config.HealthCheckURL, status)
+	}
+	return nil
+}
+
+// probe requests HealthCheckURL and returns the response status
+func (s *CollySearcher) probe(ctx context.Context, method string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.config.HealthCheckURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", s.config.UserAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	return resp.StatusCode, nil
 }
 
 // Helper methods
@@ -191,12 +455,9 @@ func (s *CollySearcher) createCollector() *colly.Collector {
 	)
 
 	// Configure collector
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: 2,
-		Delay:       s.config.Delay,
-		RandomDelay: s.config.RandomDelay,
-	})
+	if err := c.Limits(s.limitRules()); err != nil {
+		log.Printf("Invalid search delay configuration: %v", err)
+	}
 
 	c.SetRequestTimeout(s.config.Timeout)
 	c.UserAgent = s.config.UserAgent
@@ -206,8 +467,8 @@ func (s *CollySearcher) createCollector() *colly.Collector {
 	}
 
 	// Set allowed/blocked domains
-	if len(s.config.AllowedDomains) > 0 {
-		c.AllowedDomains = s.config.AllowedDomains
+	if allowed := s.AllowedDomains(); len(allowed) > 0 {
+		c.AllowedDomains = allowed
 	}
 
 	c.OnRequest(func(r *colly.Request) {
@@ -223,14 +484,55 @@ func (s *CollySearcher) createCollector() *colly.Collector {
 	return c
 }
 
-func (s *CollySearcher) buildSearchURLs(query mcp.SearchQuery) []string {
-	encodedQuery := url.QueryEscape(query.Query)
+// duckDuckGoPageSize is the number of results per DuckDuckGo HTML page, which
+// is paged by result offset
+const duckDuckGoPageSize = 30
+
+// buildSearchURLs returns the engine URLs of the given result page, counting from 0
+// limitRules returns the collector's rate limits: one rule per DomainDelays
+// override, then the global delay for every other domain. The collector applies
+// the first rule matching a domain, so more specific (longer) globs go first.
+func (s *CollySearcher) limitRules() []*colly.LimitRule {
+	globs := make([]string, 0, len(s.config.DomainDelays))
+	for glob := range s.config.DomainDelays {
+		globs = append(globs, glob)
+	}
+	sort.Slice(globs, func(i, j int) bool {
+		if len(globs[i]) != len(globs[j]) {
+			return len(globs[i]) > len(globs[j])
+		}
+		return globs[i] < globs[j]
+	})
+
+	rules := make([]*colly.LimitRule, 0, len(globs)+1)
+	for _, glob := range globs {
+		rules = append(rules, &colly.LimitRule{
+			DomainGlob:  glob,
+			Parallelism: 2,
+			Delay:       s.config.DomainDelays[glob],
+		})
+	}
+	return append(rules, &colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: 2,
+		Delay:       s.config.Delay,
+		RandomDelay: s.config.RandomDelay,
+	})
+}
+
+func (s *CollySearcher) buildSearchURLs(query mcp.SearchQuery, page int) []string {
+	// Spaces are sent as %20, which every engine decodes, rather than +
+	encodedQuery := strings.ReplaceAll(url.QueryEscape(query.Query), "+", "%20")
 	var urls []string
 
 	// DuckDuckGo (respects robots.txt and privacy-friendly)
 	duckURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", encodedQuery)
-	if query.Region != "" {
-		duckURL += "&kl=" + query.Region
+	if region := s.region(query); region != "" {
+		duckURL += "&kl=" + url.QueryEscape(region)
+	}
+	if page > 0 {
+		offset := page * duckDuckGoPageSize
+		duckURL += fmt.Sprintf("&s=%d&dc=%d", offset, offset+1)
 	}
 	urls = append(urls, duckURL)
 
@@ -239,11 +541,22 @@ func (s *CollySearcher) buildSearchURLs(query mcp.SearchQuery) []string {
 	if query.Language != "" {
 		startpageURL += "&language=" + query.Language
 	}
+	if page > 0 {
+		startpageURL += fmt.Sprintf("&page=%d", page+1)
+	}
 	urls = append(urls, startpageURL)
 
 	return urls
 }
 
+// region returns the query's region, falling back to the configured default
+func (s *CollySearcher) region(query mcp.SearchQuery) string {
+	if query.Region != "" {
+		return query.Region
+	}
+	return s.config.DefaultRegion
+}
+
 func (s *CollySearcher) getMaxResults(queryMax int) int {
 	if queryMax > 0 && queryMax < s.config.MaxResults {
 		return queryMax
@@ -257,7 +570,15 @@ func (s *CollySearcher) isBlockedDomain(link string) bool {
 		return true
 	}
 
-	hostname := parsedURL.Hostname()
+	hostname := strings.ToLower(parsedURL.Hostname())
+	if hostname == "" {
+		// Not an absolute URL, so there is no domain to vet
+		return true
+	}
+
+	s.domainsMu.RLock()
+	defer s.domainsMu.RUnlock()
+
 	for _, blocked := range s.config.BlockedDomains {
 		if strings.Contains(hostname, blocked) {
 			return true
@@ -266,7 +587,85 @@ func (s *CollySearcher) isBlockedDomain(link string) bool {
 	return false
 }
 
+// AddBlockedDomain blocks results from domain (and its subdomains) in subsequent searches
+func (s *CollySearcher) AddBlockedDomain(domain string) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return
+	}
+
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+
+	for _, blocked := range s.config.BlockedDomains {
+		if blocked == domain {
+			return
+		}
+	}
+
+	// Copy so the caller's Config slice is never modified
+	updated := make([]string, 0, len(s.config.BlockedDomains)+1)
+	updated = append(updated, s.config.BlockedDomains...)
+	s.config.BlockedDomains = append(updated, domain)
+	// Cached results may come from the newly blocked domain
+	s.ClearCache("")
+}
+
+// RemoveBlockedDomain stops blocking domain; it is a no-op if domain is not blocked
+func (s *CollySearcher) RemoveBlockedDomain(domain string) {
+	domain = normalizeDomain(domain)
+
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+
+	updated := make([]string, 0, len(s.config.BlockedDomains))
+	for _, blocked := range s.config.BlockedDomains {
+		if blocked != domain {
+			updated = append(updated, blocked)
+		}
+	}
+	s.config.BlockedDomains = updated
+}
+
+// SetAllowedDomains replaces the domains the collector may visit; an empty list allows all
+func (s *CollySearcher) SetAllowedDomains(domains []string) {
+	allowed := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain = normalizeDomain(domain); domain != "" {
+			allowed = append(allowed, domain)
+		}
+	}
+
+	s.domainsMu.Lock()
+	defer s.domainsMu.Unlock()
+	s.config.AllowedDomains = allowed
+	s.ClearCache("")
+}
+
+// BlockedDomains returns a copy of the currently blocked domains
+func (s *CollySearcher) BlockedDomains() []string {
+	s.domainsMu.RLock()
+	defer s.domainsMu.RUnlock()
+	return append([]string(nil), s.config.BlockedDomains...)
+}
+
+// AllowedDomains returns a copy of the currently allowed domains
+func (s *CollySearcher) AllowedDomains() []string {
+	s.domainsMu.RLock()
+	defer s.domainsMu.RUnlock()
+	return append([]string(nil), s.config.AllowedDomains...)
+}
+
+// normalizeDomain lower-cases and trims a domain for comparison
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
 func (s *CollySearcher) extractDescription(e *colly.HTMLElement) string {
+	if e == nil || e.DOM == nil {
+		return ""
+	}
+
 	// Try to find description in nearby elements
 	description := ""
 	
@@ -282,20 +681,78 @@ func (s *CollySearcher) extractDescription(e *colly.HTMLElement) string {
 		}
 	}
 
-	// Limit description length
-	if len(description) > 200 {
-		description = description[:200] + "..."
+	return truncateDescription(description, s.config.MaxDescriptionBytes)
+}
+
+// truncateDescription limits text to maxBytes without splitting a rune,
+// preferring to cut at a word boundary near the limit and marking the cut
+// with "…"; maxBytes <= 0 disables the limit
+func truncateDescription(text string, maxBytes int) string {
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
 	}
 
-	return description
+	// Back up to the last space if that only loses the final fifth of the text
+	if space := strings.LastIndexAny(text[:cut+1], " \t\n"); space > 0 && space >= cut*4/5 {
+		cut = space
+	}
+
+	return strings.TrimRightFunc(text[:cut], unicode.IsSpace) + "…"
+}
+
+// page holds what is extracted from a fetched result page
+type page struct {
+	Content  string
+	ImageURL string // og:image, falling back to the favicon
+	Size     int    // bytes of the page body read
 }
 
 func (s *CollySearcher) extractContent(ctx context.Context, url string) (string, error) {
+	p, err := s.fetchPage(ctx, url, s.config.MaxPageBytes)
+	if err != nil {
+		return "", err
+	}
+	return p.Content, nil
+}
+
+// fetchPage visits url and extracts its main text and a representative image.
+// At most maxBytes of the page body are read, the rest being ignored; 0 keeps
+// colly's default limit.
+func (s *CollySearcher) fetchPage(ctx context.Context, url string, maxBytes int) (*page, error) {
 	c := s.createCollector()
-	
+	c.SetRequestTimeout(s.contentTimeout(ctx))
+	if maxBytes > 0 {
+		c.MaxBodySize = maxBytes
+	}
+
 	var content strings.Builder
+	var ogImage, favicon string
+	var size int
 	var extractionError error
 
+	c.OnResponse(func(r *colly.Response) {
+		size += len(r.Body)
+	})
+
+	c.OnHTML("head", func(e *colly.HTMLElement) {
+		if image := e.ChildAttr(`meta[property="og:image"]`, "content"); image != "" {
+			ogImage = e.Request.AbsoluteURL(image)
+		}
+		e.ForEach("link[rel]", func(_ int, el *colly.HTMLElement) {
+			if favicon != "" || !isIconRel(el.Attr("rel")) {
+				return
+			}
+			if href := el.Attr("href"); href != "" {
+				favicon = el.Request.AbsoluteURL(href)
+			}
+		})
+	})
+
 	c.OnHTML("body", func(e *colly.HTMLElement) {
 		// Extract main content, avoiding navigation and ads
 		e.ForEach("p, article, main, .content, .post-content, .entry-content", func(_ int, el *colly.HTMLElement) {
@@ -312,20 +769,89 @@ func (s *CollySearcher) extractContent(ctx context.Context, url string) (string,
 	})
 
 	if err := c.Visit(url); err != nil {
-		return "", err
+		return nil, err
 	}
+	c.Wait()
 
 	if extractionError != nil {
-		return "", extractionError
+		return nil, extractionError
 	}
 
-	result := content.String()
-	// Limit content length
-	if len(result) > 5000 {
-		result = result[:5000] + "..."
+	p := &page{
+		Content:  truncateContent(normalizeWhitespace(content.String()), s.config.MaxContentBytes),
+		ImageURL: ogImage,
+		Size:     size,
+	}
+	if p.ImageURL == "" {
+		p.ImageURL = favicon
 	}
+	return p, nil
+}
 
-	return strings.TrimSpace(result), nil
+// isIconRel reports whether a link rel attribute names a favicon
+func isIconRel(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		if value == "icon" || value == "apple-touch-icon" {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchContent returns the main text of the page at url, normalized and limited
+// to MaxContentBytes like the content attached to search results
+func (s *CollySearcher) FetchContent(ctx context.Context, url string) (string, error) {
+	return s.extractContent(ctx, url)
+}
+
+// contentTimeout returns the request timeout for fetching a result page, capped
+// by the context deadline
+func (s *CollySearcher) contentTimeout(ctx context.Context) time.Duration {
+	timeout := s.config.ContentTimeout
+	if timeout <= 0 {
+		timeout = s.config.Timeout
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return timeout
+}
+
+// normalizeWhitespace collapses runs of spaces and tabs to a single space and
+// blank-line runs to a single paragraph break
+func normalizeWhitespace(text string) string {
+	lines := strings.Split(text, "\n")
+	var out []string
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// truncateContent limits text to maxBytes, cutting on a UTF-8 boundary and
+// marking the cut with "..."; maxBytes <= 0 disables the limit
+func truncateContent(text string, maxBytes int) string {
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
 }
 
 // MockSearcher implements WebSearcher for testing
@@ -344,16 +870,30 @@ func NewMockSearcher(results []*mcp.SearchResult, err error) *MockSearcher {
 
 // Search returns the mock results
 func (m *MockSearcher) Search(ctx context.Context, query mcp.SearchQuery) ([]*mcp.SearchResult, error) {
+	page, err := m.SearchPage(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+// SearchPage returns the page of mock results starting at query.Offset
+func (m *MockSearcher) SearchPage(ctx context.Context, query mcp.SearchQuery) (*mcp.SearchPage, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+
+	results, err := FilterResults(m.results, query.Filters)
+	if err != nil {
+		return nil, err
+	}
 	
-	maxResults := len(m.results)
+	maxResults := len(results)
 	if query.MaxResults > 0 && query.MaxResults < maxResults {
 		maxResults = query.MaxResults
 	}
 	
-	return m.results[:maxResults], nil
+	return pageOf(query, results, query.Offset, maxResults), nil
 }
 
 // HealthCheck always returns nil for the mock
@@ -2,9 +2,17 @@ package search
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gocolly/colly/v2"
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,8 +25,15 @@ func TestCollySearcher_Unit(t *testing.T) {
 		assert.Equal(t, 30*time.Second, config.Timeout)
 		assert.Equal(t, 1*time.Second, config.Delay)
 		assert.Equal(t, 10, config.MaxResults)
+		assert.Equal(t, 5, config.MaxPages)
+		assert.Equal(t, 20*time.Second, config.SearchBudget)
+		assert.Zero(t, config.MaxSearchURLs)
 		assert.True(t, config.CacheResults)
 		assert.Contains(t, config.BlockedDomains, "facebook.com")
+		assert.Equal(t, 5000, config.MaxContentBytes)
+		assert.Equal(t, 2<<20, config.MaxPageBytes)
+		assert.Equal(t, 8<<20, config.MaxTotalContentBytes)
+		assert.Equal(t, 200, config.MaxDescriptionBytes)
 	})
 
 	t.Run("NewCollySearcher", func(t *testing.T) {
@@ -35,7 +50,7 @@ func TestCollySearcher_Unit(t *testing.T) {
 			Region: "us",
 		}
 
-		urls := searcher.buildSearchURLs(query)
+		urls := searcher.buildSearchURLs(query, 0)
 		assert.Greater(t, len(urls), 0)
 		
 		for _, url := range urls {
@@ -43,6 +58,23 @@ func TestCollySearcher_Unit(t *testing.T) {
 		}
 	})
 
+	t.Run("BuildSearchURLs_DefaultRegion", func(t *testing.T) {
+		config := DefaultConfig()
+		config.DefaultRegion = "de-de"
+		searcher := NewCollySearcher(config)
+
+		// The default applies only when the query sets no region
+		urls := searcher.buildSearchURLs(mcp.SearchQuery{Query: "golang"}, 0)
+		assert.Contains(t, urls[0], "&kl=de-de")
+
+		urls = searcher.buildSearchURLs(mcp.SearchQuery{Query: "golang", Region: "us-en"}, 0)
+		assert.Contains(t, urls[0], "&kl=us-en")
+		assert.NotContains(t, urls[0], "de-de")
+
+		urls = NewCollySearcher(DefaultConfig()).buildSearchURLs(mcp.SearchQuery{Query: "golang"}, 0)
+		assert.NotContains(t, urls[0], "kl=")
+	})
+
 	t.Run("GetMaxResults", func(t *testing.T) {
 		config := DefaultConfig()
 		config.MaxResults = 20
@@ -85,6 +117,550 @@ func TestCollySearcher_Unit(t *testing.T) {
 	})
 }
 
+func TestCollySearcher_DomainUpdates(t *testing.T) {
+	t.Run("AddAndRemoveBlockedDomain", func(t *testing.T) {
+		searcher := NewCollySearcher(DefaultConfig())
+		assert.False(t, searcher.isBlockedDomain("https://spam.example.com/offer"))
+
+		searcher.AddBlockedDomain(" Spam.Example.com ")
+		searcher.AddBlockedDomain("spam.example.com")
+		assert.True(t, searcher.isBlockedDomain("https://spam.example.com/offer"))
+		assert.True(t, searcher.isBlockedDomain("https://www.spam.example.com/offer"))
+
+		blocked := searcher.BlockedDomains()
+		count := 0
+		for _, domain := range blocked {
+			if domain == "spam.example.com" {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count, "duplicate domains should not be added")
+
+		searcher.RemoveBlockedDomain("spam.example.com")
+		assert.False(t, searcher.isBlockedDomain("https://spam.example.com/offer"))
+		assert.True(t, searcher.isBlockedDomain("https://facebook.com/page"))
+	})
+
+	t.Run("DoesNotModifyCallerConfig", func(t *testing.T) {
+		config := DefaultConfig()
+		original := append([]string(nil), config.BlockedDomains...)
+
+		searcher := NewCollySearcher(config)
+		searcher.AddBlockedDomain("spam.example.com")
+		searcher.RemoveBlockedDomain("facebook.com")
+
+		assert.Equal(t, original, config.BlockedDomains)
+	})
+
+	t.Run("SetAllowedDomains", func(t *testing.T) {
+		searcher := NewCollySearcher(DefaultConfig())
+		searcher.SetAllowedDomains([]string{"Golang.org", " ", "go.dev"})
+		assert.Equal(t, []string{"golang.org", "go.dev"}, searcher.AllowedDomains())
+
+		searcher.SetAllowedDomains(nil)
+		assert.Empty(t, searcher.AllowedDomains())
+	})
+
+	t.Run("RuntimeBlockFiltersResults", func(t *testing.T) {
+		page := `<html><body>
+			<div><a href="https://good.example.org/article">Good article</a><p>Useful</p></div>
+			<div><a href="https://spam.example.com/offer">Spam offer</a><p>Buy now</p></div>
+		</body></html>`
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(page))
+		}))
+		defer ts.Close()
+
+		config := DefaultConfig()
+		config.Delay = 0
+		config.RandomDelay = 0
+		searcher := NewCollySearcher(config)
+		query := mcp.SearchQuery{Query: "offers"}
+
+		urls := func(results []*mcp.SearchResult) []string {
+			var out []string
+			for _, r := range results {
+				out = append(out, r.URL)
+			}
+			return out
+		}
+
+		results, err := searcher.searchURLs(context.Background(), query, []string{ts.URL}, 10, nil, 0)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"https://good.example.org/article", "https://spam.example.com/offer"}, urls(results))
+
+		searcher.AddBlockedDomain("spam.example.com")
+
+		results, err = searcher.searchURLs(context.Background(), query, []string{ts.URL}, 10, nil, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://good.example.org/article"}, urls(results))
+	})
+
+	t.Run("ConcurrentUpdates", func(t *testing.T) {
+		searcher := NewCollySearcher(DefaultConfig())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				domain := fmt.Sprintf("spam%d.example.com", i)
+				searcher.AddBlockedDomain(domain)
+				searcher.RemoveBlockedDomain(domain)
+			}(i)
+			go func() {
+				defer wg.Done()
+				searcher.isBlockedDomain("https://golang.org")
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, DefaultConfig().BlockedDomains, searcher.BlockedDomains())
+	})
+}
+
+func TestCollySearcher_LimitRules(t *testing.T) {
+	config := DefaultConfig()
+	config.Delay = 2 * time.Second
+	config.RandomDelay = time.Second
+	config.DomainDelays = map[string]time.Duration{
+		"*.duckduckgo.com":    100 * time.Millisecond,
+		"html.duckduckgo.com": 50 * time.Millisecond,
+		"*startpage.com":      3 * time.Second,
+	}
+	rules := NewCollySearcher(config).limitRules()
+
+	var globs []string
+	for _, rule := range rules {
+		globs = append(globs, rule.DomainGlob)
+	}
+	// Longer, more specific globs come first; the global rule is last
+	assert.Equal(t, []string{"html.duckduckgo.com", "*.duckduckgo.com", "*startpage.com", "*"}, globs)
+
+	// The collector accepts the rules and applies the first matching one
+	require.NoError(t, colly.NewCollector().Limits(rules))
+	matching := func(domain string) *colly.LimitRule {
+		for _, rule := range rules {
+			if rule.Match(domain) {
+				return rule
+			}
+		}
+		return nil
+	}
+	assert.Equal(t, 50*time.Millisecond, matching("html.duckduckgo.com").Delay)
+	assert.Equal(t, 100*time.Millisecond, matching("lite.duckduckgo.com").Delay)
+	assert.Zero(t, matching("lite.duckduckgo.com").RandomDelay)
+	assert.Equal(t, 3*time.Second, matching("www.startpage.com").Delay)
+	other := matching("example.org")
+	assert.Equal(t, 2*time.Second, other.Delay)
+	assert.Equal(t, time.Second, other.RandomDelay)
+
+	// Without overrides there is just the global rule
+	rules = NewCollySearcher(DefaultConfig()).limitRules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "*", rules[0].DomainGlob)
+	assert.Equal(t, DefaultConfig().Delay, rules[0].Delay)
+}
+
+func TestCollySearcher_Pagination(t *testing.T) {
+	// Three pages of four results; the second repeats a link from the first
+	pages := map[string][]string{
+		"1": {"https://a.example.org/1", "https://a.example.org/2", "https://a.example.org/3", "https://a.example.org/4"},
+		"2": {"https://a.example.org/4", "https://b.example.org/5", "https://b.example.org/6", "https://b.example.org/7", "https://b.example.org/8"},
+		"3": {"https://c.example.org/9", "https://c.example.org/10", "https://c.example.org/11", "https://c.example.org/12"},
+	}
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		mu.Lock()
+		requested = append(requested, page)
+		mu.Unlock()
+
+		var body strings.Builder
+		body.WriteString("<html><body>")
+		for _, link := range pages[page] {
+			fmt.Fprintf(&body, `<div><a href="%s">Result %s</a><p>About it</p></div>`, link, link)
+		}
+		body.WriteString("</body></html>")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body.String()))
+	}))
+	defer ts.Close()
+
+	pageURLs := func(page int) []string {
+		return []string{fmt.Sprintf("%s/?page=%d", ts.URL, page+1)}
+	}
+	search := func(t *testing.T, config Config, query mcp.SearchQuery) (*mcp.SearchPage, []string) {
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+
+		page, err := NewCollySearcher(config).searchPages(context.Background(), query, pageURLs)
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		return page, append([]string(nil), requested...)
+	}
+	links := func(page *mcp.SearchPage) []string {
+		var out []string
+		for _, r := range page.Results {
+			out = append(out, r.URL)
+		}
+		return out
+	}
+
+	config := DefaultConfig()
+	config.Delay = 0
+	config.RandomDelay = 0
+	config.MaxResults = 50
+
+	t.Run("FirstPage", func(t *testing.T) {
+		page, requested := search(t, config, mcp.SearchQuery{Query: "q", MaxResults: 3})
+		assert.Equal(t, []string{"https://a.example.org/1", "https://a.example.org/2", "https://a.example.org/3"}, links(page))
+		assert.Equal(t, EncodePageToken(mcp.SearchQuery{Query: "q"}, 3), page.NextPage)
+		// The first engine page holds enough results, so no other is fetched
+		assert.Equal(t, []string{"1"}, requested)
+	})
+
+	t.Run("FollowsEnginePages", func(t *testing.T) {
+		page, requested := search(t, config, mcp.SearchQuery{Query: "q", MaxResults: 4, Offset: 3})
+		assert.Equal(t, []string{"https://a.example.org/4", "https://b.example.org/5", "https://b.example.org/6", "https://b.example.org/7"}, links(page))
+		assert.Equal(t, EncodePageToken(mcp.SearchQuery{Query: "q"}, 7), page.NextPage)
+		assert.Equal(t, []string{"1", "2"}, requested)
+	})
+
+	t.Run("LastPage", func(t *testing.T) {
+		page, requested := search(t, config, mcp.SearchQuery{Query: "q", MaxResults: 5, Offset: 10})
+		assert.Equal(t, []string{"https://c.example.org/11", "https://c.example.org/12"}, links(page))
+		assert.Empty(t, page.NextPage)
+		// An empty engine page ends the search
+		assert.Equal(t, []string{"1", "2", "3", "4"}, requested)
+	})
+
+	t.Run("OffsetPastEnd", func(t *testing.T) {
+		page, _ := search(t, config, mcp.SearchQuery{Query: "q", MaxResults: 5, Offset: 40})
+		assert.Empty(t, page.Results)
+		assert.Empty(t, page.NextPage)
+	})
+
+	t.Run("MaxPages", func(t *testing.T) {
+		limited := config
+		limited.MaxPages = 2
+		page, requested := search(t, limited, mcp.SearchQuery{Query: "q", MaxResults: 5, Offset: 6})
+		assert.Equal(t, []string{"https://b.example.org/7", "https://b.example.org/8"}, links(page))
+		assert.Empty(t, page.NextPage)
+		assert.Equal(t, []string{"1", "2"}, requested)
+	})
+
+	t.Run("EngineURLs", func(t *testing.T) {
+		searcher := NewCollySearcher(DefaultConfig())
+		first := searcher.buildSearchURLs(mcp.SearchQuery{Query: "golang"}, 0)
+		for _, u := range first {
+			assert.NotContains(t, u, "&s=")
+			assert.NotContains(t, u, "&page=")
+		}
+
+		second := searcher.buildSearchURLs(mcp.SearchQuery{Query: "golang"}, 1)
+		assert.Contains(t, second[0], "&s=30&dc=31")
+		assert.Contains(t, second[1], "&page=2")
+	})
+
+	t.Run("PageTokens", func(t *testing.T) {
+		query := mcp.SearchQuery{Query: "golang", Language: "en"}
+		offset, err := DecodePageToken(query, EncodePageToken(query, 25))
+		require.NoError(t, err)
+		assert.Equal(t, 25, offset)
+
+		// Paging through with another page size or a fresh fetch is the same search
+		sameSearch := query
+		sameSearch.Query = " GoLang "
+		sameSearch.MaxResults = 5
+		sameSearch.Offset = 25
+		sameSearch.NoCache = true
+		offset, err = DecodePageToken(sameSearch, EncodePageToken(query, 25))
+		require.NoError(t, err)
+		assert.Equal(t, 25, offset)
+
+		offset, err = DecodePageToken(query, "")
+		require.NoError(t, err)
+		assert.Equal(t, 0, offset)
+
+		_, err = DecodePageToken(query, "not a token!")
+		assert.Error(t, err)
+		_, err = DecodePageToken(query, EncodePageToken(query, -1))
+		assert.Error(t, err)
+
+		for _, other := range []mcp.SearchQuery{
+			{Query: "rust", Language: "en"},
+			{Query: "golang", Language: "de"},
+			{Query: "golang", Language: "en", Filters: map[string]string{"domain": "go.dev"}},
+		} {
+			_, err = DecodePageToken(other, EncodePageToken(query, 25))
+			assert.ErrorIs(t, err, errPageTokenMismatch, "%+v", other)
+		}
+	})
+}
+
+func TestCollySearcher_SearchBudget(t *testing.T) {
+	// The fast engine answers at once; the slow one only after the budget
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		engine := r.URL.Query().Get("engine")
+		mu.Lock()
+		requested = append(requested, engine+r.URL.Query().Get("page"))
+		mu.Unlock()
+
+		if engine == "slow" {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><a href="https://%s.example.org/%s">Result</a></body></html>`, engine, r.URL.Query().Get("page"))
+	}))
+	defer ts.Close()
+
+	engines := func(names ...string) func(page int) []string {
+		return func(page int) []string {
+			var urls []string
+			for _, name := range names {
+				urls = append(urls, fmt.Sprintf("%s/?engine=%s&page=%d", ts.URL, name, page+1))
+			}
+			return urls
+		}
+	}
+	search := func(t *testing.T, config Config, pageURLs func(page int) []string) (*mcp.SearchPage, time.Duration, []string) {
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+
+		start := time.Now()
+		page, err := NewCollySearcher(config).searchPages(context.Background(), mcp.SearchQuery{Query: "q"}, pageURLs)
+		elapsed := time.Since(start)
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		return page, elapsed, append([]string(nil), requested...)
+	}
+
+	config := DefaultConfig()
+	config.Delay = 0
+	config.RandomDelay = 0
+	config.MaxPages = 2
+	config.SearchBudget = 300 * time.Millisecond
+
+	t.Run("ReturnsResultsGatheredInBudget", func(t *testing.T) {
+		page, elapsed, _ := search(t, config, engines("slow", "fast"))
+		assert.Less(t, elapsed, time.Second)
+		require.NotEmpty(t, page.Results)
+		for _, result := range page.Results {
+			assert.Contains(t, result.URL, "fast.example.org")
+		}
+	})
+
+	t.Run("ExhaustedBudgetIsNotAnError", func(t *testing.T) {
+		page, elapsed, requested := search(t, config, engines("slow"))
+		assert.Less(t, elapsed, time.Second)
+		assert.Empty(t, page.Results)
+		assert.Equal(t, []string{"slow1"}, requested)
+	})
+
+	t.Run("EngineTimeout", func(t *testing.T) {
+		searcher := NewCollySearcher(config)
+		timeout, ok := searcher.engineTimeout(time.Time{}, 2)
+		assert.True(t, ok)
+		assert.Equal(t, config.Timeout, timeout)
+
+		timeout, ok = searcher.engineTimeout(time.Now().Add(time.Second), 4)
+		assert.True(t, ok)
+		assert.LessOrEqual(t, timeout, 250*time.Millisecond)
+		assert.Greater(t, timeout, 200*time.Millisecond)
+
+		timeout, ok = searcher.engineTimeout(time.Now().Add(time.Hour), 2)
+		assert.True(t, ok)
+		assert.Equal(t, config.Timeout, timeout)
+
+		_, ok = searcher.engineTimeout(time.Now().Add(-time.Second), 2)
+		assert.False(t, ok)
+	})
+
+	t.Run("MaxSearchURLs", func(t *testing.T) {
+		limited := config
+		limited.SearchBudget = 0
+		limited.MaxSearchURLs = 3
+		_, _, requested := search(t, limited, engines("fast", "other"))
+		sort.Strings(requested)
+		// Both engines' first pages, then the first engine's second page
+		assert.Equal(t, []string{"fast1", "fast2", "other1"}, requested)
+	})
+}
+
+func TestCollySearcher_ExtractContent(t *testing.T) {
+	paragraph := strings.Repeat("Go is an open source programming language   that makes it simple to build software. ", 20)
+	page := "<html><body><nav>Home</nav>" +
+		"<p>" + paragraph + "</p>\n\n\n" +
+		"<p>\t" + paragraph + "</p></body></html>"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	newSearcher := func(maxContentBytes int) *CollySearcher {
+		config := DefaultConfig()
+		config.Delay = 0
+		config.RandomDelay = 0
+		config.MaxContentBytes = maxContentBytes
+		return NewCollySearcher(config)
+	}
+
+	t.Run("TruncatesToLimit", func(t *testing.T) {
+		require.Greater(t, len(page), 500)
+
+		content, err := newSearcher(500).FetchContent(context.Background(), ts.URL)
+		require.NoError(t, err)
+		assert.Len(t, content, 500+len("..."))
+		assert.True(t, strings.HasSuffix(content, "..."))
+	})
+
+	t.Run("NormalizesWhitespace", func(t *testing.T) {
+		content, err := newSearcher(0).FetchContent(context.Background(), ts.URL)
+		require.NoError(t, err)
+		assert.NotContains(t, content, "  ")
+		assert.NotContains(t, content, "\t")
+		assert.NotContains(t, content, "\n\n\n")
+		assert.Equal(t, 40, strings.Count(content, "Go is an open source"))
+	})
+
+	t.Run("Helpers", func(t *testing.T) {
+		assert.Equal(t, "a b\n\nc", normalizeWhitespace("  a \t b  \n\n\n\n c \n\n"))
+		assert.Equal(t, "", normalizeWhitespace(" \n\t\n"))
+
+		assert.Equal(t, "short", truncateContent("short", 10))
+		assert.Equal(t, "unlimited", truncateContent("unlimited", 0))
+		// "é" is two bytes, so a cut inside it backs off to the rune start
+		assert.Equal(t, "caf...", truncateContent("café au lait", 4))
+	})
+
+	t.Run("ContentTimeout", func(t *testing.T) {
+		searcher := newSearcher(0)
+		assert.Equal(t, 15*time.Second, searcher.contentTimeout(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.LessOrEqual(t, searcher.contentTimeout(ctx), time.Second)
+
+		searcher.config.ContentTimeout = 0
+		assert.Equal(t, searcher.config.Timeout, searcher.contentTimeout(context.Background()))
+	})
+}
+
+func TestCollySearcher_ContentBudget(t *testing.T) {
+	// Each page is about 40KB of paragraphs
+	paragraph := "<p>" + strings.Repeat("Large pages should not exhaust the content budget of a search. ", 10) + "</p>\n"
+	page := "<html><body>" + strings.Repeat(paragraph, 60) + "</body></html>"
+	require.Greater(t, len(page), 35000)
+
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	results := func() []*mcp.SearchResult {
+		var results []*mcp.SearchResult
+		for i := 1; i <= 4; i++ {
+			results = append(results, &mcp.SearchResult{URL: fmt.Sprintf("%s/page%d", ts.URL, i)})
+		}
+		return results
+	}
+	newSearcher := func(maxPageBytes, maxTotalBytes int) *CollySearcher {
+		config := DefaultConfig()
+		config.Delay = 0
+		config.RandomDelay = 0
+		config.MaxContentBytes = 0
+		config.MaxPageBytes = maxPageBytes
+		config.MaxTotalContentBytes = maxTotalBytes
+		return NewCollySearcher(config)
+	}
+
+	t.Run("TotalBudgetStopsFetching", func(t *testing.T) {
+		requested = nil
+		pages := results()
+		// The first page is capped at 20000 bytes and the second at the 5000
+		// left of the budget, after which no more pages are fetched
+		require.NoError(t, newSearcher(20000, 25000).AddContent(context.Background(), pages))
+
+		assert.Equal(t, []string{"/page1", "/page2"}, requested)
+		assert.NotEmpty(t, pages[0].Content)
+		assert.NotEmpty(t, pages[1].Content)
+		assert.Less(t, len(pages[1].Content), len(pages[0].Content))
+		assert.Empty(t, pages[2].Content)
+		assert.Empty(t, pages[3].Content)
+	})
+
+	t.Run("PageCap", func(t *testing.T) {
+		requested = nil
+		full := results()
+		require.NoError(t, newSearcher(0, 0).AddContent(context.Background(), full))
+		assert.Len(t, requested, 4)
+
+		capped := results()
+		require.NoError(t, newSearcher(10000, 0).AddContent(context.Background(), capped))
+		for i := range capped {
+			assert.NotEmpty(t, capped[i].Content)
+			assert.Less(t, len(capped[i].Content), 10000)
+			assert.Less(t, len(capped[i].Content), len(full[i].Content))
+		}
+	})
+}
+
+func TestCollySearcher_SearchURLs(t *testing.T) {
+	page := `<html><body>
+		<div><a href="https://one.example.org/a">One</a><p>First</p></div>
+		<div><a href="/relative">Relative</a></div>
+		<div><a href="https://two.example.org/b">Two</a><p>Second</p></div>
+		<div><a href="https://three.example.org/c">Three</a><p>Third</p></div>
+	</body></html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	config := DefaultConfig()
+	config.Delay = 0
+	config.RandomDelay = 0
+	searcher := NewCollySearcher(config)
+
+	// Results are collected once the asynchronous collector has finished
+	results, err := searcher.searchURLs(context.Background(), mcp.SearchQuery{Query: "examples"}, []string{ts.URL}, 10, nil, 0)
+	require.NoError(t, err)
+	var urls []string
+	for _, result := range results {
+		urls = append(urls, result.URL)
+	}
+	assert.Equal(t, []string{"https://one.example.org/a", "https://two.example.org/b", "https://three.example.org/c"}, urls)
+
+	// Pages visited at once still stop at the limit
+	results, err = searcher.searchURLs(context.Background(), mcp.SearchQuery{Query: "examples"}, []string{ts.URL + "/1", ts.URL + "/2"}, 2, nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
 // TestCollySearcher_Integration runs integration tests against real web services
 // These tests require internet connectivity and may be flaky
 func TestCollySearcher_Integration(t *testing.T) {
@@ -281,3 +857,190 @@ func BenchmarkMockSearcher_Search(b *testing.B) {
 		}
 	}
 }
+
+func TestCollySearcher_FetchPageImage(t *testing.T) {
+	body := "<body><p>" + strings.Repeat("Card-style results need a preview image. ", 5) + "</p></body>"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/og", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head>` +
+			`<meta property="og:image" content="/images/preview.png">` +
+			`<link rel="shortcut icon" href="/favicon.ico">` +
+			`</head>` + body + `</html>`))
+	})
+	mux.HandleFunc("/favicon-only", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head>` +
+			`<link rel="stylesheet" href="/style.css">` +
+			`<link rel="icon" href="https://cdn.example.com/icon.png">` +
+			`</head>` + body + `</html>`))
+	})
+	mux.HandleFunc("/none", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Plain</title></head>` + body + `</html>`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	config := DefaultConfig()
+	config.Delay = 0
+	config.RandomDelay = 0
+	searcher := NewCollySearcher(config)
+
+	t.Run("PrefersOpenGraphImage", func(t *testing.T) {
+		p, err := searcher.fetchPage(context.Background(), ts.URL+"/og", 0)
+		require.NoError(t, err)
+		assert.Equal(t, ts.URL+"/images/preview.png", p.ImageURL)
+		assert.Contains(t, p.Content, "preview image")
+	})
+
+	t.Run("FallsBackToFavicon", func(t *testing.T) {
+		p, err := searcher.fetchPage(context.Background(), ts.URL+"/favicon-only", 0)
+		require.NoError(t, err)
+		assert.Equal(t, "https://cdn.example.com/icon.png", p.ImageURL)
+	})
+
+	t.Run("NoImage", func(t *testing.T) {
+		p, err := searcher.fetchPage(context.Background(), ts.URL+"/none", 0)
+		require.NoError(t, err)
+		assert.Empty(t, p.ImageURL)
+	})
+
+	t.Run("IconRel", func(t *testing.T) {
+		assert.True(t, isIconRel("icon"))
+		assert.True(t, isIconRel("Shortcut Icon"))
+		assert.True(t, isIconRel("apple-touch-icon"))
+		assert.False(t, isIconRel("stylesheet"))
+	})
+}
+
+func TestTruncateDescription(t *testing.T) {
+	t.Run("ShortOrUnlimited", func(t *testing.T) {
+		assert.Equal(t, "short", truncateDescription("short", 10))
+		assert.Equal(t, "exactly ten", truncateDescription("exactly ten", 11))
+		assert.Equal(t, "no limit at all", truncateDescription("no limit at all", 0))
+	})
+
+	t.Run("PrefersWordBoundary", func(t *testing.T) {
+		assert.Equal(t, "The quick brown…", truncateDescription("The quick brown fox jumps", 18))
+	})
+
+	t.Run("CutsLongWord", func(t *testing.T) {
+		// No space near the limit, so the word itself is cut
+		assert.Equal(t, "Supercal…", truncateDescription("Supercalifragilistic", 8))
+	})
+
+	t.Run("Multibyte", func(t *testing.T) {
+		testCases := []string{
+			"Crème brûlée à la française, très délicieuse",
+			"😀😃😄😁😆😅😂🤣😊😇",
+			"日本語のテキストを切り詰める",
+		}
+		for _, text := range testCases {
+			for limit := 1; limit < len(text); limit++ {
+				truncated := truncateDescription(text, limit)
+				require.True(t, utf8.ValidString(truncated), "limit %d: %q", limit, truncated)
+				require.True(t, strings.HasSuffix(truncated, "…"))
+				require.LessOrEqual(t, len(strings.TrimSuffix(truncated, "…")), limit)
+			}
+		}
+
+		assert.Equal(t, "😀😃…", truncateDescription("😀😃😄", 10))
+	})
+
+	t.Run("UsesConfiguredLimit", func(t *testing.T) {
+		page := `<html><body><div><a href="https://example.org/a">Result</a><p>` +
+			strings.Repeat("word ", 100) + `</p></div></body></html>`
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(page))
+		}))
+		defer ts.Close()
+
+		config := DefaultConfig()
+		config.Delay = 0
+		config.RandomDelay = 0
+		config.MaxDescriptionBytes = 42
+		searcher := NewCollySearcher(config)
+
+		results, err := searcher.searchURLs(context.Background(), mcp.SearchQuery{Query: "word"}, []string{ts.URL}, 10, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, strings.TrimSpace(strings.Repeat("word ", 8))+"…", results[0].Description)
+	})
+}
+
+func TestCollySearcher_HealthCheckProbe(t *testing.T) {
+	newSearcher := func(probeURL string) *CollySearcher {
+		config := DefaultConfig()
+		config.HealthCheckURL = probeURL
+		config.HealthCheckTimeout = 200 * time.Millisecond
+		return NewCollySearcher(config)
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		var methods []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			assert.Equal(t, "MCP-Server-Bot/1.0", r.UserAgent())
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		require.NoError(t, newSearcher(ts.URL).HealthCheck(context.Background()))
+		assert.Equal(t, []string{http.MethodHead}, methods)
+	})
+
+	t.Run("FallsBackToGet", func(t *testing.T) {
+		var methods []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		require.NoError(t, newSearcher(ts.URL).HealthCheck(context.Background()))
+		assert.Equal(t, []string{http.MethodHead, http.MethodGet}, methods)
+	})
+
+	t.Run("ErrorStatus", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		err := newSearcher(ts.URL).HealthCheck(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		}))
+		defer ts.Close()
+		defer close(done)
+
+		start := time.Now()
+		err := newSearcher(ts.URL).HealthCheck(context.Background())
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		probeURL := ts.URL
+		ts.Close()
+
+		assert.Error(t, newSearcher(probeURL).HealthCheck(context.Background()))
+	})
+}
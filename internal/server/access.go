@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// collectionPatterns returns the collections Config.CollectionAccess grants
+// key, comparing keys in constant time as validAPIKey does
+func (c Config) collectionPatterns(key string) ([]string, bool) {
+	if key == "" {
+		return nil, false
+	}
+
+	var patterns []string
+	found := false
+	for candidate, granted := range c.CollectionAccess {
+		if candidate != "" && subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			patterns, found = granted, true
+		}
+	}
+	return patterns, found
+}
+
+// collectionMatcher returns a function reporting whether a collection is one
+// of patterns, which are names or path.Match patterns
+func collectionMatcher(patterns []string) func(collection string) bool {
+	return func(collection string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, collection); matched {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// collectionAllowedForKey reports whether a request authenticated with key may
+// access collection: always when Config.CollectionAccess is empty, otherwise
+// only when the key grants it
+func (s *MCPServer) collectionAllowedForKey(key, collection string) bool {
+	if len(s.config.CollectionAccess) == 0 {
+		return true
+	}
+	patterns, _ := s.config.collectionPatterns(key)
+	return collectionMatcher(patterns)(collection)
+}
+
+type collectionGrantKey struct{}
+
+// withCollectionAccess returns a context restricting the connection's requests
+// to the collections granted by the API key it presented, when
+// Config.CollectionAccess is set
+func (c *Connection) withCollectionAccess(ctx context.Context) context.Context {
+	if len(c.server.config.CollectionAccess) == 0 {
+		return ctx
+	}
+
+	granted := append([]string{}, c.collections...)
+	sort.Strings(granted)
+	grant, _ := json.Marshal(granted)
+	ctx = context.WithValue(ctx, collectionGrantKey{}, string(grant))
+	return mcp.ContextWithCollectionAccess(ctx, collectionMatcher(c.collections))
+}
+
+// collectionGrant identifies the collections granted to the client calling
+// with ctx, the same for clients granted the same ones; "" when the client
+// is not restricted
+func collectionGrant(ctx context.Context) string {
+	grant, _ := ctx.Value(collectionGrantKey{}).(string)
+	return grant
+}
+
+// ParseCollectionAccess parses a mapping of API keys to the collections they
+// grant from JSON, e.g. {"key-a": ["tenant_a", "shared_*"], "key-b": ["tenant_b"]}
+func ParseCollectionAccess(data []byte) (map[string][]string, error) {
+	var access map[string][]string
+	if err := json.Unmarshal(data, &access); err != nil {
+		return nil, fmt.Errorf("invalid collection access: %w", err)
+	}
+	for key, patterns := range access {
+		if key == "" {
+			return nil, fmt.Errorf("invalid collection access: empty API key")
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return nil, fmt.Errorf("invalid collection pattern %q", pattern)
+			}
+		}
+	}
+	return access, nil
+}
+
+// LoadCollectionAccess reads a mapping of API keys to collections from a JSON
+// file. An empty path loads none.
+func LoadCollectionAccess(path string) (map[string][]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection access: %w", err)
+	}
+	return ParseCollectionAccess(data)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// redactedValue replaces the value of sensitive arguments in audit records
+const redactedValue = "[REDACTED]"
+
+// DefaultAuditRedactKeys are the argument keys redacted when AuditConfig.RedactKeys is nil
+var DefaultAuditRedactKeys = []string{"password", "token", "secret", "api_key", "authorization"}
+
+// AuditStore persists audit records
+type AuditStore interface {
+	InsertRecord(ctx context.Context, collection string, record interface{}) error
+}
+
+// AuditConfig configures tool-call auditing
+type AuditConfig struct {
+	Collection   string        `json:"collection"`    // collection audit records are written to
+	RedactKeys   []string      `json:"redact_keys"`   // argument keys whose values are redacted, matched case-insensitively at any depth
+	WriteTimeout time.Duration `json:"write_timeout"` // timeout for writing a single record
+}
+
+// DefaultAuditConfig returns a default audit configuration
+func DefaultAuditConfig() AuditConfig {
+	return AuditConfig{
+		Collection:   "tool_audit_log",
+		RedactKeys:   DefaultAuditRedactKeys,
+		WriteTimeout: 5 * time.Second,
+	}
+}
+
+// AuditRecord is the document written for each tool invocation
+type AuditRecord struct {
+	Tool          string                 `bson:"tool" json:"tool"`
+	ClientName    string                 `bson:"client_name,omitempty" json:"client_name,omitempty"`       // from the client's initialize request
+	ClientVersion string                 `bson:"client_version,omitempty" json:"client_version,omitempty"` // from the client's initialize request
+	Arguments     map[string]interface{} `bson:"arguments,omitempty" json:"arguments,omitempty"`
+	Timestamp     time.Time              `bson:"timestamp" json:"timestamp"`
+	DurationMs    int64                  `bson:"duration_ms" json:"duration_ms"`
+	IsError       bool                   `bson:"is_error" json:"is_error"`
+	Error         string                 `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// AuditLogger records every tool invocation to an AuditStore
+type AuditLogger struct {
+	store      AuditStore
+	config     AuditConfig
+	redactKeys map[string]bool
+}
+
+// NewAuditLogger creates an audit logger writing to store
+func NewAuditLogger(store AuditStore, config AuditConfig) *AuditLogger {
+	defaults := DefaultAuditConfig()
+	if config.Collection == "" {
+		config.Collection = defaults.Collection
+	}
+	if config.RedactKeys == nil {
+		config.RedactKeys = defaults.RedactKeys
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaults.WriteTimeout
+	}
+
+	redactKeys := make(map[string]bool, len(config.RedactKeys))
+	for _, key := range config.RedactKeys {
+		redactKeys[strings.ToLower(key)] = true
+	}
+
+	return &AuditLogger{
+		store:      store,
+		config:     config,
+		redactKeys: redactKeys,
+	}
+}
+
+// Middleware returns a ToolMiddleware that writes an audit record after each call
+func (a *AuditLogger) Middleware() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+			start := time.Now()
+			response, err := next(ctx, request)
+
+			record := AuditRecord{
+				Tool:       request.Name,
+				Arguments:  a.redact(request.Arguments),
+				Timestamp:  start,
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if info, ok := mcp.ClientInfoFromContext(ctx); ok {
+				record.ClientName = info.Name
+				record.ClientVersion = info.Version
+			}
+			switch {
+			case err != nil:
+				record.IsError = true
+				record.Error = err.Error()
+			case response != nil && response.IsError:
+				record.IsError = true
+				if len(response.Content) > 0 {
+					record.Error = response.Content[0].Text
+				}
+			}
+			a.write(record)
+
+			return response, err
+		}
+	}
+}
+
+// write stores a record; failures are logged rather than failing the tool call
+func (a *AuditLogger) write(record AuditRecord) {
+	// Not derived from the request context so a cancelled call is still audited
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.WriteTimeout)
+	defer cancel()
+
+	if err := a.store.InsertRecord(ctx, a.config.Collection, record); err != nil {
+		log.Printf("Failed to write audit record for tool %s: %v", record.Tool, err)
+	}
+}
+
+// redact returns a copy of args with sensitive values replaced, including in nested objects
+func (a *AuditLogger) redact(args map[string]interface{}) map[string]interface{} {
+	if args == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		if a.redactKeys[strings.ToLower(key)] {
+			redacted[key] = redactedValue
+			continue
+		}
+		redacted[key] = a.redactValue(value)
+	}
+	return redacted
+}
+
+func (a *AuditLogger) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return a.redact(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = a.redactValue(item)
+		}
+		return items
+	default:
+		return value
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAPIKey only lets requests through that carry one of Config.APIKeys,
+// either as "Authorization: Bearer <key>" or in the X-API-Key header
+func (s *MCPServer) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.validAPIKey(apiKeyFromRequest(r)) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiKeyFromRequest extracts the API key presented by the client, if any
+func apiKeyFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if scheme, key, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(key)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// validAPIKey reports whether key is one of the configured API keys, or one
+// of the keys of Config.CollectionAccess
+func (s *MCPServer) validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	if _, ok := s.config.collectionPatterns(key); ok {
+		return true
+	}
+
+	valid := false
+	for _, candidate := range s.config.APIKeys {
+		if candidate != "" && subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxBatchCalls bounds the number of calls a single batch may run
+const maxBatchCalls = 20
+
+// builtinToolProvider exposes tools implemented by the server itself rather than
+// by a registered provider. It is registered automatically by NewServerWithConfig.
+type builtinToolProvider struct {
+	server *MCPServer
+}
+
+// newBuiltinToolProvider creates the provider for server-level tools
+func newBuiltinToolProvider(server *MCPServer) *builtinToolProvider {
+	return &builtinToolProvider{server: server}
+}
+
+// Name returns the provider name used to namespace conflicting tool names
+func (b *builtinToolProvider) Name() string {
+	return "server"
+}
+
+// ListTools returns the built-in tools
+func (b *builtinToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{
+		{
+			Name:        "describe_tool",
+			Description: "Return the description and input schema of a single tool, so schemas can be fetched on demand instead of listing every tool",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the tool to describe",
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		{
+			Name:        "export_schema",
+			Description: "Return every tool's description, annotations, input schema and output schema as a single JSON object keyed by tool name, for generating client code or documentation",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "server_info",
+			Description: "Return the server's version, build time, Go version, protocol version, uptime and active tool providers, for diagnostics",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   false, // uptime changes with every call
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":             map[string]interface{}{"type": "string"},
+					"version":          map[string]interface{}{"type": "string"},
+					"build_time":       map[string]interface{}{"type": "string"},
+					"go_version":       map[string]interface{}{"type": "string"},
+					"protocol_version": map[string]interface{}{"type": "string"},
+					"started_at":       map[string]interface{}{"type": "string", "format": "date-time"},
+					"uptime_seconds":   map[string]interface{}{"type": "integer", "minimum": 0},
+					"providers":        map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"required":             []string{"name", "version", "go_version", "protocol_version", "started_at", "uptime_seconds", "providers"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "batch",
+			Description: fmt.Sprintf("Run up to %d tool calls in order and return the result of each. Stops at the first failed call unless continue_on_error is set", maxBatchCalls),
+			Annotations: &mcp.ToolAnnotations{DestructiveHint: true, OpenWorldHint: true},
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"calls": map[string]interface{}{
+						"type":        "array",
+						"description": "Tool calls to run in order",
+						"minItems":    1,
+						"maxItems":    maxBatchCalls,
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"name": map[string]interface{}{
+									"type":        "string",
+									"description": "Tool name",
+								},
+								"arguments": map[string]interface{}{
+									"type":        "object",
+									"description": "Tool arguments",
+								},
+							},
+							"required": []string{"name"},
+						},
+					},
+					"continue_on_error": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Keep running the remaining calls after one fails (default: false)",
+					},
+				},
+				"required": []string{"calls"},
+			},
+		},
+	}, nil
+}
+
+// CallTool executes a built-in tool
+func (b *builtinToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	switch request.Name {
+	case "describe_tool":
+		return b.describeTool(ctx, request.Arguments)
+	case "export_schema":
+		return b.exportSchema(ctx)
+	case "server_info":
+		return b.serverInfo()
+	case "batch":
+		return b.batch(ctx, request.Arguments)
+	default:
+		return mcp.NewToolError(fmt.Sprintf("Unknown built-in tool: %s", request.Name), nil), nil
+	}
+}
+
+// describeTool returns the named tool's definition as JSON and as structured
+// content, searching every registered provider
+func (b *builtinToolProvider) describeTool(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	name, ok := args.String("name")
+	if !ok || name == "" {
+		return mcp.NewToolError("Missing or invalid 'name' parameter", nil), nil
+	}
+
+	tools, err := b.server.listTools(ctx)
+	if err != nil {
+		return mcp.NewToolError(fmt.Sprintf("Failed to list tools: %v", err), nil), nil
+	}
+
+	for _, tool := range tools {
+		if tool.Name != name {
+			continue
+		}
+
+		jsonData, err := mcp.FormatJSON(tool, b.server.config.PrettyJSON)
+		if err != nil {
+			return mcp.NewToolError(fmt.Sprintf("Failed to encode tool %s: %v", name, err), nil), nil
+		}
+		return &mcp.ToolCallResponse{
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: string(jsonData),
+				},
+			},
+			StructuredContent: tool,
+		}, nil
+	}
+
+	return mcp.NewToolError(fmt.Sprintf("Unknown tool: %s", name), nil), nil
+}
+
+// exportedTool is the entry of one tool in the export_schema document
+type exportedTool struct {
+	Description  string                 `json:"description"`
+	Annotations  *mcp.ToolAnnotations   `json:"annotations,omitempty"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+}
+
+// exportSchema returns the whole tool catalog as one JSON object mapping each
+// tool name to its definition. Unlike tools/list it is never paginated, and
+// its keys are sorted, so the same tools always export the same document.
+func (b *builtinToolProvider) exportSchema(ctx context.Context) (*mcp.ToolCallResponse, error) {
+	tools, err := b.server.listTools(ctx)
+	if err != nil {
+		return mcp.NewToolError(fmt.Sprintf("Failed to list tools: %v", err), nil), nil
+	}
+
+	catalog := make(map[string]exportedTool, len(tools))
+	for _, tool := range tools {
+		catalog[tool.Name] = exportedTool{
+			Description:  tool.Description,
+			Annotations:  tool.Annotations,
+			InputSchema:  tool.InputSchema,
+			OutputSchema: tool.OutputSchema,
+		}
+	}
+
+	jsonData, err := mcp.FormatJSON(catalog, b.server.config.PrettyJSON)
+	if err != nil {
+		return mcp.NewToolError(fmt.Sprintf("Failed to encode tool schemas: %v", err), nil), nil
+	}
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+	}, nil
+}
+
+// serverInfo reports the server's build and runtime information
+func (b *builtinToolProvider) serverInfo() (*mcp.ToolCallResponse, error) {
+	info := b.server.info()
+	jsonData, err := mcp.FormatJSON(info, b.server.config.PrettyJSON)
+	if err != nil {
+		return mcp.NewToolError(fmt.Sprintf("Failed to encode server info: %v", err), nil), nil
+	}
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: info,
+	}, nil
+}
+
+// batchCallResult is the outcome of one call within a batch
+type batchCallResult struct {
+	Index   int           `json:"index"`
+	Name    string        `json:"name"`
+	IsError bool          `json:"isError"`
+	Content []mcp.Content `json:"content"`
+}
+
+// batch runs tool calls sequentially through the same dispatch path as tools/call,
+// including tool middleware. The response is a JSON array with one entry per call
+// that ran; it is marked as an error when a failed call stopped the batch.
+func (b *builtinToolProvider) batch(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	rawCalls, ok := args["calls"].([]interface{})
+	if !ok || len(rawCalls) == 0 {
+		return mcp.NewToolError("Missing or invalid 'calls' parameter", nil), nil
+	}
+	if len(rawCalls) > maxBatchCalls {
+		return mcp.NewToolError(fmt.Sprintf("Too many calls: %d (maximum %d)", len(rawCalls), maxBatchCalls), nil), nil
+	}
+
+	continueOnError := args.Bool("continue_on_error", false)
+
+	// Validate every call up front so a malformed batch runs nothing
+	calls := make([]mcp.ToolCallRequest, len(rawCalls))
+	for i, rawCall := range rawCalls {
+		call, ok := rawCall.(map[string]interface{})
+		if !ok {
+			return mcp.NewToolError(fmt.Sprintf("Invalid call %d: expected an object", i), nil), nil
+		}
+		name, ok := call["name"].(string)
+		if !ok || name == "" {
+			return mcp.NewToolError(fmt.Sprintf("Invalid call %d: missing or invalid 'name'", i), nil), nil
+		}
+		if name == "batch" {
+			return mcp.NewToolError(fmt.Sprintf("Invalid call %d: batches cannot be nested", i), nil), nil
+		}
+		arguments, _ := call["arguments"].(map[string]interface{})
+		calls[i] = mcp.ToolCallRequest{Name: name, Arguments: arguments}
+	}
+
+	results := make([]batchCallResult, 0, len(calls))
+	failed := false
+	for i, call := range calls {
+		response := b.dispatch(ctx, call)
+		results = append(results, batchCallResult{
+			Index:   i,
+			Name:    call.Name,
+			IsError: response.IsError,
+			Content: response.Content,
+		})
+
+		if response.IsError && !continueOnError {
+			failed = true
+			break
+		}
+	}
+
+	jsonData, err := mcp.FormatJSON(results, b.server.config.PrettyJSON)
+	if err != nil {
+		return mcp.NewToolError(fmt.Sprintf("Failed to encode batch results: %v", err), nil), nil
+	}
+	return &mcp.ToolCallResponse{
+		IsError: failed,
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+	}, nil
+}
+
+// dispatch runs one batched call, reporting every failure as a tool error result
+func (b *builtinToolProvider) dispatch(ctx context.Context, call mcp.ToolCallRequest) *mcp.ToolCallResponse {
+	provider, ok := b.server.findToolProvider(ctx, call.Name)
+	if !ok {
+		return mcp.NewToolError(fmt.Sprintf("Tool not found: %s", call.Name), nil)
+	}
+
+	if call.Arguments == nil {
+		call.Arguments = map[string]interface{}{}
+	}
+
+	response, err := b.server.toolHandler(provider)(ctx, call)
+	if err != nil {
+		return mcp.NewToolError(fmt.Sprintf("Tool execution failed: %v", err), nil)
+	}
+	if response == nil {
+		return mcp.NewToolError(fmt.Sprintf("Tool %s returned no result", call.Name), nil)
+	}
+	return response
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxToolCacheEntries bounds the tool result cache; once full, new results are
+// only cached after expired entries make room
+const maxToolCacheEntries = 1000
+
+// collectionArgs are the tool arguments naming a collection a call reads or writes
+var collectionArgs = []string{"collection", "target_collection", "other_collection"}
+
+// noCacheArg is the tool argument with which a call asks for a fresh result:
+// the cache is not read, and the result replaces the cached one
+const noCacheArg = "no_cache"
+
+// toolCacheEntry is one memoized tool result
+type toolCacheEntry struct {
+	response    *mcp.ToolCallResponse
+	collections []string
+	expires     time.Time
+}
+
+// toolCache memoizes successful results of read-only tools for a TTL, shared by
+// every connection of the server. A successful call to any other tool
+// invalidates the entries for the collections it names, or every entry when it
+// names none.
+type toolCache struct {
+	server *MCPServer
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]toolCacheEntry
+}
+
+func newToolCache(server *MCPServer, ttl time.Duration) *toolCache {
+	return &toolCache{
+		server:  server,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]toolCacheEntry),
+	}
+}
+
+// middleware serves calls to cacheable tools from the cache and invalidates
+// it after successful calls to tools that are not read-only
+func (c *toolCache) middleware() ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+			cacheable, readOnly := c.policy(ctx, request.Name)
+			if !cacheable {
+				response, err := next(ctx, request)
+				if !readOnly && err == nil && response != nil && !response.IsError {
+					c.invalidate(requestCollections(request))
+				}
+				return response, err
+			}
+
+			// A call that may stream content has to run for the client to get it
+			if _, streaming := mcp.ContentStreamerFromContext(ctx); streaming {
+				return next(ctx, request)
+			}
+			key, ok := toolCacheKey(request, collectionGrant(ctx))
+			if !ok {
+				return next(ctx, request)
+			}
+			if !mcp.Args(request.Arguments).Bool(noCacheArg, false) {
+				if response, ok := c.get(key); ok {
+					return response, nil
+				}
+			}
+
+			response, err := next(ctx, request)
+			if err == nil && response != nil && !response.IsError {
+				c.put(key, response, requestCollections(request))
+			}
+			return response, err
+		}
+	}
+}
+
+// policy reports whether the results of the named tool may be cached, which
+// the tool declares with mcp.Tool.Cacheable, and whether it is annotated as
+// read-only, so that calling it leaves cached results valid. Health checks
+// and other read-only tools whose results vary over time are not cacheable.
+func (c *toolCache) policy(ctx context.Context, name string) (cacheable, readOnly bool) {
+	tools, err := c.server.listTools(ctx)
+	if err != nil {
+		return false, false
+	}
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool.Cacheable, tool.Annotations != nil && tool.Annotations.ReadOnlyHint
+		}
+	}
+	return false, false
+}
+
+func (c *toolCache) get(key string) (*mcp.ToolCallResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	// Callers get their own copy, so a middleware adjusting a response cannot
+	// change what later hits see
+	response := *entry.response
+	return &response, true
+}
+
+func (c *toolCache) put(key string, response *mcp.ToolCallResponse, collections []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= maxToolCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxToolCacheEntries {
+			return
+		}
+	}
+
+	stored := *response
+	c.entries[key] = toolCacheEntry{
+		response:    &stored,
+		collections: collections,
+		expires:     now.Add(c.ttl),
+	}
+}
+
+// invalidate drops the entries that read any of collections, or every entry
+// when collections is empty. Entries whose call named no collection read a
+// tool's default one, so they are always dropped.
+func (c *toolCache) invalidate(collections []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(collections) == 0 {
+		c.entries = make(map[string]toolCacheEntry)
+		return
+	}
+
+	written := make(map[string]bool, len(collections))
+	for _, collection := range collections {
+		written[collection] = true
+	}
+	for key, entry := range c.entries {
+		if len(entry.collections) == 0 {
+			delete(c.entries, key)
+			continue
+		}
+		for _, collection := range entry.collections {
+			if written[collection] {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// toolCacheKey hashes the tool name and its arguments, except no_cache, which
+// does not change the result, together with the caller's collection grant, so
+// that clients restricted to different collections never share results, even
+// of calls that name no collection and read a default one. encoding/json
+// sorts map keys, so equal arguments always produce the same key.
+func toolCacheKey(request mcp.ToolCallRequest, grant string) (string, bool) {
+	arguments := request.Arguments
+	if _, ok := arguments[noCacheArg]; ok {
+		arguments = make(map[string]interface{}, len(request.Arguments))
+		for k, v := range request.Arguments {
+			if k != noCacheArg {
+				arguments[k] = v
+			}
+		}
+	}
+
+	args, err := json.Marshal(arguments)
+	if err != nil {
+		return "", false
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(request.Name))
+	hash.Write([]byte{0})
+	hash.Write(args)
+	hash.Write([]byte{0})
+	hash.Write([]byte(grant))
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// requestCollections returns the collections named by a call's arguments
+func requestCollections(request mcp.ToolCallRequest) []string {
+	var collections []string
+	for _, arg := range collectionArgs {
+		if collection, ok := request.Arguments[arg].(string); ok && collection != "" {
+			collections = append(collections, collection)
+		}
+	}
+	return collections
+}
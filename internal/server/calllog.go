@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxLoggedArgLength bounds each argument value quoted in call log lines
+const maxLoggedArgLength = 60
+
+// callLogMiddleware logs tool calls that take longer than slow or whose
+// serialized response is larger than large bytes. A zero threshold disables
+// that check.
+func callLogMiddleware(slow time.Duration, large int, logf func(format string, args ...interface{})) ToolMiddleware {
+	redactKeys := make(map[string]bool, len(DefaultAuditRedactKeys))
+	for _, key := range DefaultAuditRedactKeys {
+		redactKeys[key] = true
+	}
+
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+			start := time.Now()
+			response, err := next(ctx, request)
+			duration := time.Since(start)
+
+			if slow > 0 && duration > slow {
+				logf("%sSlow tool call: %s took %s (threshold %s), args: %s",
+					logPrefix(ctx), request.Name, duration.Round(time.Millisecond), slow, summarizeArgs(request.Arguments, redactKeys))
+			}
+
+			if large > 0 && response != nil {
+				if data, marshalErr := json.Marshal(response); marshalErr == nil && len(data) > large {
+					logf("%sLarge tool response: %s returned %d bytes (threshold %d), args: %s",
+						logPrefix(ctx), request.Name, len(data), large, summarizeArgs(request.Arguments, redactKeys))
+				}
+			}
+
+			return response, err
+		}
+	}
+}
+
+// summarizeArgs renders tool arguments compactly for a log line: keys are
+// sorted, long strings are shortened, nested values are only described and
+// sensitive keys are redacted
+func summarizeArgs(args map[string]interface{}, redactKeys map[string]bool) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		var value string
+		switch v := args[key].(type) {
+		case string:
+			value = fmt.Sprintf("%q", truncateForLog(v))
+		case map[string]interface{}:
+			value = fmt.Sprintf("{%d keys}", len(v))
+		case []interface{}:
+			value = fmt.Sprintf("[%d items]", len(v))
+		default:
+			value = truncateForLog(fmt.Sprint(v))
+		}
+		if redactKeys[strings.ToLower(key)] {
+			value = redactedValue
+		}
+		parts[i] = key + "=" + value
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// truncateForLog shortens s to maxLoggedArgLength runes
+func truncateForLog(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxLoggedArgLength {
+		return s
+	}
+	return string(runes[:maxLoggedArgLength]) + "..."
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// clientRequestIDPrefix marks the ids of requests the server sends, keeping
+// them apart from the ids clients choose for their own requests
+const clientRequestIDPrefix = "srv-"
+
+// ClientError is the error a client answered a server request with
+type ClientError struct {
+	Method  string
+	Code    int
+	Message string
+}
+
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("client returned error %d for %s: %s", e.Code, e.Method, e.Message)
+}
+
+// Call sends a request to the client and waits for its response, returning
+// the raw result, for features where the server asks the client for something,
+// such as roots/list or sampling. The request gets an id of its own and goes
+// through the connection's outbox, so it is written by the dispatcher's
+// writer; the response is matched by id when the read loop receives it.
+//
+// Call fails when ctx is done or the connection closes first, and with a
+// *ClientError when the client answers with an error. It must not be called
+// from the read loop, which has to stay free to receive the response.
+func (c *Connection) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := fmt.Sprintf("%s%d", clientRequestIDPrefix, atomic.AddInt64(&c.lastClientRequestID, 1))
+	responses := make(chan *mcp.Message, 1)
+
+	c.mu.Lock()
+	if c.clientRequests == nil {
+		c.clientRequests = make(map[string]chan *mcp.Message)
+	}
+	c.clientRequests[id] = responses
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.clientRequests, id)
+		c.mu.Unlock()
+	}()
+
+	request := mcp.NewRequest(id, method, params)
+	if err := c.send(queuedMessage{message: request, method: method, priority: NotificationCritical}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case response, ok := <-responses:
+		if !ok {
+			return nil, errConnectionClosed
+		}
+		if response.Error != nil {
+			return nil, &ClientError{Method: method, Code: response.Error.Code, Message: response.Error.Message}
+		}
+		return json.Marshal(response.Result)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleClientResponse hands a response from the client to the call waiting
+// for it. Responses nobody is waiting for, such as late ones, are dropped.
+func (c *Connection) handleClientResponse(message *mcp.Message) {
+	id, _ := message.ID.(string)
+
+	c.mu.Lock()
+	responses, ok := c.clientRequests[id]
+	if ok {
+		delete(c.clientRequests, id)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		log.Printf("Dropping response to unknown request %v", message.ID)
+		return
+	}
+	responses <- message
+}
+
+// cancelClientRequests fails every call still waiting for the client, once the
+// connection has closed
+func (c *Connection) cancelClientRequests() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, responses := range c.clientRequests {
+		close(responses)
+		delete(c.clientRequests, id)
+	}
+}
+
+// isClientResponse reports whether a message answers a request: it has an id
+// and a result or error, but no method
+func isClientResponse(message *mcp.Message) bool {
+	return message.Method == "" && message.ID != nil && (message.Result != nil || message.Error != nil)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxCompletionValues is the most values a completion/complete response may carry
+const maxCompletionValues = 100
+
+// RegisterCompletionProvider registers a provider of argument completions
+func (s *MCPServer) RegisterCompletionProvider(provider mcp.CompletionProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completionProviders = append(s.completionProviders, provider)
+}
+
+// hasCompletionProviders reports whether the completions capability should be advertised
+func (s *MCPServer) hasCompletionProviders() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.completionProviders) > 0
+}
+
+// handleComplete processes completion/complete requests, merging the values of
+// every completion provider
+func (c *Connection) handleComplete(ctx context.Context, message *mcp.Message) *mcp.Response {
+	if !c.ready() {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest,
+			"Client not initialized", nil)
+	}
+
+	var req mcp.CompleteRequest
+	if message.Params != nil {
+		if err := c.decodeParams(message.Params, &req); err != nil {
+			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams,
+				"Invalid completion parameters", err.Error())
+		}
+	}
+	switch req.Ref.Type {
+	case mcp.CompletionRefPrompt, mcp.CompletionRefResource:
+	default:
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams,
+			"Invalid completion reference type", req.Ref.Type)
+	}
+	if req.Argument.Name == "" {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams,
+			"Missing completion argument name", nil)
+	}
+
+	c.server.mu.RLock()
+	providers := append([]mcp.CompletionProvider(nil), c.server.completionProviders...)
+	c.server.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	values := []string{}
+	for _, provider := range providers {
+		candidates, err := provider.Complete(ctx, req)
+		if err != nil {
+			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError,
+				"Completion failed", err.Error())
+		}
+		for _, value := range candidates {
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+	sort.Strings(values)
+
+	completion := mcp.Completion{Values: values}
+	if len(values) > maxCompletionValues {
+		completion = mcp.Completion{
+			Values:  values[:maxCompletionValues],
+			Total:   len(values),
+			HasMore: true,
+		}
+	}
+
+	return mcp.NewResponse(message.ID, mcp.CompleteResponse{Completion: completion})
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxCorrelationIDLength bounds client-provided correlation ids
+const maxCorrelationIDLength = 128
+
+type correlationIDKey struct{}
+
+// withCorrelationID returns a context carrying the request's correlation id
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation id of the request being handled with
+// ctx, or "" outside a request. Tool providers can use it to tag their logs.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// logPrefix returns "[<correlation id>] " for log lines written while handling
+// a request, and "" otherwise
+func logPrefix(ctx context.Context) string {
+	if id := CorrelationID(ctx); id != "" {
+		return "[" + id + "] "
+	}
+	return ""
+}
+
+// requestCorrelationID takes the correlation id from the request's
+// _meta.requestId when the client sent a usable one, and generates one otherwise
+func requestCorrelationID(message *mcp.Message) string {
+	if params, ok := message.Params.(map[string]interface{}); ok {
+		if meta, ok := params["_meta"].(map[string]interface{}); ok {
+			if id, ok := meta["requestId"].(string); ok && validCorrelationID(id) {
+				return id
+			}
+		}
+	}
+	return newCorrelationID()
+}
+
+// validCorrelationID accepts short ids of printable ASCII, so a client cannot
+// inject line breaks or control characters into the logs
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newCorrelationID returns a random RFC 4122 version 4 UUID
+func newCorrelationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand failing is not recoverable in a meaningful way
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// logRequest wraps a request handler with start and completion log lines and
+// adds the correlation id to the Data of an error response
+func (c *Connection) logRequest(ctx context.Context, message *mcp.Message, handle func() *mcp.Response) *mcp.Response {
+	prefix := logPrefix(ctx)
+	log.Printf("%sRequest %v: %s", prefix, message.ID, message.Method)
+
+	start := time.Now()
+	response := handle()
+	duration := time.Since(start).Round(time.Microsecond)
+
+	if response != nil && response.Error != nil {
+		log.Printf("%sRequest %v: %s failed in %s: %d %s", prefix, message.ID, message.Method, duration,
+			response.Error.Code, response.Error.Message)
+		response.Error.Data = correlatedErrorData(CorrelationID(ctx), response.Error.Data)
+	} else {
+		log.Printf("%sRequest %v: %s completed in %s", prefix, message.ID, message.Method, duration)
+	}
+	return response
+}
+
+// correlatedErrorData adds the correlation id to error data, keeping the
+// original data under "detail"
+func correlatedErrorData(id string, data interface{}) interface{} {
+	correlated := map[string]interface{}{"correlationId": id}
+	if data != nil {
+		correlated["detail"] = data
+	}
+	return correlated
+}
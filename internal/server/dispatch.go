@@ -0,0 +1,173 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// dispatcher runs the messages read from one connection. Requests are handled
+// concurrently, up to Config.MaxConcurrentRequests at a time; initialize and
+// notifications are handled in order by the read loop, since later messages
+// depend on the state they set. Responses, and the server's notifications,
+// which a writer goroutine takes from the connection's outbox, are written one
+// at a time.
+type dispatcher struct {
+	conn   *Connection
+	write  func(response interface{}) error
+	slots  chan struct{} // nil when requests are handled in order
+	outbox *outbox
+
+	writeMu    sync.Mutex
+	wg         sync.WaitGroup
+	writerDone chan struct{}
+}
+
+func newDispatcher(conn *Connection, write func(response interface{}) error) *dispatcher {
+	d := &dispatcher{
+		conn:       conn,
+		write:      write,
+		outbox:     newOutbox(conn.server.config.NotificationBuffer),
+		writerDone: make(chan struct{}),
+	}
+	if limit := conn.server.config.MaxConcurrentRequests; limit > 1 {
+		d.slots = make(chan struct{}, limit)
+	}
+
+	conn.mu.Lock()
+	conn.outbox = d.outbox
+	conn.mu.Unlock()
+	go d.writeNotifications()
+	return d
+}
+
+// dispatch decodes and handles one raw message. A malformed payload is
+// answered with an error response, so that only transport errors end a
+// connection's read loop. It returns once the message is handled, or once a
+// concurrent request has been handed to its goroutine, which waits there for
+// a free slot: the read loop never blocks on the limit, so responses from the
+// client, such as to sampling requests that slow tool calls wait for, are
+// always read. An error means the response could not be written and the
+// connection should be closed.
+func (d *dispatcher) dispatch(data []byte) error {
+	message, err := mcp.DecodeMessage(data, d.conn.server.config.PreciseNumbers)
+	if err != nil {
+		return d.respond(decodeErrorResponse(data, err))
+	}
+
+	// Counted until the response is written, so that a graceful Stop waits for it
+	active := &d.conn.server.activeRequests
+	atomic.AddInt64(active, 1)
+
+	if d.slots == nil || !concurrentRequest(message) {
+		defer atomic.AddInt64(active, -1)
+		return d.respond(d.conn.handleMessage(message))
+	}
+
+	d.wg.Add(1)
+	go func() {
+		d.slots <- struct{}{}
+		defer func() {
+			<-d.slots
+			d.wg.Done()
+			atomic.AddInt64(active, -1)
+		}()
+
+		if err := d.respond(d.conn.handleMessage(message)); err != nil {
+			// Closing the transport ends the read loop, as a write error there would
+			log.Printf("Failed to write response: %v", err)
+			if d.conn.conn != nil {
+				d.conn.conn.Close()
+			}
+		}
+	}()
+	return nil
+}
+
+// wait blocks until every request started by dispatch has been answered, then
+// stops the notification writer and fails the server's requests still waiting
+// for the client
+func (d *dispatcher) wait() {
+	d.wg.Wait()
+	d.outbox.close()
+	<-d.writerDone
+	d.conn.cancelClientRequests()
+}
+
+// respond writes a response, if there is one
+func (d *dispatcher) respond(response interface{}) error {
+	if response == nil {
+		return nil
+	}
+
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	if err := d.write(response); err != nil {
+		return err
+	}
+	// A slow request must not count towards the idle timeout
+	d.conn.touch()
+	return nil
+}
+
+// writeNotifications writes the connection's buffered notifications until the
+// outbox is closed. A failed write closes the transport, ending the read loop.
+func (d *dispatcher) writeNotifications() {
+	defer close(d.writerDone)
+
+	for range d.outbox.wake {
+		queue, ok := d.outbox.take()
+		if !ok {
+			return
+		}
+		for i, queued := range queue {
+			d.writeMu.Lock()
+			err := d.write(queued.message)
+			d.writeMu.Unlock()
+			queued.done(err)
+			if err != nil {
+				log.Printf("Failed to write %s: %v", queued.method, err)
+				for _, unwritten := range queue[i+1:] {
+					unwritten.done(errConnectionClosed)
+				}
+				d.outbox.close()
+				if d.conn.conn != nil {
+					d.conn.conn.Close()
+				}
+				return
+			}
+		}
+	}
+}
+
+// writeDeadliner is a transport whose writes can be given a deadline
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// withWriteTimeout bounds each call of write by timeout, so that a client that
+// stops reading fails the write instead of blocking the connection's writers
+// forever; the failed write then closes the connection. A timeout of 0 or
+// less leaves writes unbounded.
+func withWriteTimeout(conn writeDeadliner, timeout time.Duration, write func(response interface{}) error) func(response interface{}) error {
+	if timeout <= 0 {
+		return write
+	}
+	return func(response interface{}) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		return write(response)
+	}
+}
+
+// concurrentRequest reports whether a message can be handled alongside others:
+// any request except initialize, which sets up the session that later
+// requests run in
+func concurrentRequest(message *mcp.Message) bool {
+	return message.Method != "" && message.ID != nil && !message.HasNullID() &&
+		message.Method != mcp.MethodInitialize
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Endpoints lists the URLs clients should use to reach the server. Behind an
+// ingress or load balancer these differ from the address the server binds to.
+type Endpoints struct {
+	MCP    string `json:"mcp"`
+	Health string `json:"health"`
+	Tools  string `json:"tools"`
+	Status string `json:"status"`
+	Web    string `json:"web"`
+}
+
+// NewEndpoints derives the advertised endpoints from an external base URL such as
+// "https://mcp.example.com" or "http://10.0.0.5:8080/mcp-server"
+func NewEndpoints(baseURL string) (Endpoints, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return Endpoints{}, fmt.Errorf("invalid external URL %q: %w", baseURL, err)
+	}
+
+	var wsScheme string
+	switch u.Scheme {
+	case "http":
+		wsScheme = "ws"
+	case "https":
+		wsScheme = "wss"
+	default:
+		return Endpoints{}, fmt.Errorf("invalid external URL %q: scheme must be http or https", baseURL)
+	}
+	if u.Host == "" {
+		return Endpoints{}, fmt.Errorf("invalid external URL %q: missing host", baseURL)
+	}
+
+	base := u.Scheme + "://" + u.Host + strings.TrimSuffix(u.Path, "/")
+	wsBase := wsScheme + "://" + u.Host + strings.TrimSuffix(u.Path, "/")
+
+	return Endpoints{
+		MCP:    wsBase + "/mcp",
+		Health: base + "/health",
+		Tools:  base + "/tools",
+		Status: base + "/status/stream",
+		Web:    base + "/",
+	}, nil
+}
+
+// endpointsFor returns the endpoints to advertise to the client making r. The
+// configured ExternalURL wins; otherwise they are derived from the request,
+// honoring the X-Forwarded-Proto and X-Forwarded-Host headers set by proxies
+// when TrustProxyHeaders is set.
+func (s *MCPServer) endpointsFor(r *http.Request) Endpoints {
+	if s.config.ExternalURL != "" {
+		if endpoints, err := NewEndpoints(s.config.ExternalURL); err == nil {
+			return endpoints
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if s.config.TrustProxyHeaders {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	endpoints, _ := NewEndpoints(scheme + "://" + host)
+	return endpoints
+}
+
+// clientInfo describes the client behind r for logging, preferring the original
+// address and scheme reported by X-Forwarded-For and X-Forwarded-Proto
+func clientInfo(r *http.Request) string {
+	addr := r.RemoteAddr
+	if forwardedFor := firstHeaderValue(r, "X-Forwarded-For"); forwardedFor != "" {
+		addr = fmt.Sprintf("%s (via %s)", forwardedFor, remoteHost(r.RemoteAddr))
+	}
+
+	if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto != "" {
+		return fmt.Sprintf("%s [%s]", addr, proto)
+	}
+	return addr
+}
+
+// firstHeaderValue returns the first comma-separated value of a proxy header,
+// which is the one closest to the original client
+func firstHeaderValue(r *http.Request, name string) string {
+	value := r.Header.Get(name)
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// remoteHost strips the port from a RemoteAddr
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
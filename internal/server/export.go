@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// CollectionExporter streams every document of a collection as newline-delimited JSON
+type CollectionExporter interface {
+	ExportCollection(ctx context.Context, collection string, w io.Writer) error
+	ValidateCollectionName(name string) error
+}
+
+// SetCollectionExporter enables the /export/{collection} endpoint
+func (s *MCPServer) SetCollectionExporter(exporter CollectionExporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exporter = exporter
+}
+
+// handleExport streams a collection to the client as NDJSON without buffering it
+func (s *MCPServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	exporter := s.exporter
+	s.mu.RUnlock()
+	if exporter == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	collection := strings.TrimPrefix(r.URL.Path, "/export/")
+	if err := exporter.ValidateCollectionName(collection); err != nil {
+		http.Error(w, fmt.Sprintf("invalid collection: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !s.collectionAllowedForKey(apiKeyFromRequest(r), collection) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	out := &exportWriter{w: w, collection: collection}
+	err := exporter.ExportCollection(r.Context(), collection, out)
+	switch {
+	case err != nil && !out.started:
+		http.Error(w, fmt.Sprintf("export failed: %v", err), http.StatusInternalServerError)
+	case err != nil:
+		// The status is already sent, so the client only sees a truncated stream
+		log.Printf("Export of collection %s failed: %v", collection, err)
+	default:
+		out.start()
+	}
+}
+
+// exportWriter sends the NDJSON response headers on the first write, so an
+// export that fails before producing output can still report an error status
+type exportWriter struct {
+	w          http.ResponseWriter
+	collection string
+	started    bool
+}
+
+func (e *exportWriter) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.w.Header().Set("Content-Type", "application/x-ndjson")
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ndjson"`, e.collection))
+	e.w.WriteHeader(http.StatusOK)
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.start()
+	return e.w.Write(p)
+}
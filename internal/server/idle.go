@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// touch records that the client was active just now
+func (c *Connection) touch() {
+	atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+}
+
+// idleFor returns how long the client has been inactive as of now
+func (c *Connection) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&c.lastActivity)))
+}
+
+// reapIdleConnections closes connections that have been inactive for longer
+// than Config.IdleTimeout until ctx is done. Closing a connection ends its read
+// loop, which releases its slot and saves its session like any other disconnect.
+func (s *MCPServer) reapIdleConnections(ctx context.Context) {
+	timeout := s.config.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	// Scan often enough that a connection is reaped well before twice the timeout
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.reapIdle(now, timeout)
+		}
+	}
+}
+
+// reapIdle closes every connection idle for longer than timeout as of now and
+// returns how many were closed
+func (s *MCPServer) reapIdle(now time.Time, timeout time.Duration) int {
+	var idle []transport
+
+	s.mu.RLock()
+	for conn, connection := range s.connections {
+		if connection.idleFor(now) > timeout {
+			idle = append(idle, conn)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, conn := range idle {
+		log.Printf("Closing idle connection from %s: no messages for over %s", conn.RemoteAddr(), timeout)
+		conn.Close()
+	}
+	return len(idle)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// serverName is the name the server reports in the initialize response
+const serverName = "MCP Server Go"
+
+// Info describes the running server, as returned by the server_info tool
+type Info struct {
+	Name            string    `json:"name"`
+	Version         string    `json:"version"`
+	BuildTime       string    `json:"build_time,omitempty"`
+	GoVersion       string    `json:"go_version"`
+	ProtocolVersion string    `json:"protocol_version"`
+	StartedAt       time.Time `json:"started_at"`
+	UptimeSeconds   int64     `json:"uptime_seconds"`
+	Providers       []string  `json:"providers"` // registered tool providers, in registration order
+}
+
+// version returns Config.Version, or "dev" for builds that did not set one
+func (s *MCPServer) version() string {
+	if s.config.Version == "" {
+		return "dev"
+	}
+	return s.config.Version
+}
+
+// info returns the build and runtime information of the server
+func (s *MCPServer) info() Info {
+	s.mu.RLock()
+	providers := append([]string{}, s.toolProviderNames...)
+	s.mu.RUnlock()
+
+	return Info{
+		Name:            serverName,
+		Version:         s.version(),
+		BuildTime:       s.config.BuildTime,
+		GoVersion:       runtime.Version(),
+		ProtocolVersion: mcp.ProtocolVersion,
+		StartedAt:       s.startedAt.UTC(),
+		UptimeSeconds:   int64(time.Since(s.startedAt).Seconds()),
+		Providers:       providers,
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// ToolHandler executes a tool call. A provider's CallTool method is the innermost handler.
+type ToolHandler func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error)
+
+// ToolMiddleware wraps a ToolHandler to add behaviour around every tool call,
+// regardless of which provider serves it
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+// UseToolMiddleware adds middleware around tool dispatch. Middleware registered
+// first runs outermost.
+func (s *MCPServer) UseToolMiddleware(middleware ToolMiddleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toolMiddleware = append(s.toolMiddleware, middleware)
+}
+
+// toolHandler builds the middleware chain around provider.CallTool. The tool's
+// timeout applies to the provider alone. The result cache, when enabled, sits
+// next so cache hits still pass through every registered middleware, such as
+// the audit log, which then also records timeouts.
+func (s *MCPServer) toolHandler(provider mcp.ToolProvider) ToolHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	handler := s.withToolTimeout(provider.CallTool)
+	if s.toolCache != nil {
+		handler = s.toolCache.middleware()(handler)
+	}
+	for i := len(s.toolMiddleware) - 1; i >= 0; i-- {
+		handler = s.toolMiddleware[i](handler)
+	}
+	return s.countToolCalls(handler)
+}
+
+// countToolCalls counts the calls made through next, for /status/stream
+func (s *MCPServer) countToolCalls(next ToolHandler) ToolHandler {
+	return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+		atomic.AddInt64(&s.toolCalls, 1)
+		return next(ctx, request)
+	}
+}
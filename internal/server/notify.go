@@ -0,0 +1,161 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// NotificationPriority decides what happens to a notification sent while the
+// client's outbound buffer is full
+type NotificationPriority int
+
+const (
+	// NotificationDroppable notifications, such as progress updates, are only
+	// worth their latest value: the oldest buffered droppable notification is
+	// dropped to make room
+	NotificationDroppable NotificationPriority = iota
+	// NotificationCritical notifications must not be lost: a client too slow to
+	// take them is disconnected
+	NotificationCritical
+)
+
+var (
+	errNotificationBufferFull = errors.New("notification buffer full")
+	errConnectionClosed       = errors.New("connection closed")
+)
+
+// queuedMessage is one buffered outbound notification, or a request the
+// server sends to the client
+type queuedMessage struct {
+	message  interface{}
+	method   string
+	priority NotificationPriority
+
+	// written, when set, receives the outcome of writing the message: nil
+	// once it is written, or the error that kept it from being written
+	written chan error
+}
+
+// done reports the outcome of writing the message to whoever waits for it
+func (q queuedMessage) done(err error) {
+	if q.written != nil {
+		q.written <- err
+	}
+}
+
+// outbox buffers a connection's outbound notifications for the dispatcher's
+// writer goroutine, so that a slow client never blocks the server code
+// sending them
+type outbox struct {
+	size int
+
+	mu     sync.Mutex
+	queue  []queuedMessage
+	closed bool
+
+	wake chan struct{} // signalled when the queue gains an entry or closes
+}
+
+func newOutbox(size int) *outbox {
+	if size < 1 {
+		size = 1
+	}
+	return &outbox{
+		size: size,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// push buffers a notification. When the buffer is full, a droppable
+// notification replaces the oldest droppable one (or is itself dropped when
+// every buffered notification is critical), and a critical one fails with
+// errNotificationBufferFull.
+func (o *outbox) push(n queuedMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return errConnectionClosed
+	}
+	if len(o.queue) >= o.size {
+		if n.priority == NotificationCritical {
+			return errNotificationBufferFull
+		}
+		dropped := false
+		for i, queued := range o.queue {
+			if queued.priority == NotificationDroppable {
+				queued.done(errNotificationBufferFull)
+				o.queue = append(o.queue[:i], o.queue[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			n.done(errNotificationBufferFull)
+			return nil
+		}
+	}
+
+	o.queue = append(o.queue, n)
+	o.signal()
+	return nil
+}
+
+// take removes and returns every buffered notification; ok is false once the
+// outbox is closed
+func (o *outbox) take() (queue []queuedMessage, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	queue, o.queue = o.queue, nil
+	return queue, !o.closed
+}
+
+// close stops the outbox; buffered notifications are discarded
+func (o *outbox) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.closed = true
+	for _, queued := range o.queue {
+		queued.done(errConnectionClosed)
+	}
+	o.queue = nil
+	o.signal()
+}
+
+// signal wakes the writer without blocking; o.mu must be held
+func (o *outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notify sends a server-initiated notification to the client. It never blocks
+// on the client: the notification is buffered for the connection's writer, and
+// a critical notification that does not fit closes the connection.
+func (c *Connection) notify(notification *mcp.Notification, priority NotificationPriority) error {
+	return c.send(queuedMessage{message: notification, method: notification.Method, priority: priority})
+}
+
+// send buffers an outbound message for the connection's writer. A message
+// with a written channel gets an outcome on it unless send returns an error.
+func (c *Connection) send(queued queuedMessage) error {
+	c.mu.Lock()
+	outbox := c.outbox
+	c.mu.Unlock()
+	if outbox == nil {
+		return errConnectionClosed
+	}
+
+	err := outbox.push(queued)
+	if errors.Is(err, errNotificationBufferFull) && c.conn != nil {
+		log.Printf("Closing connection %s: notification buffer of %d is full", c.conn.RemoteAddr(), outbox.size)
+		c.conn.Close()
+	}
+	return err
+}
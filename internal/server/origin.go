@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// checkOrigin decides whether a WebSocket handshake is accepted, by its Origin
+// header. Without Config.AllowedOrigins every origin is; requests without an
+// Origin header come from non-browser clients and are always accepted.
+func (s *MCPServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(s.config.AllowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowed := range s.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// outputValidationMiddleware checks successful results of tools that declare an
+// OutputSchema against it and reports mismatches through logf. Results are
+// passed through unchanged; the check exists to catch regressions in debug mode.
+func (s *MCPServer) outputValidationMiddleware(logf func(format string, args ...interface{})) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+			response, err := next(ctx, request)
+			if err != nil || response == nil || response.IsError {
+				return response, err
+			}
+
+			schema := s.outputSchema(ctx, request.Name)
+			if schema == nil {
+				return response, err
+			}
+
+			if response.StructuredContent == nil {
+				logf("%sOUTPUT SCHEMA MISMATCH: tool %s declares an output schema but returned no structured content", logPrefix(ctx), request.Name)
+				return response, err
+			}
+
+			value, normalizeErr := mcp.NormalizeJSON(response.StructuredContent)
+			if normalizeErr != nil {
+				logf("%sOUTPUT SCHEMA MISMATCH: tool %s returned structured content that cannot be encoded: %v", logPrefix(ctx), request.Name, normalizeErr)
+				return response, err
+			}
+			if validateErr := mcp.ValidateSchema(schema, value); validateErr != nil {
+				logf("%sOUTPUT SCHEMA MISMATCH: tool %s: %v", logPrefix(ctx), request.Name, validateErr)
+			}
+
+			return response, err
+		}
+	}
+}
+
+// outputSchema returns the output schema declared by the named tool, if any
+func (s *MCPServer) outputSchema(ctx context.Context, name string) map[string]interface{} {
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool.OutputSchema
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// pageBounds is the half-open [start, end) slice range of one page
+type pageBounds struct {
+	start int
+	end   int
+}
+
+// encodeCursor turns an offset into the opaque cursor handed to clients
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor; an empty cursor is offset 0
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("malformed cursor: %w", err)
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("malformed cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// paginate returns the bounds of the page starting at offset and the cursor for
+// the following page, or "" when this is the last one. A pageSize of 0 or less
+// returns everything from offset onwards.
+func paginate(total, offset, pageSize int) (pageBounds, string) {
+	if offset > total {
+		offset = total
+	}
+	if pageSize <= 0 || offset+pageSize >= total {
+		return pageBounds{start: offset, end: total}, ""
+	}
+
+	end := offset + pageSize
+	return pageBounds{start: offset, end: end}, encodeCursor(end)
+}
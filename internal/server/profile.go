@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// Built-in tool profiles, available unless Config.ToolProfiles redefines them
+const (
+	ToolProfileFull     = "full"     // every tool
+	ToolProfileReadOnly = "readonly" // tools annotated as read-only
+)
+
+// toolProfile is a named subset of the registered tools: the only tools a
+// connection using it lists and calls
+type toolProfile struct {
+	name     string
+	patterns []string // tool names or path.Match patterns such as "db_*"
+	readOnly bool     // select tools annotated as read-only instead of by pattern
+}
+
+// allows reports whether tool belongs to the profile. A tool that requires
+// an allowlist entry only does when one of the patterns is its exact name.
+func (p *toolProfile) allows(tool mcp.Tool) bool {
+	if tool.RequiresAllowlist {
+		for _, pattern := range p.patterns {
+			if pattern == tool.Name {
+				return true
+			}
+		}
+		return false
+	}
+	if p.readOnly {
+		return tool.Annotations != nil && tool.Annotations.ReadOnlyHint
+	}
+	for _, pattern := range p.patterns {
+		if matched, _ := path.Match(pattern, tool.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// toolProfile returns the named profile: one of Config.ToolProfiles, or a
+// built-in one
+func (c Config) toolProfile(name string) (*toolProfile, bool) {
+	if patterns, ok := c.ToolProfiles[name]; ok {
+		return &toolProfile{name: name, patterns: patterns}, true
+	}
+	switch name {
+	case ToolProfileFull:
+		return &toolProfile{name: name, patterns: []string{"*"}}, true
+	case ToolProfileReadOnly:
+		return &toolProfile{name: name, readOnly: true}, true
+	}
+	return nil, false
+}
+
+// HasToolProfile reports whether name is a configured or built-in tool profile
+func (c Config) HasToolProfile(name string) bool {
+	_, ok := c.toolProfile(name)
+	return ok
+}
+
+// ParseToolProfiles parses tool profiles written as a comma-separated list of
+// name=patterns entries, the patterns of a profile separated by "|", e.g.
+// "db-admin=db_*|describe_tool,search=web_*"
+func ParseToolProfiles(value string) (map[string][]string, error) {
+	profiles := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawPatterns, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid tool profile %q: expected name=patterns", entry)
+		}
+		var patterns []string
+		for _, pattern := range strings.Split(rawPatterns, "|") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q in tool profile %s: %w", pattern, name, err)
+			}
+			patterns = append(patterns, pattern)
+		}
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("tool profile %s selects no tools", name)
+		}
+		profiles[name] = patterns
+	}
+	return profiles, nil
+}
+
+type toolProfileKey struct{}
+
+// withToolProfile returns a context restricting the tools listed and called
+// with it to those of profile; a nil profile leaves every tool available
+func withToolProfile(ctx context.Context, profile *toolProfile) context.Context {
+	if profile == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, toolProfileKey{}, profile)
+}
+
+// toolAllowed reports whether tool may be listed and called with ctx
+func toolAllowed(ctx context.Context, tool mcp.Tool) bool {
+	profile, ok := ctx.Value(toolProfileKey{}).(*toolProfile)
+	if !ok {
+		return !tool.RequiresAllowlist
+	}
+	return profile.allows(tool)
+}
+
+// activeToolProfile returns the profile the connection selected at
+// initialize, or Config.DefaultToolProfile; nil when neither names one. An
+// unknown default profile exposes no tools rather than all of them.
+func (c *Connection) activeToolProfile() *toolProfile {
+	c.mu.Lock()
+	name := c.toolProfile
+	c.mu.Unlock()
+	if name == "" {
+		name = c.server.config.DefaultToolProfile
+	}
+	if name == "" {
+		return nil
+	}
+	if profile, ok := c.server.config.toolProfile(name); ok {
+		return profile
+	}
+	return &toolProfile{name: name}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// responseMetaMiddleware adds the tool name, duration and start time of each
+// call to the _meta of its response. Registered first, it runs outermost, so
+// the duration covers every other middleware and a cached result still
+// reports the time of the call that returned it. Meta the tool set itself is
+// kept unless it uses one of the same keys.
+func responseMetaMiddleware(now func() time.Time) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+			start := now()
+			response, err := next(ctx, request)
+			if err != nil || response == nil {
+				return response, err
+			}
+
+			// The response may be shared, e.g. by a provider returning a fixed
+			// value, so the metadata goes on a copy
+			wrapped := *response
+			wrapped.Meta = make(map[string]interface{}, len(response.Meta)+3)
+			for key, value := range response.Meta {
+				wrapped.Meta[key] = value
+			}
+			wrapped.Meta["tool"] = request.Name
+			wrapped.Meta["durationMs"] = now().Sub(start).Milliseconds()
+			wrapped.Meta["timestamp"] = start.UTC().Format(time.RFC3339Nano)
+			return &wrapped, nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// rootsRequestTimeout bounds how long the server waits for a client to answer roots/list
+const rootsRequestTimeout = 30 * time.Second
+
+// supportsRoots reports whether the client declared the roots capability in initialize
+func (c *Connection) supportsRoots() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clientCapabilities.Roots != nil
+}
+
+// Roots returns the filesystem roots the client last reported, or nil when it
+// has not reported any
+func (c *Connection) Roots() []mcp.Root {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]mcp.Root(nil), c.roots...)
+}
+
+// ListRoots asks the client for its filesystem roots with a roots/list request
+// and stores them for the connection. The client must have declared the roots
+// capability.
+func (c *Connection) ListRoots(ctx context.Context) ([]mcp.Root, error) {
+	if !c.supportsRoots() {
+		return nil, fmt.Errorf("client does not support %s", mcp.MethodListRoots)
+	}
+
+	result, err := c.Call(ctx, mcp.MethodListRoots, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response mcp.ListRootsResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, fmt.Errorf("invalid %s result: %w", mcp.MethodListRoots, err)
+	}
+
+	c.mu.Lock()
+	c.roots = response.Roots
+	c.mu.Unlock()
+	return response.Roots, nil
+}
+
+// refreshRoots fetches the client's roots in the background, once the client
+// is initialized and whenever it reports that they changed. It runs outside the
+// read loop, which must stay free to receive the response.
+func (c *Connection) refreshRoots() {
+	if !c.supportsRoots() {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), rootsRequestTimeout)
+		defer cancel()
+
+		roots, err := c.ListRoots(ctx)
+		if err != nil {
+			log.Printf("Failed to list client roots: %v", err)
+			return
+		}
+		log.Printf("Client reported %d roots", len(roots))
+	}()
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+var _ mcp.Sampler = (*Connection)(nil)
+
+// supportsSampling reports whether the client declared the sampling capability
+// in initialize
+func (c *Connection) supportsSampling() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clientCapabilities.Sampling != nil
+}
+
+// CreateMessage asks the client's model for a completion with a
+// sampling/createMessage request. The client must have declared the sampling
+// capability.
+func (c *Connection) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	if !c.supportsSampling() {
+		return nil, fmt.Errorf("client does not support %s", mcp.MethodCreateMessage)
+	}
+
+	result, err := c.Call(ctx, mcp.MethodCreateMessage, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var message mcp.CreateMessageResult
+	if err := json.Unmarshal(result, &message); err != nil {
+		return nil, fmt.Errorf("invalid %s result: %w", mcp.MethodCreateMessage, err)
+	}
+	return &message, nil
+}
+
+// withSampler lets the request's tools ask the client's model for completions,
+// when the client supports sampling. Requests handled in order are left
+// without: the read loop would be blocked waiting on the tool, and could never
+// receive the client's answer.
+func (c *Connection) withSampler(ctx context.Context) context.Context {
+	if c.server.config.MaxConcurrentRequests <= 1 || !c.supportsSampling() {
+		return ctx
+	}
+	return mcp.ContextWithSampler(ctx, c)
+}
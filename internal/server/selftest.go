@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// selfTestCollection holds the throwaway document written by the self-test
+const selfTestCollection = "selftest"
+
+// SelfTestStep is the outcome of one self-test step
+type SelfTestStep struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// SelfTestReport lists the outcome of every self-test step, in order
+type SelfTestReport struct {
+	Steps []SelfTestStep
+}
+
+// Passed reports whether every step succeeded
+func (r *SelfTestReport) Passed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders one PASS or FAIL line per step, followed by a summary
+func (r *SelfTestReport) String() string {
+	var b strings.Builder
+	failed := 0
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			failed++
+			fmt.Fprintf(&b, "FAIL %s (%s): %v\n", step.Name, step.Duration.Round(time.Millisecond), step.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "PASS %s (%s)\n", step.Name, step.Duration.Round(time.Millisecond))
+	}
+	if failed > 0 {
+		fmt.Fprintf(&b, "Self-test failed: %d of %d steps failed\n", failed, len(r.Steps))
+	} else {
+		fmt.Fprintf(&b, "Self-test passed: %d steps\n", len(r.Steps))
+	}
+	return b.String()
+}
+
+// SelfTest runs a scripted check of the registered tools without starting a
+// listener: a database health check, creating, reading and deleting a
+// throwaway document, a web search and a call to each math tool. Calls go
+// through the tool middleware chain, as client calls would. Every step runs,
+// except that the document is only read and deleted once it was created.
+func (s *MCPServer) SelfTest(ctx context.Context) *SelfTestReport {
+	report := &SelfTestReport{}
+	step := func(name string, run func() error) bool {
+		start := time.Now()
+		err := run()
+		report.Steps = append(report.Steps, SelfTestStep{Name: name, Err: err, Duration: time.Since(start)})
+		return err == nil
+	}
+
+	step("database health check", func() error {
+		_, err := s.selfTestCall(ctx, "db_health_check", nil)
+		return err
+	})
+
+	id := "selftest-" + newCorrelationID()
+	content := "Self-test document " + id
+	created := step("create document", func() error {
+		_, err := s.selfTestCall(ctx, "db_create_document", map[string]interface{}{
+			"collection": selfTestCollection,
+			"id":         id,
+			"title":      "Self-test",
+			"content":    content,
+		})
+		return err
+	})
+	if created {
+		step("read document", func() error {
+			response, err := s.selfTestCall(ctx, "db_get_document", map[string]interface{}{
+				"collection": selfTestCollection,
+				"id":         id,
+			})
+			if err != nil {
+				return err
+			}
+			for _, c := range response.Content {
+				if strings.Contains(c.Text, content) {
+					return nil
+				}
+			}
+			return fmt.Errorf("document %s was read back without its content", id)
+		})
+		step("delete document", func() error {
+			_, err := s.selfTestCall(ctx, "db_delete_document", map[string]interface{}{
+				"collection": selfTestCollection,
+				"id":         id,
+			})
+			return err
+		})
+	}
+
+	step("web search", func() error {
+		_, err := s.selfTestCall(ctx, "web_search", map[string]interface{}{
+			"query":       "model context protocol",
+			"max_results": 1,
+		})
+		return err
+	})
+
+	mathCalls := []struct {
+		name string
+		args map[string]interface{}
+		want float64
+	}{
+		{"add", map[string]interface{}{"a": 2, "b": 3}, 5},
+		{"multiply", map[string]interface{}{"a": 4, "b": 5}, 20},
+		{"power", map[string]interface{}{"base": 2, "exponent": 10}, 1024},
+	}
+	for _, call := range mathCalls {
+		step("math tool "+call.name, func() error {
+			response, err := s.selfTestCall(ctx, call.name, call.args)
+			if err != nil {
+				return err
+			}
+			structured, err := mcp.NormalizeJSON(response.StructuredContent)
+			if err != nil {
+				return fmt.Errorf("invalid structured result: %v", err)
+			}
+			fields, _ := structured.(map[string]interface{})
+			if result, ok := fields["result"].(float64); !ok || result != call.want {
+				return fmt.Errorf("expected result %g, got %v", call.want, structured)
+			}
+			return nil
+		})
+	}
+
+	return report
+}
+
+// selfTestCall calls a registered tool, turning a tool error result into an error
+func (s *MCPServer) selfTestCall(ctx context.Context, name string, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
+	provider, ok := s.findToolProvider(ctx, name)
+	if !ok {
+		return nil, fmt.Errorf("tool not registered: %s", name)
+	}
+
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	response, err := s.toolHandler(provider)(ctx, mcp.ToolCallRequest{Name: name, Arguments: args})
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("tool %s returned no result", name)
+	}
+	if response.IsError {
+		message := "tool returned an error"
+		if len(response.Content) > 0 {
+			message = response.Content[0].Text
+		}
+		return nil, errors.New(message)
+	}
+	return response, nil
+}
@@ -2,97 +2,478 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins in this example
-		// In production, implement proper origin checking
-		return true
-	},
+// Config holds MCP server configuration
+type Config struct {
+	// Addr is the address Start listens on when it is given none
+	Addr string `json:"addr"`
+
+	MaxConnections int           `json:"max_connections"` // 0 means unlimited
+	SessionTTL     time.Duration `json:"session_ttl"`     // how long a disconnected session can be resumed; 0 disables resumption
+	ToolsPageSize  int           `json:"tools_page_size"` // tools returned per tools/list page; 0 returns all tools at once
+	IdleTimeout    time.Duration `json:"idle_timeout"`    // connections that send no message for this long are closed; 0 disables
+	WriteTimeout   time.Duration `json:"write_timeout"`   // a write to a client not reading for this long closes the connection; 0 disables
+
+	// ShutdownTimeout is how long the server waits for in-flight requests when
+	// it stops after its context is done, before closing the connections
+	// anyway. 0 closes them immediately.
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+
+	// DefaultToolTimeout bounds how long a tool call may run: the tool's context
+	// is cancelled when it expires and the call reports a timeout. ToolTimeouts
+	// overrides it for individual tools, by name. 0 means no limit.
+	DefaultToolTimeout time.Duration            `json:"default_tool_timeout"`
+	ToolTimeouts       map[string]time.Duration `json:"tool_timeouts,omitempty"`
+
+	// MaxConcurrentRequests is how many requests of a single connection are
+	// handled at once, so a slow tool call does not hold up quick ones sent
+	// after it. Responses may then arrive out of order, matched by id. 0 or 1
+	// handles each connection's requests strictly in order.
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+
+	// NotificationBuffer is how many server-initiated notifications are buffered
+	// per connection while the client is slow to read them. When it is full,
+	// droppable notifications (progress) replace the oldest droppable one and a
+	// critical one closes the connection.
+	NotificationBuffer int `json:"notification_buffer"`
+
+	// ExternalURL is the base URL clients use to reach the server (e.g. through an
+	// ingress). It only affects the endpoints advertised by /health, never the bind
+	// address; when empty they are derived from each request.
+	ExternalURL string `json:"external_url,omitempty"`
+
+	// TrustProxyHeaders derives those endpoints from the X-Forwarded-Proto and
+	// X-Forwarded-Host headers. Enable it only behind a proxy that sets them,
+	// since any client can send them otherwise.
+	TrustProxyHeaders bool `json:"trust_proxy_headers"`
+
+	// AllowNullID accepts requests sent with "id": null and handles them as
+	// notifications. By default they are rejected as invalid requests, since
+	// JSON-RPC requests must carry a string or number id.
+	AllowNullID bool `json:"allow_null_id"`
+
+	// RequireInitialized rejects requests other than initialize until the
+	// client sends the initialized notification, as the protocol requires.
+	// Turning it off serves lenient clients that call tools right away.
+	RequireInitialized bool `json:"require_initialized"`
+
+	// PreciseNumbers decodes the numbers in requests as json.Number instead of
+	// float64, so integer ids and arguments above 2^53 keep their exact value
+	PreciseNumbers bool `json:"precise_numbers"`
+
+	// ToolNameConflicts decides what RegisterToolProvider does when a provider
+	// declares a tool name that is already registered: ToolConflictStrict (the
+	// default) rejects the provider, ToolConflictPrefix exposes the new tool as
+	// "<provider>_<tool>".
+	ToolNameConflicts string `json:"tool_name_conflicts"`
+
+	// ToolProfiles are named subsets of the tools, each a list of tool names or
+	// patterns such as "db_*". A client selects one with the toolProfile hint
+	// of its initialize meta and then only lists and calls the tools of its
+	// role. "full" (every tool) and "readonly" (tools annotated as read-only)
+	// are built in unless redefined here. DefaultToolProfile applies to clients
+	// that send no hint; empty exposes every tool.
+	ToolProfiles       map[string][]string `json:"tool_profiles,omitempty"`
+	DefaultToolProfile string              `json:"default_tool_profile,omitempty"`
+
+	// AllowedOrigins are the browser origins, such as "https://app.example.com",
+	// allowed to open WebSocket connections; "*" allows any. Empty allows every
+	// origin. Clients that send no Origin header are always accepted.
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+
+	// Subprotocols are the WebSocket subprotocols the server supports, in order
+	// of preference. The handshake echoes the first one the client offers in
+	// Sec-WebSocket-Protocol; clients offering none of them, or none at all,
+	// connect without a subprotocol.
+	Subprotocols []string `json:"subprotocols,omitempty"`
+
+	// APIKeys are accepted by endpoints that require authentication, such as
+	// /export. With no keys configured those endpoints reject every request.
+	APIKeys []string `json:"-"`
+
+	// CollectionAccess maps API keys to the collections clients presenting
+	// them may access, as names or path.Match patterns such as "tenant_a_*".
+	// When set, WebSocket clients must present one of these keys in the
+	// handshake, as for /export, and the database tools, document and export
+	// resources and /export deny access to any other collection. TCP clients
+	// cannot present a key, so they get no collections. Empty leaves every
+	// collection open.
+	CollectionAccess map[string][]string `json:"-"`
+
+	SlowCallThreshold      time.Duration `json:"slow_call_threshold"`      // tool calls slower than this are logged; 0 disables
+	LargeResponseThreshold int           `json:"large_response_threshold"` // tool responses larger than this many bytes are logged; 0 disables
+
+	// ResponseMeta adds a _meta object to every tool call response with the
+	// tool name, the call's duration in milliseconds and the time it started,
+	// so clients get uniform telemetry whichever tool they call
+	ResponseMeta bool `json:"response_meta"`
+
+	// LogRequests logs the start and completion of every request, tagged with
+	// its correlation id, and adds the id to the Data of error responses
+	LogRequests bool `json:"log_requests"`
+
+	// ToolCacheTTL enables caching of read-only tool results, keyed by tool name
+	// and arguments, for this long. Successful calls to other tools invalidate
+	// the entries of the collections they name. 0 disables the cache.
+	ToolCacheTTL time.Duration `json:"tool_cache_ttl"`
+
+	// CompressResponses gzip-compresses the responses of the HTTP endpoints,
+	// such as /tools and /export, for clients that send Accept-Encoding: gzip.
+	// The WebSocket endpoint is not affected.
+	CompressResponses bool `json:"compress_responses"`
+
+	// StatusInterval is how often /status/stream sends the server status
+	StatusInterval time.Duration `json:"status_interval"`
+
+	// PrettyJSON indents the JSON that tools embed in their text content, for
+	// human readers; by default it is compact to save bandwidth
+	PrettyJSON bool `json:"pretty_json"`
+
+	// TLSCertFile and TLSKeyFile are the PEM certificate and private key used to
+	// serve HTTPS and wss://. Both empty serves plain HTTP.
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"-"`
+
+	// Version is the server version reported in the initialize response and by
+	// the server_info tool, normally injected into the binary at build time;
+	// empty reports "dev". BuildTime is when the binary was built, if known.
+	Version   string `json:"version,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+
+	// Debug enables development checks, such as validating tool results
+	// against their declared output schema and logging mismatches
+	Debug bool `json:"debug"`
+}
+
+// DefaultConfig returns a default MCP server configuration
+func DefaultConfig() Config {
+	return Config{
+		Addr:           "localhost:8080",
+		MaxConnections: 0,
+		SessionTTL:     10 * time.Minute,
+		ToolsPageSize:  50,
+		WriteTimeout:   30 * time.Second,
+
+		ShutdownTimeout: 30 * time.Second,
+
+		DefaultToolTimeout: 60 * time.Second,
+
+		RequireInitialized: true,
+		PreciseNumbers:     true,
+		CompressResponses:  true,
+
+		MaxConcurrentRequests: 8,
+		NotificationBuffer:    64,
+
+		ToolNameConflicts: ToolConflictStrict,
+		Subprotocols:      []string{"mcp"},
+
+		SlowCallThreshold: 5 * time.Second,
+
+		StatusInterval: defaultStatusInterval,
+	}
 }
 
 // MCPServer implements the MCP server
 type MCPServer struct {
-	mu                sync.RWMutex
-	toolProviders     []mcp.ToolProvider
-	resourceProviders []mcp.ResourceProvider
-	connections       map[*websocket.Conn]*Connection
-	server            *http.Server
-	initialized       bool
+	mu                  sync.RWMutex
+	config              Config
+	toolProviders       []mcp.ToolProvider
+	toolProviderNames   []string // names of toolProviders, by index
+	toolMiddleware      []ToolMiddleware
+	toolCache           *toolCache
+	exporter            CollectionExporter
+	healthChecks        map[string]HealthChecker // dependencies reported on /status/stream
+	resourceProviders   []mcp.ResourceProvider
+	completionProviders []mcp.CompletionProvider
+	connections         map[transport]*Connection
+	tcpListener         net.Listener
+	sessions            *sessionStore
+	activeConnections   int
+	rejectedConnections int64
+	activeRequests      int64 // messages being handled, accessed atomically
+	toolCalls           int64 // tool calls made, accessed atomically
+	server              *http.Server
+	upgrader            websocket.Upgrader
+	initialized         bool
+	startedAt           time.Time
+	done                chan struct{} // closed when the HTTP server shuts down, ending status streams
+	doneOnce            sync.Once
+}
+
+// transport is the network connection underlying a Connection: a WebSocket
+// or a plain TCP socket
+type transport interface {
+	RemoteAddr() net.Addr
+	Close() error
 }
 
 // Connection represents a client connection
 type Connection struct {
-	conn        *websocket.Conn
-	server      *MCPServer
-	initialized bool
-	mu          sync.Mutex
+	conn          transport
+	server        *MCPServer
+	initialized   bool
+	sessionID     string
+	clientInfo    *mcp.ClientInfo // from the initialize request; nil until then or when not sent
+	toolProfile   string          // profile named in the initialize meta; "" uses Config.DefaultToolProfile
+	collections   []string        // granted by the API key of the handshake when Config.CollectionAccess is set; fixed once connected
+	subscriptions map[string]bool
+	outbox        *outbox // outbound notifications; nil until the dispatcher starts
+
+	clientCapabilities mcp.ClientCapabilities       // from the initialize request
+	roots              []mcp.Root                   // as last reported by the client
+	clientRequests     map[string]chan *mcp.Message // server requests awaiting a response, by id
+	mu                 sync.Mutex                   // guards the session state above, not request handling
+
+	lastClientRequestID int64 // accessed atomically
+
+	// lastActivity is when the client last sent a message, in Unix nanoseconds;
+	// accessed atomically so the idle reaper never waits on a running request
+	lastActivity int64
+}
+
+// newConnection creates the per-client state for a WebSocket connection
+func newConnection(conn transport, server *MCPServer) *Connection {
+	c := &Connection{
+		conn:          conn,
+		server:        server,
+		subscriptions: make(map[string]bool),
+	}
+	c.touch()
+	return c
+}
+
+// isInitialized reports whether the client has sent the initialized notification
+func (c *Connection) isInitialized() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.initialized
+}
+
+// ready reports whether the connection may serve requests: once the client
+// has sent the initialized notification, or right away when
+// Config.RequireInitialized is off
+func (c *Connection) ready() bool {
+	return !c.server.config.RequireInitialized || c.isInitialized()
+}
+
+// detach saves the connection's session so a reconnecting client can resume it
+func (c *Connection) detach() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.server.sessions.save(c.sessionID, c.initialized, c.subscriptions)
 }
 
-// NewMCPServer creates a new MCP server instance
+// NewMCPServer creates a new MCP server instance with the default configuration
 func NewMCPServer() *MCPServer {
-	return &MCPServer{
-		connections: make(map[*websocket.Conn]*Connection),
+	return NewServerWithConfig(DefaultConfig())
+}
+
+// NewServerWithConfig creates a new MCP server instance with the given configuration
+func NewServerWithConfig(config Config) *MCPServer {
+	s := &MCPServer{
+		config:      config,
+		connections: make(map[transport]*Connection),
+		sessions:    newSessionStore(config.SessionTTL),
+		startedAt:   time.Now(),
+		done:        make(chan struct{}),
+	}
+	s.upgrader.CheckOrigin = s.checkOrigin
+	s.upgrader.Subprotocols = config.Subprotocols
+	if config.ToolCacheTTL > 0 {
+		s.toolCache = newToolCache(s, config.ToolCacheTTL)
+	}
+	s.RegisterToolProvider(newBuiltinToolProvider(s))
+	if config.ResponseMeta {
+		s.UseToolMiddleware(responseMetaMiddleware(time.Now))
+	}
+	if config.SlowCallThreshold > 0 || config.LargeResponseThreshold > 0 {
+		s.UseToolMiddleware(callLogMiddleware(config.SlowCallThreshold, config.LargeResponseThreshold, log.Printf))
+	}
+	if config.Debug {
+		s.UseToolMiddleware(s.outputValidationMiddleware(log.Printf))
 	}
+	return s
 }
 
-// Start starts the MCP server
-func (s *MCPServer) Start(ctx context.Context, addr string) error {
+// routes returns the HTTP handler serving every server endpoint
+func (s *MCPServer) routes() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/mcp", s.handleWebSocket)
 	mux.HandleFunc("/health", s.handleHealth)
-	
-	s.server = &http.Server{
-		Addr:    addr,
-		Handler: mux,
+	mux.HandleFunc("/tools", s.handleTools)
+	mux.HandleFunc("/status/stream", s.handleStatusStream)
+	mux.HandleFunc("/export/", s.requireAPIKey(s.handleExport))
+	return s.withGzip(mux)
+}
+
+// Start starts the MCP server on addr, or on Config.Addr when addr is empty.
+// When Config.TLSCertFile and Config.TLSKeyFile are set it serves HTTPS, so
+// clients connect with wss://.
+func (s *MCPServer) Start(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = s.config.Addr
+	}
+
+	tlsConfig, err := LoadTLSConfig(s.config.TLSCertFile, s.config.TLSKeyFile)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Starting MCP server on %s", addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	if tlsConfig != nil {
+		log.Printf("Starting MCP server on %s (TLS)", addr)
+	} else {
+		log.Printf("Starting MCP server on %s", addr)
+	}
+	return s.serve(ctx, listener, tlsConfig)
+}
+
+// serve runs the HTTP server on listener, over TLS when tlsConfig is set,
+// until ctx is done
+func (s *MCPServer) serve(ctx context.Context, listener net.Listener, tlsConfig *tls.Config) error {
+	httpServer := &http.Server{
+		Addr:      listener.Addr().String(),
+		Handler:   s.routes(),
+		TLSConfig: tlsConfig,
+	}
+	// Shutdown does not wait for long-lived responses such as /status/stream
+	// to end by themselves, so tell them to return
+	httpServer.RegisterOnShutdown(s.closeDone)
+	s.mu.Lock()
+	s.server = httpServer
+	s.mu.Unlock()
+
+	go s.reapIdleConnections(ctx)
 	
 	// Start server in a goroutine
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Printf("Server error: %v", err)
 		}
 	}()
 
 	// Wait for context cancellation
 	<-ctx.Done()
-	return s.Stop(context.Background())
+	stopCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	return s.Stop(stopCtx)
 }
 
-// Stop stops the MCP server
+// shutdownPollInterval is how often Stop checks whether in-flight requests have finished
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Stop shuts the server down gracefully: it stops accepting connections, lets
+// in-flight HTTP handlers and requests finish, then closes the remaining
+// connections. When ctx expires first, the connections are closed anyway and
+// ctx's error is returned.
 func (s *MCPServer) Stop(ctx context.Context) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	httpServer, tcpListener := s.server, s.tcpListener
+	s.mu.Unlock()
+
+	if tcpListener != nil {
+		tcpListener.Close()
+	}
+
+	var err error
+	if httpServer != nil {
+		// Upgraded WebSocket connections are not tracked by Shutdown, which
+		// only waits for plain HTTP handlers such as /export
+		err = httpServer.Shutdown(ctx)
+	}
+	if drainErr := s.waitForRequests(ctx); err == nil {
+		err = drainErr
+	}
 
-	// Close all connections
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for conn := range s.connections {
 		conn.Close()
 	}
-	s.connections = make(map[*websocket.Conn]*Connection)
+	s.connections = make(map[transport]*Connection)
+	return err
+}
 
-	if s.server != nil {
-		return s.server.Shutdown(ctx)
+// closeDone closes s.done, once
+func (s *MCPServer) closeDone() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// waitForRequests blocks until no request is being handled on any connection,
+// or until ctx is done
+func (s *MCPServer) waitForRequests(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(&s.activeRequests) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 	}
 	return nil
 }
 
-// RegisterToolProvider registers a tool provider
-func (s *MCPServer) RegisterToolProvider(provider mcp.ToolProvider) {
+// RegisterToolProvider registers a tool provider. Tool names must be unique
+// across providers; a name that is already taken is handled according to
+// Config.ToolNameConflicts. Providers implementing mcp.JSONFormatter are set to
+// Config.PrettyJSON.
+func (s *MCPServer) RegisterToolProvider(provider mcp.ToolProvider) error {
+	ctx := context.Background()
+
+	tools, err := provider.ListTools(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tools of %s: %w", providerName(provider, -1), err)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
+	taken, err := s.registeredToolNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := providerName(provider, len(s.toolProviders))
+	resolved, err := resolveToolNames(s.config.ToolNameConflicts, name, tools, taken)
+	if err != nil {
+		return err
+	}
+	if formatter, ok := provider.(mcp.JSONFormatter); ok {
+		formatter.SetPrettyJSON(s.config.PrettyJSON)
+	}
+	if len(resolved) > 0 {
+		provider = newRenamedToolProvider(provider, resolved)
+	}
+
 	s.toolProviders = append(s.toolProviders, provider)
+	s.toolProviderNames = append(s.toolProviderNames, name)
+	return nil
 }
 
 // RegisterResourceProvider registers a resource provider
@@ -104,49 +485,99 @@ func (s *MCPServer) RegisterResourceProvider(provider mcp.ResourceProvider) {
 
 // handleWebSocket handles WebSocket connections
 func (s *MCPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+	var collections []string
+	if len(s.config.CollectionAccess) > 0 {
+		granted, ok := s.config.collectionPatterns(apiKeyFromRequest(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		collections = granted
+	}
+
+	if !s.acquireConnectionSlot() {
+		rejected := atomic.AddInt64(&s.rejectedConnections, 1)
+		log.Printf("Rejecting connection from %s: connection limit of %d reached (%d rejected so far)",
+			clientInfo(r), s.config.MaxConnections, rejected)
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
 		return
 	}
+	defer s.releaseConnectionSlot()
 
-	connection := &Connection{
-		conn:   conn,
-		server: s,
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade connection from %s: %v", clientInfo(r), err)
+		return
 	}
+	log.Printf("Client connected: %s", clientInfo(r))
+
+	connection := newConnection(conn, s)
+	connection.collections = collections
 
 	s.mu.Lock()
 	s.connections[conn] = connection
 	s.mu.Unlock()
 
+	dispatcher := newDispatcher(connection, withWriteTimeout(conn, s.config.WriteTimeout, conn.WriteJSON))
+
 	defer func() {
 		s.mu.Lock()
 		delete(s.connections, conn)
 		s.mu.Unlock()
 		conn.Close()
+		// Requests still running finish before the session is saved
+		dispatcher.wait()
+		connection.detach()
 	}()
 
 	// Handle messages
 	for {
-		var message mcp.Message
-		err := conn.ReadJSON(&message)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		connection.touch()
 
-		response := connection.handleMessage(&message)
-		if response != nil {
-			if err := conn.WriteJSON(response); err != nil {
-				log.Printf("Failed to write response: %v", err)
-				break
-			}
+		if err := dispatcher.dispatch(data); err != nil {
+			log.Printf("Failed to write response: %v", err)
+			break
 		}
 	}
 }
 
+// decodeErrorResponse reports a payload that could not be decoded into a
+// message: invalid JSON is a parse error, while valid JSON of the wrong shape
+// (e.g. a bare string) is an invalid request. The id is unknown, so it is null.
+func decodeErrorResponse(data []byte, err error) *mcp.Response {
+	if !json.Valid(data) {
+		return mcp.NewErrorResponse(nil, mcp.ErrorCodeParseError, "Parse error", err.Error())
+	}
+	return mcp.NewErrorResponse(nil, mcp.ErrorCodeInvalidRequest, "Invalid message format", err.Error())
+}
+
+// acquireConnectionSlot reserves room for a new connection, honoring MaxConnections
+func (s *MCPServer) acquireConnectionSlot() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MaxConnections > 0 && s.activeConnections >= s.config.MaxConnections {
+		return false
+	}
+	s.activeConnections++
+	return true
+}
+
+// releaseConnectionSlot frees a slot reserved by acquireConnectionSlot
+func (s *MCPServer) releaseConnectionSlot() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activeConnections--
+}
+
 // handleHealth handles health check requests
 func (s *MCPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -155,16 +586,74 @@ func (s *MCPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"status": "healthy",
 		"service": "mcp-server",
 		"timestamp": fmt.Sprintf("%d", time.Now().Unix()),
+		"connections": map[string]interface{}{
+			"active":   s.connectionCount(),
+			"limit":    s.config.MaxConnections,
+			"rejected": atomic.LoadInt64(&s.rejectedConnections),
+		},
+		"endpoints": s.endpointsFor(r),
 	}
 	
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleMessage processes incoming messages
+// connectionCount returns the number of connections currently holding a slot
+func (s *MCPServer) connectionCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeConnections
+}
+
+// handleTools returns the registered tool definitions over plain HTTP
+func (s *MCPServer) handleTools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tools, err := s.listTools(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to list tools: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tools)
+}
+
+// listTools collects the tools of every registered provider, limited to the
+// tool profile of the request handled with ctx
+func (s *MCPServer) listTools(ctx context.Context) ([]mcp.Tool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	allTools := []mcp.Tool{}
+	for _, provider := range s.toolProviders {
+		tools, err := provider.ListTools(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, tool := range tools {
+			if toolAllowed(ctx, tool) {
+				allTools = append(allTools, tool)
+			}
+		}
+	}
+
+	return allTools, nil
+}
+
+// handleMessage processes incoming messages. It may run concurrently for
+// several requests of one connection: handlers lock c.mu only around the
+// connection state they read or change.
 func (c *Connection) handleMessage(message *mcp.Message) interface{} {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	// An explicit null id is neither a valid request nor a notification
+	if message.HasNullID() && !c.server.config.AllowNullID {
+		return mcp.NewErrorResponse(nil, mcp.ErrorCodeInvalidRequest, "Invalid request: id must not be null", nil)
+	}
 
 	// Handle requests
 	if message.Method != "" && message.ID != nil {
@@ -177,23 +666,59 @@ func (c *Connection) handleMessage(message *mcp.Message) interface{} {
 		return nil
 	}
 
+	// Handle responses to the server's own requests
+	if isClientResponse(message) {
+		c.handleClientResponse(message)
+		return nil
+	}
+
 	// Invalid message
 	return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest, "Invalid message format", nil)
 }
 
-// handleRequest processes MCP requests
+// handleRequest processes MCP requests. Each request is handled with a context
+// carrying its correlation id, the client's info and, when the client supports
+// sampling, a sampler, and logged when LogRequests is enabled.
 func (c *Connection) handleRequest(message *mcp.Message) *mcp.Response {
+	ctx := withCorrelationID(context.Background(), requestCorrelationID(message))
+	c.mu.Lock()
+	clientInfo := c.clientInfo
+	c.mu.Unlock()
+	if clientInfo != nil {
+		ctx = mcp.ContextWithClientInfo(ctx, *clientInfo)
+	}
+	ctx = c.withSampler(ctx)
+	ctx = withToolProfile(ctx, c.activeToolProfile())
+	ctx = c.withCollectionAccess(ctx)
+	if !c.server.config.LogRequests {
+		return c.dispatchRequest(ctx, message)
+	}
+	return c.logRequest(ctx, message, func() *mcp.Response {
+		return c.dispatchRequest(ctx, message)
+	})
+}
+
+// dispatchRequest routes a request to the handler for its method
+func (c *Connection) dispatchRequest(ctx context.Context, message *mcp.Message) *mcp.Response {
 	switch message.Method {
 	case mcp.MethodInitialize:
 		return c.handleInitialize(message)
 	case mcp.MethodListTools:
-		return c.handleListTools(message)
+		return c.handleListTools(ctx, message)
 	case mcp.MethodCallTool:
-		return c.handleCallTool(message)
+		return c.handleCallTool(ctx, message)
 	case mcp.MethodListResources:
-		return c.handleListResources(message)
+		return c.handleListResources(ctx, message)
+	case mcp.MethodListResourceTemplates:
+		return c.handleListResourceTemplates(ctx, message)
 	case mcp.MethodReadResource:
-		return c.handleReadResource(message)
+		return c.handleReadResource(ctx, message)
+	case mcp.MethodSubscribeResource:
+		return c.handleSubscribeResource(message, true)
+	case mcp.MethodUnsubscribeResource:
+		return c.handleSubscribeResource(message, false)
+	case mcp.MethodComplete:
+		return c.handleComplete(ctx, message)
 	default:
 		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeMethodNotFound, 
 			fmt.Sprintf("Method not found: %s", message.Method), nil)
@@ -204,24 +729,56 @@ func (c *Connection) handleRequest(message *mcp.Message) *mcp.Response {
 func (c *Connection) handleNotification(message *mcp.Message) {
 	switch message.Method {
 	case mcp.MethodInitialized:
+		c.mu.Lock()
 		c.initialized = true
+		c.mu.Unlock()
 		log.Println("Client initialized")
+		c.refreshRoots()
+	case mcp.MethodNotificationRootsListChanged:
+		c.refreshRoots()
 	default:
 		log.Printf("Unknown notification: %s", message.Method)
 	}
 }
 
+// decodeParams decodes a request's params into v. With Config.PreciseNumbers,
+// numbers in free-form values such as tool arguments arrive as json.Number.
+func (c *Connection) decodeParams(params interface{}, v interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return mcp.DecodeJSON(data, v, c.server.config.PreciseNumbers)
+}
+
 // handleInitialize processes initialize requests
 func (c *Connection) handleInitialize(message *mcp.Message) *mcp.Response {
 	var req mcp.InitializeRequest
 	if message.Params != nil {
-		paramsBytes, _ := json.Marshal(message.Params)
-		if err := json.Unmarshal(paramsBytes, &req); err != nil {
+		if err := c.decodeParams(message.Params, &req); err != nil {
 			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
 				"Invalid initialize parameters", err.Error())
 		}
 	}
 
+	profile, _ := req.Meta["toolProfile"].(string)
+	if profile != "" && !c.server.config.HasToolProfile(profile) {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams,
+			fmt.Sprintf("Unknown tool profile: %s", profile), nil)
+	}
+
+	c.mu.Lock()
+	meta := c.startSession(req.Meta)
+	c.toolProfile = profile
+	if req.ClientInfo != (mcp.ClientInfo{}) {
+		clientInfo := req.ClientInfo
+		c.clientInfo = &clientInfo
+	} else {
+		c.clientInfo = nil
+	}
+	c.clientCapabilities = req.Capabilities
+	c.mu.Unlock()
+
 	response := mcp.InitializeResponse{
 		ProtocolVersion: mcp.ProtocolVersion,
 		Capabilities: mcp.ServerCapabilities{
@@ -234,87 +791,162 @@ func (c *Connection) handleInitialize(message *mcp.Message) *mcp.Response {
 			},
 		},
 		ServerInfo: mcp.ServerInfo{
-			Name:    "MCP Server Go",
-			Version: "1.0.0",
+			Name:    serverName,
+			Version: c.server.version(),
 		},
+		Meta: meta,
+	}
+	if active := c.activeToolProfile(); active != nil {
+		if response.Meta == nil {
+			response.Meta = map[string]interface{}{}
+		}
+		response.Meta["toolProfile"] = active.name
+	}
+	if c.server.hasCompletionProviders() {
+		response.Capabilities.Completions = &mcp.CompletionsCapability{}
 	}
 
 	return mcp.NewResponse(message.ID, response)
 }
 
+// startSession resumes the session named in the initialize meta, or starts a
+// new one, and returns the meta to advertise in the initialize response. The
+// caller must hold c.mu.
+func (c *Connection) startSession(requestMeta map[string]interface{}) map[string]interface{} {
+	if !c.server.sessions.enabled() {
+		return nil
+	}
+
+	resumed := false
+	if id, ok := requestMeta["sessionId"].(string); ok && id != "" {
+		if state, ok := c.server.sessions.resume(id); ok {
+			c.sessionID = id
+			c.initialized = state.initialized
+			c.subscriptions = state.subscriptions
+			resumed = true
+			log.Printf("Client resumed session %s", id)
+		}
+	}
+
+	if !resumed {
+		c.sessionID = newSessionID()
+		c.initialized = false
+		c.subscriptions = make(map[string]bool)
+	}
+
+	return map[string]interface{}{
+		"sessionId": c.sessionID,
+		"resumed":   resumed,
+	}
+}
+
 // handleListTools processes list tools requests
-func (c *Connection) handleListTools(message *mcp.Message) *mcp.Response {
-	if !c.initialized {
+func (c *Connection) handleListTools(ctx context.Context, message *mcp.Message) *mcp.Response {
+	if !c.ready() {
 		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest, 
 			"Client not initialized", nil)
 	}
 
-	var allTools []mcp.Tool
-	
-	c.server.mu.RLock()
-	for _, provider := range c.server.toolProviders {
-		tools, err := provider.ListTools(context.Background())
-		if err != nil {
-			c.server.mu.RUnlock()
-			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError, 
-				"Failed to list tools", err.Error())
+	var req mcp.ListToolsRequest
+	if message.Params != nil {
+		if err := c.decodeParams(message.Params, &req); err != nil {
+			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
+				"Invalid list tools parameters", err.Error())
 		}
-		allTools = append(allTools, tools...)
 	}
-	c.server.mu.RUnlock()
 
-	result := map[string]interface{}{
-		"tools": allTools,
+	offset, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
+			"Invalid cursor", err.Error())
+	}
+
+	allTools, err := c.server.listTools(ctx)
+	if err != nil {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError, 
+			"Failed to list tools", err.Error())
+	}
+
+	page, next := paginate(len(allTools), offset, c.server.config.ToolsPageSize)
+	result := mcp.ListToolsResponse{
+		Tools:      allTools[page.start:page.end],
+		NextCursor: next,
 	}
 
 	return mcp.NewResponse(message.ID, result)
 }
 
-// handleCallTool processes tool call requests
-func (c *Connection) handleCallTool(message *mcp.Message) *mcp.Response {
-	if !c.initialized {
+// handleCallTool processes tool call requests.
+//
+// Failures are reported in one of two ways, following MCP:
+//   - the call cannot be dispatched (not initialized, malformed params, unknown
+//     tool): a JSON-RPC protocol error
+//   - the tool ran but failed, including a Go error returned by the provider: a
+//     successful response carrying a ToolCallResponse with IsError set
+func (c *Connection) handleCallTool(ctx context.Context, message *mcp.Message) *mcp.Response {
+	if !c.ready() {
 		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest, 
 			"Client not initialized", nil)
 	}
 
 	var req mcp.ToolCallRequest
 	if message.Params != nil {
-		paramsBytes, _ := json.Marshal(message.Params)
-		if err := json.Unmarshal(paramsBytes, &req); err != nil {
+		if err := c.decodeParams(message.Params, &req); err != nil {
 			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
 				"Invalid tool call parameters", err.Error())
 		}
 	}
+	if req.Name == "" {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
+			"Missing tool name", nil)
+	}
+	// Arguments may be omitted; tools can rely on a non-nil map
+	if req.Arguments == nil {
+		req.Arguments = map[string]interface{}{}
+	}
 
-	c.server.mu.RLock()
-	defer c.server.mu.RUnlock()
+	provider, ok := c.server.findToolProvider(ctx, req.Name)
+	if !ok {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeMethodNotFound, 
+			fmt.Sprintf("Tool not found: %s", req.Name), nil)
+	}
 
-	for _, provider := range c.server.toolProviders {
-		// Check if this provider has the requested tool
-		tools, err := provider.ListTools(context.Background())
+	response, err := c.server.toolHandler(provider)(c.withContentStreamer(ctx, req), req)
+	if err != nil {
+		return mcp.NewResponse(message.ID, mcp.NewToolError(
+			fmt.Sprintf("Tool execution failed: %v", err), nil))
+	}
+	return mcp.NewResponse(message.ID, response)
+}
+
+// findToolProvider returns the provider that declares the named tool, as long
+// as the tool profile of the request handled with ctx includes it
+func (s *MCPServer) findToolProvider(ctx context.Context, name string) (mcp.ToolProvider, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, provider := range s.toolProviders {
+		tools, err := provider.ListTools(ctx)
 		if err != nil {
 			continue
 		}
 
 		for _, tool := range tools {
-			if tool.Name == req.Name {
-				response, err := provider.CallTool(context.Background(), req)
-				if err != nil {
-					return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError, 
-						"Tool execution failed", err.Error())
+			if tool.Name == name {
+				if !toolAllowed(ctx, tool) {
+					return nil, false
 				}
-				return mcp.NewResponse(message.ID, response)
+				return provider, true
 			}
 		}
 	}
 
-	return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeMethodNotFound, 
-		fmt.Sprintf("Tool not found: %s", req.Name), nil)
+	return nil, false
 }
 
 // handleListResources processes list resources requests
-func (c *Connection) handleListResources(message *mcp.Message) *mcp.Response {
-	if !c.initialized {
+func (c *Connection) handleListResources(ctx context.Context, message *mcp.Message) *mcp.Response {
+	if !c.ready() {
 		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest, 
 			"Client not initialized", nil)
 	}
@@ -323,7 +955,7 @@ func (c *Connection) handleListResources(message *mcp.Message) *mcp.Response {
 	
 	c.server.mu.RLock()
 	for _, provider := range c.server.resourceProviders {
-		resources, err := provider.ListResources(context.Background())
+		resources, err := provider.ListResources(ctx)
 		if err != nil {
 			c.server.mu.RUnlock()
 			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError, 
@@ -340,17 +972,94 @@ func (c *Connection) handleListResources(message *mcp.Message) *mcp.Response {
 	return mcp.NewResponse(message.ID, result)
 }
 
+// handleListResourceTemplates processes resources/templates/list requests
+func (c *Connection) handleListResourceTemplates(ctx context.Context, message *mcp.Message) *mcp.Response {
+	if !c.ready() {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest,
+			"Client not initialized", nil)
+	}
+
+	templates := []mcp.ResourceTemplate{}
+
+	c.server.mu.RLock()
+	defer c.server.mu.RUnlock()
+
+	for _, provider := range c.server.resourceProviders {
+		templateProvider, ok := provider.(mcp.ResourceTemplateProvider)
+		if !ok {
+			continue
+		}
+		providerTemplates, err := templateProvider.ListResourceTemplates(ctx)
+		if err != nil {
+			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError,
+				"Failed to list resource templates", err.Error())
+		}
+		templates = append(templates, providerTemplates...)
+	}
+
+	return mcp.NewResponse(message.ID, mcp.ListResourceTemplatesResponse{ResourceTemplates: templates})
+}
+
+// templateProviderFor returns the provider with a resource template matching uri
+func (s *MCPServer) templateProviderFor(ctx context.Context, uri string) (mcp.ResourceProvider, bool) {
+	for _, provider := range s.resourceProviders {
+		templateProvider, ok := provider.(mcp.ResourceTemplateProvider)
+		if !ok {
+			continue
+		}
+		templates, err := templateProvider.ListResourceTemplates(ctx)
+		if err != nil {
+			continue
+		}
+		for _, template := range templates {
+			if _, ok := mcp.MatchURITemplate(template.URITemplate, uri); ok {
+				return provider, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// handleSubscribeResource records or removes a resource subscription for this session
+func (c *Connection) handleSubscribeResource(message *mcp.Message, subscribe bool) *mcp.Response {
+	if !c.ready() {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest, 
+			"Client not initialized", nil)
+	}
+
+	var req mcp.ResourceSubscribeRequest
+	if message.Params != nil {
+		if err := c.decodeParams(message.Params, &req); err != nil {
+			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
+				"Invalid resource subscription parameters", err.Error())
+		}
+	}
+	if req.URI == "" {
+		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
+			"Missing resource URI", nil)
+	}
+
+	c.mu.Lock()
+	if subscribe {
+		c.subscriptions[req.URI] = true
+	} else {
+		delete(c.subscriptions, req.URI)
+	}
+	c.mu.Unlock()
+
+	return mcp.NewResponse(message.ID, map[string]interface{}{})
+}
+
 // handleReadResource processes read resource requests
-func (c *Connection) handleReadResource(message *mcp.Message) *mcp.Response {
-	if !c.initialized {
+func (c *Connection) handleReadResource(ctx context.Context, message *mcp.Message) *mcp.Response {
+	if !c.ready() {
 		return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidRequest, 
 			"Client not initialized", nil)
 	}
 
 	var req mcp.ResourceReadRequest
 	if message.Params != nil {
-		paramsBytes, _ := json.Marshal(message.Params)
-		if err := json.Unmarshal(paramsBytes, &req); err != nil {
+		if err := c.decodeParams(message.Params, &req); err != nil {
 			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInvalidParams, 
 				"Invalid resource read parameters", err.Error())
 		}
@@ -361,23 +1070,48 @@ func (c *Connection) handleReadResource(message *mcp.Message) *mcp.Response {
 
 	for _, provider := range c.server.resourceProviders {
 		// Check if this provider has the requested resource
-		resources, err := provider.ListResources(context.Background())
+		resources, err := provider.ListResources(ctx)
 		if err != nil {
 			continue
 		}
 
 		for _, resource := range resources {
 			if resource.URI == req.URI {
-				response, err := provider.ReadResource(context.Background(), req.URI)
+				response, err := provider.ReadResource(ctx, req.URI)
 				if err != nil {
 					return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError, 
 						"Resource read failed", err.Error())
 				}
-				return mcp.NewResponse(message.ID, response)
+				return mcp.NewResponse(message.ID, conditionalRead(req.IfNoneMatch, response))
 			}
 		}
 	}
 
+	// Resources built from a template are read without being listed
+	if provider, ok := c.server.templateProviderFor(ctx, req.URI); ok {
+		response, err := provider.ReadResource(ctx, req.URI)
+		if err != nil {
+			return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeInternalError, 
+				"Resource read failed", err.Error())
+		}
+		return mcp.NewResponse(message.ID, conditionalRead(req.IfNoneMatch, response))
+	}
+
 	return mcp.NewErrorResponse(message.ID, mcp.ErrorCodeMethodNotFound, 
 		fmt.Sprintf("Resource not found: %s", req.URI), nil)
 }
+
+// conditionalRead answers a read whose ifNoneMatch validator equals the ETag
+// of every content with an empty NotModified response, so that unchanged
+// resources are not sent again. Contents without an ETag are always sent.
+func conditionalRead(ifNoneMatch string, response *mcp.ResourceReadResponse) *mcp.ResourceReadResponse {
+	if ifNoneMatch == "" || response == nil || len(response.Contents) == 0 {
+		return response
+	}
+	for _, content := range response.Contents {
+		if content.ETag == "" || content.ETag != ifNoneMatch {
+			return response
+		}
+	}
+	return &mcp.ResourceReadResponse{Contents: []mcp.ResourceContent{}, NotModified: true}
+}
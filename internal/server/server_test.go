@@ -0,0 +1,4008 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kringen/go-mcp-server/internal/tools"
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPServer_HTTP(t *testing.T) {
+	t.Run("ToolsEndpoint", func(t *testing.T) {
+		s := NewMCPServer()
+		s.RegisterToolProvider(tools.NewMathToolProvider())
+
+		req := httptest.NewRequest(http.MethodGet, "/tools", nil)
+		rec := httptest.NewRecorder()
+		s.handleTools(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var listed []mcp.Tool
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+
+		names := make(map[string]bool)
+		for _, tool := range listed {
+			names[tool.Name] = true
+			assert.NotNil(t, tool.InputSchema)
+		}
+		for _, expected := range []string{"add", "multiply", "power"} {
+			assert.True(t, names[expected], "Tool %s not found", expected)
+		}
+	})
+
+	t.Run("ToolsEndpoint_MethodNotAllowed", func(t *testing.T) {
+		s := NewMCPServer()
+
+		req := httptest.NewRequest(http.MethodPost, "/tools", nil)
+		rec := httptest.NewRecorder()
+		s.handleTools(rec, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+// dialTestServer opens a WebSocket connection to the /mcp handler of a test server
+func dialTestServer(t *testing.T, ts *httptest.Server) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/mcp"
+	return websocket.DefaultDialer.Dial(wsURL, nil)
+}
+
+// newTestHTTPServer serves the MCP server's WebSocket handler on a local listener
+func newTestHTTPServer(s *MCPServer) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", s.handleWebSocket)
+	return httptest.NewServer(mux)
+}
+
+func TestNewServerWithConfig(t *testing.T) {
+	t.Run("DefaultShim", func(t *testing.T) {
+		s := NewMCPServer()
+		assert.Equal(t, DefaultConfig(), s.config)
+		assert.Nil(t, s.toolCache)
+	})
+
+	t.Run("CustomConfig", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Addr = "127.0.0.1:9090"
+		config.MaxConnections = 3
+		config.IdleTimeout = time.Minute
+		config.ToolsPageSize = 2
+		config.ToolCacheTTL = time.Minute
+		config.APIKeys = []string{"secret"}
+		config.AllowedOrigins = []string{"https://app.example.com"}
+		s := NewServerWithConfig(config)
+
+		assert.Equal(t, config, s.config)
+		assert.NotNil(t, s.toolCache)
+		assert.True(t, s.validAPIKey("secret"))
+
+		// The page size applies to tools/list
+		require.NoError(t, s.RegisterToolProvider(tools.NewMathToolProvider()))
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodListTools,
+			Params:  map[string]interface{}{},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+		listed, ok := response.Result.(mcp.ListToolsResponse)
+		require.True(t, ok)
+		assert.Len(t, listed.Tools, 2)
+		assert.NotEmpty(t, listed.NextCursor)
+	})
+
+	t.Run("StartListensOnConfigAddr", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Addr = "127.0.0.1:not-a-port"
+		s := NewServerWithConfig(config)
+
+		err := s.Start(context.Background(), "")
+		assert.ErrorContains(t, err, "failed to listen on 127.0.0.1:not-a-port")
+	})
+
+	t.Run("AllowedOrigins", func(t *testing.T) {
+		config := DefaultConfig()
+		config.AllowedOrigins = []string{"https://app.example.com/"}
+		ts := newTestHTTPServer(NewServerWithConfig(config))
+		defer ts.Close()
+
+		dial := func(origin string) (*http.Response, error) {
+			header := http.Header{}
+			if origin != "" {
+				header.Set("Origin", origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/mcp", header)
+			if err == nil {
+				conn.Close()
+			}
+			return resp, err
+		}
+
+		_, err := dial("https://app.example.com")
+		assert.NoError(t, err)
+		_, err = dial("HTTPS://APP.EXAMPLE.COM")
+		assert.NoError(t, err)
+
+		// Clients outside a browser send no origin
+		_, err = dial("")
+		assert.NoError(t, err)
+
+		resp, err := dial("https://evil.example.com")
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestMCPServer_Subprotocols(t *testing.T) {
+	dial := func(t *testing.T, s *MCPServer, protocols ...string) string {
+		ts := newTestHTTPServer(s)
+		defer ts.Close()
+
+		dialer := websocket.Dialer{Subprotocols: protocols}
+		conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/mcp", nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, resp.Header.Get("Sec-WebSocket-Protocol"), conn.Subprotocol())
+		return conn.Subprotocol()
+	}
+
+	t.Run("EchoesRequestedSubprotocol", func(t *testing.T) {
+		assert.Equal(t, "mcp", dial(t, NewMCPServer(), "mcp"))
+		assert.Equal(t, "mcp", dial(t, NewMCPServer(), "graphql-ws", "mcp"))
+	})
+
+	t.Run("UnsupportedOrNoneRequested", func(t *testing.T) {
+		assert.Empty(t, dial(t, NewMCPServer(), "graphql-ws"))
+		assert.Empty(t, dial(t, NewMCPServer()))
+	})
+
+	t.Run("ServerPreferenceWins", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Subprotocols = []string{"mcp.v2", "mcp"}
+		s := NewServerWithConfig(config)
+		assert.Equal(t, "mcp.v2", dial(t, s, "mcp", "mcp.v2"))
+		assert.Equal(t, "mcp", dial(t, s, "mcp"))
+	})
+}
+
+func TestMCPServer_MaxConnections(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnections = 2
+	s := NewServerWithConfig(config)
+
+	ts := newTestHTTPServer(s)
+	defer ts.Close()
+
+	first, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	defer first.Close()
+
+	second, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	defer second.Close()
+
+	// The third connection exceeds the limit and is rejected before upgrading
+	_, resp, err := dialTestServer(t, ts)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Closing a connection frees its slot for a retrying client
+	first.Close()
+	require.Eventually(t, func() bool {
+		return s.connectionCount() < config.MaxConnections
+	}, 2*time.Second, 10*time.Millisecond)
+
+	retry, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	retry.Close()
+}
+
+func TestMCPServer_IdleTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.IdleTimeout = 100 * time.Millisecond
+	s := NewServerWithConfig(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.reapIdleConnections(ctx)
+
+	ts := newTestHTTPServer(s)
+	defer ts.Close()
+
+	idle, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	defer idle.Close()
+
+	active, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	defer active.Close()
+
+	// Keep one client talking while the other stays silent
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := active.WriteJSON(mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// The silent client is disconnected by the server
+	idle.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = idle.ReadMessage()
+	require.Error(t, err)
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "idle connection was not closed by the server")
+	}
+
+	require.Eventually(t, func() bool {
+		return s.connectionCount() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestMCPServer_MalformedMessages(t *testing.T) {
+	ts := newTestHTTPServer(NewMCPServer())
+	defer ts.Close()
+
+	conn, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	readResponse := func(t *testing.T) map[string]interface{} {
+		var response map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&response))
+		return response
+	}
+	errorCode := func(response map[string]interface{}) float64 {
+		return response["error"].(map[string]interface{})["code"].(float64)
+	}
+
+	// Invalid JSON is a parse error with a null id, and the connection stays open
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 1, "method": `)))
+	response := readResponse(t)
+	assert.Equal(t, float64(mcp.ErrorCodeParseError), errorCode(response))
+	assert.Contains(t, response, "id")
+	assert.Nil(t, response["id"])
+
+	// Valid JSON that is not a message object is an invalid request
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`"initialize"`)))
+	assert.Equal(t, float64(mcp.ErrorCodeInvalidRequest), errorCode(readResponse(t)))
+
+	// A valid request on the same connection is still handled
+	require.NoError(t, conn.WriteJSON(mcp.NewRequest(2, mcp.MethodInitialize, map[string]interface{}{
+		"protocolVersion": mcp.ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "test-client", "version": "1.0.0"},
+	})))
+	response = readResponse(t)
+	assert.Equal(t, float64(2), response["id"])
+	assert.NotContains(t, response, "error")
+	assert.Contains(t, response, "result")
+}
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key as PEM
+// files and returns their paths along with the certificate, for use as a root
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mcp-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+func TestMCPServer_TLS(t *testing.T) {
+	certFile, keyFile, cert := writeSelfSignedCert(t)
+
+	t.Run("AcceptsWSS", func(t *testing.T) {
+		config := DefaultConfig()
+		config.TLSCertFile = certFile
+		config.TLSKeyFile = keyFile
+		s := NewServerWithConfig(config)
+
+		tlsConfig, err := LoadTLSConfig(config.TLSCertFile, config.TLSKeyFile)
+		require.NoError(t, err)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() {
+			served <- s.serve(ctx, listener, tlsConfig)
+		}()
+		defer func() {
+			cancel()
+			assert.NoError(t, <-served)
+		}()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		dialer := websocket.Dialer{
+			TLSClientConfig:  &tls.Config{RootCAs: roots},
+			HandshakeTimeout: 5 * time.Second,
+		}
+		conn, _, err := dialer.Dial("wss://"+listener.Addr().String()+"/mcp", nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  mcp.MethodInitialize,
+			"params":  map[string]interface{}{},
+		}))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var response map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&response))
+		assert.Equal(t, float64(1), response["id"])
+		assert.Nil(t, response["error"])
+
+		// Plain ws:// is not served on a TLS listener
+		_, _, err = websocket.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/mcp", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		config, err := LoadTLSConfig("", "")
+		assert.NoError(t, err)
+		assert.Nil(t, config)
+
+		_, err = LoadTLSConfig(certFile, "")
+		assert.ErrorContains(t, err, "both a certificate and a key")
+
+		_, err = LoadTLSConfig(certFile, filepath.Join(t.TempDir(), "missing.pem"))
+		assert.ErrorContains(t, err, "failed to load TLS certificate")
+
+		// Start reports the problem instead of serving plain HTTP
+		s := NewServerWithConfig(Config{TLSCertFile: keyFile, TLSKeyFile: certFile})
+		err = s.Start(context.Background(), "127.0.0.1:0")
+		assert.ErrorContains(t, err, "failed to load TLS certificate")
+	})
+}
+
+func TestMCPServer_TCP(t *testing.T) {
+	s := NewMCPServer()
+	require.NoError(t, s.RegisterToolProvider(tools.NewMathToolProvider()))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.serveTCP(context.Background(), listener)
+	}()
+
+	type tcpClient struct {
+		conn    net.Conn
+		scanner *bufio.Scanner
+	}
+	dial := func(t *testing.T) *tcpClient {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return &tcpClient{conn: conn, scanner: bufio.NewScanner(conn)}
+	}
+	roundTrip := func(t *testing.T, c *tcpClient, line string) map[string]interface{} {
+		_, err := io.WriteString(c.conn, line+"\n")
+		require.NoError(t, err)
+		require.True(t, c.scanner.Scan(), "no response: %v", c.scanner.Err())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(c.scanner.Bytes(), &response))
+		return response
+	}
+
+	// Several clients are served concurrently, each with its own session state
+	clients := []*tcpClient{dial(t), dial(t), dial(t)}
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *tcpClient) {
+			defer wg.Done()
+
+			response := roundTrip(t, c, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"initialize","params":{}}`, i))
+			assert.Equal(t, float64(i), response["id"])
+			assert.Contains(t, response, "result")
+
+			_, err := io.WriteString(c.conn, `{"jsonrpc":"2.0","method":"initialized"}`+"\n\n")
+			require.NoError(t, err)
+
+			response = roundTrip(t, c, `{"jsonrpc":"2.0","id":"call","method":"tools/call","params":{"name":"add","arguments":{"a":2,"b":3}}}`)
+			assert.Equal(t, "call", response["id"])
+			assert.Contains(t, fmt.Sprint(response["result"]), "5.00")
+
+			// A malformed line is answered and the connection stays usable
+			response = roundTrip(t, c, `{not json`)
+			assert.Equal(t, float64(mcp.ErrorCodeParseError), response["error"].(map[string]interface{})["code"])
+		}(i, c)
+	}
+	wg.Wait()
+	assert.Equal(t, len(clients), s.connectionCount())
+
+	// Closing a client frees its slot
+	clients[0].conn.Close()
+	require.Eventually(t, func() bool {
+		return s.connectionCount() == len(clients)-1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Stopping the server disconnects the remaining clients and ends serveTCP
+	require.NoError(t, s.Stop(context.Background()))
+	for _, c := range clients[1:] {
+		assert.False(t, c.scanner.Scan())
+	}
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("serveTCP did not return after Stop")
+	}
+}
+
+// newTestConnection creates a connection that is driven directly through handleMessage
+func newTestConnection(s *MCPServer) *Connection {
+	return newConnection(nil, s)
+}
+
+// initializeConnection runs the initialize request, optionally resuming sessionID, and returns the response meta
+func initializeConnection(t *testing.T, c *Connection, sessionID string) map[string]interface{} {
+	t.Helper()
+
+	params := map[string]interface{}{
+		"protocolVersion": mcp.ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "test-client", "version": "1.0.0"},
+	}
+	if sessionID != "" {
+		params["meta"] = map[string]interface{}{"sessionId": sessionID}
+	}
+
+	response, ok := c.handleMessage(&mcp.Message{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  mcp.MethodInitialize,
+		Params:  params,
+	}).(*mcp.Response)
+	require.True(t, ok)
+	require.Nil(t, response.Error)
+
+	result, ok := response.Result.(mcp.InitializeResponse)
+	require.True(t, ok)
+	return result.Meta
+}
+
+func TestMCPServer_SessionResumption(t *testing.T) {
+	subscribe := func(t *testing.T, c *Connection, uri string) {
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodSubscribeResource,
+			Params:  map[string]interface{}{"uri": uri},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+	}
+
+	t.Run("ResumeRestoresState", func(t *testing.T) {
+		s := NewMCPServer()
+
+		first := newTestConnection(s)
+		meta := initializeConnection(t, first, "")
+		sessionID, _ := meta["sessionId"].(string)
+		require.NotEmpty(t, sessionID)
+		assert.Equal(t, false, meta["resumed"])
+
+		first.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		subscribe(t, first, "mongodb://knowledgebase/doc-1")
+		first.detach()
+
+		second := newTestConnection(s)
+		meta = initializeConnection(t, second, sessionID)
+		assert.Equal(t, sessionID, meta["sessionId"])
+		assert.Equal(t, true, meta["resumed"])
+		assert.True(t, second.initialized)
+		assert.True(t, second.subscriptions["mongodb://knowledgebase/doc-1"])
+	})
+
+	t.Run("UnknownSessionStartsFresh", func(t *testing.T) {
+		s := NewMCPServer()
+
+		c := newTestConnection(s)
+		meta := initializeConnection(t, c, "does-not-exist")
+		assert.NotEqual(t, "does-not-exist", meta["sessionId"])
+		assert.Equal(t, false, meta["resumed"])
+		assert.False(t, c.initialized)
+	})
+
+	t.Run("ExpiredSessionStartsFresh", func(t *testing.T) {
+		config := DefaultConfig()
+		config.SessionTTL = 20 * time.Millisecond
+		s := NewServerWithConfig(config)
+
+		first := newTestConnection(s)
+		sessionID := initializeConnection(t, first, "")["sessionId"].(string)
+		first.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		first.detach()
+
+		time.Sleep(50 * time.Millisecond)
+
+		second := newTestConnection(s)
+		meta := initializeConnection(t, second, sessionID)
+		assert.Equal(t, false, meta["resumed"])
+		assert.False(t, second.initialized)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		config := DefaultConfig()
+		config.SessionTTL = 0
+		s := NewServerWithConfig(config)
+
+		c := newTestConnection(s)
+		assert.Nil(t, initializeConnection(t, c, ""))
+	})
+}
+
+// stubToolProvider exposes a single tool whose CallTool behaviour is set per test
+type stubToolProvider struct {
+	name     string
+	response *mcp.ToolCallResponse
+	err      error
+}
+
+func (p *stubToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: p.name}}, nil
+}
+
+func (p *stubToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	return p.response, p.err
+}
+
+func TestMCPServer_RequireInitialized(t *testing.T) {
+	setup := func(requireInitialized bool) *Connection {
+		config := DefaultConfig()
+		config.RequireInitialized = requireInitialized
+		s := NewServerWithConfig(config)
+		s.RegisterToolProvider(tools.NewMathToolProvider())
+		return newTestConnection(s)
+	}
+	request := func(t *testing.T, c *Connection, method string, params interface{}) *mcp.Response {
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  method,
+			Params:  params,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		return response
+	}
+	add := map[string]interface{}{"name": "add", "arguments": map[string]interface{}{"a": 2, "b": 3}}
+
+	t.Run("Strict", func(t *testing.T) {
+		c := setup(true)
+		initializeConnection(t, c, "")
+
+		// Until the initialized notification, requests are rejected
+		for _, method := range []string{mcp.MethodListTools, mcp.MethodCallTool, mcp.MethodListResources} {
+			response := request(t, c, method, add)
+			require.NotNil(t, response.Error, method)
+			assert.Equal(t, mcp.ErrorCodeInvalidRequest, response.Error.Code, method)
+			assert.Equal(t, "Client not initialized", response.Error.Message, method)
+		}
+
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		response := request(t, c, mcp.MethodCallTool, add)
+		assert.Nil(t, response.Error)
+	})
+
+	t.Run("Lenient", func(t *testing.T) {
+		c := setup(false)
+
+		// Calls are served right away, even without initialize
+		response := request(t, c, mcp.MethodCallTool, add)
+		require.Nil(t, response.Error)
+		result, ok := response.Result.(*mcp.ToolCallResponse)
+		require.True(t, ok)
+		assert.False(t, result.IsError)
+
+		response = request(t, c, mcp.MethodListTools, map[string]interface{}{})
+		assert.Nil(t, response.Error)
+		response = request(t, c, mcp.MethodListResources, map[string]interface{}{})
+		assert.Nil(t, response.Error)
+
+		// initialize still works afterwards
+		initializeConnection(t, c, "")
+		assert.Nil(t, request(t, c, mcp.MethodCallTool, add).Error)
+	})
+}
+
+func TestMCPServer_CallToolErrors(t *testing.T) {
+	callTool := func(t *testing.T, provider mcp.ToolProvider, params interface{}) *mcp.Response {
+		s := NewMCPServer()
+		s.RegisterToolProvider(provider)
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodCallTool,
+			Params:  params,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		return response
+	}
+
+	t.Run("ToolErrorResult", func(t *testing.T) {
+		provider := &stubToolProvider{name: "stub", response: mcp.NewToolError("bad input", nil)}
+		response := callTool(t, provider, map[string]interface{}{"name": "stub"})
+
+		require.Nil(t, response.Error)
+		result, ok := response.Result.(*mcp.ToolCallResponse)
+		require.True(t, ok)
+		assert.True(t, result.IsError)
+		assert.Equal(t, "bad input", result.Content[0].Text)
+	})
+
+	t.Run("ProviderGoErrorIsToolError", func(t *testing.T) {
+		provider := &stubToolProvider{name: "stub", err: errors.New("backend unavailable")}
+		response := callTool(t, provider, map[string]interface{}{"name": "stub"})
+
+		require.Nil(t, response.Error)
+		result, ok := response.Result.(*mcp.ToolCallResponse)
+		require.True(t, ok)
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content[0].Text, "backend unavailable")
+	})
+
+	t.Run("UnknownToolIsProtocolError", func(t *testing.T) {
+		provider := &stubToolProvider{name: "stub"}
+		response := callTool(t, provider, map[string]interface{}{"name": "missing"})
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeMethodNotFound, response.Error.Code)
+		assert.Nil(t, response.Result)
+	})
+
+	t.Run("MissingNameIsProtocolError", func(t *testing.T) {
+		provider := &stubToolProvider{name: "stub"}
+		response := callTool(t, provider, map[string]interface{}{})
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidParams, response.Error.Code)
+	})
+}
+
+func TestMCPServer_DescribeTool(t *testing.T) {
+	s := NewMCPServer()
+	s.RegisterToolProvider(tools.NewMathToolProvider())
+
+	describe := func(t *testing.T, args map[string]interface{}) *mcp.ToolCallResponse {
+		provider, ok := s.findToolProvider(context.Background(), "describe_tool")
+		require.True(t, ok, "describe_tool should be registered automatically")
+
+		response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name:      "describe_tool",
+			Arguments: args,
+		})
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("KnownTool", func(t *testing.T) {
+		response := describe(t, map[string]interface{}{"name": "power"})
+		require.False(t, response.IsError)
+		require.Len(t, response.Content, 1)
+
+		var tool mcp.Tool
+		require.NoError(t, json.Unmarshal([]byte(response.Content[0].Text), &tool))
+		assert.Equal(t, "power", tool.Name)
+		assert.NotEmpty(t, tool.Description)
+		assert.Contains(t, tool.InputSchema, "properties")
+
+		// The same definition is returned as structured content
+		structured, ok := response.StructuredContent.(mcp.Tool)
+		require.True(t, ok, "structured content is %T", response.StructuredContent)
+		assert.Equal(t, "power", structured.Name)
+		assert.Equal(t, tool.Description, structured.Description)
+		assert.Contains(t, structured.InputSchema, "properties")
+		require.NotNil(t, structured.Annotations)
+		assert.True(t, structured.Annotations.ReadOnlyHint)
+	})
+
+	t.Run("DescribesItself", func(t *testing.T) {
+		response := describe(t, map[string]interface{}{"name": "describe_tool"})
+		assert.False(t, response.IsError)
+	})
+
+	t.Run("UnknownTool", func(t *testing.T) {
+		response := describe(t, map[string]interface{}{"name": "does_not_exist"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Unknown tool: does_not_exist")
+	})
+
+	t.Run("MissingName", func(t *testing.T) {
+		response := describe(t, map[string]interface{}{})
+		assert.True(t, response.IsError)
+	})
+}
+
+func TestMCPServer_ExportSchema(t *testing.T) {
+	s := NewMCPServer()
+	require.NoError(t, s.RegisterToolProvider(tools.NewMathToolProvider()))
+	require.NoError(t, s.RegisterToolProvider(tools.NewSearchTool(nil)))
+	require.NoError(t, s.RegisterToolProvider(tools.NewDatabaseTool(nil)))
+
+	provider, ok := s.findToolProvider(context.Background(), "export_schema")
+	require.True(t, ok, "export_schema should be registered automatically")
+	response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{Name: "export_schema"})
+	require.NoError(t, err)
+	require.False(t, response.IsError)
+	require.Len(t, response.Content, 1)
+
+	var catalog map[string]struct {
+		Description  string                 `json:"description"`
+		InputSchema  map[string]interface{} `json:"inputSchema"`
+		OutputSchema map[string]interface{} `json:"outputSchema"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(response.Content[0].Text), &catalog))
+
+	registered, err := s.listTools(context.Background())
+	require.NoError(t, err)
+	require.Len(t, catalog, len(registered))
+	for _, tool := range registered {
+		entry, ok := catalog[tool.Name]
+		if assert.True(t, ok, "missing %s", tool.Name) {
+			assert.NotEmpty(t, entry.Description, tool.Name)
+			assert.NotEmpty(t, entry.InputSchema, tool.Name)
+			assert.Equal(t, "object", entry.InputSchema["type"], tool.Name)
+		}
+	}
+
+	// Output schemas are part of the export
+	assert.NotEmpty(t, catalog["add"].OutputSchema)
+	assert.Empty(t, catalog["batch"].OutputSchema)
+}
+
+func TestMCPServer_ServerInfo(t *testing.T) {
+	config := DefaultConfig()
+	config.Version = "1.4.2"
+	config.BuildTime = "2024-05-01T12:00:00Z"
+	s := NewServerWithConfig(config)
+	require.NoError(t, s.RegisterToolProvider(tools.NewMathToolProvider()))
+	require.NoError(t, s.RegisterToolProvider(tools.NewDatabaseTool(nil)))
+
+	provider, ok := s.findToolProvider(context.Background(), "server_info")
+	require.True(t, ok, "server_info should be registered automatically")
+	response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{Name: "server_info"})
+	require.NoError(t, err)
+	require.False(t, response.IsError)
+
+	info, ok := response.StructuredContent.(Info)
+	require.True(t, ok)
+	assert.Equal(t, "MCP Server Go", info.Name)
+	assert.Equal(t, "1.4.2", info.Version)
+	assert.Equal(t, "2024-05-01T12:00:00Z", info.BuildTime)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, mcp.ProtocolVersion, info.ProtocolVersion)
+	assert.WithinDuration(t, time.Now(), info.StartedAt, time.Minute)
+	assert.GreaterOrEqual(t, info.UptimeSeconds, int64(0))
+	assert.Equal(t, []string{"server", "math", "database"}, info.Providers)
+	assert.Contains(t, response.Content[0].Text, `"version":"1.4.2"`)
+
+	// The structured result matches the declared output schema
+	registered, err := s.listTools(context.Background())
+	require.NoError(t, err)
+	for _, tool := range registered {
+		if tool.Name == "server_info" {
+			structured, err := mcp.NormalizeJSON(response.StructuredContent)
+			require.NoError(t, err)
+			assert.NoError(t, mcp.ValidateSchema(tool.OutputSchema, structured))
+		}
+	}
+
+	// The initialize response reports the same version
+	c := newTestConnection(s)
+	initialize, ok := c.handleMessage(&mcp.Message{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  mcp.MethodInitialize,
+		Params:  map[string]interface{}{"protocolVersion": mcp.ProtocolVersion},
+	}).(*mcp.Response)
+	require.True(t, ok)
+	require.Nil(t, initialize.Error)
+	assert.Equal(t, "1.4.2", initialize.Result.(mcp.InitializeResponse).ServerInfo.Version)
+
+	// Builds without an injected version report "dev"
+	assert.Equal(t, "dev", NewMCPServer().info().Version)
+}
+
+func TestMCPServer_ServerInfoNotCached(t *testing.T) {
+	config := DefaultConfig()
+	config.ToolCacheTTL = time.Minute
+	s := NewServerWithConfig(config)
+
+	uptime := func() int64 {
+		provider, ok := s.findToolProvider(context.Background(), "server_info")
+		require.True(t, ok)
+		response, err := s.toolHandler(provider)(context.Background(), mcp.ToolCallRequest{Name: "server_info"})
+		require.NoError(t, err)
+		return response.StructuredContent.(Info).UptimeSeconds
+	}
+
+	s.startedAt = time.Now().Add(-time.Hour)
+	assert.GreaterOrEqual(t, uptime(), int64(3600))
+	s.startedAt = s.startedAt.Add(-time.Hour)
+	assert.GreaterOrEqual(t, uptime(), int64(7200))
+}
+
+// formattingToolProvider records the PrettyJSON setting it is given
+type formattingToolProvider struct {
+	stubToolProvider
+	pretty *bool
+}
+
+func (p *formattingToolProvider) SetPrettyJSON(pretty bool) {
+	p.pretty = &pretty
+}
+
+func TestMCPServer_PrettyJSON(t *testing.T) {
+	describePower := func(t *testing.T, s *MCPServer) string {
+		provider, ok := s.findToolProvider(context.Background(), "describe_tool")
+		require.True(t, ok)
+		response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name:      "describe_tool",
+			Arguments: map[string]interface{}{"name": "power"},
+		})
+		require.NoError(t, err)
+		require.False(t, response.IsError)
+		return response.Content[0].Text
+	}
+
+	for _, pretty := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Pretty=%v", pretty), func(t *testing.T) {
+			config := DefaultConfig()
+			config.PrettyJSON = pretty
+			s := NewServerWithConfig(config)
+			require.NoError(t, s.RegisterToolProvider(tools.NewMathToolProvider()))
+
+			// The setting is handed to providers that embed JSON
+			formatter := &formattingToolProvider{stubToolProvider: stubToolProvider{name: "stub"}}
+			require.NoError(t, s.RegisterToolProvider(formatter))
+			require.NotNil(t, formatter.pretty)
+			assert.Equal(t, pretty, *formatter.pretty)
+
+			text := describePower(t, s)
+			if pretty {
+				assert.True(t, strings.HasPrefix(text, "{\n  \"name\": \"power\","), text)
+			} else {
+				assert.True(t, strings.HasPrefix(text, `{"name":"power",`), text)
+			}
+		})
+	}
+}
+
+func TestMCPServer_ListToolsPagination(t *testing.T) {
+	listTools := func(t *testing.T, c *Connection, cursor string) *mcp.Response {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodListTools,
+			Params:  params,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		return response
+	}
+
+	newInitialized := func(t *testing.T, pageSize int) (*MCPServer, *Connection) {
+		config := DefaultConfig()
+		config.ToolsPageSize = pageSize
+		s := NewServerWithConfig(config)
+		s.RegisterToolProvider(tools.NewMathToolProvider())
+
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		return s, c
+	}
+
+	t.Run("PagesCoverEveryToolOnce", func(t *testing.T) {
+		s, c := newInitialized(t, 2)
+		expected, err := s.listTools(context.Background())
+		require.NoError(t, err)
+		require.Greater(t, len(expected), 2)
+
+		var names []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, len(expected), "pagination did not terminate")
+
+			response := listTools(t, c, cursor)
+			require.Nil(t, response.Error)
+			result, ok := response.Result.(mcp.ListToolsResponse)
+			require.True(t, ok)
+			assert.LessOrEqual(t, len(result.Tools), 2)
+
+			for _, tool := range result.Tools {
+				names = append(names, tool.Name)
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			cursor = result.NextCursor
+		}
+
+		expectedNames := make([]string, len(expected))
+		for i, tool := range expected {
+			expectedNames[i] = tool.Name
+		}
+		assert.Equal(t, expectedNames, names)
+	})
+
+	t.Run("UnlimitedPageSize", func(t *testing.T) {
+		s, c := newInitialized(t, 0)
+		expected, err := s.listTools(context.Background())
+		require.NoError(t, err)
+
+		response := listTools(t, c, "")
+		require.Nil(t, response.Error)
+		result := response.Result.(mcp.ListToolsResponse)
+		assert.Len(t, result.Tools, len(expected))
+		assert.Empty(t, result.NextCursor)
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		_, c := newInitialized(t, 2)
+
+		response := listTools(t, c, "not a cursor!")
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidParams, response.Error.Code)
+	})
+
+	t.Run("CursorPastEnd", func(t *testing.T) {
+		_, c := newInitialized(t, 2)
+
+		response := listTools(t, c, encodeCursor(1000))
+		require.Nil(t, response.Error)
+		result := response.Result.(mcp.ListToolsResponse)
+		assert.Empty(t, result.Tools)
+		assert.Empty(t, result.NextCursor)
+	})
+}
+
+func TestMCPServer_Endpoints(t *testing.T) {
+	t.Run("NewEndpoints", func(t *testing.T) {
+		endpoints, err := NewEndpoints("https://mcp.example.com/mcp-server/")
+		require.NoError(t, err)
+		assert.Equal(t, "wss://mcp.example.com/mcp-server/mcp", endpoints.MCP)
+		assert.Equal(t, "https://mcp.example.com/mcp-server/health", endpoints.Health)
+		assert.Equal(t, "https://mcp.example.com/mcp-server/tools", endpoints.Tools)
+		assert.Equal(t, "https://mcp.example.com/mcp-server/status/stream", endpoints.Status)
+		assert.Equal(t, "https://mcp.example.com/mcp-server/", endpoints.Web)
+
+		endpoints, err = NewEndpoints("http://localhost:8080")
+		require.NoError(t, err)
+		assert.Equal(t, "ws://localhost:8080/mcp", endpoints.MCP)
+
+		for _, invalid := range []string{"localhost:8080", "ftp://example.com", "http://", "://bad"} {
+			_, err := NewEndpoints(invalid)
+			assert.Error(t, err, invalid)
+		}
+	})
+
+	t.Run("HealthUsesExternalURL", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ExternalURL = "https://mcp.example.com"
+		s := NewServerWithConfig(config)
+
+		req := httptest.NewRequest(http.MethodGet, "http://10.0.0.5:8080/health", nil)
+		rec := httptest.NewRecorder()
+		s.handleHealth(rec, req)
+
+		var body struct {
+			Endpoints Endpoints `json:"endpoints"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "wss://mcp.example.com/mcp", body.Endpoints.MCP)
+	})
+
+	forwardedHealth := func(t *testing.T, config Config) Endpoints {
+		s := NewServerWithConfig(config)
+
+		req := httptest.NewRequest(http.MethodGet, "http://10.0.0.5:8080/health", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "public.example.com")
+		rec := httptest.NewRecorder()
+		s.handleHealth(rec, req)
+
+		var body struct {
+			Endpoints Endpoints `json:"endpoints"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Endpoints
+	}
+
+	t.Run("HealthDerivesFromForwardedHeaders", func(t *testing.T) {
+		config := DefaultConfig()
+		config.TrustProxyHeaders = true
+		endpoints := forwardedHealth(t, config)
+		assert.Equal(t, "wss://public.example.com/mcp", endpoints.MCP)
+		assert.Equal(t, "https://public.example.com/health", endpoints.Health)
+	})
+
+	t.Run("HealthIgnoresUntrustedForwardedHeaders", func(t *testing.T) {
+		endpoints := forwardedHealth(t, DefaultConfig())
+		assert.Equal(t, "ws://10.0.0.5:8080/mcp", endpoints.MCP)
+		assert.Equal(t, "http://10.0.0.5:8080/health", endpoints.Health)
+	})
+
+	t.Run("ClientInfo", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.RemoteAddr = "10.0.0.9:51234"
+		assert.Equal(t, "10.0.0.9:51234", clientInfo(req))
+
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		assert.Equal(t, "203.0.113.7 (via 10.0.0.9) [https]", clientInfo(req))
+	})
+}
+
+// fakeAuditStore captures audit records in memory
+type fakeAuditStore struct {
+	mu         sync.Mutex
+	collection string
+	records    []AuditRecord
+}
+
+func (f *fakeAuditStore) InsertRecord(ctx context.Context, collection string, record interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.collection = collection
+	f.records = append(f.records, record.(AuditRecord))
+	return nil
+}
+
+func TestMCPServer_AuditLog(t *testing.T) {
+	callTool := func(t *testing.T, provider mcp.ToolProvider, store *fakeAuditStore, args map[string]interface{}) {
+		s := NewMCPServer()
+		s.RegisterToolProvider(provider)
+		config := DefaultAuditConfig()
+		config.Collection = "audit_test"
+		s.UseToolMiddleware(NewAuditLogger(store, config).Middleware())
+
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodCallTool,
+			Params:  map[string]interface{}{"name": "stub", "arguments": args},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+	}
+
+	t.Run("SuccessRecord", func(t *testing.T) {
+		store := &fakeAuditStore{}
+		provider := &stubToolProvider{name: "stub", response: &mcp.ToolCallResponse{
+			Content: []mcp.Content{{Type: "text", Text: "ok"}},
+		}}
+
+		before := time.Now()
+		callTool(t, provider, store, map[string]interface{}{
+			"query":    "golang",
+			"Password": "hunter2",
+			"options": map[string]interface{}{
+				"api_key": "abc123",
+				"limit":   float64(5),
+			},
+			"headers": []interface{}{map[string]interface{}{"authorization": "Bearer xyz"}},
+		})
+
+		require.Len(t, store.records, 1)
+		assert.Equal(t, "audit_test", store.collection)
+
+		record := store.records[0]
+		assert.Equal(t, "stub", record.Tool)
+		assert.Equal(t, "test-client", record.ClientName)
+		assert.Equal(t, "1.0.0", record.ClientVersion)
+		assert.False(t, record.IsError)
+		assert.Empty(t, record.Error)
+		assert.False(t, record.Timestamp.Before(before))
+		assert.GreaterOrEqual(t, record.DurationMs, int64(0))
+
+		assert.Equal(t, "golang", record.Arguments["query"])
+		assert.Equal(t, redactedValue, record.Arguments["Password"])
+		options := record.Arguments["options"].(map[string]interface{})
+		assert.Equal(t, redactedValue, options["api_key"])
+		assert.Equal(t, json.Number("5"), options["limit"])
+		headers := record.Arguments["headers"].([]interface{})
+		assert.Equal(t, redactedValue, headers[0].(map[string]interface{})["authorization"])
+	})
+
+	t.Run("ToolErrorRecord", func(t *testing.T) {
+		store := &fakeAuditStore{}
+		provider := &stubToolProvider{name: "stub", response: mcp.NewToolError("bad input", nil)}
+
+		callTool(t, provider, store, nil)
+
+		require.Len(t, store.records, 1)
+		assert.True(t, store.records[0].IsError)
+		assert.Equal(t, "bad input", store.records[0].Error)
+	})
+
+	t.Run("ProviderErrorRecord", func(t *testing.T) {
+		store := &fakeAuditStore{}
+		provider := &stubToolProvider{name: "stub", err: errors.New("backend unavailable")}
+
+		callTool(t, provider, store, nil)
+
+		require.Len(t, store.records, 1)
+		assert.True(t, store.records[0].IsError)
+		assert.Equal(t, "backend unavailable", store.records[0].Error)
+	})
+
+	t.Run("DoesNotModifyArguments", func(t *testing.T) {
+		logger := NewAuditLogger(&fakeAuditStore{}, AuditConfig{RedactKeys: []string{"token"}})
+		args := map[string]interface{}{"token": "secret-value", "password": "kept"}
+
+		redacted := logger.redact(args)
+		assert.Equal(t, redactedValue, redacted["token"])
+		assert.Equal(t, "kept", redacted["password"], "only configured keys are redacted")
+		assert.Equal(t, "secret-value", args["token"])
+	})
+}
+
+func TestMCPServer_BatchTool(t *testing.T) {
+	type batchResult struct {
+		Index   int           `json:"index"`
+		Name    string        `json:"name"`
+		IsError bool          `json:"isError"`
+		Content []mcp.Content `json:"content"`
+	}
+
+	runBatch := func(t *testing.T, s *MCPServer, args map[string]interface{}) (*mcp.ToolCallResponse, []batchResult) {
+		provider, ok := s.findToolProvider(context.Background(), "batch")
+		require.True(t, ok)
+
+		response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{Name: "batch", Arguments: args})
+		require.NoError(t, err)
+
+		var results []batchResult
+		if len(response.Content) > 0 {
+			json.Unmarshal([]byte(response.Content[0].Text), &results)
+		}
+		return response, results
+	}
+
+	newServer := func() *MCPServer {
+		s := NewMCPServer()
+		s.RegisterToolProvider(tools.NewMathToolProvider())
+		return s
+	}
+
+	calls := []interface{}{
+		map[string]interface{}{"name": "add", "arguments": map[string]interface{}{"a": 1, "b": 2}},
+		map[string]interface{}{"name": "add", "arguments": map[string]interface{}{"a": 1}},
+		map[string]interface{}{"name": "multiply", "arguments": map[string]interface{}{"a": 3, "b": 4}},
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		response, results := runBatch(t, newServer(), map[string]interface{}{
+			"calls": []interface{}{calls[0], calls[2]},
+		})
+
+		assert.False(t, response.IsError)
+		require.Len(t, results, 2)
+		assert.Equal(t, "add", results[0].Name)
+		assert.Contains(t, results[0].Content[0].Text, "= 3.00")
+		assert.Equal(t, 1, results[1].Index)
+		assert.Contains(t, results[1].Content[0].Text, "= 12.00")
+	})
+
+	t.Run("StopsOnFirstError", func(t *testing.T) {
+		response, results := runBatch(t, newServer(), map[string]interface{}{"calls": calls})
+
+		assert.True(t, response.IsError)
+		require.Len(t, results, 2)
+		assert.False(t, results[0].IsError)
+		assert.True(t, results[1].IsError)
+	})
+
+	t.Run("ContinueOnError", func(t *testing.T) {
+		response, results := runBatch(t, newServer(), map[string]interface{}{
+			"calls":             calls,
+			"continue_on_error": true,
+		})
+
+		assert.False(t, response.IsError)
+		require.Len(t, results, 3)
+		assert.True(t, results[1].IsError)
+		assert.Contains(t, results[2].Content[0].Text, "= 12.00")
+	})
+
+	t.Run("UnknownToolIsCallError", func(t *testing.T) {
+		response, results := runBatch(t, newServer(), map[string]interface{}{
+			"calls": []interface{}{map[string]interface{}{"name": "missing"}, calls[0]},
+		})
+
+		assert.True(t, response.IsError)
+		require.Len(t, results, 1)
+		assert.Contains(t, results[0].Content[0].Text, "Tool not found: missing")
+	})
+
+	t.Run("UsesToolMiddleware", func(t *testing.T) {
+		s := newServer()
+		var seen []string
+		s.UseToolMiddleware(func(next ToolHandler) ToolHandler {
+			return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+				seen = append(seen, request.Name)
+				return next(ctx, request)
+			}
+		})
+
+		runBatch(t, s, map[string]interface{}{"calls": []interface{}{calls[0], calls[2]}})
+		assert.Equal(t, []string{"add", "multiply"}, seen)
+	})
+
+	t.Run("InvalidBatches", func(t *testing.T) {
+		tooMany := make([]interface{}, maxBatchCalls+1)
+		for i := range tooMany {
+			tooMany[i] = calls[0]
+		}
+
+		invalid := []map[string]interface{}{
+			{},
+			{"calls": []interface{}{}},
+			{"calls": tooMany},
+			{"calls": []interface{}{"add"}},
+			{"calls": []interface{}{map[string]interface{}{"arguments": map[string]interface{}{}}}},
+			{"calls": []interface{}{calls[0], map[string]interface{}{"name": "batch"}}},
+		}
+		for _, args := range invalid {
+			response, results := runBatch(t, newServer(), args)
+			assert.True(t, response.IsError, "%v", args)
+			assert.Empty(t, results)
+		}
+	})
+}
+
+func TestMCPServer_RequestIDs(t *testing.T) {
+	decode := func(t *testing.T, raw string) *mcp.Message {
+		t.Helper()
+		var message mcp.Message
+		require.NoError(t, json.Unmarshal([]byte(raw), &message))
+		return &message
+	}
+
+	t.Run("AbsentIDIsNotification", func(t *testing.T) {
+		c := newTestConnection(NewMCPServer())
+
+		response := c.handleMessage(decode(t, `{"jsonrpc":"2.0","method":"initialized"}`))
+		assert.Nil(t, response)
+		assert.True(t, c.initialized)
+	})
+
+	t.Run("NullIDIsInvalidRequest", func(t *testing.T) {
+		c := newTestConnection(NewMCPServer())
+
+		response, ok := c.handleMessage(decode(t, `{"jsonrpc":"2.0","id":null,"method":"initialized"}`)).(*mcp.Response)
+		require.True(t, ok)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidRequest, response.Error.Code)
+		assert.Nil(t, response.ID)
+		assert.False(t, c.initialized)
+	})
+
+	t.Run("NullIDAllowed", func(t *testing.T) {
+		config := DefaultConfig()
+		config.AllowNullID = true
+		c := newTestConnection(NewServerWithConfig(config))
+
+		response := c.handleMessage(decode(t, `{"jsonrpc":"2.0","id":null,"method":"initialized"}`))
+		assert.Nil(t, response)
+		assert.True(t, c.initialized)
+	})
+
+	t.Run("PresentIDIsRequest", func(t *testing.T) {
+		c := newTestConnection(NewMCPServer())
+
+		response, ok := c.handleMessage(decode(t, `{"jsonrpc":"2.0","id":"req-1","method":"unknown/method"}`)).(*mcp.Response)
+		require.True(t, ok)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeMethodNotFound, response.Error.Code)
+		assert.Equal(t, "req-1", response.ID)
+	})
+}
+
+func TestMCPServer_ToolAnnotations(t *testing.T) {
+	s := NewMCPServer()
+	s.RegisterToolProvider(tools.NewMathToolProvider())
+
+	c := newTestConnection(s)
+	initializeConnection(t, c, "")
+	c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+	response, ok := c.handleMessage(&mcp.Message{
+		JSONRPC: "2.0",
+		ID:      2,
+		Method:  mcp.MethodListTools,
+	}).(*mcp.Response)
+	require.True(t, ok)
+	require.Nil(t, response.Error)
+
+	data, err := json.Marshal(response.Result)
+	require.NoError(t, err)
+
+	var result struct {
+		Tools []struct {
+			Name        string                 `json:"name"`
+			Annotations map[string]interface{} `json:"annotations"`
+		} `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal(data, &result))
+
+	annotations := make(map[string]map[string]interface{})
+	for _, tool := range result.Tools {
+		annotations[tool.Name] = tool.Annotations
+	}
+
+	require.Contains(t, annotations, "add")
+	assert.Equal(t, true, annotations["add"]["readOnlyHint"])
+	assert.Equal(t, false, annotations["add"]["openWorldHint"])
+
+	require.Contains(t, annotations, "describe_tool")
+	assert.Equal(t, true, annotations["describe_tool"]["readOnlyHint"])
+
+	require.Contains(t, annotations, "batch")
+	assert.Equal(t, false, annotations["batch"]["readOnlyHint"])
+	assert.Equal(t, true, annotations["batch"]["destructiveHint"])
+}
+
+// namedToolProvider is a stub provider that has a provider name and records the
+// tool names it is called with
+type namedToolProvider struct {
+	stubToolProvider
+	providerName string
+	called       []string
+}
+
+func (p *namedToolProvider) Name() string {
+	return p.providerName
+}
+
+func (p *namedToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	p.called = append(p.called, request.Name)
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: p.providerName}}}, nil
+}
+
+func TestMCPServer_ToolNameConflicts(t *testing.T) {
+	newProviders := func() (*namedToolProvider, *namedToolProvider) {
+		return &namedToolProvider{stubToolProvider: stubToolProvider{name: "health_check"}, providerName: "database"},
+			&namedToolProvider{stubToolProvider: stubToolProvider{name: "health_check"}, providerName: "search"}
+	}
+
+	toolNames := func(t *testing.T, s *MCPServer) []string {
+		tools, err := s.listTools(context.Background())
+		require.NoError(t, err)
+		var names []string
+		for _, tool := range tools {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+
+	t.Run("StrictRejectsDuplicate", func(t *testing.T) {
+		s := NewMCPServer()
+		first, second := newProviders()
+
+		require.NoError(t, s.RegisterToolProvider(first))
+		err := s.RegisterToolProvider(second)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"health_check"`)
+		assert.Contains(t, err.Error(), "search")
+
+		names := toolNames(t, s)
+		assert.Equal(t, 1, countOf(names, "health_check"))
+		assert.NotContains(t, names, "search_health_check")
+	})
+
+	t.Run("PrefixRenamesDuplicate", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ToolNameConflicts = ToolConflictPrefix
+		s := NewServerWithConfig(config)
+		first, second := newProviders()
+
+		require.NoError(t, s.RegisterToolProvider(first))
+		require.NoError(t, s.RegisterToolProvider(second))
+
+		names := toolNames(t, s)
+		assert.Equal(t, 1, countOf(names, "health_check"))
+		assert.Contains(t, names, "search_health_check")
+
+		provider, ok := s.findToolProvider(context.Background(), "search_health_check")
+		require.True(t, ok)
+		response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{Name: "search_health_check"})
+		require.NoError(t, err)
+		assert.Equal(t, "search", response.Content[0].Text)
+		assert.Equal(t, []string{"health_check"}, second.called, "the provider sees its own tool name")
+
+		provider, ok = s.findToolProvider(context.Background(), "health_check")
+		require.True(t, ok)
+		response, err = provider.CallTool(context.Background(), mcp.ToolCallRequest{Name: "health_check"})
+		require.NoError(t, err)
+		assert.Equal(t, "database", response.Content[0].Text)
+	})
+
+	t.Run("PrefixStillCollides", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ToolNameConflicts = ToolConflictPrefix
+		s := NewServerWithConfig(config)
+		first, second := newProviders()
+
+		require.NoError(t, s.RegisterToolProvider(first))
+		require.NoError(t, s.RegisterToolProvider(&stubToolProvider{name: "search_health_check"}))
+		assert.Error(t, s.RegisterToolProvider(second))
+	})
+
+	t.Run("UnnamedProvider", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ToolNameConflicts = ToolConflictPrefix
+		s := NewServerWithConfig(config)
+
+		require.NoError(t, s.RegisterToolProvider(&stubToolProvider{name: "echo"}))
+		require.NoError(t, s.RegisterToolProvider(&stubToolProvider{name: "echo"}))
+
+		// The built-in provider is registered first, so the duplicate is provider2
+		assert.Contains(t, toolNames(t, s), "provider2_echo")
+	})
+
+	t.Run("BuiltinNamesAreReserved", func(t *testing.T) {
+		s := NewMCPServer()
+		assert.Error(t, s.RegisterToolProvider(&stubToolProvider{name: "batch"}))
+	})
+}
+
+func countOf(values []string, value string) int {
+	count := 0
+	for _, v := range values {
+		if v == value {
+			count++
+		}
+	}
+	return count
+}
+
+// memoryExporter streams an in-memory collection, one JSON document per line
+type memoryExporter struct {
+	collections map[string][]map[string]interface{}
+	err         error
+}
+
+func (e *memoryExporter) ExportCollection(ctx context.Context, collection string, w io.Writer) error {
+	if e.err != nil {
+		return e.err
+	}
+	encoder := json.NewEncoder(w)
+	for _, doc := range e.collections[collection] {
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *memoryExporter) ValidateCollectionName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/$") {
+		return fmt.Errorf("invalid collection name %q", name)
+	}
+	return nil
+}
+
+func TestMCPServer_GzipResponses(t *testing.T) {
+	getTools := func(s *MCPServer, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tools", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+	s := NewMCPServer()
+	s.RegisterToolProvider(tools.NewMathToolProvider())
+	plain := getTools(s, "").Body.Bytes()
+	require.True(t, json.Valid(plain))
+
+	t.Run("Compressed", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"gzip", "deflate, gzip;q=0.5", "*"} {
+			rec := getTools(s, acceptEncoding)
+			assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"), acceptEncoding)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+
+			reader, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, plain, body)
+		}
+	})
+
+	t.Run("Uncompressed", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"", "deflate", "gzip;q=0", "*;q=0.0"} {
+			rec := getTools(s, acceptEncoding)
+			assert.Empty(t, rec.Header().Get("Content-Encoding"), acceptEncoding)
+			assert.Equal(t, plain, rec.Body.Bytes())
+		}
+
+		config := DefaultConfig()
+		config.CompressResponses = false
+		disabled := NewServerWithConfig(config)
+		disabled.RegisterToolProvider(tools.NewMathToolProvider())
+		rec := getTools(disabled, "gzip")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Empty(t, rec.Header().Get("Vary"))
+		assert.Equal(t, plain, rec.Body.Bytes())
+	})
+
+	t.Run("WebSocketUnaffected", func(t *testing.T) {
+		ts := httptest.NewServer(s.routes())
+		defer ts.Close()
+
+		header := http.Header{"Accept-Encoding": []string{"gzip"}}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/mcp", header)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  mcp.MethodInitialize,
+			"params":  map[string]interface{}{},
+		}))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var response map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&response))
+		assert.Nil(t, response["error"])
+	})
+}
+
+func TestMCPServer_Export(t *testing.T) {
+	docs := make([]map[string]interface{}, 250)
+	for i := range docs {
+		docs[i] = map[string]interface{}{"_id": fmt.Sprintf("doc-%d", i), "n": i}
+	}
+	exporter := &memoryExporter{collections: map[string][]map[string]interface{}{"documents": docs}}
+
+	newServer := func(keys ...string) *httptest.Server {
+		config := DefaultConfig()
+		config.APIKeys = keys
+		s := NewServerWithConfig(config)
+		s.SetCollectionExporter(exporter)
+		return httptest.NewServer(s.routes())
+	}
+
+	get := func(t *testing.T, ts *httptest.Server, path string, header http.Header) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		require.NoError(t, err)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("StreamsEveryDocument", func(t *testing.T) {
+		ts := newServer("secret")
+		defer ts.Close()
+
+		resp := get(t, ts, "/export/documents", http.Header{"Authorization": {"Bearer secret"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		lines := 0
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var doc map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+			lines++
+		}
+		require.NoError(t, scanner.Err())
+		assert.Equal(t, len(docs), lines)
+	})
+
+	t.Run("APIKeyHeader", func(t *testing.T) {
+		ts := newServer("secret")
+		defer ts.Close()
+
+		resp := get(t, ts, "/export/documents", http.Header{"X-Api-Key": {"secret"}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("EmptyCollection", func(t *testing.T) {
+		ts := newServer("secret")
+		defer ts.Close()
+
+		resp := get(t, ts, "/export/empty", http.Header{"Authorization": {"Bearer secret"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Empty(t, body)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		ts := newServer("secret")
+		defer ts.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, get(t, ts, "/export/documents", nil).StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, get(t, ts, "/export/documents", http.Header{"Authorization": {"Bearer wrong"}}).StatusCode)
+	})
+
+	t.Run("NoKeysConfigured", func(t *testing.T) {
+		ts := newServer()
+		defer ts.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, get(t, ts, "/export/documents", http.Header{"Authorization": {"Bearer "}}).StatusCode)
+	})
+
+	t.Run("InvalidCollection", func(t *testing.T) {
+		ts := newServer("secret")
+		defer ts.Close()
+
+		resp := get(t, ts, "/export/bad$name", http.Header{"Authorization": {"Bearer secret"}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("ExportError", func(t *testing.T) {
+		config := DefaultConfig()
+		config.APIKeys = []string{"secret"}
+		s := NewServerWithConfig(config)
+		s.SetCollectionExporter(&memoryExporter{err: fmt.Errorf("connection lost")})
+		ts := httptest.NewServer(s.routes())
+		defer ts.Close()
+
+		resp := get(t, ts, "/export/documents", http.Header{"Authorization": {"Bearer secret"}})
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("NoExporter", func(t *testing.T) {
+		config := DefaultConfig()
+		config.APIKeys = []string{"secret"}
+		ts := httptest.NewServer(NewServerWithConfig(config).routes())
+		defer ts.Close()
+
+		resp := get(t, ts, "/export/documents", http.Header{"Authorization": {"Bearer secret"}})
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// slowToolProvider exposes a "slow" tool that sleeps before answering and a
+// "large" tool that returns size bytes of text
+type slowToolProvider struct {
+	delay time.Duration
+	size  int
+}
+
+func (p *slowToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "slow"}, {Name: "large"}}, nil
+}
+
+func (p *slowToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	if request.Name == "slow" {
+		time.Sleep(p.delay)
+	}
+	text := "ok"
+	if request.Name == "large" {
+		text = strings.Repeat("x", p.size)
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+}
+
+// latencyToolProvider has a "fast" tool that returns at once, a "slow" one
+// that takes delay unless its context is cancelled first, and a "stubborn"
+// one that takes delay regardless
+type latencyToolProvider struct {
+	delay     time.Duration
+	cancelled chan struct{}
+}
+
+func (p *latencyToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "fast"}, {Name: "slow"}, {Name: "stubborn"}}, nil
+}
+
+func (p *latencyToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	switch request.Name {
+	case "stubborn":
+		time.Sleep(p.delay)
+	case "slow":
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			close(p.cancelled)
+			return nil, ctx.Err()
+		}
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: request.Name}}}, nil
+}
+
+func TestMCPServer_ToolTimeouts(t *testing.T) {
+	call := func(t *testing.T, config Config, provider *latencyToolProvider, name string) *mcp.ToolCallResponse {
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(provider))
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodCallTool,
+			Params:  map[string]interface{}{"name": name},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+		return response.Result.(*mcp.ToolCallResponse)
+	}
+
+	config := DefaultConfig()
+	config.DefaultToolTimeout = 50 * time.Millisecond
+	config.ToolTimeouts = map[string]time.Duration{"slow": 2 * time.Second}
+
+	t.Run("FastToolWithinDefault", func(t *testing.T) {
+		provider := &latencyToolProvider{delay: time.Second, cancelled: make(chan struct{})}
+		result := call(t, config, provider, "fast")
+		assert.False(t, result.IsError)
+		assert.Equal(t, "fast", result.Content[0].Text)
+	})
+
+	t.Run("SlowToolWithinOverride", func(t *testing.T) {
+		provider := &latencyToolProvider{delay: 200 * time.Millisecond, cancelled: make(chan struct{})}
+		result := call(t, config, provider, "slow")
+		assert.False(t, result.IsError)
+		assert.Equal(t, "slow", result.Content[0].Text)
+	})
+
+	t.Run("SlowToolExceedsTimeout", func(t *testing.T) {
+		tight := config
+		tight.ToolTimeouts = map[string]time.Duration{"slow": 50 * time.Millisecond}
+		provider := &latencyToolProvider{delay: 5 * time.Second, cancelled: make(chan struct{})}
+
+		started := time.Now()
+		result := call(t, tight, provider, "slow")
+		assert.Less(t, time.Since(started), 2*time.Second)
+		assert.True(t, result.IsError)
+		assert.Equal(t, "Tool slow timed out after 50ms", result.Content[0].Text)
+
+		select {
+		case <-provider.cancelled:
+		default:
+			t.Fatal("the tool's context was not cancelled")
+		}
+	})
+
+	t.Run("SlowToolExceedsDefault", func(t *testing.T) {
+		provider := &latencyToolProvider{delay: 5 * time.Second, cancelled: make(chan struct{})}
+		result := call(t, Config{DefaultToolTimeout: 50 * time.Millisecond}, provider, "slow")
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content[0].Text, "timed out after 50ms")
+	})
+
+	t.Run("SuccessPastTimeoutKept", func(t *testing.T) {
+		provider := &latencyToolProvider{delay: 150 * time.Millisecond, cancelled: make(chan struct{})}
+		result := call(t, Config{DefaultToolTimeout: 50 * time.Millisecond}, provider, "stubborn")
+		assert.False(t, result.IsError)
+		assert.Equal(t, "stubborn", result.Content[0].Text)
+	})
+
+	t.Run("ZeroDisables", func(t *testing.T) {
+		unlimited := config
+		unlimited.DefaultToolTimeout = 0
+		unlimited.ToolTimeouts = map[string]time.Duration{"slow": 0}
+		provider := &latencyToolProvider{delay: 100 * time.Millisecond, cancelled: make(chan struct{})}
+		result := call(t, unlimited, provider, "slow")
+		assert.False(t, result.IsError)
+	})
+}
+
+func TestParseToolTimeouts(t *testing.T) {
+	timeouts, err := ParseToolTimeouts(" web_search=30s, db_count_documents = 2s,,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"web_search":         30 * time.Second,
+		"db_count_documents": 2 * time.Second,
+	}, timeouts)
+
+	timeouts, err = ParseToolTimeouts("")
+	require.NoError(t, err)
+	assert.Empty(t, timeouts)
+
+	for _, invalid := range []string{"web_search", "=5s", "web_search=soon", "web_search=-1s"} {
+		_, err := ParseToolTimeouts(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMCPServer_CallLog(t *testing.T) {
+	call := func(t *testing.T, name string, args map[string]interface{}) []string {
+		config := DefaultConfig()
+		config.SlowCallThreshold = 0
+		config.LargeResponseThreshold = 0
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(&slowToolProvider{delay: 30 * time.Millisecond, size: 2048}))
+
+		var lines []string
+		s.UseToolMiddleware(callLogMiddleware(10*time.Millisecond, 1024, func(format string, args ...interface{}) {
+			lines = append(lines, fmt.Sprintf(format, args...))
+		}))
+
+		provider, ok := s.findToolProvider(context.Background(), name)
+		require.True(t, ok)
+		_, err := s.toolHandler(provider)(context.Background(), mcp.ToolCallRequest{Name: name, Arguments: args})
+		require.NoError(t, err)
+		return lines
+	}
+
+	t.Run("SlowCallIsLogged", func(t *testing.T) {
+		lines := call(t, "slow", map[string]interface{}{"collection": "documents", "limit": float64(5)})
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], "Slow tool call: slow")
+		assert.Contains(t, lines[0], `collection="documents"`)
+		assert.Contains(t, lines[0], "limit=5")
+	})
+
+	t.Run("LargeResponseIsLogged", func(t *testing.T) {
+		lines := call(t, "large", nil)
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], "Large tool response: large")
+	})
+
+	t.Run("LargeResponseOffByDefault", func(t *testing.T) {
+		var lines []string
+		handler := callLogMiddleware(0, DefaultConfig().LargeResponseThreshold, func(format string, args ...interface{}) {
+			lines = append(lines, fmt.Sprintf(format, args...))
+		})((&slowToolProvider{size: 2 << 20}).CallTool)
+		_, err := handler(context.Background(), mcp.ToolCallRequest{Name: "large"})
+		require.NoError(t, err)
+		assert.Empty(t, lines)
+	})
+
+	t.Run("SensitiveArgumentsRedacted", func(t *testing.T) {
+		lines := call(t, "slow", map[string]interface{}{"password": "hunter2"})
+		require.Len(t, lines, 1)
+		assert.NotContains(t, lines[0], "hunter2")
+		assert.Contains(t, lines[0], "password=[REDACTED]")
+	})
+
+	t.Run("SummarizeArgs", func(t *testing.T) {
+		summary := summarizeArgs(map[string]interface{}{
+			"query":  strings.Repeat("a", 100),
+			"filter": map[string]interface{}{"a": 1, "b": 2},
+			"tags":   []interface{}{"x", "y", "z"},
+			"flag":   true,
+		}, nil)
+		assert.Equal(t, `{filter={2 keys} flag=true query="`+strings.Repeat("a", maxLoggedArgLength)+`..." tags=[3 items]}`, summary)
+		assert.Equal(t, "{}", summarizeArgs(nil, nil))
+	})
+}
+
+func TestMCPServer_ResponseMeta(t *testing.T) {
+	call := func(t *testing.T, config Config, name string) *mcp.ToolCallResponse {
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(&slowToolProvider{delay: 20 * time.Millisecond}))
+
+		provider, ok := s.findToolProvider(context.Background(), name)
+		require.True(t, ok)
+		response, err := s.toolHandler(provider)(context.Background(), mcp.ToolCallRequest{Name: name})
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ResponseMeta = true
+		before := time.Now().UTC()
+		response := call(t, config, "slow")
+
+		assert.Equal(t, "ok", response.Content[0].Text)
+		require.NotNil(t, response.Meta)
+		assert.Equal(t, "slow", response.Meta["tool"])
+		assert.GreaterOrEqual(t, response.Meta["durationMs"], int64(20))
+
+		timestamp, err := time.Parse(time.RFC3339Nano, response.Meta["timestamp"].(string))
+		require.NoError(t, err)
+		assert.False(t, timestamp.Before(before))
+
+		data, err := json.Marshal(response)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"_meta":{"durationMs":`)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		response := call(t, DefaultConfig(), "slow")
+		assert.Nil(t, response.Meta)
+
+		data, err := json.Marshal(response)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "_meta")
+	})
+
+	t.Run("KeepsToolMeta", func(t *testing.T) {
+		shared := &mcp.ToolCallResponse{
+			Content: []mcp.Content{{Type: "text", Text: "fixed"}},
+			Meta:    map[string]interface{}{"source": "cache", "tool": "spoofed"},
+		}
+		handler := responseMetaMiddleware(time.Now)(func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+			return shared, nil
+		})
+
+		response, err := handler(context.Background(), mcp.ToolCallRequest{Name: "fixed"})
+		require.NoError(t, err)
+		assert.Equal(t, "cache", response.Meta["source"])
+		assert.Equal(t, "fixed", response.Meta["tool"])
+
+		// The tool's own response is left untouched
+		assert.Equal(t, map[string]interface{}{"source": "cache", "tool": "spoofed"}, shared.Meta)
+	})
+}
+
+// schemaToolProvider declares an output schema and returns the configured structured content
+type schemaToolProvider struct {
+	structured interface{}
+}
+
+func (p *schemaToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{
+		Name: "measure",
+		OutputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"value": map[string]interface{}{"type": "number"},
+			},
+			"required": []string{"value"},
+		},
+	}}, nil
+}
+
+func (p *schemaToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	return &mcp.ToolCallResponse{
+		Content:           []mcp.Content{{Type: "text", Text: "measured"}},
+		StructuredContent: p.structured,
+	}, nil
+}
+
+func TestMCPServer_OutputValidation(t *testing.T) {
+	call := func(t *testing.T, provider mcp.ToolProvider, name string, args map[string]interface{}) []string {
+		s := NewMCPServer()
+		require.NoError(t, s.RegisterToolProvider(provider))
+
+		var lines []string
+		s.UseToolMiddleware(s.outputValidationMiddleware(func(format string, args ...interface{}) {
+			lines = append(lines, fmt.Sprintf(format, args...))
+		}))
+
+		p, ok := s.findToolProvider(context.Background(), name)
+		require.True(t, ok)
+		_, err := s.toolHandler(p)(context.Background(), mcp.ToolCallRequest{Name: name, Arguments: args})
+		require.NoError(t, err)
+		return lines
+	}
+
+	t.Run("ValidResult", func(t *testing.T) {
+		assert.Empty(t, call(t, &schemaToolProvider{structured: map[string]interface{}{"value": 1.5}}, "measure", nil))
+	})
+
+	t.Run("MalformedResultIsFlagged", func(t *testing.T) {
+		lines := call(t, &schemaToolProvider{structured: map[string]interface{}{"value": "high"}}, "measure", nil)
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], "OUTPUT SCHEMA MISMATCH: tool measure")
+		assert.Contains(t, lines[0], "$.value: expected number, got string")
+	})
+
+	t.Run("MissingStructuredContentIsFlagged", func(t *testing.T) {
+		lines := call(t, &schemaToolProvider{}, "measure", nil)
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], "no structured content")
+	})
+
+	t.Run("MathToolsMatchTheirSchemas", func(t *testing.T) {
+		math := tools.NewMathToolProvider()
+		assert.Empty(t, call(t, math, "add", map[string]interface{}{"a": 2.0, "b": 3.0}))
+		assert.Empty(t, call(t, math, "multiply", map[string]interface{}{"a": 2.0, "b": 3.0}))
+		assert.Empty(t, call(t, math, "power", map[string]interface{}{"base": 2.0, "exponent": 10.0}))
+	})
+
+	t.Run("ToolsWithoutSchemaAreSkipped", func(t *testing.T) {
+		assert.Empty(t, call(t, &stubToolProvider{name: "plain", response: &mcp.ToolCallResponse{}}, "plain", nil))
+	})
+}
+
+// staticCompletionProvider completes a single argument from a fixed list
+type staticCompletionProvider struct {
+	argument string
+	values   []string
+}
+
+func (p *staticCompletionProvider) Complete(ctx context.Context, request mcp.CompleteRequest) ([]string, error) {
+	if request.Argument.Name != p.argument {
+		return nil, nil
+	}
+	var matches []string
+	for _, value := range p.values {
+		if strings.HasPrefix(value, request.Argument.Value) {
+			matches = append(matches, value)
+		}
+	}
+	return matches, nil
+}
+
+func TestMCPServer_Completion(t *testing.T) {
+	connect := func(t *testing.T, providers ...mcp.CompletionProvider) *Connection {
+		s := NewMCPServer()
+		for _, provider := range providers {
+			s.RegisterCompletionProvider(provider)
+		}
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		return c
+	}
+	complete := func(t *testing.T, c *Connection, params map[string]interface{}) *mcp.Response {
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodComplete,
+			Params:  params,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		return response
+	}
+	categoryParams := func(value string) map[string]interface{} {
+		return map[string]interface{}{
+			"ref":      map[string]interface{}{"type": mcp.CompletionRefResource, "uri": "mongodb://documents"},
+			"argument": map[string]interface{}{"name": "category", "value": value},
+		}
+	}
+
+	t.Run("CategoryPrefix", func(t *testing.T) {
+		c := connect(t,
+			&staticCompletionProvider{argument: "category", values: []string{"kubernetes", "golang", "kafka"}},
+			&staticCompletionProvider{argument: "category", values: []string{"kafka", "keda"}},
+			&staticCompletionProvider{argument: "collection", values: []string{"knowledgebase"}},
+		)
+
+		response := complete(t, c, categoryParams("k"))
+		require.Nil(t, response.Error)
+		result, ok := response.Result.(mcp.CompleteResponse)
+		require.True(t, ok)
+		assert.Equal(t, []string{"kafka", "keda", "kubernetes"}, result.Completion.Values)
+		assert.False(t, result.Completion.HasMore)
+	})
+
+	t.Run("CapabilityAdvertised", func(t *testing.T) {
+		s := NewMCPServer()
+		c := newTestConnection(s)
+		result := initializeResult(t, c)
+		assert.Nil(t, result.Capabilities.Completions)
+
+		s.RegisterCompletionProvider(&staticCompletionProvider{})
+		result = initializeResult(t, newTestConnection(s))
+		assert.NotNil(t, result.Capabilities.Completions)
+	})
+
+	t.Run("TruncatesLongLists", func(t *testing.T) {
+		values := make([]string, maxCompletionValues+20)
+		for i := range values {
+			values[i] = fmt.Sprintf("category-%03d", i)
+		}
+		c := connect(t, &staticCompletionProvider{argument: "category", values: values})
+
+		response := complete(t, c, categoryParams("category-"))
+		require.Nil(t, response.Error)
+		completion := response.Result.(mcp.CompleteResponse).Completion
+		assert.Len(t, completion.Values, maxCompletionValues)
+		assert.Equal(t, len(values), completion.Total)
+		assert.True(t, completion.HasMore)
+	})
+
+	t.Run("InvalidParams", func(t *testing.T) {
+		c := connect(t)
+
+		response := complete(t, c, map[string]interface{}{
+			"ref":      map[string]interface{}{"type": "ref/tool"},
+			"argument": map[string]interface{}{"name": "category"},
+		})
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidParams, response.Error.Code)
+
+		response = complete(t, c, map[string]interface{}{
+			"ref": map[string]interface{}{"type": mcp.CompletionRefPrompt, "name": "summarize"},
+		})
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidParams, response.Error.Code)
+	})
+
+	t.Run("RequiresInitialization", func(t *testing.T) {
+		c := newTestConnection(NewMCPServer())
+		response := complete(t, c, categoryParams("k"))
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidRequest, response.Error.Code)
+	})
+}
+
+// initializeResult runs the initialize request and returns its result
+func initializeResult(t *testing.T, c *Connection) mcp.InitializeResponse {
+	t.Helper()
+
+	response, ok := c.handleMessage(&mcp.Message{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  mcp.MethodInitialize,
+	}).(*mcp.Response)
+	require.True(t, ok)
+	require.Nil(t, response.Error)
+
+	result, ok := response.Result.(mcp.InitializeResponse)
+	require.True(t, ok)
+	return result
+}
+
+func TestMCPServer_CorrelationID(t *testing.T) {
+	// captureLog redirects the standard logger for the duration of the test
+	captureLog := func(t *testing.T) *bytes.Buffer {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		t.Cleanup(func() { log.SetOutput(os.Stderr) })
+		return &buf
+	}
+
+	connect := func(t *testing.T, logRequests bool) *Connection {
+		config := DefaultConfig()
+		config.LogRequests = logRequests
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(tools.NewMathToolProvider()))
+		c := newTestConnection(s)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		return c
+	}
+
+	callUnknownTool := func(t *testing.T, c *Connection, meta map[string]interface{}) *mcp.Response {
+		params := map[string]interface{}{"name": "no_such_tool"}
+		if meta != nil {
+			params["_meta"] = meta
+		}
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      7,
+			Method:  mcp.MethodCallTool,
+			Params:  params,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.NotNil(t, response.Error)
+		return response
+	}
+
+	t.Run("ClientProvidedID", func(t *testing.T) {
+		c := connect(t, true)
+		buf := captureLog(t)
+
+		response := callUnknownTool(t, c, map[string]interface{}{"requestId": "req-42"})
+		assert.Contains(t, buf.String(), "[req-42] Request 7: tools/call")
+		assert.Contains(t, buf.String(), "[req-42] Request 7: tools/call failed")
+
+		data, ok := response.Error.Data.(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, "req-42", data["correlationId"])
+	})
+
+	t.Run("GeneratedID", func(t *testing.T) {
+		c := connect(t, true)
+		buf := captureLog(t)
+
+		// An id with a line break would allow forging log lines, so it is replaced
+		response := callUnknownTool(t, c, map[string]interface{}{"requestId": "bad\nid"})
+		data, ok := response.Error.Data.(map[string]interface{})
+		require.True(t, ok)
+		id, _ := data["correlationId"].(string)
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+		assert.Contains(t, buf.String(), "["+id+"] Request 7: tools/call")
+		assert.NotContains(t, buf.String(), "bad\nid")
+	})
+
+	t.Run("ToolMiddlewareSeesID", func(t *testing.T) {
+		c := connect(t, false)
+		var seen string
+		c.server.UseToolMiddleware(func(next ToolHandler) ToolHandler {
+			return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+				seen = CorrelationID(ctx)
+				return next(ctx, request)
+			}
+		})
+
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      8,
+			Method:  mcp.MethodCallTool,
+			Params: map[string]interface{}{
+				"name":      "add",
+				"arguments": map[string]interface{}{"a": 1, "b": 2},
+				"_meta":     map[string]interface{}{"requestId": "req-43"},
+			},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+		assert.Equal(t, "req-43", seen)
+	})
+
+	t.Run("DisabledLeavesErrorData", func(t *testing.T) {
+		c := connect(t, false)
+		buf := captureLog(t)
+
+		response := callUnknownTool(t, c, map[string]interface{}{"requestId": "req-44"})
+		assert.Nil(t, response.Error.Data)
+		assert.NotContains(t, buf.String(), "req-44")
+	})
+}
+
+// countingToolProvider counts calls to a read-only "lookup" tool and a
+// mutating "store" tool
+type countingToolProvider struct {
+	calls map[string]int
+}
+
+func (p *countingToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{
+		{Name: "lookup", Annotations: mcp.ReadOnlyAnnotations(false), Cacheable: true},
+		{Name: "status", Annotations: mcp.ReadOnlyAnnotations(false)},
+		{Name: "store", Annotations: &mcp.ToolAnnotations{DestructiveHint: true}},
+	}, nil
+}
+
+func (p *countingToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	p.calls[request.Name]++
+	if request.Arguments["fail"] == true {
+		return mcp.NewToolError("failed", nil), nil
+	}
+	text := fmt.Sprintf("%s call %d", request.Name, p.calls[request.Name])
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+}
+
+func TestMCPServer_ToolCache(t *testing.T) {
+	setup := func(t *testing.T) (*MCPServer, *countingToolProvider, func(name string, args map[string]interface{}) string) {
+		config := DefaultConfig()
+		config.ToolCacheTTL = time.Minute
+		s := NewServerWithConfig(config)
+		provider := &countingToolProvider{calls: make(map[string]int)}
+		require.NoError(t, s.RegisterToolProvider(provider))
+
+		call := func(name string, args map[string]interface{}) string {
+			p, ok := s.findToolProvider(context.Background(), name)
+			require.True(t, ok)
+			response, err := s.toolHandler(p)(context.Background(), mcp.ToolCallRequest{Name: name, Arguments: args})
+			require.NoError(t, err)
+			return response.Content[0].Text
+		}
+		return s, provider, call
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		_, provider, call := setup(t)
+
+		first := call("lookup", map[string]interface{}{"collection": "docs", "id": "1"})
+		second := call("lookup", map[string]interface{}{"id": "1", "collection": "docs"})
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, provider.calls["lookup"])
+
+		// Different arguments are a different entry
+		call("lookup", map[string]interface{}{"collection": "docs", "id": "2"})
+		assert.Equal(t, 2, provider.calls["lookup"])
+	})
+
+	t.Run("NoCacheRefreshes", func(t *testing.T) {
+		_, provider, call := setup(t)
+
+		assert.Equal(t, "lookup call 1", call("lookup", map[string]interface{}{"collection": "docs"}))
+		assert.Equal(t, "lookup call 2", call("lookup", map[string]interface{}{"collection": "docs", "no_cache": true}))
+		assert.Equal(t, "lookup call 3", call("lookup", map[string]interface{}{"collection": "docs", "no_cache": true}))
+
+		// The fresh result replaced the cached one
+		assert.Equal(t, "lookup call 3", call("lookup", map[string]interface{}{"collection": "docs"}))
+		assert.Equal(t, "lookup call 3", call("lookup", map[string]interface{}{"collection": "docs", "no_cache": false}))
+		assert.Equal(t, 3, provider.calls["lookup"])
+	})
+
+	t.Run("TTLExpiry", func(t *testing.T) {
+		s, provider, call := setup(t)
+		now := time.Now()
+		s.toolCache.now = func() time.Time { return now }
+
+		call("lookup", map[string]interface{}{"collection": "docs"})
+		now = now.Add(59 * time.Second)
+		call("lookup", map[string]interface{}{"collection": "docs"})
+		assert.Equal(t, 1, provider.calls["lookup"])
+
+		now = now.Add(time.Second)
+		assert.Equal(t, "lookup call 2", call("lookup", map[string]interface{}{"collection": "docs"}))
+	})
+
+	t.Run("InvalidatedByWrite", func(t *testing.T) {
+		_, provider, call := setup(t)
+
+		call("lookup", map[string]interface{}{"collection": "docs"})
+		call("lookup", map[string]interface{}{"collection": "notes"})
+
+		call("store", map[string]interface{}{"collection": "docs"})
+		assert.Equal(t, "lookup call 3", call("lookup", map[string]interface{}{"collection": "docs"}))
+		assert.Equal(t, "lookup call 2", call("lookup", map[string]interface{}{"collection": "notes"}))
+
+		// A write naming no collection clears everything
+		call("store", nil)
+		call("lookup", map[string]interface{}{"collection": "notes"})
+		assert.Equal(t, 4, provider.calls["lookup"])
+
+		// A failed write leaves the cache alone
+		call("store", map[string]interface{}{"collection": "notes", "fail": true})
+		call("lookup", map[string]interface{}{"collection": "notes"})
+		assert.Equal(t, 4, provider.calls["lookup"])
+
+		// A read naming no collection may have used a default one, so any write drops it
+		call("lookup", map[string]interface{}{"id": "1"})
+		call("store", map[string]interface{}{"collection": "docs"})
+		call("lookup", map[string]interface{}{"id": "1"})
+		assert.Equal(t, 6, provider.calls["lookup"])
+	})
+
+	t.Run("OnlyCacheableToolsCached", func(t *testing.T) {
+		_, provider, call := setup(t)
+
+		// status is read-only but not cacheable: every call runs
+		assert.Equal(t, "status call 1", call("status", nil))
+		assert.Equal(t, "status call 2", call("status", nil))
+
+		// and, being read-only, it leaves cached results in place
+		call("lookup", map[string]interface{}{"collection": "docs"})
+		call("status", map[string]interface{}{"collection": "docs"})
+		call("lookup", map[string]interface{}{"collection": "docs"})
+		assert.Equal(t, 1, provider.calls["lookup"])
+	})
+
+	t.Run("ErrorsNotCached", func(t *testing.T) {
+		_, provider, call := setup(t)
+
+		call("lookup", map[string]interface{}{"fail": true})
+		call("lookup", map[string]interface{}{"fail": true})
+		assert.Equal(t, 2, provider.calls["lookup"])
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		s := NewMCPServer()
+		assert.Nil(t, s.toolCache)
+	})
+}
+
+// clientInfoToolProvider reports the client info found in the call context
+type clientInfoToolProvider struct{}
+
+func (p *clientInfoToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "whoami"}}, nil
+}
+
+func (p *clientInfoToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	info, ok := mcp.ClientInfoFromContext(ctx)
+	if !ok {
+		return mcp.NewToolError("no client info", nil), nil
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: info.Name + " " + info.Version}}}, nil
+}
+
+func TestMCPServer_ClientInfo(t *testing.T) {
+	whoami := func(t *testing.T, clientInfo map[string]interface{}) *mcp.ToolCallResponse {
+		s := NewMCPServer()
+		require.NoError(t, s.RegisterToolProvider(&clientInfoToolProvider{}))
+		c := newTestConnection(s)
+
+		params := map[string]interface{}{"protocolVersion": mcp.ProtocolVersion}
+		if clientInfo != nil {
+			params["clientInfo"] = clientInfo
+		}
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", ID: 1, Method: mcp.MethodInitialize, Params: params})
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodCallTool,
+			Params:  map[string]interface{}{"name": "whoami"},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+		return response.Result.(*mcp.ToolCallResponse)
+	}
+
+	t.Run("AvailableToTools", func(t *testing.T) {
+		result := whoami(t, map[string]interface{}{"name": "claude-desktop", "version": "0.9.2"})
+		require.False(t, result.IsError)
+		assert.Equal(t, "claude-desktop 0.9.2", result.Content[0].Text)
+	})
+
+	t.Run("AbsentWhenNotSent", func(t *testing.T) {
+		result := whoami(t, nil)
+		assert.True(t, result.IsError)
+	})
+}
+
+// argumentsToolProvider writes into the arguments map it is given, as a tool
+// filling in defaults would
+type argumentsToolProvider struct {
+	received map[string]interface{}
+}
+
+func (p *argumentsToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "defaults"}}, nil
+}
+
+func (p *argumentsToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	request.Arguments["limit"] = 10
+	p.received = request.Arguments
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: "ok"}}}, nil
+}
+
+func TestMCPServer_OmittedArguments(t *testing.T) {
+	s := NewMCPServer()
+	provider := &argumentsToolProvider{}
+	require.NoError(t, s.RegisterToolProvider(provider))
+	c := newTestConnection(s)
+	initializeConnection(t, c, "")
+	c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+	call := func(t *testing.T, params map[string]interface{}) *mcp.ToolCallResponse {
+		provider.received = nil
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodCallTool,
+			Params:  params,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+		return response.Result.(*mcp.ToolCallResponse)
+	}
+
+	t.Run("Direct", func(t *testing.T) {
+		result := call(t, map[string]interface{}{"name": "defaults"})
+		assert.False(t, result.IsError)
+		assert.Equal(t, map[string]interface{}{"limit": 10}, provider.received)
+	})
+
+	t.Run("NullArguments", func(t *testing.T) {
+		result := call(t, map[string]interface{}{"name": "defaults", "arguments": nil})
+		assert.False(t, result.IsError)
+		assert.NotNil(t, provider.received)
+	})
+
+	t.Run("InBatch", func(t *testing.T) {
+		result := call(t, map[string]interface{}{
+			"name": "batch",
+			"arguments": map[string]interface{}{
+				"calls": []interface{}{map[string]interface{}{"name": "defaults"}},
+			},
+		})
+		assert.False(t, result.IsError, result.Content[0].Text)
+		assert.NotNil(t, provider.received)
+	})
+}
+
+// templateResourceProvider serves "notes://{id}" from a template only
+type templateResourceProvider struct{}
+
+func (p *templateResourceProvider) ListResources(ctx context.Context) ([]mcp.Resource, error) {
+	return nil, nil
+}
+
+func (p *templateResourceProvider) ListResourceTemplates(ctx context.Context) ([]mcp.ResourceTemplate, error) {
+	return []mcp.ResourceTemplate{{URITemplate: "notes://{id}", Name: "Note", MimeType: "text/plain"}}, nil
+}
+
+func (p *templateResourceProvider) ReadResource(ctx context.Context, uri string) (*mcp.ResourceReadResponse, error) {
+	values, ok := mcp.MatchURITemplate("notes://{id}", uri)
+	if !ok {
+		return nil, fmt.Errorf("not a note: %s", uri)
+	}
+	return &mcp.ResourceReadResponse{Contents: []mcp.ResourceContent{{URI: uri, Text: "note " + values["id"], ETag: `"v1"`}}}, nil
+}
+
+// staticResourceProvider lists one resource and has no templates
+type staticResourceProvider struct{}
+
+func (p *staticResourceProvider) ListResources(ctx context.Context) ([]mcp.Resource, error) {
+	return []mcp.Resource{{URI: "static://readme", Name: "Readme"}}, nil
+}
+
+func (p *staticResourceProvider) ReadResource(ctx context.Context, uri string) (*mcp.ResourceReadResponse, error) {
+	return &mcp.ResourceReadResponse{Contents: []mcp.ResourceContent{{URI: uri, Text: "readme"}}}, nil
+}
+
+func TestMCPServer_ResourceTemplates(t *testing.T) {
+	s := NewMCPServer()
+	s.RegisterResourceProvider(&staticResourceProvider{})
+	s.RegisterResourceProvider(&templateResourceProvider{})
+	c := newTestConnection(s)
+	initializeConnection(t, c, "")
+	c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+
+	request := func(t *testing.T, method string, params interface{}) *mcp.Response {
+		response, ok := c.handleMessage(&mcp.Message{JSONRPC: "2.0", ID: 2, Method: method, Params: params}).(*mcp.Response)
+		require.True(t, ok)
+		return response
+	}
+
+	t.Run("List", func(t *testing.T) {
+		response := request(t, mcp.MethodListResourceTemplates, nil)
+		require.Nil(t, response.Error)
+
+		data, err := json.Marshal(response.Result)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"resourceTemplates":[{"uriTemplate":"notes://{id}","name":"Note","mimeType":"text/plain"}]}`, string(data))
+	})
+
+	t.Run("ReadTemplatedURI", func(t *testing.T) {
+		response := request(t, mcp.MethodReadResource, map[string]interface{}{"uri": "notes://42"})
+		require.Nil(t, response.Error)
+		result := response.Result.(*mcp.ResourceReadResponse)
+		assert.Equal(t, "note 42", result.Contents[0].Text)
+
+		// Listed resources are still served by their own provider
+		response = request(t, mcp.MethodReadResource, map[string]interface{}{"uri": "static://readme"})
+		require.Nil(t, response.Error)
+
+		response = request(t, mcp.MethodReadResource, map[string]interface{}{"uri": "other://42"})
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeMethodNotFound, response.Error.Code)
+	})
+
+	t.Run("ConditionalRead", func(t *testing.T) {
+		// A matching validator: the cached contents are current
+		response := request(t, mcp.MethodReadResource, map[string]interface{}{"uri": "notes://42", "ifNoneMatch": `"v1"`})
+		require.Nil(t, response.Error)
+		data, err := json.Marshal(response.Result)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"contents":[],"notModified":true}`, string(data))
+
+		// A stale validator: the contents are sent with their new ETag
+		response = request(t, mcp.MethodReadResource, map[string]interface{}{"uri": "notes://42", "ifNoneMatch": `"v0"`})
+		require.Nil(t, response.Error)
+		result := response.Result.(*mcp.ResourceReadResponse)
+		assert.False(t, result.NotModified)
+		require.Len(t, result.Contents, 1)
+		assert.Equal(t, "note 42", result.Contents[0].Text)
+		assert.Equal(t, `"v1"`, result.Contents[0].ETag)
+
+		// Contents without an ETag are always sent
+		response = request(t, mcp.MethodReadResource, map[string]interface{}{"uri": "static://readme", "ifNoneMatch": `"v1"`})
+		require.Nil(t, response.Error)
+		assert.Equal(t, "readme", response.Result.(*mcp.ResourceReadResponse).Contents[0].Text)
+	})
+
+	t.Run("RequiresInitialization", func(t *testing.T) {
+		response, ok := newTestConnection(s).handleMessage(&mcp.Message{
+			JSONRPC: "2.0", ID: 1, Method: mcp.MethodListResourceTemplates,
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidRequest, response.Error.Code)
+	})
+}
+
+// blockingToolProvider has a "wait" tool that returns once release is closed
+// and a "quick" tool that returns immediately
+type blockingToolProvider struct {
+	release chan struct{}
+}
+
+func (p *blockingToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "wait"}, {Name: "quick"}}, nil
+}
+
+func (p *blockingToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	if request.Name == "wait" {
+		select {
+		case <-p.release:
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: request.Name}}}, nil
+}
+
+func TestMCPServer_ConcurrentRequests(t *testing.T) {
+	// connect serves a TCP listener with the given limit and returns an
+	// initialized client
+	connect := func(t *testing.T, limit int, provider mcp.ToolProvider) (net.Conn, *bufio.Scanner) {
+		config := DefaultConfig()
+		config.MaxConcurrentRequests = limit
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(provider))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go s.serveTCP(ctx, listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		scanner := bufio.NewScanner(conn)
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`+"\n"+
+			`{"jsonrpc":"2.0","method":"initialized"}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+		return conn, scanner
+	}
+
+	callTool := func(id int, name string) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q}}`+"\n", id, name)
+	}
+	responseID := func(t *testing.T, scanner *bufio.Scanner) float64 {
+		require.True(t, scanner.Scan(), "no response: %v", scanner.Err())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &response))
+		require.Nil(t, response["error"])
+		return response["id"].(float64)
+	}
+
+	t.Run("FastRequestOvertakesSlowOne", func(t *testing.T) {
+		provider := &blockingToolProvider{release: make(chan struct{})}
+		conn, scanner := connect(t, 4, provider)
+
+		_, err := io.WriteString(conn, callTool(1, "wait")+callTool(2, "quick"))
+		require.NoError(t, err)
+
+		// The quick call is answered while the slow one is still running
+		assert.Equal(t, float64(2), responseID(t, scanner))
+		close(provider.release)
+		assert.Equal(t, float64(1), responseID(t, scanner))
+	})
+
+	t.Run("SerialWhenLimitIsOne", func(t *testing.T) {
+		provider := &blockingToolProvider{release: make(chan struct{})}
+		conn, scanner := connect(t, 1, provider)
+
+		_, err := io.WriteString(conn, callTool(1, "wait")+callTool(2, "quick"))
+		require.NoError(t, err)
+
+		time.Sleep(50 * time.Millisecond)
+		close(provider.release)
+		assert.Equal(t, float64(1), responseID(t, scanner))
+		assert.Equal(t, float64(2), responseID(t, scanner))
+	})
+}
+
+func TestMCPServer_GracefulStop(t *testing.T) {
+	// start serves a TCP listener and returns a client with a "wait" call in flight
+	start := func(t *testing.T, provider *blockingToolProvider) (*MCPServer, *bufio.Scanner) {
+		s := NewMCPServer()
+		require.NoError(t, s.RegisterToolProvider(provider))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go s.serveTCP(context.Background(), listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		scanner := bufio.NewScanner(conn)
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`+"\n"+
+			`{"jsonrpc":"2.0","method":"initialized"}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"wait"}}`+"\n")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt64(&s.activeRequests) == 1
+		}, 2*time.Second, 10*time.Millisecond)
+		return s, scanner
+	}
+
+	t.Run("InFlightRequestFinishes", func(t *testing.T) {
+		provider := &blockingToolProvider{release: make(chan struct{})}
+		s, scanner := start(t, provider)
+
+		stopped := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			stopped <- s.Stop(ctx)
+		}()
+
+		// Stop waits for the call instead of cutting the connection
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 1, s.connectionCount())
+		close(provider.release)
+
+		require.True(t, scanner.Scan(), "no response: %v", scanner.Err())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &response))
+		assert.Equal(t, float64(1), response["id"])
+		assert.Nil(t, response["error"])
+
+		require.NoError(t, <-stopped)
+		assert.False(t, scanner.Scan())
+	})
+
+	t.Run("ForcedCloseAfterTimeout", func(t *testing.T) {
+		provider := &blockingToolProvider{release: make(chan struct{})}
+		defer close(provider.release)
+		s, scanner := start(t, provider)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+		assert.False(t, scanner.Scan())
+	})
+}
+
+func TestMCPServer_StopEndsStatusStreams(t *testing.T) {
+	config := DefaultConfig()
+	config.StatusInterval = time.Hour
+	s := NewServerWithConfig(config)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.serve(ctx, listener, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+"/status/stream", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "id: 1\n", line)
+
+	// The open stream does not hold Stop up until its deadline
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stopCancel()
+	started := time.Now()
+	require.NoError(t, s.Stop(stopCtx))
+	assert.Less(t, time.Since(started), time.Second)
+
+	_, err = io.Copy(io.Discard, reader)
+	assert.NoError(t, err, "stream not ended cleanly")
+}
+
+func TestMCPServer_WriteTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.WriteTimeout = 200 * time.Millisecond
+	s := NewServerWithConfig(config)
+	// A response far larger than the socket buffers, so writing it blocks
+	// until the client reads
+	require.NoError(t, s.RegisterToolProvider(&stubToolProvider{
+		name: "large",
+		response: &mcp.ToolCallResponse{
+			Content: []mcp.Content{{Type: "text", Text: strings.Repeat("x", 16<<20)}},
+		},
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.serveTCP(ctx, listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	scanner := bufio.NewScanner(conn)
+
+	_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`+"\n"+
+		`{"jsonrpc":"2.0","method":"initialized"}`+"\n")
+	require.NoError(t, err)
+	require.True(t, scanner.Scan())
+
+	// The client stops reading: the blocked write times out and the server
+	// closes the connection
+	_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"large"}}`+"\n")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return s.connectionCount() == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+// numberToolProvider reports the Go type and value of its "n" argument
+type numberToolProvider struct{}
+
+func (p *numberToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "number"}}, nil
+}
+
+func (p *numberToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	n := request.Arguments["n"]
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: fmt.Sprintf("%T %v", n, n)}}}, nil
+}
+
+func TestMCPServer_PreciseNumbers(t *testing.T) {
+	call := func(t *testing.T, preciseNumbers bool) (id json.RawMessage, text string) {
+		config := DefaultConfig()
+		config.PreciseNumbers = preciseNumbers
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(&numberToolProvider{}))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.serveTCP(ctx, listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		scanner := bufio.NewScanner(conn)
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`+"\n"+
+			`{"jsonrpc":"2.0","method":"initialized"}`+"\n"+
+			`{"jsonrpc":"2.0","id":9007199254740993,"method":"tools/call","params":{"name":"number","arguments":{"n":9007199254740993}}}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+		require.True(t, scanner.Scan())
+
+		var response struct {
+			ID     json.RawMessage      `json:"id"`
+			Result mcp.ToolCallResponse `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &response))
+		require.Len(t, response.Result.Content, 1)
+		return response.ID, response.Result.Content[0].Text
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		id, text := call(t, true)
+		assert.Equal(t, "9007199254740993", string(id))
+		assert.Equal(t, "json.Number 9007199254740993", text)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		id, text := call(t, false)
+		assert.Equal(t, "9007199254740992", string(id))
+		assert.Equal(t, "float64 9.007199254740992e+15", text)
+	})
+}
+
+// selfTestToolProvider stands in for the database and search tools, keeping
+// documents in memory
+type selfTestToolProvider struct {
+	documents   map[string]string
+	searchError bool
+}
+
+func (p *selfTestToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	var list []mcp.Tool
+	for _, name := range []string{"db_health_check", "db_create_document", "db_get_document", "db_delete_document", "web_search"} {
+		list = append(list, mcp.Tool{Name: name})
+	}
+	return list, nil
+}
+
+func (p *selfTestToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	args := mcp.Args(request.Arguments)
+	key, _ := args.String("id")
+	if collection, _ := args.String("collection"); collection != "" {
+		key = collection + "/" + key
+	}
+
+	text := "ok"
+	switch request.Name {
+	case "db_create_document":
+		content, _ := args.String("content")
+		p.documents[key] = content
+	case "db_get_document":
+		content, ok := p.documents[key]
+		if !ok {
+			return mcp.NewToolError("document not found", nil), nil
+		}
+		text = "Content:\n" + content
+	case "db_delete_document":
+		delete(p.documents, key)
+	case "web_search":
+		if p.searchError {
+			return mcp.NewToolError("Search failed: no results page", nil), nil
+		}
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+}
+
+func TestMCPServer_SelfTest(t *testing.T) {
+	setup := func(t *testing.T) (*MCPServer, *selfTestToolProvider) {
+		server := NewMCPServer()
+		backend := &selfTestToolProvider{documents: make(map[string]string)}
+		require.NoError(t, server.RegisterToolProvider(tools.NewMathToolProvider()))
+		require.NoError(t, server.RegisterToolProvider(backend))
+		return server, backend
+	}
+
+	t.Run("Passes", func(t *testing.T) {
+		server, backend := setup(t)
+
+		report := server.SelfTest(context.Background())
+		assert.True(t, report.Passed(), report.String())
+
+		var names []string
+		for _, step := range report.Steps {
+			names = append(names, step.Name)
+		}
+		assert.Equal(t, []string{
+			"database health check", "create document", "read document", "delete document",
+			"web search", "math tool add", "math tool multiply", "math tool power",
+		}, names)
+		assert.Contains(t, report.String(), "Self-test passed: 8 steps")
+		// The throwaway document is cleaned up
+		assert.Empty(t, backend.documents)
+	})
+
+	t.Run("ReportsFailures", func(t *testing.T) {
+		server, backend := setup(t)
+		backend.searchError = true
+
+		report := server.SelfTest(context.Background())
+		assert.False(t, report.Passed())
+		assert.Contains(t, report.String(), "FAIL web search")
+		assert.Contains(t, report.String(), "Search failed: no results page")
+		assert.Contains(t, report.String(), "PASS math tool power")
+		assert.Contains(t, report.String(), "Self-test failed: 1 of 8 steps failed")
+	})
+
+	t.Run("MissingTools", func(t *testing.T) {
+		server := NewMCPServer()
+		require.NoError(t, server.RegisterToolProvider(tools.NewMathToolProvider()))
+
+		report := server.SelfTest(context.Background())
+		assert.False(t, report.Passed())
+		// Reading and deleting are skipped when the document was never created
+		assert.Len(t, report.Steps, 6)
+		assert.Contains(t, report.String(), "FAIL create document")
+		assert.Contains(t, report.String(), "tool not registered: db_create_document")
+	})
+}
+
+func TestConnection_NotificationBuffer(t *testing.T) {
+	// setup returns a connection whose notification writes block until release
+	// is closed, and the methods written so far
+	setup := func(t *testing.T, buffer int) (*Connection, *dispatcher, net.Conn, chan struct{}, func() []string) {
+		config := DefaultConfig()
+		config.NotificationBuffer = buffer
+		serverSide, clientSide := net.Pipe()
+		t.Cleanup(func() { clientSide.Close() })
+		conn := newConnection(serverSide, NewServerWithConfig(config))
+
+		release := make(chan struct{})
+		writing := make(chan struct{}, 1)
+		var mu sync.Mutex
+		var written []string
+		d := newDispatcher(conn, func(v interface{}) error {
+			select {
+			case writing <- struct{}{}:
+			default:
+			}
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			written = append(written, v.(*mcp.Notification).Method)
+			return nil
+		})
+
+		// The first notification is taken by the writer, which then blocks
+		require.NoError(t, conn.notify(mcp.NewNotification("first", nil), NotificationDroppable))
+		select {
+		case <-writing:
+		case <-time.After(2 * time.Second):
+			t.Fatal("notification writer did not start")
+		}
+
+		return conn, d, clientSide, release, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), written...)
+		}
+	}
+
+	t.Run("DropsOldestDroppable", func(t *testing.T) {
+		conn, d, _, release, written := setup(t, 3)
+
+		require.NoError(t, conn.notify(mcp.NewNotification("progress-1", nil), NotificationDroppable))
+		require.NoError(t, conn.notify(mcp.NewNotification("updated", nil), NotificationCritical))
+		require.NoError(t, conn.notify(mcp.NewNotification("progress-2", nil), NotificationDroppable))
+		// The buffer is full: progress-1 makes room, the critical one stays
+		require.NoError(t, conn.notify(mcp.NewNotification("progress-3", nil), NotificationDroppable))
+
+		close(release)
+		assert.Eventually(t, func() bool { return len(written()) == 4 }, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"first", "updated", "progress-2", "progress-3"}, written())
+
+		d.wait()
+		assert.ErrorIs(t, conn.notify(mcp.NewNotification("late", nil), NotificationDroppable), errConnectionClosed)
+	})
+
+	t.Run("DroppableDroppedWhenAllCritical", func(t *testing.T) {
+		conn, d, _, release, written := setup(t, 1)
+
+		require.NoError(t, conn.notify(mcp.NewNotification("updated", nil), NotificationCritical))
+		require.NoError(t, conn.notify(mcp.NewNotification("progress", nil), NotificationDroppable))
+
+		close(release)
+		assert.Eventually(t, func() bool { return len(written()) == 2 }, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"first", "updated"}, written())
+		d.wait()
+	})
+
+	t.Run("CriticalOverflowClosesConnection", func(t *testing.T) {
+		conn, d, client, release, _ := setup(t, 1)
+
+		require.NoError(t, conn.notify(mcp.NewNotification("updated-1", nil), NotificationCritical))
+		err := conn.notify(mcp.NewNotification("updated-2", nil), NotificationCritical)
+		assert.ErrorIs(t, err, errNotificationBufferFull)
+
+		// The server closed its end of the transport
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+
+		close(release)
+		d.wait()
+	})
+}
+
+func TestConnection_Roots(t *testing.T) {
+	t.Run("RequestedFromClient", func(t *testing.T) {
+		s := NewMCPServer()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.serveTCP(ctx, listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		scanner := bufio.NewScanner(conn)
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"capabilities":{"roots":{"listChanged":true}}}}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+
+		// answerRootsList reads the server's roots/list request and replies with uris
+		answerRootsList := func(t *testing.T, uris ...string) {
+			require.True(t, scanner.Scan(), "no request: %v", scanner.Err())
+			var request mcp.Message
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &request))
+			assert.Equal(t, mcp.MethodListRoots, request.Method)
+			require.NotNil(t, request.ID)
+
+			roots := make([]mcp.Root, len(uris))
+			for i, uri := range uris {
+				roots[i] = mcp.Root{URI: uri}
+			}
+			require.NoError(t, json.NewEncoder(conn).Encode(mcp.NewResponse(request.ID, mcp.ListRootsResponse{Roots: roots})))
+		}
+		rootURIs := func() []string {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			var uris []string
+			for _, connection := range s.connections {
+				for _, root := range connection.Roots() {
+					uris = append(uris, root.URI)
+				}
+			}
+			return uris
+		}
+
+		// The roots are requested once the client is initialized...
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","method":"initialized"}`+"\n")
+		require.NoError(t, err)
+		answerRootsList(t, "file:///home/user/project")
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]string{"file:///home/user/project"}, rootURIs())
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// ...and again when the client reports a change
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`+"\n")
+		require.NoError(t, err)
+		answerRootsList(t, "file:///home/user/a", "file:///home/user/b")
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]string{"file:///home/user/a", "file:///home/user/b"}, rootURIs())
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("ClientWithoutRoots", func(t *testing.T) {
+		c := newTestConnection(NewMCPServer())
+		initializeConnection(t, c, "")
+
+		_, err := c.ListRoots(context.Background())
+		assert.Error(t, err)
+		assert.Nil(t, c.Roots())
+	})
+}
+
+func TestConnection_Call(t *testing.T) {
+	// connect returns the server side of an initialized TCP connection and the
+	// mock client's end
+	connect := func(t *testing.T) (*Connection, net.Conn, *bufio.Scanner) {
+		s := NewMCPServer()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go s.serveTCP(ctx, listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		scanner := bufio.NewScanner(conn)
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		require.Len(t, s.connections, 1)
+		for _, connection := range s.connections {
+			return connection, conn, scanner
+		}
+		return nil, nil, nil
+	}
+	// readRequest reads a request the server sent to the mock client
+	readRequest := func(t *testing.T, scanner *bufio.Scanner) mcp.Message {
+		require.True(t, scanner.Scan(), "no request: %v", scanner.Err())
+		var request mcp.Message
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &request))
+		return request
+	}
+	type result struct {
+		raw json.RawMessage
+		err error
+	}
+	call := func(c *Connection, ctx context.Context, method string, params interface{}) chan result {
+		results := make(chan result, 1)
+		go func() {
+			raw, err := c.Call(ctx, method, params)
+			results <- result{raw, err}
+		}()
+		return results
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		c, conn, scanner := connect(t)
+		results := call(c, context.Background(), "sampling/createMessage", map[string]interface{}{"maxTokens": 10})
+
+		request := readRequest(t, scanner)
+		assert.Equal(t, "sampling/createMessage", request.Method)
+		assert.Equal(t, map[string]interface{}{"maxTokens": float64(10)}, request.Params)
+		require.NoError(t, json.NewEncoder(conn).Encode(mcp.NewResponse(request.ID, map[string]interface{}{"text": "hello"})))
+
+		r := <-results
+		require.NoError(t, r.err)
+		assert.JSONEq(t, `{"text":"hello"}`, string(r.raw))
+	})
+
+	t.Run("ResponsesMatchedByID", func(t *testing.T) {
+		c, conn, scanner := connect(t)
+		first := call(c, context.Background(), "first", nil)
+		firstRequest := readRequest(t, scanner)
+		second := call(c, context.Background(), "second", nil)
+		secondRequest := readRequest(t, scanner)
+		assert.NotEqual(t, firstRequest.ID, secondRequest.ID)
+
+		// Answered out of order
+		encoder := json.NewEncoder(conn)
+		require.NoError(t, encoder.Encode(mcp.NewResponse(secondRequest.ID, "two")))
+		require.NoError(t, encoder.Encode(mcp.NewResponse(firstRequest.ID, "one")))
+
+		r := <-first
+		require.NoError(t, r.err)
+		assert.JSONEq(t, `"one"`, string(r.raw))
+		r = <-second
+		require.NoError(t, r.err)
+		assert.JSONEq(t, `"two"`, string(r.raw))
+	})
+
+	t.Run("ClientError", func(t *testing.T) {
+		c, conn, scanner := connect(t)
+		results := call(c, context.Background(), "elicitation/create", nil)
+
+		request := readRequest(t, scanner)
+		require.NoError(t, json.NewEncoder(conn).Encode(mcp.NewErrorResponse(request.ID, mcp.ErrorCodeMethodNotFound, "not supported", nil)))
+
+		r := <-results
+		var clientErr *ClientError
+		require.ErrorAs(t, r.err, &clientErr)
+		assert.Equal(t, mcp.ErrorCodeMethodNotFound, clientErr.Code)
+		assert.Equal(t, "elicitation/create", clientErr.Method)
+	})
+
+	t.Run("ContextDone", func(t *testing.T) {
+		c, _, scanner := connect(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		results := call(c, ctx, "roots/list", nil)
+		readRequest(t, scanner)
+
+		r := <-results
+		assert.ErrorIs(t, r.err, context.DeadlineExceeded)
+	})
+
+	t.Run("ConnectionClosed", func(t *testing.T) {
+		c, conn, scanner := connect(t)
+		results := call(c, context.Background(), "roots/list", nil)
+		readRequest(t, scanner)
+		conn.Close()
+
+		select {
+		case r := <-results:
+			assert.ErrorIs(t, r.err, errConnectionClosed)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Call did not return after the connection closed")
+		}
+	})
+}
+
+// samplingToolProvider has an "ask" tool that returns what the client's model
+// answers to its question
+type samplingToolProvider struct{}
+
+func (p *samplingToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "ask"}}, nil
+}
+
+func (p *samplingToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	sampler, ok := mcp.SamplerFromContext(ctx)
+	if !ok {
+		return mcp.NewToolError("no sampler", nil), nil
+	}
+	result, err := sampler.CreateMessage(ctx, mcp.CreateMessageRequest{
+		Messages:  []mcp.SamplingMessage{{Role: "user", Content: mcp.Content{Type: "text", Text: "What is MCP?"}}},
+		MaxTokens: 50,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{result.Content}}, nil
+}
+
+func TestConnection_Sampling(t *testing.T) {
+	// callAsk runs the ask tool for a client declaring capabilities, answering
+	// the server's sampling request when one comes, and returns the tool's text
+	callAsk := func(t *testing.T, config Config, capabilities string) string {
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(&samplingToolProvider{}))
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.serveTCP(ctx, listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		scanner := bufio.NewScanner(conn)
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"capabilities":`+capabilities+`}}`+"\n"+
+			`{"jsonrpc":"2.0","method":"initialized"}`+"\n"+
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"ask"}}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+
+		for scanner.Scan() {
+			var message mcp.Message
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+			if message.Method == mcp.MethodCreateMessage {
+				var request mcp.CreateMessageRequest
+				data, _ := json.Marshal(message.Params)
+				require.NoError(t, json.Unmarshal(data, &request))
+				assert.Equal(t, 50, request.MaxTokens)
+				require.NoError(t, json.NewEncoder(conn).Encode(mcp.NewResponse(message.ID, mcp.CreateMessageResult{
+					Role:    "assistant",
+					Content: mcp.Content{Type: "text", Text: "A protocol for tools."},
+					Model:   "mock-model",
+				})))
+				continue
+			}
+
+			var response struct {
+				Result mcp.ToolCallResponse `json:"result"`
+			}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &response))
+			require.NotEmpty(t, response.Result.Content)
+			return response.Result.Content[0].Text
+		}
+		t.Fatalf("no tool response: %v", scanner.Err())
+		return ""
+	}
+
+	t.Run("AnsweredByClient", func(t *testing.T) {
+		assert.Equal(t, "A protocol for tools.", callAsk(t, DefaultConfig(), `{"sampling":{}}`))
+	})
+
+	t.Run("ClientWithoutSampling", func(t *testing.T) {
+		assert.Equal(t, "no sampler", callAsk(t, DefaultConfig(), `{}`))
+	})
+
+	t.Run("NotOfferedWhenSerial", func(t *testing.T) {
+		config := DefaultConfig()
+		config.MaxConcurrentRequests = 1
+		assert.Equal(t, "no sampler", callAsk(t, config, `{"sampling":{}}`))
+	})
+
+	t.Run("AnsweredWhileEverySlotIsBusy", func(t *testing.T) {
+		config := DefaultConfig()
+		config.MaxConcurrentRequests = 2
+		s := NewServerWithConfig(config)
+		require.NoError(t, s.RegisterToolProvider(&samplingToolProvider{}))
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.serveTCP(ctx, listener)
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		scanner := bufio.NewScanner(conn)
+		ask := func(id int) string {
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"ask"}}`+"\n", id)
+		}
+		read := func() mcp.Message {
+			require.True(t, scanner.Scan(), "nothing read: %v", scanner.Err())
+			var message mcp.Message
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+			return message
+		}
+		answer := func(message mcp.Message) {
+			require.Equal(t, mcp.MethodCreateMessage, message.Method)
+			require.NoError(t, json.NewEncoder(conn).Encode(mcp.NewResponse(message.ID, mcp.CreateMessageResult{
+				Role:    "assistant",
+				Content: mcp.Content{Type: "text", Text: "A protocol for tools."},
+				Model:   "mock-model",
+			})))
+		}
+
+		_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"capabilities":{"sampling":{}}}}`+"\n"+
+			`{"jsonrpc":"2.0","method":"initialized"}`+"\n"+ask(1)+ask(2))
+		require.NoError(t, err)
+		read()
+
+		// Both slots wait for the client's model when another call comes in
+		pending := []mcp.Message{read(), read()}
+		_, err = io.WriteString(conn, ask(3))
+		require.NoError(t, err)
+		for _, request := range pending {
+			answer(request)
+		}
+
+		answered := map[float64]bool{}
+		for len(answered) < 3 {
+			message := read()
+			if message.Method == mcp.MethodCreateMessage {
+				answer(message)
+				continue
+			}
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &response))
+			require.Nil(t, response["error"])
+			answered[response["id"].(float64)] = true
+		}
+	})
+}
+
+// healthCheckFunc adapts a function to HealthChecker
+type healthCheckFunc func(ctx context.Context) error
+
+func (f healthCheckFunc) HealthCheck(ctx context.Context) error { return f(ctx) }
+
+func TestMCPServer_StatusStream(t *testing.T) {
+	config := DefaultConfig()
+	config.StatusInterval = 20 * time.Millisecond
+	s := NewServerWithConfig(config)
+	s.RegisterToolProvider(tools.NewMathToolProvider())
+	s.AddHealthCheck("database", healthCheckFunc(func(ctx context.Context) error { return nil }))
+	s.AddHealthCheck("search", healthCheckFunc(func(ctx context.Context) error { return errors.New("search endpoint unreachable") }))
+
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	// One connected client that has made one tool call
+	client, _, err := dialTestServer(t, ts)
+	require.NoError(t, err)
+	defer client.Close()
+	for _, message := range []mcp.Message{
+		{JSONRPC: "2.0", ID: 1, Method: mcp.MethodInitialize, Params: map[string]interface{}{"protocolVersion": mcp.ProtocolVersion}},
+		{JSONRPC: "2.0", Method: mcp.MethodInitialized},
+		{JSONRPC: "2.0", ID: 2, Method: mcp.MethodCallTool, Params: map[string]interface{}{"name": "add", "arguments": map[string]interface{}{"a": 1, "b": 2}}},
+	} {
+		require.NoError(t, client.WriteJSON(message))
+		if message.ID != nil {
+			var response mcp.Response
+			require.NoError(t, client.ReadJSON(&response))
+			require.Nil(t, response.Error)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/status/stream", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// readEvent reads the fields of the next event, up to its blank line
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() map[string]string {
+		fields := map[string]string{}
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return fields
+			}
+			name, value, ok := strings.Cut(line, ": ")
+			require.True(t, ok, "malformed line %q", line)
+			fields[name] = value
+		}
+	}
+
+	for _, id := range []string{"1", "2"} {
+		event := readEvent()
+		assert.Equal(t, id, event["id"])
+		assert.Equal(t, "status", event["event"])
+
+		var status Status
+		require.NoError(t, json.Unmarshal([]byte(event["data"]), &status))
+		assert.WithinDuration(t, time.Now(), status.Timestamp, 5*time.Second)
+		assert.Equal(t, 1, status.Connections)
+		assert.Equal(t, int64(1), status.ToolCalls)
+		assert.Equal(t, map[string]DependencyStatus{
+			"database": {Healthy: true},
+			"search":   {Error: "search endpoint unreachable"},
+		}, status.Dependencies)
+	}
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status/stream", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+// streamingToolProvider streams its "text" argument in chunks of "chunk_size" bytes
+type streamingToolProvider struct{}
+
+func (p *streamingToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "stream", Annotations: mcp.ReadOnlyAnnotations(false)}}, nil
+}
+
+func (p *streamingToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	text, _ := mcp.Args(request.Arguments).String("text")
+	streamer, ok := mcp.ContentStreamerFromContext(ctx)
+	if !ok {
+		return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+	}
+	chunkSize, _ := mcp.Args(request.Arguments).Int("chunk_size")
+	chunks, err := mcp.StreamText(ctx, streamer, "test://text", text, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: fmt.Sprintf("%d bytes in %d chunks", len(text), chunks)}}}, nil
+}
+
+func TestMCPServer_StreamContent(t *testing.T) {
+	config := DefaultConfig()
+	// Fewer buffered notifications than chunks: streaming waits for each write
+	config.NotificationBuffer = 2
+	config.ToolCacheTTL = time.Minute
+	s := NewServerWithConfig(config)
+	require.NoError(t, s.RegisterToolProvider(&streamingToolProvider{}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.serveTCP(ctx, listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	scanner := bufio.NewScanner(conn)
+
+	_, err = io.WriteString(conn, `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`+"\n"+
+		`{"jsonrpc":"2.0","method":"initialized"}`+"\n")
+	require.NoError(t, err)
+	require.True(t, scanner.Scan())
+
+	text := strings.Repeat("Streamed content, ", 100)
+
+	t.Run("Chunks", func(t *testing.T) {
+		// The same call twice: the second is not served from the tool cache
+		for id := 1; id <= 2; id++ {
+			_, err := fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"stream","arguments":{"text":%q,"chunk_size":256},"_meta":{"progressToken":"tok-1"}}}`+"\n", id, text)
+			require.NoError(t, err)
+
+			var received strings.Builder
+			var chunks []mcp.ContentChunk
+			for {
+				require.True(t, scanner.Scan(), "no message: %v", scanner.Err())
+				var message struct {
+					ID     *int             `json:"id"`
+					Method string           `json:"method"`
+					Params mcp.ContentChunk `json:"params"`
+					Result mcp.ToolCallResponse
+				}
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+				if message.ID != nil {
+					// Every chunk arrives before the response
+					assert.Equal(t, id, *message.ID)
+					assert.Equal(t, fmt.Sprintf("%d bytes in %d chunks", len(text), len(chunks)), message.Result.Content[0].Text)
+					break
+				}
+				assert.Equal(t, mcp.MethodNotificationToolContent, message.Method)
+				chunk := message.Params
+				assert.Equal(t, "tok-1", chunk.ProgressToken)
+				assert.Equal(t, len(chunks), chunk.Index)
+				assert.Equal(t, received.Len(), chunk.Offset)
+				assert.LessOrEqual(t, len(chunk.Text), 256)
+				received.WriteString(chunk.Text)
+				chunks = append(chunks, chunk)
+			}
+
+			assert.Greater(t, len(chunks), 1)
+			assert.Equal(t, text, received.String())
+			last := chunks[len(chunks)-1]
+			assert.True(t, last.Final)
+			assert.Equal(t, len(text), last.Total)
+		}
+	})
+
+	t.Run("WithoutProgressToken", func(t *testing.T) {
+		_, err := fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"stream","arguments":{"text":"inline"}}}`+"\n")
+		require.NoError(t, err)
+		require.True(t, scanner.Scan())
+		assert.Contains(t, scanner.Text(), `"id":3`)
+		assert.Contains(t, scanner.Text(), `"text":"inline"`)
+	})
+}
+
+// profileToolProvider exposes read-only and destructive tools for the tool profile tests
+type profileToolProvider struct{}
+
+func (p *profileToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{
+		{Name: "db_find", Annotations: mcp.ReadOnlyAnnotations(false)},
+		{Name: "db_delete", Annotations: &mcp.ToolAnnotations{DestructiveHint: true}},
+		{Name: "web_search", Annotations: mcp.ReadOnlyAnnotations(true)},
+		{Name: "db_drop", Annotations: &mcp.ToolAnnotations{DestructiveHint: true}, RequiresAllowlist: true},
+	}, nil
+}
+
+func (p *profileToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: request.Name}}}, nil
+}
+
+func TestMCPServer_ToolProfiles(t *testing.T) {
+	newServer := func(defaultProfile string) *MCPServer {
+		config := DefaultConfig()
+		config.ToolsPageSize = 0
+		config.ToolProfiles = map[string][]string{"db-admin": {"db_*", "batch"}, "db-owner": {"db_*", "db_drop"}}
+		config.DefaultToolProfile = defaultProfile
+		s := NewServerWithConfig(config)
+		s.RegisterToolProvider(&profileToolProvider{})
+		return s
+	}
+	request := func(t *testing.T, c *Connection, method string, params interface{}) *mcp.Response {
+		response, ok := c.handleMessage(&mcp.Message{JSONRPC: "2.0", ID: 2, Method: method, Params: params}).(*mcp.Response)
+		require.True(t, ok)
+		return response
+	}
+	// connect initializes a connection asking for profile, if any
+	connect := func(t *testing.T, s *MCPServer, profile string) (*Connection, *mcp.Response) {
+		c := newTestConnection(s)
+		params := map[string]interface{}{"protocolVersion": mcp.ProtocolVersion, "capabilities": map[string]interface{}{}}
+		if profile != "" {
+			params["meta"] = map[string]interface{}{"toolProfile": profile}
+		}
+		response := request(t, c, mcp.MethodInitialize, params)
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		return c, response
+	}
+	listed := func(t *testing.T, c *Connection) []string {
+		response := request(t, c, mcp.MethodListTools, map[string]interface{}{})
+		require.Nil(t, response.Error)
+		var names []string
+		for _, tool := range response.Result.(mcp.ListToolsResponse).Tools {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+	callable := func(t *testing.T, c *Connection, name string) bool {
+		response := request(t, c, mcp.MethodCallTool, map[string]interface{}{"name": name})
+		if response.Error != nil {
+			assert.Equal(t, mcp.ErrorCodeMethodNotFound, response.Error.Code)
+			assert.Equal(t, "Tool not found: "+name, response.Error.Message)
+			return false
+		}
+		return true
+	}
+
+	t.Run("NoProfileExposesEveryTool", func(t *testing.T) {
+		c, response := connect(t, newServer(""), "")
+		require.Nil(t, response.Error)
+		assert.NotContains(t, response.Result.(mcp.InitializeResponse).Meta, "toolProfile")
+		assert.Subset(t, listed(t, c), []string{"db_find", "db_delete", "web_search", "batch"})
+		assert.True(t, callable(t, c, "db_delete"))
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		c, response := connect(t, newServer(""), ToolProfileReadOnly)
+		require.Nil(t, response.Error)
+		assert.Equal(t, ToolProfileReadOnly, response.Result.(mcp.InitializeResponse).Meta["toolProfile"])
+
+		names := listed(t, c)
+		assert.Subset(t, names, []string{"db_find", "web_search", "describe_tool"})
+		assert.NotContains(t, names, "db_delete")
+		assert.NotContains(t, names, "batch")
+		assert.True(t, callable(t, c, "db_find"))
+		assert.False(t, callable(t, c, "db_delete"))
+		assert.False(t, callable(t, c, "batch"))
+	})
+
+	t.Run("ConfiguredProfile", func(t *testing.T) {
+		c, response := connect(t, newServer(""), "db-admin")
+		require.Nil(t, response.Error)
+		assert.ElementsMatch(t, []string{"db_find", "db_delete", "batch"}, listed(t, c))
+		assert.True(t, callable(t, c, "db_delete"))
+		assert.False(t, callable(t, c, "web_search"))
+
+		// Calls made through batch are held to the same profile
+		response = request(t, c, mcp.MethodCallTool, map[string]interface{}{
+			"name": "batch",
+			"arguments": map[string]interface{}{
+				"calls": []interface{}{map[string]interface{}{"name": "web_search"}},
+			},
+		})
+		require.Nil(t, response.Error)
+		result := response.Result.(*mcp.ToolCallResponse)
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content[0].Text, "Tool not found: web_search")
+	})
+
+	t.Run("AllowlistedTool", func(t *testing.T) {
+		// Hidden without a profile, from "full" and from patterns matching it
+		for _, profile := range []string{"", ToolProfileFull, "db-admin"} {
+			c, _ := connect(t, newServer(""), profile)
+			assert.NotContains(t, listed(t, c), "db_drop", profile)
+			assert.False(t, callable(t, c, "db_drop"), profile)
+		}
+
+		c, _ := connect(t, newServer(""), "db-owner")
+		assert.ElementsMatch(t, []string{"db_find", "db_delete", "db_drop"}, listed(t, c))
+		assert.True(t, callable(t, c, "db_drop"))
+	})
+
+	t.Run("DefaultProfile", func(t *testing.T) {
+		s := newServer(ToolProfileReadOnly)
+		c, response := connect(t, s, "")
+		require.Nil(t, response.Error)
+		assert.Equal(t, ToolProfileReadOnly, response.Result.(mcp.InitializeResponse).Meta["toolProfile"])
+		assert.NotContains(t, listed(t, c), "db_delete")
+
+		// A client can still ask for another profile
+		c, _ = connect(t, s, ToolProfileFull)
+		assert.Contains(t, listed(t, c), "db_delete")
+		assert.True(t, callable(t, c, "db_delete"))
+	})
+
+	t.Run("UnknownProfile", func(t *testing.T) {
+		_, response := connect(t, newServer(""), "superuser")
+		require.NotNil(t, response.Error)
+		assert.Equal(t, mcp.ErrorCodeInvalidParams, response.Error.Code)
+		assert.Contains(t, response.Error.Message, "superuser")
+
+		// A misconfigured default hides every tool instead of exposing them all
+		c, _ := connect(t, newServer("missing"), "")
+		assert.Empty(t, listed(t, c))
+		assert.False(t, callable(t, c, "db_find"))
+	})
+
+	t.Run("HTTPToolsUnaffected", func(t *testing.T) {
+		tools, err := newServer(ToolProfileReadOnly).listTools(context.Background())
+		require.NoError(t, err)
+		var names []string
+		for _, tool := range tools {
+			names = append(names, tool.Name)
+		}
+		assert.Contains(t, names, "db_delete")
+	})
+}
+
+func TestParseToolProfiles(t *testing.T) {
+	profiles, err := ParseToolProfiles(" db-admin=db_*| describe_tool ,search=web_search,,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"db-admin": {"db_*", "describe_tool"},
+		"search":   {"web_search"},
+	}, profiles)
+
+	profiles, err = ParseToolProfiles("")
+	require.NoError(t, err)
+	assert.Empty(t, profiles)
+
+	for _, invalid := range []string{"db_*", "=db_*", "empty=", "empty=|", "bad=db_["} {
+		_, err := ParseToolProfiles(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// accessToolProvider has a read-only "read" tool that fails for collections
+// the calling client may not access, reading "documents" when given none
+type accessToolProvider struct {
+	calls int
+}
+
+func (p *accessToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	return []mcp.Tool{{Name: "read", Annotations: mcp.ReadOnlyAnnotations(false), Cacheable: true}}, nil
+}
+
+func (p *accessToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	p.calls++
+	collection, _ := request.Arguments["collection"].(string)
+	if collection == "" {
+		collection = "documents"
+	}
+	if !mcp.CollectionAllowed(ctx, collection) {
+		return mcp.NewToolError("access denied", nil), nil
+	}
+	return &mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: "read " + collection}}}, nil
+}
+
+func TestMCPServer_CollectionAccess(t *testing.T) {
+	access := map[string][]string{
+		"key-a": {"tenant_a", "shared_*", "documents"},
+		"key-b": {"tenant_b"},
+	}
+	newServer := func(access map[string][]string) (*MCPServer, *accessToolProvider) {
+		config := DefaultConfig()
+		config.CollectionAccess = access
+		config.ToolCacheTTL = time.Minute
+		s := NewServerWithConfig(config)
+		provider := &accessToolProvider{}
+		require.NoError(t, s.RegisterToolProvider(provider))
+		return s, provider
+	}
+	// connect initializes a connection granted the collections of key, as the
+	// WebSocket handshake would
+	connect := func(t *testing.T, s *MCPServer, key string) *Connection {
+		c := newTestConnection(s)
+		c.collections, _ = s.config.collectionPatterns(key)
+		initializeConnection(t, c, "")
+		c.handleMessage(&mcp.Message{JSONRPC: "2.0", Method: mcp.MethodInitialized})
+		return c
+	}
+	read := func(t *testing.T, c *Connection, collection string) *mcp.ToolCallResponse {
+		arguments := map[string]interface{}{}
+		if collection != "" {
+			arguments["collection"] = collection
+		}
+		response, ok := c.handleMessage(&mcp.Message{
+			JSONRPC: "2.0",
+			ID:      2,
+			Method:  mcp.MethodCallTool,
+			Params:  map[string]interface{}{"name": "read", "arguments": arguments},
+		}).(*mcp.Response)
+		require.True(t, ok)
+		require.Nil(t, response.Error)
+		result, ok := response.Result.(*mcp.ToolCallResponse)
+		require.True(t, ok)
+		return result
+	}
+
+	t.Run("HandshakeRequiresMappedKey", func(t *testing.T) {
+		s, _ := newServer(access)
+		ts := newTestHTTPServer(s)
+		defer ts.Close()
+		wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/mcp"
+
+		for _, header := range []http.Header{nil, {"X-API-Key": {"unknown"}}} {
+			_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+			require.Error(t, err)
+			require.NotNil(t, resp)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+
+		for _, header := range []http.Header{{"Authorization": {"Bearer key-a"}}, {"X-API-Key": {"key-b"}}} {
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+			require.NoError(t, err)
+			conn.Close()
+		}
+	})
+
+	t.Run("AllowedAndDeniedPerKey", func(t *testing.T) {
+		s, _ := newServer(access)
+
+		a := connect(t, s, "key-a")
+		assert.False(t, read(t, a, "tenant_a").IsError)
+		assert.False(t, read(t, a, "shared_docs").IsError)
+		assert.True(t, read(t, a, "tenant_b").IsError)
+
+		b := connect(t, s, "key-b")
+		assert.False(t, read(t, b, "tenant_b").IsError)
+		assert.True(t, read(t, b, "tenant_a").IsError)
+		assert.True(t, read(t, b, "shared_docs").IsError)
+	})
+
+	t.Run("CachedResultNotSharedAcrossTenants", func(t *testing.T) {
+		s, provider := newServer(access)
+
+		b := connect(t, s, "key-b")
+		assert.Equal(t, "read tenant_b", read(t, b, "tenant_b").Content[0].Text)
+		assert.Equal(t, "read tenant_b", read(t, b, "tenant_b").Content[0].Text)
+		assert.Equal(t, 1, provider.calls)
+
+		a := connect(t, s, "key-a")
+		assert.True(t, read(t, a, "tenant_b").IsError)
+		assert.Equal(t, 2, provider.calls)
+	})
+
+	t.Run("DefaultCollectionNotSharedAcrossTenants", func(t *testing.T) {
+		s, provider := newServer(access)
+		a := connect(t, s, "key-a")
+		b := connect(t, s, "key-b")
+
+		// The call names no collection, so only the grant tells the results apart
+		assert.True(t, read(t, b, "").IsError)
+		assert.Equal(t, "read documents", read(t, a, "").Content[0].Text)
+		assert.Equal(t, "read documents", read(t, a, "").Content[0].Text)
+		assert.Equal(t, 2, provider.calls)
+
+		assert.True(t, read(t, b, "").IsError)
+		assert.Equal(t, 3, provider.calls)
+
+		// Clients granted the same collections share results
+		assert.Equal(t, "read documents", read(t, connect(t, s, "key-a"), "").Content[0].Text)
+		assert.Equal(t, 3, provider.calls)
+	})
+
+	t.Run("NoMappingAllowsEveryCollection", func(t *testing.T) {
+		s, _ := newServer(nil)
+		c := connect(t, s, "")
+		assert.False(t, read(t, c, "tenant_a").IsError)
+		assert.False(t, read(t, c, "tenant_b").IsError)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		s, _ := newServer(access)
+		s.SetCollectionExporter(&memoryExporter{collections: map[string][]map[string]interface{}{
+			"tenant_a": {{"_id": "1"}},
+			"tenant_b": {{"_id": "2"}},
+		}})
+		ts := httptest.NewServer(s.routes())
+		defer ts.Close()
+
+		get := func(path, key string) int {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("X-API-Key", key)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		assert.Equal(t, http.StatusOK, get("/export/tenant_a", "key-a"))
+		assert.Equal(t, http.StatusForbidden, get("/export/tenant_b", "key-a"))
+		assert.Equal(t, http.StatusOK, get("/export/tenant_b", "key-b"))
+		assert.Equal(t, http.StatusUnauthorized, get("/export/tenant_a", "unknown"))
+	})
+}
+
+func TestParseCollectionAccess(t *testing.T) {
+	access, err := ParseCollectionAccess([]byte(`{"key-a": ["tenant_a", "shared_*"], "key-b": ["tenant_b"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"key-a": {"tenant_a", "shared_*"},
+		"key-b": {"tenant_b"},
+	}, access)
+
+	for _, invalid := range []string{`["tenant_a"]`, `{"": ["tenant_a"]}`, `{"key": ["tenant_["]}`, `{"key": [""]}`} {
+		_, err := ParseCollectionAccess([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// sessionState is the per-client state preserved between WebSocket connections
+type sessionState struct {
+	initialized   bool
+	subscriptions map[string]bool
+	expiresAt     time.Time
+}
+
+// sessionStore keeps the state of disconnected clients so they can resume
+// within the idle TTL instead of re-initializing from scratch
+type sessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*sessionState
+}
+
+// newSessionStore creates a session store; a zero ttl disables resumption
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*sessionState),
+	}
+}
+
+// enabled reports whether sessions can be resumed
+func (st *sessionStore) enabled() bool {
+	return st.ttl > 0
+}
+
+// save stores the state of a disconnecting client under its session id
+func (st *sessionStore) save(id string, initialized bool, subscriptions map[string]bool) {
+	if !st.enabled() || id == "" {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.purgeExpiredLocked()
+
+	subs := make(map[string]bool, len(subscriptions))
+	for uri := range subscriptions {
+		subs[uri] = true
+	}
+	st.sessions[id] = &sessionState{
+		initialized:   initialized,
+		subscriptions: subs,
+		expiresAt:     time.Now().Add(st.ttl),
+	}
+}
+
+// resume removes and returns the saved state for id if it has not expired
+func (st *sessionStore) resume(id string) (*sessionState, bool) {
+	if !st.enabled() || id == "" {
+		return nil, false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.purgeExpiredLocked()
+
+	state, ok := st.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	delete(st.sessions, id)
+	return state, true
+}
+
+// purgeExpiredLocked drops sessions whose idle TTL has elapsed; st.mu must be held
+func (st *sessionStore) purgeExpiredLocked() {
+	now := time.Now()
+	for id, state := range st.sessions {
+		if now.After(state.expiresAt) {
+			delete(st.sessions, id)
+		}
+	}
+}
+
+// newSessionID returns a random, URL-safe session identifier
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is not recoverable in a meaningful way
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
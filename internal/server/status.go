@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStatusInterval is used when Config.StatusInterval is not positive
+const defaultStatusInterval = 5 * time.Second
+
+// HealthChecker is a dependency of the server, such as the database or the
+// web searcher, whose health is reported on /status/stream
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Status is the server state sent by /status/stream
+type Status struct {
+	Timestamp    time.Time                   `json:"timestamp"`
+	Connections  int                         `json:"connections"`
+	ToolCalls    int64                       `json:"tool_calls"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// DependencyStatus is the outcome of a dependency's health check
+type DependencyStatus struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AddHealthCheck registers a dependency whose health is reported under name
+func (s *MCPServer) AddHealthCheck(name string, checker HealthChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.healthChecks == nil {
+		s.healthChecks = make(map[string]HealthChecker)
+	}
+	s.healthChecks[name] = checker
+}
+
+// status returns the current state of the server, running the dependency
+// health checks concurrently
+func (s *MCPServer) status(ctx context.Context) Status {
+	s.mu.RLock()
+	checks := make(map[string]HealthChecker, len(s.healthChecks))
+	for name, checker := range s.healthChecks {
+		checks[name] = checker
+	}
+	s.mu.RUnlock()
+
+	status := Status{
+		Timestamp:    time.Now().UTC(),
+		Connections:  s.connectionCount(),
+		ToolCalls:    atomic.LoadInt64(&s.toolCalls),
+		Dependencies: make(map[string]DependencyStatus, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+			dependency := DependencyStatus{Healthy: true}
+			if err := checker.HealthCheck(ctx); err != nil {
+				dependency = DependencyStatus{Error: err.Error()}
+			}
+			mu.Lock()
+			status.Dependencies[name] = dependency
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+	return status
+}
+
+// handleStatusStream streams the server status as Server-Sent Events: a
+// "status" event right away, then one every StatusInterval until the client
+// disconnects or the server shuts down. Health checks that outlast the
+// interval are reported as failed.
+func (s *MCPServer) handleStatusStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	interval := s.config.StatusInterval
+	if interval <= 0 {
+		interval = defaultStatusInterval
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep reverse proxies from buffering events
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for id := 1; ; id++ {
+		checkCtx, cancel := context.WithTimeout(r.Context(), interval)
+		status := s.status(checkCtx)
+		cancel()
+
+		data, err := json.Marshal(status)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", id, data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// contentStreamer sends the content a tool streams as notifications tied to
+// the progress token of its call
+type contentStreamer struct {
+	conn  *Connection
+	token interface{}
+}
+
+// StreamContent sends one chunk and waits until it is written, so that chunks
+// never pile up in the connection's outbox and all of them reach the client
+// before the tool's response
+func (s contentStreamer) StreamContent(ctx context.Context, chunk mcp.ContentChunk) error {
+	chunk.ProgressToken = s.token
+	written := make(chan error, 1)
+	err := s.conn.send(queuedMessage{
+		message:  mcp.NewNotification(mcp.MethodNotificationToolContent, chunk),
+		method:   mcp.MethodNotificationToolContent,
+		priority: NotificationCritical,
+		written:  written,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-written:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withContentStreamer lets the tool of a call that carries a progress token
+// stream content to the client
+func (c *Connection) withContentStreamer(ctx context.Context, request mcp.ToolCallRequest) context.Context {
+	if request.Meta == nil || request.Meta.ProgressToken == nil {
+		return ctx
+	}
+	return mcp.ContextWithContentStreamer(ctx, contentStreamer{conn: c, token: request.Meta.ProgressToken})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxTCPMessageSize bounds a single newline-delimited message on the TCP transport
+const maxTCPMessageSize = 4 << 20
+
+// StartTCP serves newline-delimited JSON-RPC over plain TCP on addr until ctx
+// is done or the server is stopped. TCP clients share the WebSocket clients'
+// connection limit, idle timeout and sessions.
+func (s *MCPServer) StartTCP(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	log.Printf("Starting MCP TCP transport on %s", addr)
+	return s.serveTCP(ctx, listener)
+}
+
+// serveTCP accepts TCP clients from listener until ctx is done or the listener is closed
+func (s *MCPServer) serveTCP(ctx context.Context, listener net.Listener) error {
+	s.mu.Lock()
+	s.tcpListener = listener
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept TCP connection: %w", err)
+		}
+		go s.handleTCP(conn)
+	}
+}
+
+// handleTCP runs the read loop of a TCP client: one JSON-RPC message per line
+// in, one response per line out
+func (s *MCPServer) handleTCP(conn net.Conn) {
+	encoder := json.NewEncoder(conn)
+
+	if !s.acquireConnectionSlot() {
+		log.Printf("Rejecting TCP connection from %s: connection limit of %d reached", conn.RemoteAddr(), s.config.MaxConnections)
+		encoder.Encode(mcp.NewErrorResponse(nil, mcp.ErrorCodeInternalError, "too many connections", nil))
+		conn.Close()
+		return
+	}
+	defer s.releaseConnectionSlot()
+	log.Printf("TCP client connected: %s", conn.RemoteAddr())
+
+	connection := newConnection(conn, s)
+
+	s.mu.Lock()
+	s.connections[conn] = connection
+	s.mu.Unlock()
+
+	dispatcher := newDispatcher(connection, withWriteTimeout(conn, s.config.WriteTimeout, encoder.Encode))
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.connections, conn)
+		s.mu.Unlock()
+		conn.Close()
+		// Requests still running finish before the session is saved
+		dispatcher.wait()
+		connection.detach()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTCPMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		connection.touch()
+
+		if err := dispatcher.dispatch(line); err != nil {
+			log.Printf("Failed to write TCP response: %v", err)
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("TCP connection error from %s: %v", conn.RemoteAddr(), err)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// errToolTimeout is the cause of a tool context cancelled by its own timeout,
+// telling it apart from a deadline inherited from the caller
+var errToolTimeout = errors.New("tool timeout")
+
+// ToolTimeout returns how long the named tool may run: its entry in
+// ToolTimeouts, or DefaultToolTimeout. 0 means no limit.
+func (c Config) ToolTimeout(name string) time.Duration {
+	if timeout, ok := c.ToolTimeouts[name]; ok {
+		return timeout
+	}
+	return c.DefaultToolTimeout
+}
+
+// ParseToolTimeouts parses per-tool timeouts written as a comma-separated list
+// of name=duration pairs, e.g. "web_search=30s,db_count_documents=2s"
+func ParseToolTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawTimeout, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid tool timeout %q: expected name=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(rawTimeout))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for tool %s: %w", name, err)
+		}
+		if timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout for tool %s: must not be negative", name)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// withToolTimeout bounds each call by the tool's timeout. When it expires the
+// tool's context is cancelled, and a tool that then fails has the failure
+// reported as the timeout. A tool that finishes successfully anyway keeps its
+// result.
+func (s *MCPServer) withToolTimeout(next ToolHandler) ToolHandler {
+	return func(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+		timeout := s.config.ToolTimeout(request.Name)
+		if timeout <= 0 {
+			return next(ctx, request)
+		}
+
+		ctx, cancel := context.WithTimeoutCause(ctx, timeout, errToolTimeout)
+		defer cancel()
+
+		response, err := next(ctx, request)
+		failed := err != nil || response == nil || response.IsError
+		if failed && errors.Is(context.Cause(ctx), errToolTimeout) {
+			return mcp.NewToolError(fmt.Sprintf("Tool %s timed out after %s", request.Name, timeout), nil), nil
+		}
+		return response, err
+	}
+}
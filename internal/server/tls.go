@@ -0,0 +1,29 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// LoadTLSConfig loads the certificate and private key the server presents to
+// clients. It returns nil when both paths are empty, meaning plain HTTP, and an
+// error when only one is set or the pair cannot be loaded.
+func LoadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("TLS requires both a certificate and a key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate %s with key %s: %w", certFile, keyFile, err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// Tool name conflict policies for Config.ToolNameConflicts
+const (
+	ToolConflictStrict = "strict" // reject a provider whose tool names are already registered
+	ToolConflictPrefix = "prefix" // expose colliding tools as "<provider>_<tool>"
+)
+
+// providerName returns the name used to namespace a provider's tools. Providers
+// that do not implement mcp.NamedToolProvider are named after their position.
+func providerName(provider mcp.ToolProvider, index int) string {
+	if named, ok := provider.(mcp.NamedToolProvider); ok && named.Name() != "" {
+		return named.Name()
+	}
+	if index < 0 {
+		return "tool provider"
+	}
+	return fmt.Sprintf("provider%d", index)
+}
+
+// registeredToolNames returns the names exposed by the registered providers.
+// The caller must hold s.mu.
+func (s *MCPServer) registeredToolNames(ctx context.Context) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, provider := range s.toolProviders {
+		tools, err := provider.ListTools(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list registered tools: %w", err)
+		}
+		for _, tool := range tools {
+			names[tool.Name] = true
+		}
+	}
+	return names, nil
+}
+
+// resolveToolNames checks a provider's tools against the names already taken and
+// returns the renames (exposed name -> tool name) needed under policy
+func resolveToolNames(policy, provider string, tools []mcp.Tool, taken map[string]bool) (map[string]string, error) {
+	renames := make(map[string]string)
+	seen := make(map[string]bool, len(tools))
+
+	for _, tool := range tools {
+		if seen[tool.Name] {
+			return nil, fmt.Errorf("%s declares tool %q more than once", provider, tool.Name)
+		}
+		seen[tool.Name] = true
+
+		if !taken[tool.Name] {
+			continue
+		}
+
+		switch policy {
+		case ToolConflictPrefix:
+			exposed := provider + "_" + tool.Name
+			if taken[exposed] || seen[exposed] {
+				return nil, fmt.Errorf("tool %q from %s conflicts with an existing tool, and so does %q", tool.Name, provider, exposed)
+			}
+			renames[exposed] = tool.Name
+		case ToolConflictStrict, "":
+			return nil, fmt.Errorf("tool %q from %s is already registered", tool.Name, provider)
+		default:
+			return nil, fmt.Errorf("unknown tool name conflict policy %q", policy)
+		}
+	}
+
+	return renames, nil
+}
+
+// renamedToolProvider exposes some of a provider's tools under different names
+type renamedToolProvider struct {
+	mcp.ToolProvider
+	renames  map[string]string // exposed name -> provider's tool name
+	exposeAs map[string]string // provider's tool name -> exposed name
+}
+
+func newRenamedToolProvider(provider mcp.ToolProvider, renames map[string]string) *renamedToolProvider {
+	exposeAs := make(map[string]string, len(renames))
+	for exposed, name := range renames {
+		exposeAs[name] = exposed
+	}
+	return &renamedToolProvider{
+		ToolProvider: provider,
+		renames:      renames,
+		exposeAs:     exposeAs,
+	}
+}
+
+// ListTools returns the provider's tools under their exposed names
+func (r *renamedToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	tools, err := r.ToolProvider.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := make([]mcp.Tool, len(tools))
+	for i, tool := range tools {
+		if exposed, ok := r.exposeAs[tool.Name]; ok {
+			tool.Name = exposed
+		}
+		renamed[i] = tool
+	}
+	return renamed, nil
+}
+
+// CallTool maps an exposed name back to the provider's tool name
+func (r *renamedToolProvider) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	if name, ok := r.renames[request.Name]; ok {
+		request.Name = name
+	}
+	return r.ToolProvider.CallTool(ctx, request)
+}
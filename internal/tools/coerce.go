@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// numberPattern matches strings that read as a JSON number. Leading zeros are
+// excluded, since values such as "007" are usually codes rather than numbers.
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// coerceFilterValues returns a copy of filter in which string values that
+// look like numbers or booleans, such as "2" or "true", are converted to them,
+// for clients that send every value as a string. Values of the document
+// fields that are always strings (_id, title, content, category and tags),
+// regular expressions and top-level operators such as $text and $expr are
+// left as they are.
+func coerceFilterValues(filter map[string]interface{}) map[string]interface{} {
+	if filter == nil {
+		return nil
+	}
+	coerced := make(map[string]interface{}, len(filter))
+	for key, value := range filter {
+		switch {
+		case key == "$and" || key == "$or" || key == "$nor":
+			coerced[key] = coerceFilterList(value)
+		case strings.HasPrefix(key, "$"), key == "_id", documentFieldTypes[key] != "":
+			coerced[key] = value
+		default:
+			coerced[key] = coerceCondition(value)
+		}
+	}
+	return coerced
+}
+
+// coerceFilterList coerces the filters of a logical operator
+func coerceFilterList(value interface{}) interface{} {
+	filters, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	coerced := make([]interface{}, len(filters))
+	for i, filter := range filters {
+		if m, ok := filter.(map[string]interface{}); ok {
+			coerced[i] = coerceFilterValues(m)
+		} else {
+			coerced[i] = filter
+		}
+	}
+	return coerced
+}
+
+// coerceCondition coerces the value a field is matched against: a plain
+// value, a list, or operators such as {"$gt": "5"} or {"$in": ["1", "2"]}
+func coerceCondition(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return coerceString(v)
+	case []interface{}:
+		coerced := make([]interface{}, len(v))
+		for i, item := range v {
+			coerced[i] = coerceCondition(item)
+		}
+		return coerced
+	case map[string]interface{}:
+		coerced := make(map[string]interface{}, len(v))
+		for key, operand := range v {
+			if key == "$regex" || key == "$options" {
+				coerced[key] = operand
+				continue
+			}
+			coerced[key] = coerceCondition(operand)
+		}
+		return coerced
+	default:
+		return value
+	}
+}
+
+// coerceString converts "true" and "false" to booleans and numbers to int64
+// or float64; other strings are returned unchanged
+func coerceString(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if !numberPattern.MatchString(s) {
+		return s
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
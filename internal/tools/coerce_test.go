@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionStore counts documents by version the way MongoDB compares values:
+// numbers match by value, while a string never equals a number
+type versionStore struct {
+	*MockMongoDB
+}
+
+func (s versionStore) CountDocuments(ctx context.Context, collection string, filter map[string]interface{}) (int64, error) {
+	var count int64
+	for _, doc := range s.documents {
+		switch version := filter["version"].(type) {
+		case int64:
+			if int64(doc.Version) == version {
+				count++
+			}
+		case float64:
+			if float64(doc.Version) == version {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func TestCoerceFilterValues(t *testing.T) {
+	t.Run("Values", func(t *testing.T) {
+		coerced := coerceFilterValues(map[string]interface{}{
+			"version":          "2",
+			"metadata.score":   map[string]interface{}{"$gte": "4.5", "$lt": "1e3"},
+			"metadata.draft":   "false",
+			"metadata.ids":     map[string]interface{}{"$in": []interface{}{"1", "two", "-3"}},
+			"metadata.code":    "007",
+			"metadata.name":    "2fa",
+			"metadata.pattern": map[string]interface{}{"$regex": "123", "$options": "i"},
+			"$or": []interface{}{
+				map[string]interface{}{"version": "3"},
+				map[string]interface{}{"category": "2024"},
+			},
+			// Fields that are always strings keep their values
+			"_id":      "12345",
+			"title":    "1984",
+			"category": map[string]interface{}{"$in": []interface{}{"2023", "2024"}},
+			"tags":     "true",
+		})
+
+		assert.Equal(t, map[string]interface{}{
+			"version":          int64(2),
+			"metadata.score":   map[string]interface{}{"$gte": 4.5, "$lt": 1000.0},
+			"metadata.draft":   false,
+			"metadata.ids":     map[string]interface{}{"$in": []interface{}{int64(1), "two", int64(-3)}},
+			"metadata.code":    "007",
+			"metadata.name":    "2fa",
+			"metadata.pattern": map[string]interface{}{"$regex": "123", "$options": "i"},
+			"$or": []interface{}{
+				map[string]interface{}{"version": int64(3)},
+				map[string]interface{}{"category": "2024"},
+			},
+			"_id":      "12345",
+			"title":    "1984",
+			"category": map[string]interface{}{"$in": []interface{}{"2023", "2024"}},
+			"tags":     "true",
+		}, coerced)
+
+		assert.Nil(t, coerceFilterValues(nil))
+	})
+
+	t.Run("CoercedVsRaw", func(t *testing.T) {
+		store := versionStore{NewMockMongoDB(true, nil)}
+		for _, doc := range []*mcp.Document{
+			{ID: "a", Title: "First", Content: "v1", Version: 1},
+			{ID: "b", Title: "Second", Content: "v2", Version: 2},
+			{ID: "c", Title: "Third", Content: "v2", Version: 2},
+		} {
+			store.documents[doc.ID] = doc
+		}
+		count := func(tool *DatabaseTool, version interface{}) interface{} {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name: "db_count_documents",
+				Arguments: map[string]interface{}{
+					"collection": "knowledgebase",
+					"filter":     map[string]interface{}{"version": version},
+				},
+			})
+			require.NoError(t, err)
+			require.False(t, response.IsError, response.Content[0].Text)
+			return response.StructuredContent.(map[string]interface{})["count"]
+		}
+
+		raw := NewDatabaseTool(store)
+		assert.Equal(t, int64(2), count(raw, int64(2)))
+		// Without coercion the string matches no numeric version
+		assert.Equal(t, int64(0), count(raw, "2"))
+
+		coercing := NewDatabaseTool(store)
+		coercing.SetCoerceFilterValues(true)
+		assert.Equal(t, int64(2), count(coercing, "2"))
+		assert.Equal(t, int64(1), count(coercing, "1"))
+		assert.Equal(t, int64(0), count(coercing, "v2"))
+	})
+}
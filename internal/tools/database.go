@@ -2,34 +2,94 @@ package tools
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/kringen/go-mcp-server/internal/database"
 	"github.com/kringen/go-mcp-server/pkg/mcp"
-	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// defaultCollection is the collection used by tools whose collection argument is optional
+const defaultCollection = "documents"
+
+// collectionArgs are the tool arguments naming a collection, which CallTool
+// checks against the collections the client may access
+var collectionArgs = []string{"collection", "target_collection", "other_collection"}
+
+// maxGetDocumentsIDs is the most IDs db_get_documents looks up in one call
+const maxGetDocumentsIDs = 100
+
+// maxRecentDocuments is the most documents db_recent_documents lists
+const maxRecentDocuments = 100
+
 // DatabaseTool provides database operations as MCP tools
 type DatabaseTool struct {
-	db *database.MongoDB
+	db            database.DocumentStore
+	prettyJSON    bool
+	schemas       CollectionSchemas
+	coerceFilters bool
+
+	// recentCollections are the collections db_recent_documents lists when
+	// called without a collection
+	recentCollections []string
+
+	// exports keeps the collections exported by db_export_collection; nil
+	// disables the tool
+	exports *ExportStore
 }
 
 // NewDatabaseTool creates a new DatabaseTool
-func NewDatabaseTool(db *database.MongoDB) *DatabaseTool {
+func NewDatabaseTool(db database.DocumentStore) *DatabaseTool {
 	return &DatabaseTool{
 		db: db,
 	}
 }
 
+// SetPrettyJSON switches the JSON embedded in tool results between indented and compact
+func (d *DatabaseTool) SetPrettyJSON(pretty bool) {
+	d.prettyJSON = pretty
+}
+
+// SetCollectionSchemas sets the fields documents must have, per collection,
+// enforced when documents are created or updated
+func (d *DatabaseTool) SetCollectionSchemas(schemas CollectionSchemas) {
+	d.schemas = schemas
+}
+
+// SetCoerceFilterValues makes the tools convert filter values sent as strings
+// to numbers or booleans when they look like them, so {"version": "2"}
+// matches documents whose version is the number 2
+func (d *DatabaseTool) SetCoerceFilterValues(coerce bool) {
+	d.coerceFilters = coerce
+}
+
+// SetRecentCollections sets the collections db_recent_documents spans when
+// called without a collection; with none set it lists defaultCollection
+func (d *DatabaseTool) SetRecentCollections(collections []string) {
+	d.recentCollections = collections
+}
+
+// SetExportStore sets where db_export_collection keeps the exports it serves
+// as resources; the store must also be registered as a resource provider
+func (d *DatabaseTool) SetExportStore(store *ExportStore) {
+	d.exports = store
+}
+
+// Name returns the provider name used to namespace conflicting tool names
+func (d *DatabaseTool) Name() string {
+	return "database"
+}
+
 // ListTools returns the available database tools
 func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 	return []mcp.Tool{
 		{
 			Name:        "db_create_document",
 			Description: "Create a new document in the database",
+			Annotations: &mcp.ToolAnnotations{},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -37,6 +97,10 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"type":        "string",
 						"description": "Collection name",
 					},
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Document ID to use instead of a generated one; must not already exist",
+					},
 					"title": map[string]interface{}{
 						"type":        "string",
 						"description": "Document title",
@@ -45,6 +109,10 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"type":        "string",
 						"description": "Document content",
 					},
+					"category": map[string]interface{}{
+						"type":        "string",
+						"description": "Document category",
+					},
 					"tags": map[string]interface{}{
 						"type":        "array",
 						"description": "Document tags",
@@ -63,6 +131,51 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 		{
 			Name:        "db_get_document",
 			Description: "Get a document by ID",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Document ID",
+					},
+				},
+				"required": []string{"collection", "id"},
+			},
+		},
+		{
+			Name:        "db_get_documents",
+			Description: "Retrieve several documents by ID in one call, in the order requested, listing the IDs that were not found",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"ids": map[string]interface{}{
+						"type":        "array",
+						"description": "Document IDs",
+						"items":       map[string]interface{}{"type": "string"},
+						"minItems":    1,
+						"maxItems":    maxGetDocumentsIDs,
+					},
+				},
+				"required": []string{"collection", "ids"},
+			},
+		},
+		{
+			Name:        "db_document_exists",
+			Description: "Check whether a document with the given ID exists, without fetching it",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -81,6 +194,7 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 		{
 			Name:        "db_update_document",
 			Description: "Update an existing document",
+			Annotations: &mcp.ToolAnnotations{DestructiveHint: true},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -100,6 +214,10 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"type":        "string",
 						"description": "Document content",
 					},
+					"category": map[string]interface{}{
+						"type":        "string",
+						"description": "Document category",
+					},
 					"tags": map[string]interface{}{
 						"type":        "array",
 						"description": "Document tags",
@@ -115,9 +233,33 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 				"required": []string{"collection", "id"},
 			},
 		},
+		{
+			Name:        "db_update_many",
+			Description: "Set fields on every document matching a filter (e.g. to retag or recategorize a batch) and return how many were modified. The ID, version and timestamps cannot be set",
+			Annotations: &mcp.ToolAnnotations{DestructiveHint: true},
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter selecting the documents to update; must select by at least one field",
+					},
+					"set": map[string]interface{}{
+						"type":        "object",
+						"description": "Fields to set on each matching document, e.g. {\"category\": \"Networking\"}",
+					},
+				},
+				"required": []string{"collection", "filter", "set"},
+			},
+		},
 		{
 			Name:        "db_delete_document",
 			Description: "Delete a document by ID",
+			Annotations: &mcp.ToolAnnotations{DestructiveHint: true, IdempotentHint: true},
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -133,9 +275,55 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 				"required": []string{"collection", "id"},
 			},
 		},
+		{
+			Name:        "db_clear_collection",
+			Description: "Drop a collection with all its documents and indexes. Requires confirm: true",
+			Annotations: &mcp.ToolAnnotations{DestructiveHint: true, IdempotentHint: true},
+			// Only offered to clients whose tool profile lists it by name
+			RequiresAllowlist: true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"confirm": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Must be true; guards against clearing a collection by accident",
+					},
+				},
+				"required": []string{"collection", "confirm"},
+			},
+		},
+		{
+			Name:        "db_move_document",
+			Description: "Move a document to another collection, keeping its ID and timestamps. Fails without changes if the target collection already has a document with that ID",
+			Annotations: &mcp.ToolAnnotations{DestructiveHint: true},
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection the document is in",
+					},
+					"target_collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection to move the document to",
+					},
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Document ID",
+					},
+				},
+				"required": []string{"collection", "target_collection", "id"},
+			},
+		},
 		{
 			Name:        "db_query_documents",
 			Description: "Query documents in a collection",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -148,8 +336,21 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"description": "MongoDB filter query",
 					},
 					"sort": map[string]interface{}{
-						"type":        "object",
-						"description": "Sort specification",
+						"type":        []string{"object", "array"},
+						"description": "Sort specification: an object like {\"created_at\": -1}, or an ordered array like [{\"field\": \"created_at\", \"dir\": \"desc\"}] for multi-key sorts. Directions may be 1/-1 or asc/desc",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"field": map[string]interface{}{
+									"type": "string",
+								},
+								"dir": map[string]interface{}{
+									"type":        []string{"string", "integer"},
+									"description": "asc, desc, 1 or -1 (default: asc)",
+								},
+							},
+							"required": []string{"field"},
+						},
 					},
 					"limit": map[string]interface{}{
 						"type":        "integer",
@@ -166,9 +367,83 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 				"required": []string{"collection"},
 			},
 		},
+		{
+			Name:        "db_explain_query",
+			Description: "Explain how MongoDB runs a query, to diagnose slow queries: whether it scanned the whole collection, which index it used, and how many documents it examined for those it returned",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter query, as for db_query_documents",
+					},
+					"sort": map[string]interface{}{
+						"type":        []string{"object", "array"},
+						"description": "Sort specification, as for db_query_documents",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of documents the query returns",
+						"minimum":     1,
+						"maximum":     100,
+					},
+					"skip": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of documents the query skips",
+						"minimum":     0,
+					},
+				},
+				"required": []string{"collection"},
+			},
+		},
+		{
+			Name:        "db_find_by_tags",
+			Description: "Find documents tagged with any or all of the given tags",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name (default: documents)",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"description": "Tags to match",
+						"items":       map[string]interface{}{"type": "string"},
+						"minItems":    1,
+					},
+					"match": map[string]interface{}{
+						"type":        "string",
+						"description": "'any' to match documents with at least one of the tags, 'all' to match documents with every tag (default: any)",
+						"enum":        []string{"any", "all"},
+					},
+					"sort": map[string]interface{}{
+						"type":        []string{"object", "array"},
+						"description": "Sort specification, as for db_query_documents",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of documents to return",
+						"minimum":     1,
+						"maximum":     100,
+					},
+				},
+				"required": []string{"tags"},
+			},
+		},
 		{
 			Name:        "db_search_documents",
-			Description: "Search documents using text search",
+			Description: "Search documents using text search, optionally narrowed by a filter (a text index is created automatically if the collection has none; if that fails, a slower case-insensitive substring match on title and content is used instead)",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -180,19 +455,30 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"type":        "string",
 						"description": "Text to search for",
 					},
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter the matches must also satisfy, e.g. {\"category\": \"Security\"}",
+					},
 					"limit": map[string]interface{}{
 						"type":        "integer",
 						"description": "Maximum number of results",
 						"minimum":     1,
 						"maximum":     50,
 					},
+					"min_score": map[string]interface{}{
+						"type":        "number",
+						"description": "Drop results whose text-search relevance score is below this value; applied after limit",
+						"minimum":     0,
+					},
 				},
 				"required": []string{"collection", "search_text"},
 			},
 		},
 		{
-			Name:        "db_count_documents",
-			Description: "Count documents matching a filter",
+			Name:        "db_related_documents",
+			Description: "Find documents related to a given document in the same collection, ranked by the number of tags they share (documents in the same category rank higher on ties)",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -200,99 +486,540 @@ func (d *DatabaseTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"type":        "string",
 						"description": "Collection name",
 					},
-					"filter": map[string]interface{}{
-						"type":        "object",
-						"description": "MongoDB filter query",
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the source document",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of related documents (default: 5)",
+						"minimum":     1,
+						"maximum":     50,
 					},
 				},
-				"required": []string{"collection"},
+				"required": []string{"collection", "id"},
 			},
 		},
 		{
-			Name:        "db_health_check",
-			Description: "Check database health",
+			Name:        "db_find_by_title",
+			Description: "Find documents by an approximate title, tolerating typos, differences in case and punctuation, and partial titles. Returns the best matches with a similarity score from 0 to 1, where 1 is an exact match.",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
-				"properties": map[string]interface{}{},
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "Title, or part of it, as remembered",
+					},
+					"min_score": map[string]interface{}{
+						"type":        "number",
+						"description": "Minimum similarity score of returned matches (default: 0.5)",
+						"minimum":     0,
+						"maximum":     1,
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of matches (default: 5)",
+						"minimum":     1,
+						"maximum":     50,
+					},
+				},
+				"required": []string{"collection", "title"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"matches": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"id":       map[string]interface{}{"type": "string"},
+								"title":    map[string]interface{}{"type": "string"},
+								"category": map[string]interface{}{"type": "string"},
+								"score":    map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+							},
+							"required":             []string{"id", "title", "score"},
+							"additionalProperties": false,
+						},
+					},
+				},
+				"required":             []string{"matches"},
+				"additionalProperties": false,
 			},
 		},
-	}, nil
-}
-
-// CallTool executes the specified database tool
-func (d *DatabaseTool) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
-	switch request.Name {
-	case "db_create_document":
-		return d.createDocument(ctx, request.Arguments)
-	case "db_get_document":
-		return d.getDocument(ctx, request.Arguments)
-	case "db_update_document":
-		return d.updateDocument(ctx, request.Arguments)
-	case "db_delete_document":
-		return d.deleteDocument(ctx, request.Arguments)
-	case "db_query_documents":
-		return d.queryDocuments(ctx, request.Arguments)
-	case "db_search_documents":
-		return d.searchDocuments(ctx, request.Arguments)
-	case "db_count_documents":
-		return d.countDocuments(ctx, request.Arguments)
-	case "db_health_check":
-		return d.healthCheck(ctx)
-	default:
-		return &mcp.ToolCallResponse{
-			IsError: true,
-			Content: []mcp.Content{
-				{
-					Type: "text",
-					Text: fmt.Sprintf("Unknown database tool: %s", request.Name),
+		{
+			Name:        "db_recent_documents",
+			Description: "List the most recently updated documents of a collection, or of the server's configured collections when no collection is given, newest first",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name (default: the configured collections, listed together with the collection of each document)",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Maximum number of documents (default: 10, max: %d)", maxRecentDocuments),
+						"minimum":     1,
+						"maximum":     maxRecentDocuments,
+					},
 				},
 			},
-		}, nil
-	}
-}
-
-func (d *DatabaseTool) createDocument(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
-	}
-
-	title, ok := args["title"].(string)
-	if !ok || title == "" {
-		return d.errorResponse("Missing or invalid 'title' parameter"), nil
-	}
-
-	content, ok := args["content"].(string)
-	if !ok || content == "" {
-		return d.errorResponse("Missing or invalid 'content' parameter"), nil
-	}
-
-	doc := &mcp.Document{
-		ID:      bson.NewObjectID().Hex(),
-		Title:   title,
-		Content: content,
-	}
-
-	// Extract optional tags
-	if tagsInterface, ok := args["tags"]; ok {
-		if tagsSlice, ok := tagsInterface.([]interface{}); ok {
-			tags := make([]string, len(tagsSlice))
-			for i, tag := range tagsSlice {
-				if tagStr, ok := tag.(string); ok {
-					tags[i] = tagStr
-				}
-			}
-			doc.Tags = tags
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"documents": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"id":         map[string]interface{}{"type": "string"},
+								"title":      map[string]interface{}{"type": "string"},
+								"category":   map[string]interface{}{"type": "string"},
+								"collection": map[string]interface{}{"type": "string"},
+								"updated_at": map[string]interface{}{"type": "string", "format": "date-time"},
+							},
+							"required":             []string{"id", "title", "updated_at"},
+							"additionalProperties": false,
+						},
+					},
+				},
+				"required":             []string{"documents"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_diff_documents",
+			Description: "Compare two documents field by field, e.g. to review an edit: reports the title, category, tags and metadata values added, removed or changed from the first document to the second, and a unified line diff of the content",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the document to compare from",
+					},
+					"other_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the document to compare to",
+					},
+					"other_collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection of the document to compare to (default: collection)",
+					},
+				},
+				"required": []string{"collection", "id", "other_id"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"identical": map[string]interface{}{"type": "boolean"},
+					"changes": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"field":   map[string]interface{}{"type": "string"},
+								"change":  map[string]interface{}{"type": "string", "enum": []string{fieldAdded, fieldRemoved, fieldChanged}},
+								"old":     map[string]interface{}{},
+								"new":     map[string]interface{}{},
+								"added":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+								"removed": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+								"diff":    map[string]interface{}{"type": "string"},
+							},
+							"required":             []string{"field", "change"},
+							"additionalProperties": false,
+						},
+					},
+				},
+				"required":             []string{"identical", "changes"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_export_collection",
+			Description: "Export every document of a collection as newline-delimited JSON into a resource, and return its export:// URI to fetch with resources/read, instead of returning the documents inline. The export is a snapshot kept for a limited time.",
+			Annotations: &mcp.ToolAnnotations{},
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+				},
+				"required": []string{"collection"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uri":        map[string]interface{}{"type": "string"},
+					"documents":  map[string]interface{}{"type": "integer", "minimum": 0},
+					"bytes":      map[string]interface{}{"type": "integer", "minimum": 0},
+					"expires_at": map[string]interface{}{"type": "string", "format": "date-time"},
+				},
+				"required":             []string{"uri", "documents", "bytes"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_ensure_text_index",
+			Description: "Create a text index on title and content so a collection can be used with db_search_documents",
+			Annotations: &mcp.ToolAnnotations{IdempotentHint: true},
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+				},
+				"required": []string{"collection"},
+			},
+		},
+		{
+			Name:        "db_list_indexes",
+			Description: "List the indexes of a collection with their keys, and whether each is a text or TTL index, e.g. to check that db_search_documents will work or why a query is slow",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+				},
+				"required": []string{"collection"},
+			},
+		},
+		{
+			Name:        "db_count_documents",
+			Description: "Count documents matching a filter",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter query",
+					},
+				},
+				"required": []string{"collection"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{"type": "string"},
+					"count":      map[string]interface{}{"type": "integer", "minimum": 0},
+				},
+				"required":             []string{"collection", "count"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_field_stats",
+			Description: "Compute count, sum, average, minimum and maximum of a numeric field over documents matching an optional filter, e.g. version or metadata.word_count. Missing and non-numeric values are ignored.",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"field": map[string]interface{}{
+						"type":        "string",
+						"description": "Numeric field, using dots for nested fields (e.g. metadata.word_count)",
+					},
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter query",
+					},
+				},
+				"required": []string{"collection", "field"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"field":   map[string]interface{}{"type": "string"},
+					"matched": map[string]interface{}{"type": "integer", "minimum": 0},
+					"count":   map[string]interface{}{"type": "integer", "minimum": 0},
+					"sum":     map[string]interface{}{"type": "number"},
+					"avg":     map[string]interface{}{"type": "number"},
+					"min":     map[string]interface{}{"type": "number"},
+					"max":     map[string]interface{}{"type": "number"},
+				},
+				"required":             []string{"field", "matched", "count", "sum", "avg", "min", "max"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_distinct_count",
+			Description: "Count the distinct values (cardinality) of a field over documents matching an optional filter, e.g. how many categories or tags are in use, without returning the values themselves. Each element of an array field counts as a value; missing and null values are not counted.",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"field": map[string]interface{}{
+						"type":        "string",
+						"description": "Field, using dots for nested fields (e.g. category, tags or metadata.author)",
+					},
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter query",
+					},
+				},
+				"required": []string{"collection", "field"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{"type": "string"},
+					"field":      map[string]interface{}{"type": "string"},
+					"count":      map[string]interface{}{"type": "integer", "minimum": 0},
+				},
+				"required":             []string{"collection", "field", "count"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_time_histogram",
+			Description: "Count documents per day, week or month of a date field such as created_at, to show activity over time. Weeks start on Monday and buckets are in UTC; documents without a date in the field are left out, as are empty buckets.",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"collection": map[string]interface{}{
+						"type":        "string",
+						"description": "Collection name",
+					},
+					"field": map[string]interface{}{
+						"type":        "string",
+						"description": "Date field (default: created_at)",
+						"default":     "created_at",
+					},
+					"bucket": map[string]interface{}{
+						"type":        "string",
+						"description": "Bucket size (default: day)",
+						"enum":        []string{database.TimeBucketDay, database.TimeBucketWeek, database.TimeBucketMonth},
+						"default":     database.TimeBucketDay,
+					},
+				},
+				"required": []string{"collection"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"field":  map[string]interface{}{"type": "string"},
+					"bucket": map[string]interface{}{"type": "string"},
+					"counts": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"bucket": map[string]interface{}{"type": "string", "format": "date-time"},
+								"count":  map[string]interface{}{"type": "integer", "minimum": 1},
+							},
+							"required":             []string{"bucket", "count"},
+							"additionalProperties": false,
+						},
+					},
+				},
+				"required":             []string{"field", "bucket", "counts"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_validate_filter",
+			Description: "Check a MongoDB filter without running it: whether it is well-formed and safe, listing rejected operators such as $where and other problems. Use it to check a filter before an expensive query.",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"filter": map[string]interface{}{
+						"type":        "object",
+						"description": "MongoDB filter query, as for db_query_documents",
+					},
+				},
+				"required": []string{"filter"},
+			},
+			OutputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"valid":              map[string]interface{}{"type": "boolean"},
+					"rejected_operators": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					"problems":           map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"required":             []string{"valid", "rejected_operators", "problems"},
+				"additionalProperties": false,
+			},
+		},
+		{
+			Name:        "db_health_check",
+			Description: "Check database health",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}, nil
+}
+
+// CallTool executes the specified database tool
+func (d *DatabaseTool) CallTool(ctx context.Context, request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
+	for _, arg := range collectionArgs {
+		if collection, ok := request.Arguments[arg].(string); ok && !mcp.CollectionAllowed(ctx, collection) {
+			return d.accessDenied(collection), nil
+		}
+	}
+
+	switch request.Name {
+	case "db_create_document":
+		return d.createDocument(ctx, request.Arguments)
+	case "db_get_document":
+		return d.getDocument(ctx, request.Arguments)
+	case "db_get_documents":
+		return d.getDocuments(ctx, request.Arguments)
+	case "db_document_exists":
+		return d.documentExists(ctx, request.Arguments)
+	case "db_update_document":
+		return d.updateDocument(ctx, request.Arguments)
+	case "db_update_many":
+		return d.updateMany(ctx, request.Arguments)
+	case "db_delete_document":
+		return d.deleteDocument(ctx, request.Arguments)
+	case "db_clear_collection":
+		return d.clearCollection(ctx, request.Arguments)
+	case "db_move_document":
+		return d.moveDocument(ctx, request.Arguments)
+	case "db_explain_query":
+		return d.explainQuery(ctx, request.Arguments)
+	case "db_query_documents":
+		return d.queryDocuments(ctx, request.Arguments)
+	case "db_find_by_tags":
+		return d.findByTags(ctx, request.Arguments)
+	case "db_search_documents":
+		return d.searchDocuments(ctx, request.Arguments)
+	case "db_related_documents":
+		return d.relatedDocuments(ctx, request.Arguments)
+	case "db_find_by_title":
+		return d.findByTitle(ctx, request.Arguments)
+	case "db_recent_documents":
+		return d.recentDocuments(ctx, request.Arguments)
+	case "db_diff_documents":
+		return d.diffDocuments(ctx, request.Arguments)
+	case "db_export_collection":
+		return d.exportCollection(ctx, request.Arguments)
+	case "db_ensure_text_index":
+		return d.ensureTextIndex(ctx, request.Arguments)
+	case "db_list_indexes":
+		return d.listIndexes(ctx, request.Arguments)
+	case "db_count_documents":
+		return d.countDocuments(ctx, request.Arguments)
+	case "db_field_stats":
+		return d.fieldStats(ctx, request.Arguments)
+	case "db_distinct_count":
+		return d.distinctCount(ctx, request.Arguments)
+	case "db_time_histogram":
+		return d.timeHistogram(ctx, request.Arguments)
+	case "db_validate_filter":
+		return d.validateFilter(request.Arguments)
+	case "db_health_check":
+		return d.healthCheck(ctx)
+	default:
+		return &mcp.ToolCallResponse{
+			IsError: true,
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: fmt.Sprintf("Unknown database tool: %s", request.Name),
+				},
+			},
+		}, nil
+	}
+}
+
+func (d *DatabaseTool) createDocument(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	title, ok := args.String("title")
+	if !ok || title == "" {
+		return d.errorResponse("Missing or invalid 'title' parameter"), nil
+	}
+
+	content, ok := args.String("content")
+	if !ok || content == "" {
+		return d.errorResponse("Missing or invalid 'content' parameter"), nil
+	}
+
+	doc := &mcp.Document{
+		Title:   title,
+		Content: content,
+	}
+
+	// Use the caller's ID if given, otherwise the database generates one
+	if args.Has("id") {
+		id, ok := args.String("id")
+		if !ok || id == "" {
+			return d.errorResponse("Invalid 'id' parameter: must be a non-empty string"), nil
 		}
+		doc.ID = id
+	}
+
+	if category, ok := args.String("category"); ok {
+		doc.Category = category
+	}
+
+	// Extract optional tags
+	if tags, ok := args.StringSlice("tags"); ok {
+		doc.Tags = tags
 	}
 
 	// Extract optional metadata
-	if metadata, ok := args["metadata"].(map[string]interface{}); ok {
+	if metadata, ok := args.Map("metadata"); ok {
 		doc.Metadata = metadata
 	}
 
-	err := d.db.CreateDocument(ctx, collection, doc)
+	if err := d.validateDocument(collection, doc); err != nil {
+		return d.errorResponse(fmt.Sprintf("Invalid document: %v", err)), nil
+	}
+
+	err = d.db.CreateDocument(ctx, collection, doc)
 	if err != nil {
+		if errors.Is(err, database.ErrDuplicateID) {
+			return d.errorResponse(fmt.Sprintf("Failed to create document: a document with ID '%s' already exists in '%s'", doc.ID, collection)), nil
+		}
 		return d.errorResponse(fmt.Sprintf("Failed to create document: %v", err)), nil
 	}
 
@@ -311,268 +1038,1245 @@ func (d *DatabaseTool) createDocument(ctx context.Context, args map[string]inter
 	}, nil
 }
 
-func (d *DatabaseTool) getDocument(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
+func (d *DatabaseTool) getDocument(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	id, ok := args.String("id")
+	if !ok || id == "" {
+		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	}
+
+	doc, err := d.db.GetDocument(ctx, collection, id)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to get document: %v", err)), nil
+	}
+
+	// Format document for display
+	content := []mcp.Content{
+		{
+			Type: "text",
+			Text: fmt.Sprintf("Document found:\n- ID: %s\n- Title: %s\n- Created: %s\n- Updated: %s\n- Version: %d",
+				doc.ID, doc.Title, doc.CreatedAt.Format(time.RFC3339), doc.UpdatedAt.Format(time.RFC3339), doc.Version),
+		},
+		{
+			Type: "text",
+			Text: fmt.Sprintf("Content:\n%s", doc.Content),
+		},
+	}
+
+	if len(doc.Tags) > 0 {
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("Tags: %v", doc.Tags),
+		})
+	}
+
+	// Add JSON data
+	jsonData, _ := mcp.FormatJSON(doc, d.prettyJSON)
+	content = append(content, mcp.Content{
+		Type: "text",
+		Text: fmt.Sprintf("Raw JSON:\n```json\n%s\n```", string(jsonData)),
+	})
+
+	return &mcp.ToolCallResponse{
+		Content: content,
+	}, nil
+}
+
+// getDocuments retrieves several documents by ID, reporting the IDs not found
+func (d *DatabaseTool) getDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	ids, ok := args.StringSlice("ids")
+	if !ok || len(ids) == 0 {
+		return d.errorResponse("Missing or invalid 'ids' parameter: expected a non-empty array of strings"), nil
+	}
+	if len(ids) > maxGetDocumentsIDs {
+		return d.errorResponse(fmt.Sprintf("Too many 'ids': at most %d per call", maxGetDocumentsIDs)), nil
+	}
+
+	docs, err := d.db.GetDocuments(ctx, collection, ids)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to get documents: %v", err)), nil
+	}
+
+	found := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		found[doc.ID] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true // list repeated IDs once
+		}
+	}
+
+	header := fmt.Sprintf("Found %d of %d requested documents in collection '%s'", len(docs), len(docs)+len(missing), collection)
+	if len(missing) > 0 {
+		header += fmt.Sprintf("\nNot found: %s", strings.Join(missing, ", "))
+	}
+	return &mcp.ToolCallResponse{
+		Content: d.documentListContent(header, docs),
+	}, nil
+}
+
+func (d *DatabaseTool) documentExists(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	id, ok := args.String("id")
+	if !ok || id == "" {
+		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	}
+
+	exists, err := d.db.DocumentExists(ctx, collection, id)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to check document: %v", err)), nil
+	}
+
+	text := fmt.Sprintf("Document '%s' exists in '%s'", id, collection)
+	if !exists {
+		text = fmt.Sprintf("Document '%s' does not exist in '%s'", id, collection)
+	}
+
+	jsonData, _ := mcp.FormatJSON(map[string]interface{}{"id": id, "exists": exists}, d.prettyJSON)
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text,
+			},
+			{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+	}, nil
+}
+
+func (d *DatabaseTool) updateDocument(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	id, ok := args.String("id")
+	if !ok || id == "" {
+		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	}
+
+	// Get existing document
+	doc, err := d.db.GetDocument(ctx, collection, id)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to find document: %v", err)), nil
+	}
+
+	// Update fields if provided
+	if title, ok := args.String("title"); ok && title != "" {
+		doc.Title = title
+	}
+
+	if content, ok := args.String("content"); ok && content != "" {
+		doc.Content = content
+	}
+
+	if category, ok := args.String("category"); ok && category != "" {
+		doc.Category = category
+	}
+
+	if tags, ok := args.StringSlice("tags"); ok {
+		doc.Tags = tags
+	}
+
+	if metadata, ok := args.Map("metadata"); ok {
+		doc.Metadata = metadata
+	}
+
+	if err := d.validateDocument(collection, doc); err != nil {
+		return d.errorResponse(fmt.Sprintf("Invalid document: %v", err)), nil
+	}
+
+	err = d.db.UpdateDocument(ctx, collection, doc)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to update document: %v", err)), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Document updated successfully. New version: %d", doc.Version),
+			},
+		},
+	}, nil
+}
+
+func (d *DatabaseTool) updateMany(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+	if filter == nil {
+		return d.errorResponse("Missing or invalid 'filter' parameter"), nil
+	}
+	if database.MatchesEverything(filter) {
+		return d.errorResponse("'filter' must not be empty; updating every document in a collection is not allowed"), nil
+	}
+
+	fields, ok := args.Map("set")
+	if !ok || len(fields) == 0 {
+		return d.errorResponse("Missing or invalid 'set' parameter"), nil
+	}
+	if schema, ok := d.schemas[collection]; ok {
+		if err := schema.ValidateSet(fields); err != nil {
+			return d.errorResponse(fmt.Sprintf("Invalid 'set' parameter: %v", err)), nil
+		}
+	}
+
+	modified, err := d.db.UpdateMany(ctx, collection, filter, fields)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to update documents: %v", err)), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Modified %d documents in collection %s", modified, collection),
+			},
+		},
+	}, nil
+}
+
+// clearCollection drops a collection, reporting how many documents it held
+func (d *DatabaseTool) clearCollection(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	if !args.Bool("confirm", false) {
+		return d.errorResponse(fmt.Sprintf("Refusing to clear collection '%s': set 'confirm' to true to delete all of its documents", collection)), nil
+	}
+
+	count, err := d.db.CountDocuments(ctx, collection, nil)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to count documents: %v", err)), nil
+	}
+
+	if err := d.db.DropCollection(ctx, collection); err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to clear collection: %v", err)), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Cleared collection '%s': %d documents removed", collection, count),
+			},
+		},
+	}, nil
+}
+
+func (d *DatabaseTool) deleteDocument(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	id, ok := args.String("id")
+	if !ok || id == "" {
+		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	}
+
+	err = d.db.DeleteDocument(ctx, collection, id)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to delete document: %v", err)), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Document with ID %s deleted successfully from collection %s", id, collection),
+			},
+		},
+	}, nil
+}
+
+func (d *DatabaseTool) moveDocument(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	target, ok := args.String("target_collection")
+	if !ok || target == "" {
+		return d.errorResponse("Missing or invalid 'target_collection' parameter"), nil
+	}
+	if err := d.db.ValidateCollectionName(target); err != nil {
+		return d.errorResponse(fmt.Sprintf("Invalid 'target_collection' parameter: %v", err)), nil
+	}
+	if target == collection {
+		return d.errorResponse("'target_collection' must differ from 'collection'"), nil
+	}
+
+	id, ok := args.String("id")
+	if !ok || id == "" {
+		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	}
+
+	err = d.db.MoveDocument(ctx, collection, target, id)
+	if err != nil {
+		if errors.Is(err, database.ErrDuplicateID) {
+			return d.errorResponse(fmt.Sprintf("Failed to move document: a document with ID '%s' already exists in '%s'; nothing was moved", id, target)), nil
+		}
+		return d.errorResponse(fmt.Sprintf("Failed to move document: %v", err)), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Document with ID %s moved from collection %s to %s", id, collection, target),
+			},
+		},
+	}, nil
+}
+
+func (d *DatabaseTool) queryDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	query := mcp.DatabaseQuery{
+		Collection: collection,
+		Limit:      10, // default
+	}
+
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+	query.Filter = filter
+
+	if err := d.applySortArg(args, &query); err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	if l, ok := args.Int("limit"); ok && l > 0 && l <= 100 {
+		query.Limit = l
+	}
+
+	if s, ok := args.Int("skip"); ok && s >= 0 {
+		query.Skip = s
+	}
+
+	docs, err := d.db.QueryDocuments(ctx, query)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Query failed: %v", err)), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: d.documentListContent(fmt.Sprintf("Found %d documents in collection '%s'", len(docs), collection), docs),
+	}, nil
+}
+
+// explainQuery summarizes how MongoDB executes a query
+func (d *DatabaseTool) explainQuery(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	query := mcp.DatabaseQuery{Collection: collection}
+
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+	query.Filter = filter
+
+	if err := d.applySortArg(args, &query); err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	if l, ok := args.Int("limit"); ok && l > 0 && l <= 100 {
+		query.Limit = l
+	}
+
+	if s, ok := args.Int("skip"); ok && s >= 0 {
+		query.Skip = s
+	}
+
+	summary, err := d.db.ExplainQuery(ctx, query)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Explain failed: %v", err)), nil
+	}
+
+	plan := "used a collection scan"
+	if index, ok := summary["index"].(string); ok && index != "" {
+		plan = fmt.Sprintf("used index '%s'", index)
+		if scan, _ := summary["collection_scan"].(bool); scan {
+			plan += " and a collection scan"
+		}
+	} else if scan, _ := summary["collection_scan"].(bool); !scan {
+		plan = "used no index"
+	}
+
+	jsonData, _ := mcp.FormatJSON(summary, d.prettyJSON)
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Query on '%s' %s: examined %v documents and %v index keys to return %v documents",
+					collection, plan, summary["docs_examined"], summary["keys_examined"], summary["docs_returned"]),
+			},
+			{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+	}, nil
+}
+
+// findByTags finds the documents tagged with any or all of the given tags
+func (d *DatabaseTool) findByTags(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection := defaultCollection
+	if args.Has("collection") {
+		var err error
+		if collection, err = d.collectionArg(args); err != nil {
+			return d.errorResponse(err.Error()), nil
+		}
+	} else if !mcp.CollectionAllowed(ctx, collection) {
+		return d.accessDenied(collection), nil
+	}
+
+	tags, ok := args.StringSlice("tags")
+	if !ok || len(tags) == 0 {
+		return d.errorResponse("Missing or invalid 'tags' parameter: expected a non-empty array of strings"), nil
+	}
+
+	match, _ := args.String("match")
+	var operator string
+	switch match {
+	case "", "any":
+		match, operator = "any", "$in"
+	case "all":
+		operator = "$all"
+	default:
+		return d.errorResponse(fmt.Sprintf("Invalid 'match' parameter %q: expected 'any' or 'all'", match)), nil
+	}
+
+	query := mcp.DatabaseQuery{
+		Collection: collection,
+		Filter:     map[string]interface{}{"tags": map[string]interface{}{operator: tags}},
+		Limit:      10, // default
+	}
+
+	if err := d.applySortArg(args, &query); err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	if l, ok := args.Int("limit"); ok && l > 0 && l <= 100 {
+		query.Limit = l
+	}
+
+	docs, err := d.db.QueryDocuments(ctx, query)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Query failed: %v", err)), nil
+	}
+
+	header := fmt.Sprintf("Found %d documents in collection '%s' tagged with %s of: %s",
+		len(docs), collection, match, strings.Join(tags, ", "))
+	return &mcp.ToolCallResponse{
+		Content: d.documentListContent(header, docs),
+	}, nil
+}
+
+// applySortArg parses the optional 'sort' argument, either an object of field
+// directions or an ordered array of {field, dir} entries, into query
+func (d *DatabaseTool) applySortArg(args mcp.Args, query *mcp.DatabaseQuery) error {
+	switch sort := args["sort"].(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(sort))
+		for field, dir := range sort {
+			direction, err := d.parseSortDirection(dir)
+			if err != nil {
+				return fmt.Errorf("Invalid 'sort' parameter for field '%s': %v", field, err)
+			}
+			normalized[field] = direction
+		}
+		query.Sort = normalized
+	case []interface{}:
+		orderedSort, err := d.parseOrderedSort(sort)
+		if err != nil {
+			return fmt.Errorf("Invalid 'sort' parameter: %v", err)
+		}
+		query.OrderedSort = orderedSort
+	}
+	return nil
+}
+
+// documentListContent renders a header, a numbered line per document and the
+// documents as raw JSON
+func (d *DatabaseTool) documentListContent(header string, docs []*mcp.Document) []mcp.Content {
+	content := []mcp.Content{
+		{
+			Type: "text",
+			Text: header,
+		},
+	}
+
+	for i, doc := range docs {
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("%d. **%s** (ID: %s)\n   Created: %s\n   Content preview: %s...",
+				i+1, doc.Title, doc.ID, doc.CreatedAt.Format(time.RFC3339),
+				d.truncateString(doc.Content, 100)),
+		})
+	}
+
+	// Add JSON data
+	jsonData, _ := mcp.FormatJSON(docs, d.prettyJSON)
+	content = append(content, mcp.Content{
+		Type: "text",
+		Text: fmt.Sprintf("Raw JSON:\n```json\n%s\n```", string(jsonData)),
+	})
+
+	return content
+}
+
+func (d *DatabaseTool) searchDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	searchText, ok := args.String("search_text")
+	if !ok || searchText == "" {
+		return d.errorResponse("Missing or invalid 'search_text' parameter"), nil
+	}
+
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	limit := 10 // default
+	if parsed, ok := args.Int("limit"); ok && parsed > 0 && parsed <= 50 {
+		limit = parsed
+	}
+
+	docs, err := d.db.SearchDocuments(ctx, collection, searchText, filter, limit)
+	indexCreated := false
+	var indexErr error
+	if errors.Is(err, database.ErrTextIndexRequired) {
+		// Make the collection searchable on first use, then retry once
+		if indexErr = d.db.EnsureTextIndex(ctx, collection); indexErr == nil {
+			indexCreated = true
+			docs, err = d.db.SearchDocuments(ctx, collection, searchText, filter, limit)
+		}
+	}
+	substring := false
+	if errors.Is(err, database.ErrTextIndexRequired) {
+		// Still no usable text index: fall back to a case-insensitive
+		// substring match, which works on any collection
+		substring = true
+		docs, err = d.db.SubstringSearch(ctx, collection, searchText, filter, limit)
+	}
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+
+	// Substring matches carry no score, so min_score only applies to text search
+	if minScore, ok := args.Float("min_score"); ok && minScore > 0 && !substring {
+		relevant := docs[:0]
+		for _, doc := range docs {
+			if doc.Score >= minScore {
+				relevant = append(relevant, doc)
+			}
+		}
+		docs = relevant
+	}
+
+	content := []mcp.Content{
+		{
+			Type: "text",
+			Text: fmt.Sprintf("Found %d documents matching '%s' in collection '%s'", len(docs), searchText, collection),
+		},
+	}
+
+	if indexCreated {
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("Note: collection '%s' had no text index, so one was created on title and content.", collection),
+		})
+	}
+	if substring {
+		note := fmt.Sprintf("Note: collection '%s' has no text index, so these results come from a slower substring match on title and content rather than indexed full-text search, and are not ranked by relevance.", collection)
+		if indexErr != nil {
+			note += fmt.Sprintf(" Creating a text index failed: %v", indexErr)
+		}
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: note,
+		})
+	}
+
+	for i, doc := range docs {
+		score := ""
+		if !substring {
+			score = fmt.Sprintf("\n   Score: %.2f", doc.Score)
+		}
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("%d. **%s** (ID: %s)%s\n   Created: %s\n   Content preview: %s...",
+				i+1, doc.Title, doc.ID, score, doc.CreatedAt.Format(time.RFC3339),
+				d.truncateString(doc.Content, 100)),
+		})
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: content,
+	}, nil
+}
+
+func (d *DatabaseTool) relatedDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	id, ok := args.String("id")
+	if !ok || id == "" {
+		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	}
+
+	limit := 5 // default
+	if parsed, ok := args.Int("limit"); ok && parsed > 0 && parsed <= 50 {
+		limit = parsed
+	}
+
+	source, err := d.db.GetDocument(ctx, collection, id)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to get document: %v", err)), nil
+	}
+
+	if len(source.Tags) == 0 && source.Category == "" {
+		return &mcp.ToolCallResponse{
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: fmt.Sprintf("Document '%s' has no tags or category to find related documents by", id),
+				},
+			},
+		}, nil
+	}
+
+	// Only fetch candidates sharing a tag or the category; ranking happens here
+	var match []interface{}
+	if len(source.Tags) > 0 {
+		match = append(match, map[string]interface{}{"tags": map[string]interface{}{"$in": source.Tags}})
+	}
+	if source.Category != "" {
+		match = append(match, map[string]interface{}{"category": source.Category})
+	}
+	candidates, err := d.db.QueryDocuments(ctx, mcp.DatabaseQuery{
+		Collection: collection,
+		Filter: map[string]interface{}{
+			"_id": map[string]interface{}{"$ne": id},
+			"$or": match,
+		},
+		Limit: maxRelatedCandidates,
+	})
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to find related documents: %v", err)), nil
+	}
+
+	related := rankRelatedDocuments(source, candidates, limit)
+
+	content := []mcp.Content{
+		{
+			Type: "text",
+			Text: fmt.Sprintf("Found %d documents related to '%s' in collection '%s'", len(related), source.Title, collection),
+		},
+	}
+
+	for i, r := range related {
+		text := fmt.Sprintf("%d. **%s** (ID: %s)\n   Shared tags (%d): %s",
+			i+1, r.Title, r.ID, len(r.SharedTags), strings.Join(r.SharedTags, ", "))
+		if r.SameCategory {
+			text += fmt.Sprintf("\n   Same category: %s", source.Category)
+		}
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: text,
+		})
+	}
+
+	jsonData, _ := mcp.FormatJSON(related, d.prettyJSON)
+	content = append(content, mcp.Content{
+		Type: "text",
+		Text: fmt.Sprintf("Raw JSON:\n```json\n%s\n```", string(jsonData)),
+	})
+
+	return &mcp.ToolCallResponse{
+		Content: content,
+	}, nil
+}
+
+// findByTitle finds documents whose title approximately matches the given
+// one: candidates sharing the start of a word with it are fetched, then
+// ranked here by similarity
+func (d *DatabaseTool) findByTitle(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+
+	title, ok := args.String("title")
+	if !ok || strings.TrimSpace(title) == "" {
+		return d.errorResponse("Missing or invalid 'title' parameter"), nil
+	}
+	pattern := titleCandidatePattern(title)
+	if pattern == "" {
+		return d.errorResponse("Invalid 'title' parameter: it has no letters or digits"), nil
+	}
+
+	minScore := 0.5 // default
+	if args.Has("min_score") {
+		parsed, ok := args.Float("min_score")
+		if !ok || parsed < 0 || parsed > 1 {
+			return d.errorResponse("Invalid 'min_score' parameter: expected a number from 0 to 1"), nil
+		}
+		minScore = parsed
+	}
+
+	limit := 5 // default
+	if parsed, ok := args.Int("limit"); ok && parsed > 0 && parsed <= 50 {
+		limit = parsed
+	}
+
+	candidates, err := d.db.QueryDocuments(ctx, mcp.DatabaseQuery{
+		Collection: collection,
+		Filter: map[string]interface{}{
+			"title": map[string]interface{}{"$regex": pattern, "$options": "i"},
+		},
+		Limit: maxTitleCandidates,
+	})
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to find documents by title: %v", err)), nil
+	}
+
+	matches := rankTitleMatches(title, candidates, minScore, limit)
+
+	content := []mcp.Content{
+		{
+			Type: "text",
+			Text: fmt.Sprintf("Found %d documents with a title like '%s' in collection '%s'", len(matches), title, collection),
+		},
+	}
+	for i, match := range matches {
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("%d. **%s** (ID: %s)\n   Score: %.2f", i+1, match.Title, match.ID, match.Score),
+		})
+	}
+
+	return &mcp.ToolCallResponse{
+		Content:           content,
+		StructuredContent: map[string]interface{}{"matches": matches},
+	}, nil
+}
+
+// recentDocument is a document listed by db_recent_documents
+type recentDocument struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Category   string    `json:"category,omitempty"`
+	Collection string    `json:"collection,omitempty"` // set when the listing spans collections
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// recentDocuments lists the most recently updated documents of a collection,
+// or of the configured recent collections merged by update time
+func (d *DatabaseTool) recentDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collections := d.recentCollections
+	if args.Has("collection") {
+		collection, err := d.collectionArg(args)
+		if err != nil {
+			return d.errorResponse(err.Error()), nil
+		}
+		collections = []string{collection}
+	} else {
+		if len(collections) == 0 {
+			collections = []string{defaultCollection}
+		}
+		// Only the collections the client may access are spanned
+		allowed := allowedCollections(ctx, collections)
+		if len(allowed) == 0 {
+			return d.accessDenied(strings.Join(collections, ", ")), nil
+		}
+		collections = allowed
+	}
+	spanning := len(collections) > 1
+
+	limit := 10 // default
+	if parsed, ok := args.Int("limit"); ok && parsed > 0 && parsed <= maxRecentDocuments {
+		limit = parsed
+	}
+
+	// Each collection's newest documents, merged, give the newest overall
+	documents := []recentDocument{}
+	for _, collection := range collections {
+		docs, err := d.db.QueryDocuments(ctx, mcp.DatabaseQuery{
+			Collection:  collection,
+			OrderedSort: []mcp.SortField{{Field: "updated_at", Direction: -1}},
+			Limit:       limit,
+		})
+		if err != nil {
+			return d.errorResponse(fmt.Sprintf("Failed to list recent documents in collection '%s': %v", collection, err)), nil
+		}
+		for _, doc := range docs {
+			recent := recentDocument{ID: doc.ID, Title: doc.Title, Category: doc.Category, UpdatedAt: doc.UpdatedAt}
+			if spanning {
+				recent.Collection = collection
+			}
+			documents = append(documents, recent)
+		}
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].UpdatedAt.After(documents[j].UpdatedAt)
+	})
+	if len(documents) > limit {
+		documents = documents[:limit]
+	}
+
+	header := fmt.Sprintf("Found %d recently updated documents in collection '%s'", len(documents), collections[0])
+	if spanning {
+		header = fmt.Sprintf("Found %d recently updated documents across collections %s", len(documents), strings.Join(collections, ", "))
+	}
+	content := []mcp.Content{{Type: "text", Text: header}}
+	for i, doc := range documents {
+		text := fmt.Sprintf("%d. **%s** (ID: %s", i+1, doc.Title, doc.ID)
+		if doc.Collection != "" {
+			text += fmt.Sprintf(", collection: %s", doc.Collection)
+		}
+		text += fmt.Sprintf(")\n   Updated: %s", doc.UpdatedAt.Format(time.RFC3339))
+		content = append(content, mcp.Content{Type: "text", Text: text})
+	}
+
+	return &mcp.ToolCallResponse{
+		Content:           content,
+		StructuredContent: map[string]interface{}{"documents": documents},
+	}, nil
+}
+
+// diffDocuments compares two documents, which may be in different collections,
+// field by field
+func (d *DatabaseTool) diffDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+	otherCollection := collection
+	if args.Has("other_collection") {
+		otherCollection, _ = args.String("other_collection")
+		if otherCollection == "" {
+			return d.errorResponse("Missing or invalid 'other_collection' parameter"), nil
+		}
+		if err := d.db.ValidateCollectionName(otherCollection); err != nil {
+			return d.errorResponse(fmt.Sprintf("Invalid 'other_collection' parameter: %v", err)), nil
+		}
 	}
 
-	id, ok := args["id"].(string)
+	id, ok := args.String("id")
 	if !ok || id == "" {
 		return d.errorResponse("Missing or invalid 'id' parameter"), nil
 	}
+	otherID, ok := args.String("other_id")
+	if !ok || otherID == "" {
+		return d.errorResponse("Missing or invalid 'other_id' parameter"), nil
+	}
 
 	doc, err := d.db.GetDocument(ctx, collection, id)
 	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Failed to get document: %v", err)), nil
+		return d.errorResponse(fmt.Sprintf("Failed to get document %s: %v", id, err)), nil
+	}
+	other, err := d.db.GetDocument(ctx, otherCollection, otherID)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to get document %s: %v", otherID, err)), nil
 	}
 
-	// Format document for display
-	content := []mcp.Content{
-		{
-			Type: "text",
-			Text: fmt.Sprintf("Document found:\n- ID: %s\n- Title: %s\n- Created: %s\n- Updated: %s\n- Version: %d",
-				doc.ID, doc.Title, doc.CreatedAt.Format(time.RFC3339), doc.UpdatedAt.Format(time.RFC3339), doc.Version),
-		},
-		{
-			Type: "text",
-			Text: fmt.Sprintf("Content:\n%s", doc.Content),
-		},
+	changes := diffDocuments(doc, other)
+	if len(changes) == 0 {
+		return &mcp.ToolCallResponse{
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: fmt.Sprintf("Documents %s and %s are identical in title, content, category, tags and metadata", id, otherID),
+				},
+			},
+			StructuredContent: map[string]interface{}{"identical": true, "changes": changes},
+		}, nil
 	}
 
-	if len(doc.Tags) > 0 {
+	lines := []string{fmt.Sprintf("Documents %s and %s differ in %d fields:", id, otherID, len(changes))}
+	var contentDiff string
+	for _, change := range changes {
+		switch {
+		case change.Diff != "":
+			contentDiff = change.Diff
+			lines = append(lines, fmt.Sprintf("- %s: changed (line diff below)", change.Field))
+		case change.Field == "tags":
+			lines = append(lines, fmt.Sprintf("- tags: added %v, removed %v", change.Added, change.Removed))
+		case change.Change == fieldAdded:
+			lines = append(lines, fmt.Sprintf("- %s: added %v", change.Field, change.New))
+		case change.Change == fieldRemoved:
+			lines = append(lines, fmt.Sprintf("- %s: removed %v", change.Field, change.Old))
+		default:
+			lines = append(lines, fmt.Sprintf("- %s: changed from %v to %v", change.Field, change.Old, change.New))
+		}
+	}
+	content := []mcp.Content{{Type: "text", Text: strings.Join(lines, "\n")}}
+	if contentDiff != "" {
 		content = append(content, mcp.Content{
 			Type: "text",
-			Text: fmt.Sprintf("Tags: %v", doc.Tags),
+			Text: fmt.Sprintf("Content diff:\n```diff\n%s```", contentDiff),
 		})
 	}
 
-	// Add JSON data
-	jsonData, _ := json.Marshal(doc)
-	content = append(content, mcp.Content{
-		Type: "text",
-		Text: fmt.Sprintf("Raw JSON:\n```json\n%s\n```", string(jsonData)),
-	})
-
 	return &mcp.ToolCallResponse{
-		Content: content,
+		Content:           content,
+		StructuredContent: map[string]interface{}{"identical": false, "changes": changes},
 	}, nil
 }
 
-func (d *DatabaseTool) updateDocument(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
+// exportCollection exports a collection into a resource and returns its URI
+func (d *DatabaseTool) exportCollection(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
-
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	if d.exports == nil {
+		return d.errorResponse("Collection exports are not enabled on this server"), nil
 	}
 
-	// Get existing document
-	doc, err := d.db.GetDocument(ctx, collection, id)
+	export, err := d.exports.create(ctx, collection)
 	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Failed to find document: %v", err)), nil
+		return d.errorResponse(fmt.Sprintf("Failed to export collection '%s': %v", collection, err)), nil
 	}
 
-	// Update fields if provided
-	if title, ok := args["title"].(string); ok && title != "" {
-		doc.Title = title
+	text := fmt.Sprintf("Exported %d documents (%d bytes) from collection '%s' to %s. Read it with resources/read",
+		export.documents, len(export.data), collection, export.uri)
+	structured := map[string]interface{}{
+		"uri":       export.uri,
+		"documents": export.documents,
+		"bytes":     len(export.data),
+	}
+	if !export.expiresAt.IsZero() {
+		text += fmt.Sprintf(" before it expires at %s", export.expiresAt.Format(time.RFC3339))
+		structured["expires_at"] = export.expiresAt.Format(time.RFC3339)
 	}
 
-	if content, ok := args["content"].(string); ok && content != "" {
-		doc.Content = content
+	return &mcp.ToolCallResponse{
+		Content:           []mcp.Content{{Type: "text", Text: text + "."}},
+		StructuredContent: structured,
+	}, nil
+}
+
+func (d *DatabaseTool) ensureTextIndex(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
 
-	if tagsInterface, ok := args["tags"]; ok {
-		if tagsSlice, ok := tagsInterface.([]interface{}); ok {
-			tags := make([]string, len(tagsSlice))
-			for i, tag := range tagsSlice {
-				if tagStr, ok := tag.(string); ok {
-					tags[i] = tagStr
-				}
-			}
-			doc.Tags = tags
-		}
+	if err := d.db.EnsureTextIndex(ctx, collection); err != nil {
+		return d.errorResponse(fmt.Sprintf("Failed to ensure text index: %v", err)), nil
 	}
 
-	if metadata, ok := args["metadata"].(map[string]interface{}); ok {
-		doc.Metadata = metadata
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Collection '%s' has a text index and can be searched with db_search_documents", collection),
+			},
+		},
+	}, nil
+}
+
+// listIndexes describes the indexes of a collection, one line per index
+func (d *DatabaseTool) listIndexes(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
 
-	err = d.db.UpdateDocument(ctx, collection, doc)
+	indexes, err := d.db.ListIndexes(ctx, collection)
 	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Failed to update document: %v", err)), nil
+		return d.errorResponse(fmt.Sprintf("Failed to list indexes: %v", err)), nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Collection '%s' has %d indexes", collection, len(indexes))
+	for _, index := range indexes {
+		var keys []string
+		if list, ok := index["keys"].([]map[string]interface{}); ok {
+			for _, key := range list {
+				keys = append(keys, fmt.Sprintf("%v: %v", key["field"], key["type"]))
+			}
+		}
+		fmt.Fprintf(&text, "\n- %v (%s)", index["name"], strings.Join(keys, ", "))
+		if ttl, _ := index["ttl"].(bool); ttl {
+			fmt.Fprintf(&text, ", TTL %vs", index["expire_after_seconds"])
+		}
+		if unique, _ := index["unique"].(bool); unique {
+			text.WriteString(", unique")
+		}
 	}
 
+	jsonData, _ := mcp.FormatJSON(indexes, d.prettyJSON)
 	return &mcp.ToolCallResponse{
 		Content: []mcp.Content{
 			{
 				Type: "text",
-				Text: fmt.Sprintf("Document updated successfully. New version: %d", doc.Version),
+				Text: text.String(),
+			},
+			{
+				Type: "text",
+				Text: string(jsonData),
 			},
 		},
 	}, nil
 }
 
-func (d *DatabaseTool) deleteDocument(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
+func (d *DatabaseTool) countDocuments(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
 
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return d.errorResponse("Missing or invalid 'id' parameter"), nil
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+	if filter == nil {
+		filter = make(map[string]interface{})
 	}
 
-	err := d.db.DeleteDocument(ctx, collection, id)
+	count, err := d.db.CountDocuments(ctx, collection, filter)
 	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Failed to delete document: %v", err)), nil
+		return d.errorResponse(fmt.Sprintf("Count failed: %v", err)), nil
 	}
 
 	return &mcp.ToolCallResponse{
 		Content: []mcp.Content{
 			{
 				Type: "text",
-				Text: fmt.Sprintf("Document with ID %s deleted successfully from collection %s", id, collection),
+				Text: fmt.Sprintf("Collection '%s' contains %d documents matching the filter", collection, count),
 			},
 		},
+		StructuredContent: map[string]interface{}{
+			"collection": collection,
+			"count":      count,
+		},
 	}, nil
 }
 
-func (d *DatabaseTool) queryDocuments(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
+func (d *DatabaseTool) fieldStats(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
 
-	query := mcp.DatabaseQuery{
-		Collection: collection,
-		Limit:      10, // default
+	field, ok := args.String("field")
+	if !ok || field == "" {
+		return d.errorResponse("Missing or invalid 'field' parameter"), nil
 	}
 
-	if filter, ok := args["filter"].(map[string]interface{}); ok {
-		query.Filter = filter
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
-
-	if sort, ok := args["sort"].(map[string]interface{}); ok {
-		query.Sort = sort
+	if filter == nil {
+		filter = make(map[string]interface{})
 	}
 
-	if limit, ok := args["limit"]; ok {
-		if l, err := d.toInt(limit); err == nil && l > 0 && l <= 100 {
-			query.Limit = l
-		}
+	stats, err := d.db.FieldStats(ctx, collection, field, filter)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Field stats failed: %v", err)), nil
 	}
 
-	if skip, ok := args["skip"]; ok {
-		if s, err := d.toInt(skip); err == nil && s >= 0 {
-			query.Skip = s
-		}
+	var text string
+	if stats.Count == 0 {
+		text = fmt.Sprintf("No numeric values for '%s' in %d matching documents of collection '%s'",
+			field, stats.Matched, collection)
+	} else {
+		text = fmt.Sprintf("Stats for '%s' in collection '%s' (%d of %d matching documents have a numeric value):\n",
+			field, collection, stats.Count, stats.Matched)
+		text += fmt.Sprintf("Sum: %g\nAvg: %g\nMin: %g\nMax: %g", stats.Sum, stats.Avg, stats.Min, stats.Max)
 	}
 
-	docs, err := d.db.QueryDocuments(ctx, query)
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+		StructuredContent: stats,
+	}, nil
+}
+
+// distinctCount counts the distinct values of a field
+func (d *DatabaseTool) distinctCount(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
 	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Query failed: %v", err)), nil
+		return d.errorResponse(err.Error()), nil
 	}
 
-	content := []mcp.Content{
-		{
-			Type: "text",
-			Text: fmt.Sprintf("Found %d documents in collection '%s'", len(docs), collection),
-		},
+	field, ok := args.String("field")
+	if !ok || field == "" {
+		return d.errorResponse("Missing or invalid 'field' parameter"), nil
 	}
 
-	for i, doc := range docs {
-		content = append(content, mcp.Content{
-			Type: "text",
-			Text: fmt.Sprintf("%d. **%s** (ID: %s)\n   Created: %s\n   Content preview: %s...",
-				i+1, doc.Title, doc.ID, doc.CreatedAt.Format(time.RFC3339),
-				d.truncateString(doc.Content, 100)),
-		})
+	filter, err := d.filterArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
+	}
+	if filter == nil {
+		filter = make(map[string]interface{})
 	}
 
-	// Add JSON data
-	jsonData, _ := json.Marshal(docs)
-	content = append(content, mcp.Content{
-		Type: "text",
-		Text: fmt.Sprintf("Raw JSON:\n```json\n%s\n```", string(jsonData)),
-	})
+	count, err := d.db.DistinctCount(ctx, collection, field, filter)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Distinct count failed: %v", err)), nil
+	}
 
 	return &mcp.ToolCallResponse{
-		Content: content,
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Found %d distinct values of '%s' in collection '%s'", count, field, collection),
+			},
+		},
+		StructuredContent: map[string]interface{}{
+			"collection": collection,
+			"field":      field,
+			"count":      count,
+		},
 	}, nil
 }
 
-func (d *DatabaseTool) searchDocuments(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
+// timeHistogram counts documents per time bucket of a date field
+func (d *DatabaseTool) timeHistogram(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	collection, err := d.collectionArg(args)
+	if err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
 
-	searchText, ok := args["search_text"].(string)
-	if !ok || searchText == "" {
-		return d.errorResponse("Missing or invalid 'search_text' parameter"), nil
+	field := "created_at"
+	if args.Has("field") {
+		value, ok := args.String("field")
+		if !ok || value == "" {
+			return d.errorResponse("Invalid 'field' parameter"), nil
+		}
+		field = value
 	}
 
-	limit := 10 // default
-	if l, ok := args["limit"]; ok {
-		if parsed, err := d.toInt(l); err == nil && parsed > 0 && parsed <= 50 {
-			limit = parsed
+	bucket := database.TimeBucketDay
+	if args.Has("bucket") {
+		value, ok := args.String("bucket")
+		if !ok {
+			return d.errorResponse("Invalid 'bucket' parameter"), nil
 		}
+		bucket = value
 	}
-
-	docs, err := d.db.SearchDocuments(ctx, collection, searchText, limit)
-	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Search failed: %v", err)), nil
+	if err := database.ValidateTimeBucket(bucket); err != nil {
+		return d.errorResponse(err.Error()), nil
 	}
 
-	content := []mcp.Content{
-		{
-			Type: "text",
-			Text: fmt.Sprintf("Found %d documents matching '%s' in collection '%s'", len(docs), searchText, collection),
-		},
+	histogram, err := d.db.TimeHistogram(ctx, collection, field, bucket)
+	if err != nil {
+		return d.errorResponse(fmt.Sprintf("Time histogram failed: %v", err)), nil
 	}
 
-	for i, doc := range docs {
-		content = append(content, mcp.Content{
-			Type: "text",
-			Text: fmt.Sprintf("%d. **%s** (ID: %s)\n   Created: %s\n   Content preview: %s...",
-				i+1, doc.Title, doc.ID, doc.CreatedAt.Format(time.RFC3339),
-				d.truncateString(doc.Content, 100)),
-		})
+	var text string
+	if len(histogram.Counts) == 0 {
+		text = fmt.Sprintf("No documents with a date in '%s' in collection '%s'", field, collection)
+	} else {
+		text = fmt.Sprintf("Documents per %s of '%s' in collection '%s':\n", bucket, field, collection)
+		for _, count := range histogram.Counts {
+			text += fmt.Sprintf("%s: %d\n", count.Bucket.Format("2006-01-02"), count.Count)
+		}
 	}
 
 	return &mcp.ToolCallResponse{
-		Content: content,
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+		StructuredContent: histogram,
 	}, nil
 }
 
-func (d *DatabaseTool) countDocuments(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
-	collection, ok := args["collection"].(string)
-	if !ok || collection == "" {
-		return d.errorResponse("Missing or invalid 'collection' parameter"), nil
+// validateFilter reports whether a filter is well-formed and safe, without
+// touching the database
+func (d *DatabaseTool) validateFilter(args mcp.Args) (*mcp.ToolCallResponse, error) {
+	if args["filter"] == nil {
+		return d.errorResponse("Missing 'filter' parameter"), nil
 	}
 
-	filter := make(map[string]interface{})
-	if f, ok := args["filter"].(map[string]interface{}); ok {
-		filter = f
+	var check database.FilterCheck
+	if filter, ok := args.Map("filter"); ok {
+		check = d.db.CheckFilter(filter)
+	} else {
+		check = database.FilterCheck{RejectedOperators: []string{}, Problems: []string{"filter must be an object"}}
 	}
 
-	count, err := d.db.CountDocuments(ctx, collection, filter)
-	if err != nil {
-		return d.errorResponse(fmt.Sprintf("Count failed: %v", err)), nil
+	text := "Filter is valid"
+	if !check.Valid() {
+		var b strings.Builder
+		b.WriteString("Filter is invalid:")
+		for _, operator := range check.RejectedOperators {
+			fmt.Fprintf(&b, "\n- operator %s is not allowed", operator)
+		}
+		for _, problem := range check.Problems {
+			fmt.Fprintf(&b, "\n- %s", problem)
+		}
+		text = b.String()
 	}
 
 	return &mcp.ToolCallResponse{
 		Content: []mcp.Content{
 			{
 				Type: "text",
-				Text: fmt.Sprintf("Collection '%s' contains %d documents matching the filter", collection, count),
+				Text: text,
 			},
 		},
+		StructuredContent: map[string]interface{}{
+			"valid":              check.Valid(),
+			"rejected_operators": check.RejectedOperators,
+			"problems":           check.Problems,
+		},
 	}, nil
 }
 
@@ -600,22 +2304,237 @@ func (d *DatabaseTool) healthCheck(ctx context.Context) (*mcp.ToolCallResponse,
 	}, nil
 }
 
+// Complete suggests existing collection names for "collection" arguments and
+// distinct category values for "category" arguments. Categories come from the
+// collection already chosen in the request context, or from every collection
+// when none is.
+func (d *DatabaseTool) Complete(ctx context.Context, request mcp.CompleteRequest) ([]string, error) {
+	switch request.Argument.Name {
+	case "collection":
+		collections, err := d.db.ListCollections(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return matchPrefix(allowedCollections(ctx, collections), request.Argument.Value), nil
+	case "category":
+		collections, err := d.completionCollections(ctx, request.Context)
+		if err != nil {
+			return nil, err
+		}
+		var categories []string
+		seen := make(map[string]bool)
+		for _, collection := range collections {
+			values, err := d.db.DistinctValues(ctx, collection, "category")
+			if err != nil {
+				return nil, err
+			}
+			for _, value := range values {
+				if category, ok := value.(string); ok && category != "" && !seen[category] {
+					seen[category] = true
+					categories = append(categories, category)
+				}
+			}
+		}
+		return matchPrefix(categories, request.Argument.Value), nil
+	default:
+		return nil, nil
+	}
+}
+
+// completionCollections returns the collection named in the completion context,
+// or every collection when none (or an invalid one) is given, leaving out
+// those the client may not access
+func (d *DatabaseTool) completionCollections(ctx context.Context, completion *mcp.CompletionContext) ([]string, error) {
+	if completion != nil {
+		if collection := completion.Arguments["collection"]; collection != "" && d.db.ValidateCollectionName(collection) == nil {
+			return allowedCollections(ctx, []string{collection}), nil
+		}
+	}
+	collections, err := d.db.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return allowedCollections(ctx, collections), nil
+}
+
+// allowedCollections returns the collections the client calling with ctx may access
+func allowedCollections(ctx context.Context, collections []string) []string {
+	allowed := []string{}
+	for _, collection := range collections {
+		if mcp.CollectionAllowed(ctx, collection) {
+			allowed = append(allowed, collection)
+		}
+	}
+	return allowed
+}
+
 // Helper methods
 
-func (d *DatabaseTool) toInt(value interface{}) (int, error) {
-	switch v := value.(type) {
-	case int:
-		return v, nil
-	case float64:
-		return int(v), nil
-	case string:
-		return strconv.Atoi(v)
-	case json.Number:
-		i, err := v.Int64()
-		return int(i), err
-	default:
-		return 0, fmt.Errorf("cannot convert %T to int", value)
+// matchPrefix returns the values starting with prefix, ignoring case
+func matchPrefix(values []string, prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	matches := []string{}
+	for _, value := range values {
+		if strings.HasPrefix(strings.ToLower(value), prefix) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+// maxRelatedCandidates bounds the documents db_related_documents ranks
+const maxRelatedCandidates = 500
+
+// relatedDocument is a document ranked by its overlap with a source document
+type relatedDocument struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	Category     string   `json:"category,omitempty"`
+	SharedTags   []string `json:"shared_tags"`
+	SameCategory bool     `json:"same_category"`
+}
+
+// rankRelatedDocuments orders candidates by the number of tags shared with
+// source, then by whether they share its category, and returns the top limit.
+// The source itself and candidates sharing nothing are dropped.
+func rankRelatedDocuments(source *mcp.Document, candidates []*mcp.Document, limit int) []relatedDocument {
+	sourceTags := make(map[string]bool, len(source.Tags))
+	for _, tag := range source.Tags {
+		sourceTags[tag] = true
+	}
+
+	related := []relatedDocument{}
+	for _, doc := range candidates {
+		if doc.ID == source.ID {
+			continue
+		}
+
+		shared := []string{}
+		seen := make(map[string]bool, len(doc.Tags))
+		for _, tag := range doc.Tags {
+			if sourceTags[tag] && !seen[tag] {
+				shared = append(shared, tag)
+				seen[tag] = true
+			}
+		}
+		sameCategory := source.Category != "" && doc.Category == source.Category
+
+		if len(shared) == 0 && !sameCategory {
+			continue
+		}
+		related = append(related, relatedDocument{
+			ID:           doc.ID,
+			Title:        doc.Title,
+			Category:     doc.Category,
+			SharedTags:   shared,
+			SameCategory: sameCategory,
+		})
+	}
+
+	sort.SliceStable(related, func(i, j int) bool {
+		if len(related[i].SharedTags) != len(related[j].SharedTags) {
+			return len(related[i].SharedTags) > len(related[j].SharedTags)
+		}
+		if related[i].SameCategory != related[j].SameCategory {
+			return related[i].SameCategory
+		}
+		return related[i].Title < related[j].Title
+	})
+
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related
+}
+
+// collectionArg extracts the 'collection' argument and checks it against MongoDB's naming rules
+func (d *DatabaseTool) collectionArg(args mcp.Args) (string, error) {
+	collection, ok := args.String("collection")
+	if !ok || collection == "" {
+		return "", errors.New("Missing or invalid 'collection' parameter")
+	}
+	if err := d.db.ValidateCollectionName(collection); err != nil {
+		return "", fmt.Errorf("Invalid 'collection' parameter: %v", err)
+	}
+	return collection, nil
+}
+
+// validateDocument checks doc against the store's limits and the schema of
+// its collection, if it has one
+func (d *DatabaseTool) validateDocument(collection string, doc *mcp.Document) error {
+	if err := d.db.ValidateDocument(doc); err != nil {
+		return err
 	}
+	if schema, ok := d.schemas[collection]; ok {
+		return schema.Validate(doc)
+	}
+	return nil
+}
+
+// filterArg extracts the optional 'filter' argument, rejecting filters that
+// are not objects or that the store's CheckFilter finds unsafe, not allowed
+// or malformed. Its values are coerced when SetCoerceFilterValues is on.
+func (d *DatabaseTool) filterArg(args mcp.Args) (map[string]interface{}, error) {
+	if args["filter"] == nil {
+		return nil, nil
+	}
+	filter, ok := args.Map("filter")
+	if !ok {
+		return nil, errors.New("Invalid 'filter' parameter: must be an object")
+	}
+	if err := d.db.CheckFilter(filter).Err(); err != nil {
+		return nil, fmt.Errorf("Invalid 'filter' parameter: %v", err)
+	}
+	if d.coerceFilters {
+		filter = coerceFilterValues(filter)
+	}
+	return filter, nil
+}
+
+// parseOrderedSort converts [{"field": ..., "dir": ...}] into sort fields, keeping their order
+func (d *DatabaseTool) parseOrderedSort(spec []interface{}) ([]mcp.SortField, error) {
+	fields := make([]mcp.SortField, 0, len(spec))
+	for i, entry := range spec {
+		item, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("entry %d must be an object with 'field' and 'dir'", i)
+		}
+
+		field, ok := item["field"].(string)
+		if !ok || field == "" {
+			return nil, fmt.Errorf("entry %d is missing 'field'", i)
+		}
+
+		direction := 1
+		if dir, ok := item["dir"]; ok {
+			parsed, err := d.parseSortDirection(dir)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d (%s): %v", i, field, err)
+			}
+			direction = parsed
+		}
+
+		fields = append(fields, mcp.SortField{Field: field, Direction: direction})
+	}
+	return fields, nil
+}
+
+// parseSortDirection accepts 1/-1 as well as asc/desc (and ascending/descending)
+func (d *DatabaseTool) parseSortDirection(value interface{}) (int, error) {
+	if str, ok := value.(string); ok {
+		switch strings.ToLower(strings.TrimSpace(str)) {
+		case "asc", "ascending":
+			return 1, nil
+		case "desc", "descending":
+			return -1, nil
+		}
+	}
+
+	n, ok := mcp.ToInt(value)
+	if !ok || (n != 1 && n != -1) {
+		return 0, fmt.Errorf("direction must be asc, desc, 1 or -1, got %v", value)
+	}
+	return n, nil
 }
 
 func (d *DatabaseTool) truncateString(s string, maxLen int) string {
@@ -626,13 +2545,11 @@ func (d *DatabaseTool) truncateString(s string, maxLen int) string {
 }
 
 func (d *DatabaseTool) errorResponse(message string) *mcp.ToolCallResponse {
-	return &mcp.ToolCallResponse{
-		IsError: true,
-		Content: []mcp.Content{
-			{
-				Type: "text",
-				Text: message,
-			},
-		},
-	}
+	return mcp.NewToolError(message, nil)
+}
+
+// accessDenied is the error response to a call naming a collection the client
+// may not access
+func (d *DatabaseTool) accessDenied(collection string) *mcp.ToolCallResponse {
+	return d.errorResponse(fmt.Sprintf("Access denied to collection '%s'", collection))
 }
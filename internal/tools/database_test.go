@@ -2,7 +2,14 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kringen/go-mcp-server/internal/database"
 	"github.com/kringen/go-mcp-server/pkg/mcp"
@@ -15,13 +22,36 @@ type MockMongoDB struct {
 	documents map[string]*mcp.Document
 	healthy   bool
 	err       error
+
+	// missingTextIndex makes SearchDocuments fail until EnsureTextIndex is called
+	lastQuery        mcp.DatabaseQuery
+	lastSearchFilter map[string]interface{}
+	missingTextIndex bool
+	ensureIndexErr   error
+	ensureIndexCalls int
+
+	// substringSearches counts SubstringSearch calls
+	substringSearches int
+
+	// collections is what ListCollections reports
+	collections []string
+
+	// targets holds the documents of move target collections, keyed by collection then ID
+	targets map[string]map[string]*mcp.Document
+
+	// dropped lists the collections passed to DropCollection
+	dropped []string
+
+	// idGenerator assigns IDs to documents created without one, like database.Config.IDGenerator
+	idGenerator func() string
 }
 
 func NewMockMongoDB(healthy bool, err error) *MockMongoDB {
 	return &MockMongoDB{
-		documents: make(map[string]*mcp.Document),
-		healthy:   healthy,
-		err:       err,
+		documents:   make(map[string]*mcp.Document),
+		healthy:     healthy,
+		err:         err,
+		idGenerator: database.ObjectIDHex,
 	}
 }
 
@@ -30,7 +60,10 @@ func (m *MockMongoDB) CreateDocument(ctx context.Context, collection string, doc
 		return m.err
 	}
 	if doc.ID == "" {
-		doc.ID = "mock-id-123"
+		doc.ID = m.idGenerator()
+	}
+	if _, exists := m.documents[doc.ID]; exists {
+		return fmt.Errorf("document %s: %w", doc.ID, database.ErrDuplicateID)
 	}
 	m.documents[doc.ID] = doc
 	return nil
@@ -47,6 +80,29 @@ func (m *MockMongoDB) GetDocument(ctx context.Context, collection, id string) (*
 	return doc, nil
 }
 
+func (m *MockMongoDB) GetDocuments(ctx context.Context, collection string, ids []string) ([]*mcp.Document, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var docs []*mcp.Document
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if doc, ok := m.documents[id]; ok && !seen[id] {
+			docs = append(docs, doc)
+			seen[id] = true
+		}
+	}
+	return docs, nil
+}
+
+func (m *MockMongoDB) DocumentExists(ctx context.Context, collection, id string) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	_, exists := m.documents[id]
+	return exists, nil
+}
+
 func (m *MockMongoDB) UpdateDocument(ctx context.Context, collection string, doc *mcp.Document) error {
 	if m.err != nil {
 		return m.err
@@ -69,13 +125,44 @@ func (m *MockMongoDB) DeleteDocument(ctx context.Context, collection, id string)
 	return nil
 }
 
+// ExplainQuery reports an index scan on "title_1" when the filter names title,
+// and a collection scan otherwise
+func (m *MockMongoDB) ExplainQuery(ctx context.Context, query mcp.DatabaseQuery) (map[string]interface{}, error) {
+	m.lastQuery = query
+	if m.err != nil {
+		return nil, m.err
+	}
+	summary := map[string]interface{}{
+		"collection_scan":   true,
+		"index":             nil,
+		"stages":            "COLLSCAN",
+		"docs_examined":     int64(len(m.documents)),
+		"keys_examined":     int64(0),
+		"docs_returned":     int64(0),
+		"execution_time_ms": int64(0),
+	}
+	if _, ok := query.Filter["title"]; ok {
+		summary["collection_scan"] = false
+		summary["index"] = "title_1"
+		summary["stages"] = "FETCH > IXSCAN"
+		summary["docs_examined"] = int64(1)
+		summary["keys_examined"] = int64(1)
+		summary["docs_returned"] = int64(1)
+	}
+	return summary, nil
+}
+
 func (m *MockMongoDB) QueryDocuments(ctx context.Context, query mcp.DatabaseQuery) ([]*mcp.Document, error) {
+	m.lastQuery = query
 	if m.err != nil {
 		return nil, m.err
 	}
 	
 	var results []*mcp.Document
 	for _, doc := range m.documents {
+		if !matchesTags(doc, query.Filter["tags"]) || !matchesTitle(doc, query.Filter["title"]) {
+			continue
+		}
 		results = append(results, doc)
 		if query.Limit > 0 && len(results) >= query.Limit {
 			break
@@ -84,14 +171,21 @@ func (m *MockMongoDB) QueryDocuments(ctx context.Context, query mcp.DatabaseQuer
 	return results, nil
 }
 
-func (m *MockMongoDB) SearchDocuments(ctx context.Context, collection, searchText string, limit int) ([]*mcp.Document, error) {
+func (m *MockMongoDB) SearchDocuments(ctx context.Context, collection, searchText string, filter map[string]interface{}, limit int) ([]*mcp.Document, error) {
+	m.lastSearchFilter = filter
 	if m.err != nil {
 		return nil, m.err
 	}
+	if m.missingTextIndex {
+		return nil, fmt.Errorf("collection %s: %w", collection, database.ErrTextIndexRequired)
+	}
 	
 	var results []*mcp.Document
 	for _, doc := range m.documents {
 		// Simple mock search - just check if searchText is in title or content
+		if category, ok := filter["category"].(string); ok && doc.Category != category {
+			continue
+		}
 		if searchText == "" || 
 		   containsIgnoreCase(doc.Title, searchText) || 
 		   containsIgnoreCase(doc.Content, searchText) {
@@ -104,6 +198,38 @@ func (m *MockMongoDB) SearchDocuments(ctx context.Context, collection, searchTex
 	return results, nil
 }
 
+func (m *MockMongoDB) SubstringSearch(ctx context.Context, collection, searchText string, filter map[string]interface{}, limit int) ([]*mcp.Document, error) {
+	m.substringSearches++
+	m.lastSearchFilter = filter
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	var results []*mcp.Document
+	for _, doc := range m.documents {
+		if category, ok := filter["category"].(string); ok && doc.Category != category {
+			continue
+		}
+		text := strings.ToLower(searchText)
+		if strings.Contains(strings.ToLower(doc.Title), text) || strings.Contains(strings.ToLower(doc.Content), text) {
+			results = append(results, doc)
+			if limit > 0 && len(results) >= limit {
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+func (m *MockMongoDB) DropCollection(ctx context.Context, collection string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.dropped = append(m.dropped, collection)
+	m.documents = make(map[string]*mcp.Document)
+	return nil
+}
+
 func (m *MockMongoDB) CountDocuments(ctx context.Context, collection string, filter map[string]interface{}) (int64, error) {
 	if m.err != nil {
 		return 0, m.err
@@ -111,6 +237,57 @@ func (m *MockMongoDB) CountDocuments(ctx context.Context, collection string, fil
 	return int64(len(m.documents)), nil
 }
 
+func (m *MockMongoDB) EnsureTextIndex(ctx context.Context, collection string) error {
+	m.ensureIndexCalls++
+	if m.ensureIndexErr != nil {
+		return m.ensureIndexErr
+	}
+	m.missingTextIndex = false
+	return nil
+}
+
+// ListIndexes reports the _id index, and a text index on title and content
+// unless missingTextIndex is set
+func (m *MockMongoDB) ListIndexes(ctx context.Context, collection string) ([]map[string]interface{}, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	indexes := []map[string]interface{}{
+		{
+			"name":   "_id_",
+			"keys":   []map[string]interface{}{{"field": "_id", "type": int64(1)}},
+			"text":   false,
+			"ttl":    false,
+			"unique": false,
+		},
+	}
+	if !m.missingTextIndex {
+		indexes = append(indexes, map[string]interface{}{
+			"name": "title_text_content_text",
+			"keys": []map[string]interface{}{
+				{"field": "content", "type": "text"},
+				{"field": "title", "type": "text"},
+			},
+			"text":   true,
+			"ttl":    false,
+			"unique": false,
+		})
+	}
+	return indexes, nil
+}
+
+func (m *MockMongoDB) ValidateCollectionName(name string) error {
+	return database.ValidateCollectionName(name, 120)
+}
+
+func (m *MockMongoDB) ValidateDocument(doc *mcp.Document) error {
+	return database.DefaultConfig().ValidateDocument(doc)
+}
+
+func (m *MockMongoDB) CheckFilter(filter map[string]interface{}) database.FilterCheck {
+	return database.DefaultConfig().CheckFilter(filter)
+}
+
 func (m *MockMongoDB) HealthCheck(ctx context.Context) error {
 	if !m.healthy {
 		return assert.AnError
@@ -118,6 +295,260 @@ func (m *MockMongoDB) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
+// UpdateMany supports top-level equality filters on category and tags, and setting category or title
+func (m *MockMongoDB) UpdateMany(ctx context.Context, collection string, filter, fields map[string]interface{}) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if err := database.ValidateFilter(filter); err != nil {
+		return 0, err
+	}
+	if err := database.DefaultConfig().ValidateSetFields(fields); err != nil {
+		return 0, err
+	}
+
+	var modified int64
+	for _, doc := range m.documents {
+		if category, ok := filter["category"]; ok && doc.Category != category {
+			continue
+		}
+		if tag, ok := filter["tags"].(string); ok && !containsString(doc.Tags, tag) {
+			continue
+		}
+		if category, ok := fields["category"].(string); ok {
+			doc.Category = category
+		}
+		if title, ok := fields["title"].(string); ok {
+			doc.Title = title
+		}
+		doc.Version++
+		modified++
+	}
+	return modified, nil
+}
+
+func (m *MockMongoDB) FieldStats(ctx context.Context, collection, field string, filter map[string]interface{}) (*mcp.FieldStats, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if err := database.ValidateFieldPath(field); err != nil {
+		return nil, err
+	}
+
+	stats := &mcp.FieldStats{Field: field}
+	for _, doc := range m.documents {
+		if category, ok := filter["category"]; ok && doc.Category != category {
+			continue
+		}
+		stats.Matched++
+
+		var value interface{}
+		if field == "version" {
+			value = doc.Version
+		} else if key := strings.TrimPrefix(field, "metadata."); key != field {
+			value = doc.Metadata[key]
+		}
+		var n float64
+		switch v := value.(type) {
+		case int:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			continue
+		}
+
+		if stats.Count == 0 || n < stats.Min {
+			stats.Min = n
+		}
+		if stats.Count == 0 || n > stats.Max {
+			stats.Max = n
+		}
+		stats.Count++
+		stats.Sum += n
+	}
+	if stats.Count > 0 {
+		stats.Avg = stats.Sum / float64(stats.Count)
+	}
+	return stats, nil
+}
+
+func (m *MockMongoDB) DistinctCount(ctx context.Context, collection, field string, filter map[string]interface{}) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if err := database.ValidateFieldPath(field); err != nil {
+		return 0, err
+	}
+
+	seen := make(map[interface{}]bool)
+	for _, doc := range m.documents {
+		if category, ok := filter["category"]; ok && doc.Category != category {
+			continue
+		}
+		switch field {
+		case "category":
+			if doc.Category != "" {
+				seen[doc.Category] = true
+			}
+		case "tags":
+			for _, tag := range doc.Tags {
+				seen[tag] = true
+			}
+		}
+	}
+	return int64(len(seen)), nil
+}
+
+func (m *MockMongoDB) TimeHistogram(ctx context.Context, collection, field, bucket string) (*mcp.TimeHistogram, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if err := database.ValidateFieldPath(field); err != nil {
+		return nil, err
+	}
+	if err := database.ValidateTimeBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[time.Time]int64)
+	for _, doc := range m.documents {
+		var date time.Time
+		switch field {
+		case "created_at":
+			date = doc.CreatedAt
+		case "updated_at":
+			date = doc.UpdatedAt
+		}
+		if date.IsZero() {
+			continue
+		}
+		date = date.UTC()
+		day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+		switch bucket {
+		case database.TimeBucketWeek:
+			day = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		case database.TimeBucketMonth:
+			day = day.AddDate(0, 0, 1-day.Day())
+		}
+		counts[day]++
+	}
+
+	histogram := &mcp.TimeHistogram{Field: field, Bucket: bucket, Counts: []mcp.TimeBucketCount{}}
+	for start, count := range counts {
+		histogram.Counts = append(histogram.Counts, mcp.TimeBucketCount{Bucket: start, Count: count})
+	}
+	sort.Slice(histogram.Counts, func(i, j int) bool {
+		return histogram.Counts[i].Bucket.Before(histogram.Counts[j].Bucket)
+	})
+	return histogram, nil
+}
+
+// matchesTags applies a {"$in": [...]} or {"$all": [...]} condition on tags
+func matchesTags(doc *mcp.Document, condition interface{}) bool {
+	spec, ok := condition.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	if tags, ok := spec["$in"].([]string); ok {
+		for _, tag := range tags {
+			if containsString(doc.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	if tags, ok := spec["$all"].([]string); ok {
+		for _, tag := range tags {
+			if !containsString(doc.Tags, tag) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesTitle applies a {"$regex": ..., "$options": "i"} title condition
+func matchesTitle(doc *mcp.Document, condition interface{}) bool {
+	spec, ok := condition.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	pattern, ok := spec["$regex"].(string)
+	if !ok {
+		return true
+	}
+	if spec["$options"] == "i" {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.MustCompile(pattern).MatchString(doc.Title)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockMongoDB) MoveDocument(ctx context.Context, from, to, id string) error {
+	if m.err != nil {
+		return m.err
+	}
+	doc, exists := m.documents[id]
+	if !exists {
+		return fmt.Errorf("document not found")
+	}
+	if m.targets == nil {
+		m.targets = make(map[string]map[string]*mcp.Document)
+	}
+	if m.targets[to] == nil {
+		m.targets[to] = make(map[string]*mcp.Document)
+	}
+	if _, exists := m.targets[to][id]; exists {
+		return fmt.Errorf("document %s in collection %s: %w", id, to, database.ErrDuplicateID)
+	}
+	doc.Version++
+	m.targets[to][id] = doc
+	delete(m.documents, id)
+	return nil
+}
+
+func (m *MockMongoDB) ListCollections(ctx context.Context) ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.collections, nil
+}
+
+func (m *MockMongoDB) DistinctValues(ctx context.Context, collection, field string) ([]interface{}, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	seen := make(map[interface{}]bool)
+	var values []interface{}
+	add := func(value interface{}) {
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	for _, doc := range m.documents {
+		switch field {
+		case "category":
+			add(doc.Category)
+		case "tags":
+			for _, tag := range doc.Tags {
+				add(tag)
+			}
+		}
+	}
+	return values, nil
+}
+
 func (m *MockMongoDB) Close(ctx context.Context) error {
 	return nil
 }
@@ -139,11 +570,29 @@ func TestDatabaseTool(t *testing.T) {
 		expectedTools := []string{
 			"db_create_document",
 			"db_get_document", 
+			"db_get_documents",
+			"db_document_exists",
 			"db_update_document",
+			"db_update_many",
 			"db_delete_document",
+			"db_clear_collection",
+			"db_move_document",
 			"db_query_documents",
+			"db_explain_query",
+			"db_find_by_tags",
 			"db_search_documents",
+			"db_related_documents",
+			"db_find_by_title",
+			"db_recent_documents",
+			"db_diff_documents",
+			"db_export_collection",
+			"db_ensure_text_index",
+			"db_list_indexes",
 			"db_count_documents",
+			"db_field_stats",
+			"db_distinct_count",
+			"db_time_histogram",
+			"db_validate_filter",
 			"db_health_check",
 		}
 
@@ -163,6 +612,56 @@ func TestDatabaseTool(t *testing.T) {
 		}
 	})
 
+	t.Run("ListTools_Annotations", func(t *testing.T) {
+		tool := NewDatabaseTool(NewMockMongoDB(true, nil))
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+
+		readOnly := mcp.ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true}
+		expected := map[string]mcp.ToolAnnotations{
+			"db_create_document":   {},
+			"db_get_document":      readOnly,
+			"db_get_documents":     readOnly,
+			"db_document_exists":   readOnly,
+			"db_update_document":   {DestructiveHint: true},
+			"db_update_many":       {DestructiveHint: true},
+			"db_delete_document":   {DestructiveHint: true, IdempotentHint: true},
+			"db_clear_collection":  {DestructiveHint: true, IdempotentHint: true},
+			"db_move_document":     {DestructiveHint: true},
+			"db_query_documents":   readOnly,
+			"db_explain_query":     readOnly,
+			"db_find_by_tags":      readOnly,
+			"db_search_documents":  readOnly,
+			"db_related_documents": readOnly,
+			"db_find_by_title":     readOnly,
+			"db_recent_documents":  readOnly,
+			"db_diff_documents":    readOnly,
+			"db_export_collection": {},
+			"db_ensure_text_index": {IdempotentHint: true},
+			"db_list_indexes":      readOnly,
+			"db_count_documents":   readOnly,
+			"db_field_stats":       readOnly,
+			"db_distinct_count":    readOnly,
+			"db_time_histogram":    readOnly,
+			"db_validate_filter":   readOnly,
+			"db_health_check":      readOnly,
+		}
+
+		require.Len(t, tools, len(expected))
+		for _, tool := range tools {
+			require.NotNil(t, tool.Annotations, tool.Name)
+			assert.Equal(t, expected[tool.Name], *tool.Annotations, tool.Name)
+
+			// Read-only tools may have their results cached, except the
+			// health check, whose result changes over time
+			cacheable := tool.Annotations.ReadOnlyHint && tool.Name != "db_health_check"
+			assert.Equal(t, cacheable, tool.Cacheable, tool.Name)
+
+			// Dropping a collection has to be allowlisted explicitly
+			assert.Equal(t, tool.Name == "db_clear_collection", tool.RequiresAllowlist, tool.Name)
+		}
+	})
+
 	t.Run("CallTool_CreateDocument_Success", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -186,6 +685,84 @@ func TestDatabaseTool(t *testing.T) {
 		assert.Contains(t, response.Content[0].Text, "Document created successfully")
 	})
 
+	t.Run("CallTool_CreateDocument_IDs", func(t *testing.T) {
+		create := func(t *testing.T, tool *DatabaseTool, args map[string]interface{}) *mcp.ToolCallResponse {
+			args["collection"] = "test_docs"
+			args["title"] = "Test Document"
+			args["content"] = "This is a test document"
+
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_create_document",
+				Arguments: args,
+			})
+			require.NoError(t, err)
+			return response
+		}
+
+		t.Run("AutoGenerated", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			tool := NewDatabaseTool(mockDB)
+
+			first := create(t, tool, map[string]interface{}{})
+			second := create(t, tool, map[string]interface{}{})
+			require.False(t, first.IsError)
+			require.False(t, second.IsError)
+
+			assert.Len(t, mockDB.documents, 2)
+			for id := range mockDB.documents {
+				assert.Regexp(t, `^[0-9a-f]{24}$`, id)
+			}
+		})
+
+		t.Run("UUIDGenerator", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			mockDB.idGenerator = database.UUIDv4
+			tool := NewDatabaseTool(mockDB)
+
+			response := create(t, tool, map[string]interface{}{})
+			require.False(t, response.IsError)
+
+			for id := range mockDB.documents {
+				assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+				assert.Contains(t, response.Content[0].Text, id)
+			}
+		})
+
+		t.Run("CallerProvided", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			tool := NewDatabaseTool(mockDB)
+
+			response := create(t, tool, map[string]interface{}{"id": "getting-started"})
+			require.False(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "getting-started")
+			assert.Contains(t, mockDB.documents, "getting-started")
+		})
+
+		t.Run("DuplicateRejected", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			tool := NewDatabaseTool(mockDB)
+
+			require.False(t, create(t, tool, map[string]interface{}{"id": "getting-started"}).IsError)
+
+			response := create(t, tool, map[string]interface{}{"id": "getting-started"})
+			assert.True(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "already exists")
+			assert.Len(t, mockDB.documents, 1)
+		})
+
+		t.Run("InvalidID", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			tool := NewDatabaseTool(mockDB)
+
+			for _, id := range []interface{}{"", 42} {
+				response := create(t, tool, map[string]interface{}{"id": id})
+				assert.True(t, response.IsError)
+				assert.Contains(t, response.Content[0].Text, "Invalid 'id' parameter")
+			}
+			assert.Empty(t, mockDB.documents)
+		})
+	})
+
 	t.Run("CallTool_CreateDocument_MissingParams", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -232,6 +809,66 @@ func TestDatabaseTool(t *testing.T) {
 		}
 	})
 
+	t.Run("CallTool_DocumentLimits", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		limits := database.DefaultConfig()
+
+		tooManyTags := make([]interface{}, limits.MaxTags+1)
+		for i := range tooManyTags {
+			tooManyTags[i] = fmt.Sprintf("tag-%d", i)
+		}
+
+		testCases := []struct {
+			name     string
+			args     map[string]interface{}
+			expected string
+		}{
+			{
+				name:     "TooManyTags",
+				args:     map[string]interface{}{"tags": tooManyTags},
+				expected: fmt.Sprintf("document has %d tags, more than the limit of %d", limits.MaxTags+1, limits.MaxTags),
+			},
+			{
+				name:     "TagTooLong",
+				args:     map[string]interface{}{"tags": []interface{}{"ok", strings.Repeat("t", limits.MaxTagLength+1)}},
+				expected: fmt.Sprintf("tag 2 is %d bytes long, more than the limit of %d", limits.MaxTagLength+1, limits.MaxTagLength),
+			},
+			{
+				name:     "MetadataTooLarge",
+				args:     map[string]interface{}{"metadata": map[string]interface{}{"blob": strings.Repeat("x", limits.MaxMetadataBytes)}},
+				expected: "more than the limit of 65536",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				mockDB.documents["doc-1"] = &mcp.Document{ID: "doc-1", Title: "Pods", Content: "About pods", Version: 1}
+				create := map[string]interface{}{"collection": "documents", "title": "New", "content": "New content"}
+				update := map[string]interface{}{"collection": "documents", "id": "doc-1"}
+				for key, value := range tc.args {
+					create[key] = value
+					update[key] = value
+				}
+
+				response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_create_document", Arguments: create})
+				require.NoError(t, err)
+				assert.True(t, response.IsError)
+				assert.Contains(t, response.Content[0].Text, "Invalid document: ")
+				assert.Contains(t, response.Content[0].Text, tc.expected)
+
+				response, err = tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_update_document", Arguments: update})
+				require.NoError(t, err)
+				assert.True(t, response.IsError)
+				assert.Contains(t, response.Content[0].Text, tc.expected)
+				assert.Equal(t, 1, mockDB.documents["doc-1"].Version, "document was updated")
+			})
+		}
+
+		// No document was created
+		assert.Len(t, mockDB.documents, 1)
+	})
+
 	t.Run("CallTool_GetDocument_Success", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -259,6 +896,29 @@ func TestDatabaseTool(t *testing.T) {
 		assert.Contains(t, response.Content[1].Text, "Test content")
 	})
 
+	t.Run("CallTool_GetDocument_JSONFormat", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		mockDB.documents["test-123"] = &mcp.Document{ID: "test-123", Title: "Test Doc"}
+		tool := NewDatabaseTool(mockDB)
+
+		rawJSON := func() string {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_get_document",
+				Arguments: map[string]interface{}{"collection": "test_docs", "id": "test-123"},
+			})
+			require.NoError(t, err)
+			require.False(t, response.IsError)
+			return response.Content[len(response.Content)-1].Text
+		}
+
+		compact := rawJSON()
+		assert.Contains(t, compact, `{"id":"test-123","title":"Test Doc"`)
+
+		tool.SetPrettyJSON(true)
+		pretty := rawJSON()
+		assert.Contains(t, pretty, "{\n  \"id\": \"test-123\",\n  \"title\": \"Test Doc\",")
+	})
+
 	t.Run("CallTool_GetDocument_NotFound", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -277,17 +937,66 @@ func TestDatabaseTool(t *testing.T) {
 		assert.Contains(t, response.Content[0].Text, "Failed to get document")
 	})
 
-	t.Run("CallTool_UpdateDocument_Success", func(t *testing.T) {
+	t.Run("CallTool_DocumentExists", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
-
-		// Create initial document
-		doc := &mcp.Document{
-			ID:      "test-123",
-			Title:   "Original Title",
-			Content: "Original content",
+		mockDB.documents["test-123"] = &mcp.Document{ID: "test-123", Title: "Test Doc"}
+
+		exists := func(id string) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name: "db_document_exists",
+				Arguments: map[string]interface{}{
+					"collection": "test_docs",
+					"id":         id,
+				},
+			})
+			require.NoError(t, err)
+			return response
 		}
-		mockDB.documents[doc.ID] = doc
+
+		response := exists("test-123")
+		assert.False(t, response.IsError)
+		require.Len(t, response.Content, 2)
+		assert.Contains(t, response.Content[0].Text, "exists in 'test_docs'")
+		assert.JSONEq(t, `{"id":"test-123","exists":true}`, response.Content[1].Text)
+
+		response = exists("nonexistent")
+		assert.False(t, response.IsError, "a missing document is not an error")
+		require.Len(t, response.Content, 2)
+		assert.Contains(t, response.Content[0].Text, "does not exist")
+		assert.JSONEq(t, `{"id":"nonexistent","exists":false}`, response.Content[1].Text)
+
+		response = exists("")
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Missing or invalid 'id' parameter")
+	})
+
+	t.Run("CallTool_DocumentExists_DatabaseError", func(t *testing.T) {
+		tool := NewDatabaseTool(NewMockMongoDB(true, assert.AnError))
+
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name: "db_document_exists",
+			Arguments: map[string]interface{}{
+				"collection": "test_docs",
+				"id":         "test-123",
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Failed to check document")
+	})
+
+	t.Run("CallTool_UpdateDocument_Success", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		// Create initial document
+		doc := &mcp.Document{
+			ID:      "test-123",
+			Title:   "Original Title",
+			Content: "Original content",
+		}
+		mockDB.documents[doc.ID] = doc
 
 		request := mcp.ToolCallRequest{
 			Name: "db_update_document",
@@ -336,6 +1045,155 @@ func TestDatabaseTool(t *testing.T) {
 		assert.False(t, exists)
 	})
 
+	t.Run("CallTool_UpdateMany", func(t *testing.T) {
+		newMock := func() *MockMongoDB {
+			mockDB := NewMockMongoDB(true, nil)
+			for _, doc := range []*mcp.Document{
+				{ID: "1", Title: "Pods", Category: "Kubernetes", Version: 1},
+				{ID: "2", Title: "Services", Category: "Kubernetes", Version: 1},
+				{ID: "3", Title: "Images", Category: "Docker", Version: 1},
+			} {
+				mockDB.documents[doc.ID] = doc
+			}
+			return mockDB
+		}
+		update := func(mockDB *MockMongoDB, args map[string]interface{}) *mcp.ToolCallResponse {
+			args["collection"] = "knowledgebase"
+			response, err := NewDatabaseTool(mockDB).CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_update_many",
+				Arguments: args,
+			})
+			require.NoError(t, err)
+			return response
+		}
+
+		t.Run("MultipleMatches", func(t *testing.T) {
+			mockDB := newMock()
+			response := update(mockDB, map[string]interface{}{
+				"filter": map[string]interface{}{"category": "Kubernetes"},
+				"set":    map[string]interface{}{"category": "Orchestration"},
+			})
+			require.False(t, response.IsError, response.Content[0].Text)
+			assert.Equal(t, "Modified 2 documents in collection knowledgebase", response.Content[0].Text)
+
+			assert.Equal(t, "Orchestration", mockDB.documents["1"].Category)
+			assert.Equal(t, 2, mockDB.documents["2"].Version)
+			assert.Equal(t, "Docker", mockDB.documents["3"].Category)
+			assert.Equal(t, 1, mockDB.documents["3"].Version)
+		})
+
+		t.Run("NoMatches", func(t *testing.T) {
+			mockDB := newMock()
+			response := update(mockDB, map[string]interface{}{
+				"filter": map[string]interface{}{"category": "Networking"},
+				"set":    map[string]interface{}{"category": "Orchestration"},
+			})
+			require.False(t, response.IsError)
+			assert.Equal(t, "Modified 0 documents in collection knowledgebase", response.Content[0].Text)
+		})
+
+		t.Run("Rejected", func(t *testing.T) {
+			invalid := []map[string]interface{}{
+				{"set": map[string]interface{}{"category": "x"}},
+				{"filter": map[string]interface{}{}, "set": map[string]interface{}{"category": "x"}},
+				{"filter": map[string]interface{}{"$comment": "everything"}, "set": map[string]interface{}{"category": "x"}},
+				{"filter": map[string]interface{}{"$and": []interface{}{map[string]interface{}{}}}, "set": map[string]interface{}{"category": "x"}},
+				{"filter": map[string]interface{}{"category": "Docker"}},
+				{"filter": map[string]interface{}{"category": "Docker"}, "set": map[string]interface{}{"_id": "x"}},
+				{"filter": map[string]interface{}{"category": "Docker"}, "set": map[string]interface{}{"version": 9}},
+				{"filter": map[string]interface{}{"category": "Docker"}, "set": map[string]interface{}{"$inc": map[string]interface{}{"n": 1}}},
+				{"filter": map[string]interface{}{"$where": "true"}, "set": map[string]interface{}{"category": "x"}},
+			}
+			for _, args := range invalid {
+				mockDB := newMock()
+				response := update(mockDB, args)
+				assert.True(t, response.IsError, "%v", args)
+				assert.Equal(t, "Docker", mockDB.documents["3"].Category)
+			}
+		})
+
+		t.Run("OverLimits", func(t *testing.T) {
+			tags := make([]interface{}, 51)
+			for i := range tags {
+				tags[i] = fmt.Sprintf("tag%d", i)
+			}
+			mockDB := newMock()
+			response := update(mockDB, map[string]interface{}{
+				"filter": map[string]interface{}{"category": "Docker"},
+				"set":    map[string]interface{}{"tags": tags},
+			})
+			assert.True(t, response.IsError)
+			assert.Equal(t, "Failed to update documents: document has 51 tags, more than the limit of 50", response.Content[0].Text)
+
+			response = update(mockDB, map[string]interface{}{
+				"filter": map[string]interface{}{"category": "Docker"},
+				"set":    map[string]interface{}{"metadata": map[string]interface{}{"blob": strings.Repeat("x", 64*1024)}},
+			})
+			assert.True(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "more than the limit of 65536")
+			assert.Equal(t, 1, mockDB.documents["3"].Version)
+		})
+	})
+
+	t.Run("CallTool_MoveDocument", func(t *testing.T) {
+		move := func(mockDB *MockMongoDB, args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := NewDatabaseTool(mockDB).CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_move_document",
+				Arguments: args,
+			})
+			require.NoError(t, err)
+			return response
+		}
+		args := map[string]interface{}{
+			"collection":        "drafts",
+			"target_collection": "knowledgebase",
+			"id":                "doc-1",
+		}
+
+		t.Run("Success", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			mockDB.documents["doc-1"] = &mcp.Document{ID: "doc-1", Title: "Draft", CreatedAt: created, Version: 2}
+
+			response := move(mockDB, args)
+			require.False(t, response.IsError, response.Content[0].Text)
+			assert.Contains(t, response.Content[0].Text, "moved from collection drafts to knowledgebase")
+
+			assert.NotContains(t, mockDB.documents, "doc-1")
+			moved := mockDB.targets["knowledgebase"]["doc-1"]
+			require.NotNil(t, moved)
+			assert.Equal(t, created, moved.CreatedAt)
+			assert.Equal(t, 3, moved.Version)
+		})
+
+		t.Run("TargetCollision", func(t *testing.T) {
+			mockDB := NewMockMongoDB(true, nil)
+			mockDB.documents["doc-1"] = &mcp.Document{ID: "doc-1", Title: "Draft"}
+			mockDB.targets = map[string]map[string]*mcp.Document{
+				"knowledgebase": {"doc-1": {ID: "doc-1", Title: "Published"}},
+			}
+
+			response := move(mockDB, args)
+			assert.True(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "already exists in 'knowledgebase'")
+			assert.Contains(t, mockDB.documents, "doc-1")
+			assert.Equal(t, "Published", mockDB.targets["knowledgebase"]["doc-1"].Title)
+		})
+
+		t.Run("InvalidArguments", func(t *testing.T) {
+			invalid := []map[string]interface{}{
+				{"collection": "drafts", "id": "doc-1"},
+				{"collection": "drafts", "target_collection": "system.users", "id": "doc-1"},
+				{"collection": "drafts", "target_collection": "drafts", "id": "doc-1"},
+				{"collection": "drafts", "target_collection": "knowledgebase"},
+			}
+			for _, a := range invalid {
+				response := move(NewMockMongoDB(true, nil), a)
+				assert.True(t, response.IsError, "%v", a)
+			}
+		})
+	})
+
 	t.Run("CallTool_QueryDocuments_Success", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -363,6 +1221,159 @@ func TestDatabaseTool(t *testing.T) {
 		assert.Contains(t, response.Content[0].Text, "Found")
 	})
 
+	t.Run("CallTool_QueryDocuments_OrderedSort", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		request := mcp.ToolCallRequest{
+			Name: "db_query_documents",
+			Arguments: map[string]interface{}{
+				"collection": "knowledgebase",
+				"sort": []interface{}{
+					map[string]interface{}{"field": "category", "dir": "asc"},
+					map[string]interface{}{"field": "created_at", "dir": "desc"},
+					map[string]interface{}{"field": "version", "dir": float64(-1)},
+					map[string]interface{}{"field": "title"},
+				},
+			},
+		}
+
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.False(t, response.IsError)
+		assert.Equal(t, []mcp.SortField{
+			{Field: "category", Direction: 1},
+			{Field: "created_at", Direction: -1},
+			{Field: "version", Direction: -1},
+			{Field: "title", Direction: 1},
+		}, mockDB.lastQuery.OrderedSort)
+		assert.Nil(t, mockDB.lastQuery.Sort)
+	})
+
+	t.Run("CallTool_QueryDocuments_ObjectSort", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		request := mcp.ToolCallRequest{
+			Name: "db_query_documents",
+			Arguments: map[string]interface{}{
+				"collection": "knowledgebase",
+				"sort":       map[string]interface{}{"created_at": "desc"},
+			},
+		}
+
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.False(t, response.IsError)
+		assert.Equal(t, map[string]interface{}{"created_at": -1}, mockDB.lastQuery.Sort)
+	})
+
+	t.Run("CallTool_QueryDocuments_InvalidSort", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		invalidSorts := []interface{}{
+			[]interface{}{map[string]interface{}{"dir": "asc"}},
+			[]interface{}{map[string]interface{}{"field": "title", "dir": "sideways"}},
+			[]interface{}{"title"},
+			map[string]interface{}{"title": 2},
+		}
+
+		for _, sort := range invalidSorts {
+			request := mcp.ToolCallRequest{
+				Name: "db_query_documents",
+				Arguments: map[string]interface{}{
+					"collection": "knowledgebase",
+					"sort":       sort,
+				},
+			}
+
+			response, err := tool.CallTool(context.Background(), request)
+			require.NoError(t, err)
+			assert.True(t, response.IsError, "sort %v should be rejected", sort)
+			assert.Contains(t, response.Content[0].Text, "Invalid 'sort' parameter")
+		}
+	})
+
+	t.Run("CallTool_RelatedDocuments", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		docs := []*mcp.Document{
+			{ID: "src", Title: "Source", Category: "go", Tags: []string{"mcp", "go", "websocket", "json"}},
+			{ID: "one", Title: "One Shared", Category: "rust", Tags: []string{"json", "serde"}},
+			{ID: "three", Title: "Three Shared", Category: "rust", Tags: []string{"mcp", "go", "json"}},
+			{ID: "two", Title: "Two Shared", Category: "go", Tags: []string{"websocket", "go"}},
+			{ID: "two-b", Title: "Also Two Shared", Category: "python", Tags: []string{"mcp", "json"}},
+			{ID: "category", Title: "Category Only", Category: "go", Tags: []string{"cli"}},
+			{ID: "none", Title: "Unrelated", Category: "rust", Tags: []string{"cli"}},
+		}
+		for _, doc := range docs {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_related_documents",
+				Arguments: args,
+			})
+			require.NoError(t, err)
+			return response
+		}
+		relatedIDs := func(response *mcp.ToolCallResponse) []string {
+			raw := response.Content[len(response.Content)-1].Text
+			raw = strings.TrimSuffix(strings.TrimPrefix(raw, "Raw JSON:\n```json\n"), "\n```")
+			var related []relatedDocument
+			require.NoError(t, json.Unmarshal([]byte(raw), &related))
+			ids := make([]string, len(related))
+			for i, r := range related {
+				ids[i] = r.ID
+			}
+			return ids
+		}
+
+		t.Run("RankedBySharedTags", func(t *testing.T) {
+			response := call(map[string]interface{}{"collection": "test_docs", "id": "src"})
+			require.False(t, response.IsError)
+
+			// Same category breaks the tie between the two-tag documents
+			assert.Equal(t, []string{"three", "two", "two-b", "one", "category"}, relatedIDs(response))
+			assert.Contains(t, response.Content[0].Text, "Found 5 documents related to 'Source'")
+			assert.Contains(t, response.Content[1].Text, "Shared tags (3): mcp, go, json")
+			assert.Contains(t, response.Content[2].Text, "Same category: go")
+
+			assert.Equal(t, map[string]interface{}{"$ne": "src"}, mockDB.lastQuery.Filter["_id"])
+			assert.Equal(t, maxRelatedCandidates, mockDB.lastQuery.Limit)
+		})
+
+		t.Run("Limit", func(t *testing.T) {
+			response := call(map[string]interface{}{"collection": "test_docs", "id": "src", "limit": 2})
+			require.False(t, response.IsError)
+			assert.Equal(t, []string{"three", "two"}, relatedIDs(response))
+		})
+
+		t.Run("NoTagsOrCategory", func(t *testing.T) {
+			mockDB.documents["bare"] = &mcp.Document{ID: "bare", Title: "Bare"}
+			defer delete(mockDB.documents, "bare")
+
+			response := call(map[string]interface{}{"collection": "test_docs", "id": "bare"})
+			assert.False(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "no tags or category")
+		})
+
+		t.Run("SourceNotFound", func(t *testing.T) {
+			response := call(map[string]interface{}{"collection": "test_docs", "id": "missing"})
+			assert.True(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "Failed to get document")
+		})
+
+		t.Run("MissingID", func(t *testing.T) {
+			response := call(map[string]interface{}{"collection": "test_docs"})
+			assert.True(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "'id'")
+		})
+	})
+
 	t.Run("CallTool_SearchDocuments_Success", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -390,6 +1401,453 @@ func TestDatabaseTool(t *testing.T) {
 		assert.Contains(t, response.Content[0].Text, "Found")
 	})
 
+	t.Run("CallTool_SearchDocuments_MinScore", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		for _, doc := range []*mcp.Document{
+			{ID: "strong", Title: "Strong Match", Content: "kubernetes", Score: 2.5},
+			{ID: "fair", Title: "Fair Match", Content: "kubernetes", Score: 1.2},
+			{ID: "weak", Title: "Weak Match", Content: "kubernetes", Score: 0.4},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		search := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			args["collection"] = "test_docs"
+			args["search_text"] = "kubernetes"
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_search_documents",
+				Arguments: args,
+			})
+			require.NoError(t, err)
+			require.False(t, response.IsError)
+			return response
+		}
+		text := func(response *mcp.ToolCallResponse) string {
+			var b strings.Builder
+			for _, c := range response.Content {
+				b.WriteString(c.Text + "\n")
+			}
+			return b.String()
+		}
+
+		all := search(map[string]interface{}{})
+		assert.Contains(t, all.Content[0].Text, "Found 3 documents")
+		assert.Contains(t, text(all), "Score: 2.50")
+		assert.Contains(t, text(all), "Score: 0.40")
+
+		filtered := search(map[string]interface{}{"min_score": 1.0})
+		assert.Contains(t, filtered.Content[0].Text, "Found 2 documents")
+		assert.Contains(t, text(filtered), "Strong Match")
+		assert.Contains(t, text(filtered), "Fair Match")
+		assert.NotContains(t, text(filtered), "Weak Match")
+	})
+
+	t.Run("CallTool_SearchDocuments_Filter", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		for _, doc := range []*mcp.Document{
+			{ID: "rbac", Title: "Kubernetes RBAC", Content: "kubernetes", Category: "Security"},
+			{ID: "ingress", Title: "Kubernetes ingress", Content: "kubernetes", Category: "Networking"},
+			{ID: "firewall", Title: "Firewall rules", Content: "firewall", Category: "Security"},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name: "db_search_documents",
+			Arguments: map[string]interface{}{
+				"collection":  "knowledgebase",
+				"search_text": "kubernetes",
+				"filter":      map[string]interface{}{"category": "Security"},
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, map[string]interface{}{"category": "Security"}, mockDB.lastSearchFilter)
+		assert.Contains(t, response.Content[0].Text, "Found 1 documents")
+		assert.Contains(t, response.Content[1].Text, "Kubernetes RBAC")
+
+		for _, filter := range []interface{}{"Security", map[string]interface{}{"$where": "true"}} {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name: "db_search_documents",
+				Arguments: map[string]interface{}{
+					"collection":  "knowledgebase",
+					"search_text": "kubernetes",
+					"filter":      filter,
+				},
+			})
+			require.NoError(t, err)
+			assert.True(t, response.IsError)
+			assert.Contains(t, response.Content[0].Text, "Invalid 'filter' parameter")
+		}
+	})
+
+	t.Run("CallTool_SearchDocuments_CreatesMissingTextIndex", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		mockDB.missingTextIndex = true
+		tool := NewDatabaseTool(mockDB)
+
+		request := mcp.ToolCallRequest{
+			Name: "db_search_documents",
+			Arguments: map[string]interface{}{
+				"collection":  "knowledgebase",
+				"search_text": "kubernetes",
+			},
+		}
+
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.False(t, response.IsError)
+		assert.Equal(t, 1, mockDB.ensureIndexCalls)
+		assert.Contains(t, response.Content[0].Text, "Found")
+		assert.Contains(t, response.Content[1].Text, "had no text index")
+	})
+
+	t.Run("CallTool_SearchDocuments_SubstringFallback", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		mockDB.missingTextIndex = true
+		mockDB.ensureIndexErr = assert.AnError
+		tool := NewDatabaseTool(mockDB)
+
+		request := mcp.ToolCallRequest{
+			Name: "db_search_documents",
+			Arguments: map[string]interface{}{
+				"collection":  "knowledgebase",
+				"search_text": "kubernetes",
+			},
+		}
+
+		mockDB.documents["1"] = &mcp.Document{ID: "1", Title: "Running Kubernetes locally", Content: "Use kind"}
+		mockDB.documents["2"] = &mcp.Document{ID: "2", Title: "Docker basics", Content: "Images and containers"}
+
+		// The search falls back to a substring match and says so
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, 1, mockDB.ensureIndexCalls)
+		assert.Equal(t, 1, mockDB.substringSearches)
+		require.Len(t, response.Content, 3)
+		assert.Contains(t, response.Content[0].Text, "Found 1 documents")
+		assert.Contains(t, response.Content[1].Text, "slower substring match")
+		assert.Contains(t, response.Content[1].Text, "Creating a text index failed")
+		assert.Contains(t, response.Content[2].Text, "Running Kubernetes locally")
+		assert.NotContains(t, response.Content[2].Text, "Score")
+	})
+
+	t.Run("CallTool_SearchDocuments_SubstringFallbackFilter", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		mockDB.missingTextIndex = true
+		mockDB.ensureIndexErr = assert.AnError
+		mockDB.documents["1"] = &mcp.Document{ID: "1", Title: "Kubernetes guide", Category: "guides"}
+		mockDB.documents["2"] = &mcp.Document{ID: "2", Title: "Kubernetes notes", Category: "notes"}
+		tool := NewDatabaseTool(mockDB)
+
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name: "db_search_documents",
+			Arguments: map[string]interface{}{
+				"collection":  "knowledgebase",
+				"search_text": "kubernetes",
+				"filter":      map[string]interface{}{"category": "notes"},
+				"min_score":   5.0,
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, map[string]interface{}{"category": "notes"}, mockDB.lastSearchFilter)
+		// min_score is ignored, since substring matches have no score
+		assert.Contains(t, response.Content[0].Text, "Found 1 documents")
+		assert.Contains(t, response.Content[2].Text, "Kubernetes notes")
+	})
+
+	t.Run("CallTool_RecentDocuments", func(t *testing.T) {
+		now := time.Now().UTC().Truncate(time.Second)
+		store := recentStore{MockMongoDB: NewMockMongoDB(true, nil), collections: map[string][]*mcp.Document{
+			"documents": {
+				{ID: "d-old", Title: "Old document", UpdatedAt: now.Add(-72 * time.Hour)},
+				{ID: "d-new", Title: "New document", UpdatedAt: now.Add(-time.Minute)},
+				{ID: "d-mid", Title: "Middle document", UpdatedAt: now.Add(-3 * time.Hour)},
+			},
+			"knowledgebase": {
+				{ID: "k-mid", Title: "Middle article", Category: "guides", UpdatedAt: now.Add(-2 * time.Hour)},
+				{ID: "k-newest", Title: "Newest article", UpdatedAt: now},
+			},
+		}}
+		tool := NewDatabaseTool(store)
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_recent_documents", Arguments: args})
+			require.NoError(t, err)
+			require.False(t, response.IsError, response.Content[0].Text)
+			return response
+		}
+		recent := func(response *mcp.ToolCallResponse) (ids, collections []string) {
+			for _, doc := range response.StructuredContent.(map[string]interface{})["documents"].([]recentDocument) {
+				ids = append(ids, doc.ID)
+				collections = append(collections, doc.Collection)
+			}
+			return ids, collections
+		}
+
+		// One collection, newest first, without the collection on each document
+		response := call(map[string]interface{}{"collection": "documents"})
+		ids, collections := recent(response)
+		assert.Equal(t, []string{"d-new", "d-mid", "d-old"}, ids)
+		assert.Equal(t, []string{"", "", ""}, collections)
+		assert.Equal(t, []mcp.SortField{{Field: "updated_at", Direction: -1}}, store.lastQuery.OrderedSort)
+		assert.Equal(t, "Found 3 recently updated documents in collection 'documents'", response.Content[0].Text)
+		assert.Equal(t, "1. **New document** (ID: d-new)\n   Updated: "+now.Add(-time.Minute).Format(time.RFC3339), response.Content[1].Text)
+
+		ids, _ = recent(call(map[string]interface{}{"collection": "documents", "limit": 2}))
+		assert.Equal(t, []string{"d-new", "d-mid"}, ids)
+
+		// Without a collection, the default one
+		ids, _ = recent(call(map[string]interface{}{}))
+		assert.Equal(t, []string{"d-new", "d-mid", "d-old"}, ids)
+
+		// Configured collections are merged by update time
+		tool.SetRecentCollections([]string{"documents", "knowledgebase"})
+		response = call(map[string]interface{}{"limit": 4})
+		ids, collections = recent(response)
+		assert.Equal(t, []string{"k-newest", "d-new", "k-mid", "d-mid"}, ids)
+		assert.Equal(t, []string{"knowledgebase", "documents", "knowledgebase", "documents"}, collections)
+		assert.Equal(t, "Found 4 recently updated documents across collections documents, knowledgebase", response.Content[0].Text)
+		assert.Contains(t, response.Content[1].Text, "(ID: k-newest, collection: knowledgebase)")
+
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		recentTool := findTool(tools, "db_recent_documents")
+		require.NotNil(t, recentTool)
+		structured, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(recentTool.OutputSchema, structured))
+
+		store.err = errors.New("connection refused")
+		response, err = tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_recent_documents", Arguments: map[string]interface{}{}})
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Failed to list recent documents in collection 'documents': connection refused", response.Content[0].Text)
+	})
+
+	t.Run("CallTool_DiffDocuments", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		mockDB.documents["draft"] = &mcp.Document{
+			ID:       "draft",
+			Title:    "Kubernetes RBAC",
+			Content:  "Roles grant permissions.\nBind them to users.\nAudit regularly.",
+			Category: "security",
+			Tags:     []string{"k8s", "rbac", "draft"},
+			Metadata: map[string]interface{}{
+				"author": "ana",
+				"words":  int32(9),
+				"review": map[string]interface{}{"status": "pending", "by": "lee"},
+			},
+		}
+		mockDB.documents["final"] = &mcp.Document{
+			ID:      "final",
+			Title:   "Kubernetes RBAC Guide",
+			Content: "Roles grant permissions.\nBind them to groups.\nAudit regularly.",
+			Tags:    []string{"rbac", "k8s", "published"},
+			Metadata: map[string]interface{}{
+				"author":    "ana",
+				"words":     float64(9),
+				"review":    map[string]interface{}{"status": "approved", "by": "lee"},
+				"published": true,
+			},
+		}
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_diff_documents", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		response := call(map[string]interface{}{"collection": "knowledgebase", "id": "draft", "other_id": "final"})
+		require.False(t, response.IsError, response.Content[0].Text)
+		structured := response.StructuredContent.(map[string]interface{})
+		assert.Equal(t, false, structured["identical"])
+		// Equal numbers of different types and reordered tags are no change
+		assert.Equal(t, []fieldChange{
+			{Field: "title", Change: fieldChanged, Old: "Kubernetes RBAC", New: "Kubernetes RBAC Guide"},
+			{Field: "content", Change: fieldChanged, Diff: "@@ -1,3 +1,3 @@\n Roles grant permissions.\n-Bind them to users.\n+Bind them to groups.\n Audit regularly.\n"},
+			{Field: "category", Change: fieldRemoved, Old: "security"},
+			{Field: "tags", Change: fieldChanged, Added: []string{"published"}, Removed: []string{"draft"}},
+			{Field: "metadata.published", Change: fieldAdded, New: true},
+			{Field: "metadata.review.status", Change: fieldChanged, Old: "pending", New: "approved"},
+		}, structured["changes"])
+		assert.Equal(t, "Documents draft and final differ in 6 fields:\n"+
+			"- title: changed from Kubernetes RBAC to Kubernetes RBAC Guide\n"+
+			"- content: changed (line diff below)\n"+
+			"- category: removed security\n"+
+			"- tags: added [published], removed [draft]\n"+
+			"- metadata.published: added true\n"+
+			"- metadata.review.status: changed from pending to approved", response.Content[0].Text)
+		assert.Contains(t, response.Content[1].Text, "```diff\n@@ -1,3 +1,3 @@\n")
+
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		diffTool := findTool(tools, "db_diff_documents")
+		require.NotNil(t, diffTool)
+		normalized, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(diffTool.OutputSchema, normalized))
+
+		// The reverse diff swaps additions and removals
+		response = call(map[string]interface{}{"collection": "knowledgebase", "id": "final", "other_id": "draft"})
+		changes := response.StructuredContent.(map[string]interface{})["changes"].([]fieldChange)
+		assert.Equal(t, fieldChange{Field: "category", Change: fieldAdded, New: "security"}, changes[2])
+		assert.Equal(t, fieldChange{Field: "metadata.published", Change: fieldRemoved, Old: true}, changes[4])
+
+		response = call(map[string]interface{}{"collection": "knowledgebase", "id": "draft", "other_id": "draft"})
+		require.False(t, response.IsError)
+		assert.Equal(t, true, response.StructuredContent.(map[string]interface{})["identical"])
+		assert.Len(t, response.Content, 1)
+		assert.Equal(t, "Documents draft and draft are identical in title, content, category, tags and metadata", response.Content[0].Text)
+
+		for _, c := range []struct {
+			args    map[string]interface{}
+			message string
+		}{
+			{map[string]interface{}{"collection": "knowledgebase", "other_id": "final"}, "Missing or invalid 'id' parameter"},
+			{map[string]interface{}{"collection": "knowledgebase", "id": "draft"}, "Missing or invalid 'other_id' parameter"},
+			{map[string]interface{}{"collection": "knowledgebase", "id": "draft", "other_id": "final", "other_collection": "bad\x00name"}, "Invalid 'other_collection' parameter"},
+			{map[string]interface{}{"collection": "knowledgebase", "id": "draft", "other_id": "missing"}, "Failed to get document missing"},
+		} {
+			response := call(c.args)
+			assert.True(t, response.IsError, c.message)
+			assert.Contains(t, response.Content[0].Text, c.message)
+		}
+	})
+
+	t.Run("CallTool_FindByTitle", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		for _, doc := range []*mcp.Document{
+			{ID: "k8s", Title: "Kubernetes Networking Guide", Category: "guides"},
+			{ID: "k8s-rbac", Title: "Kubernetes RBAC"},
+			{ID: "net", Title: "Networking Basics"},
+			{ID: "docker", Title: "Docker Compose Tips"},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			args["collection"] = "knowledgebase"
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_find_by_title", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+		matches := func(response *mcp.ToolCallResponse) []titleMatch {
+			require.False(t, response.IsError, response.Content[0].Text)
+			return response.StructuredContent.(map[string]interface{})["matches"].([]titleMatch)
+		}
+
+		// Near misses of a title rank the intended document first
+		for _, title := range []string{
+			"kubernetes networking guide",
+			"Kubernets Netwroking Guide",
+			"kubernetes-networking guide!",
+			"kubernetes networking",
+		} {
+			found := matches(call(map[string]interface{}{"title": title}))
+			require.NotEmpty(t, found, title)
+			assert.Equal(t, "k8s", found[0].ID, title)
+		}
+
+		// Only documents sharing the start of a word are fetched as candidates
+		assert.Equal(t, map[string]interface{}{
+			"title": map[string]interface{}{"$regex": "kub|net", "$options": "i"},
+		}, mockDB.lastQuery.Filter)
+
+		response := call(map[string]interface{}{"title": "Kubernetes Networking Guide"})
+		found := matches(response)
+		assert.Equal(t, titleMatch{ID: "k8s", Title: "Kubernetes Networking Guide", Category: "guides", Score: 1}, found[0])
+		for i := 1; i < len(found); i++ {
+			assert.Less(t, found[i].Score, found[0].Score)
+		}
+		assert.Contains(t, response.Content[1].Text, "**Kubernetes Networking Guide** (ID: k8s)\n   Score: 1.00")
+
+		// The structured result matches the declared output schema
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		titleTool := findTool(tools, "db_find_by_title")
+		require.NotNil(t, titleTool)
+		structured, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(titleTool.OutputSchema, structured))
+
+		found = matches(call(map[string]interface{}{"title": "kubernetes", "limit": 1}))
+		assert.Len(t, found, 1)
+
+		found = matches(call(map[string]interface{}{"title": "docker compose tips", "min_score": 0.99}))
+		require.Len(t, found, 1)
+		assert.Equal(t, "docker", found[0].ID)
+
+		assert.Empty(t, matches(call(map[string]interface{}{"title": "Quantum chromodynamics"})))
+
+		for _, args := range []map[string]interface{}{
+			{},
+			{"title": "  "},
+			{"title": "!?"},
+			{"title": "docker", "min_score": 1.5},
+		} {
+			response := call(args)
+			assert.True(t, response.IsError, "%v", args)
+		}
+	})
+
+	t.Run("CallTool_EnsureTextIndex", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		request := mcp.ToolCallRequest{
+			Name: "db_ensure_text_index",
+			Arguments: map[string]interface{}{
+				"collection": "knowledgebase",
+			},
+		}
+
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.False(t, response.IsError)
+		assert.Equal(t, 1, mockDB.ensureIndexCalls)
+		assert.Contains(t, response.Content[0].Text, "knowledgebase")
+
+		request.Arguments = map[string]interface{}{}
+		response, err = tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+	})
+
+	t.Run("CallTool_ListIndexes", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		request := mcp.ToolCallRequest{
+			Name:      "db_list_indexes",
+			Arguments: map[string]interface{}{"collection": "knowledgebase"},
+		}
+
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		require.False(t, response.IsError, response.Content[0].Text)
+		require.Len(t, response.Content, 2)
+		assert.Equal(t, "Collection 'knowledgebase' has 2 indexes\n"+
+			"- _id_ (_id: 1)\n"+
+			"- title_text_content_text (content: text, title: text)", response.Content[0].Text)
+
+		var indexes []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(response.Content[1].Text), &indexes))
+		require.Len(t, indexes, 2)
+		assert.Equal(t, true, indexes[1]["text"])
+
+		mockDB.err = assert.AnError
+		response, err = tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Failed to list indexes")
+	})
+
 	t.Run("CallTool_CountDocuments_Success", func(t *testing.T) {
 		mockDB := NewMockMongoDB(true, nil)
 		tool := NewDatabaseTool(mockDB)
@@ -415,6 +1873,612 @@ func TestDatabaseTool(t *testing.T) {
 		require.NoError(t, err)
 		assert.False(t, response.IsError)
 		assert.Contains(t, response.Content[0].Text, "contains 3 documents")
+
+		// The structured result matches the declared output schema
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		countTool := findTool(tools, "db_count_documents")
+		require.NotNil(t, countTool)
+		require.NotNil(t, countTool.OutputSchema)
+
+		structured, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(countTool.OutputSchema, structured))
+		assert.Equal(t, map[string]interface{}{"collection": "test_docs", "count": 3.0}, structured)
+	})
+
+	t.Run("CallTool_ValidateFilter", func(t *testing.T) {
+		mockDB := NewMockMongoDB(false, assert.AnError)
+		tool := NewDatabaseTool(mockDB)
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		validateTool := findTool(tools, "db_validate_filter")
+		require.NotNil(t, validateTool)
+
+		// The database is never consulted, so it works even while it is down
+		validate := func(filter interface{}) (*mcp.ToolCallResponse, map[string]interface{}) {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_validate_filter",
+				Arguments: map[string]interface{}{"filter": filter},
+			})
+			require.NoError(t, err)
+			require.False(t, response.IsError, response.Content[0].Text)
+			structured, err := mcp.NormalizeJSON(response.StructuredContent)
+			require.NoError(t, err)
+			require.NoError(t, mcp.ValidateSchema(validateTool.OutputSchema, structured))
+			return response, structured.(map[string]interface{})
+		}
+
+		response, result := validate(map[string]interface{}{
+			"category": "guides",
+			"$or": []interface{}{
+				map[string]interface{}{"tags": map[string]interface{}{"$in": []interface{}{"go", "mcp"}}},
+				map[string]interface{}{"version": map[string]interface{}{"$gte": 2}},
+			},
+		})
+		assert.Equal(t, "Filter is valid", response.Content[0].Text)
+		assert.Equal(t, true, result["valid"])
+		assert.Empty(t, result["rejected_operators"])
+		assert.Empty(t, result["problems"])
+
+		response, result = validate(map[string]interface{}{
+			"$where": "this.title.length > 3",
+			"$expr":  map[string]interface{}{"$function": map[string]interface{}{"body": "return true"}},
+		})
+		assert.Equal(t, false, result["valid"])
+		assert.ElementsMatch(t, []interface{}{"$where", "$function"}, result["rejected_operators"])
+		assert.Contains(t, response.Content[0].Text, "operator $where is not allowed")
+
+		response, result = validate(map[string]interface{}{
+			"$or":   "title",
+			"tags":  map[string]interface{}{"$in": "go"},
+			"title": map[string]interface{}{"$contains": "Go"},
+		})
+		assert.Equal(t, false, result["valid"])
+		assert.Empty(t, result["rejected_operators"])
+		assert.ElementsMatch(t, []interface{}{
+			"$or must be a non-empty list of filters",
+			"$in must be a list",
+			"unknown operator $contains",
+		}, result["problems"])
+		assert.Contains(t, response.Content[0].Text, "Filter is invalid")
+
+		_, result = validate("category = guides")
+		assert.Equal(t, false, result["valid"])
+		assert.Equal(t, []interface{}{"filter must be an object"}, result["problems"])
+
+		response, err = tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_validate_filter"})
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+	})
+
+	t.Run("CallTool_AllowedQueryOperators", func(t *testing.T) {
+		config := database.DefaultConfig()
+		config.AllowedQueryOperators = []string{"$and", "$or", "$in"}
+		tool := NewDatabaseTool(allowlistStore{MockMongoDB: NewMockMongoDB(true, nil), config: config})
+		filter := map[string]interface{}{
+			"$or": []interface{}{
+				map[string]interface{}{"tags": map[string]interface{}{"$in": []interface{}{"go"}}},
+				map[string]interface{}{"version": map[string]interface{}{"$gte": 2}},
+			},
+		}
+
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name:      "db_validate_filter",
+			Arguments: map[string]interface{}{"filter": filter},
+		})
+		require.NoError(t, err)
+		structured := response.StructuredContent.(map[string]interface{})
+		assert.Equal(t, false, structured["valid"])
+		assert.Equal(t, []string{"$gte"}, structured["rejected_operators"])
+		assert.Contains(t, response.Content[0].Text, "operator $gte is not allowed")
+
+		response, err = tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name:      "db_count_documents",
+			Arguments: map[string]interface{}{"collection": "docs", "filter": filter},
+		})
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Invalid 'filter' parameter: filter operator $gte is not allowed", response.Content[0].Text)
+	})
+
+	t.Run("CallTool_AllowedQueryOperatorsSkipServerFilters", func(t *testing.T) {
+		// The allowlist restricts client filters, not the ones tools build
+		config := database.DefaultConfig()
+		config.AllowedQueryOperators = []string{"$eq"}
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(allowlistStore{MockMongoDB: mockDB, config: config})
+		for _, doc := range []*mcp.Document{
+			{ID: "k8s", Title: "Kubernetes Networking Guide", Category: "guides", Tags: []string{"kubernetes", "networking"}},
+			{ID: "net", Title: "Networking Basics", Category: "guides", Tags: []string{"networking"}},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+		call := func(name string, args map[string]interface{}) *mcp.ToolCallResponse {
+			args["collection"] = "knowledgebase"
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: name, Arguments: args})
+			require.NoError(t, err)
+			require.False(t, response.IsError, response.Content[0].Text)
+			return response
+		}
+
+		response := call("db_find_by_title", map[string]interface{}{"title": "kubernetes networking guide"})
+		matches := response.StructuredContent.(map[string]interface{})["matches"].([]titleMatch)
+		require.NotEmpty(t, matches)
+		assert.Equal(t, "k8s", matches[0].ID)
+
+		response = call("db_find_by_tags", map[string]interface{}{"tags": []interface{}{"kubernetes", "networking"}})
+		assert.Contains(t, response.Content[0].Text, "Found 2 documents")
+		response = call("db_find_by_tags", map[string]interface{}{"tags": []interface{}{"kubernetes", "networking"}, "match": "all"})
+		assert.Contains(t, response.Content[0].Text, "Found 1 documents")
+
+		response = call("db_related_documents", map[string]interface{}{"id": "k8s"})
+		assert.Contains(t, response.Content[len(response.Content)-1].Text, `"net"`)
+
+		// A client filter using the same operators is still rejected
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name: "db_query_documents",
+			Arguments: map[string]interface{}{
+				"collection": "knowledgebase",
+				"filter":     map[string]interface{}{"title": map[string]interface{}{"$regex": "kube", "$options": "i"}},
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "is not allowed")
+	})
+
+	t.Run("CallTool_InvalidFilter", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		// The query tools reject the filters db_validate_filter does
+		for _, name := range []string{"db_query_documents", "db_explain_query", "db_count_documents", "db_field_stats", "db_update_many"} {
+			for _, filter := range []interface{}{
+				map[string]interface{}{"$where": "true"},
+				map[string]interface{}{"title": map[string]interface{}{"$contains": "Go"}},
+				"category = guides",
+			} {
+				response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+					Name: name,
+					Arguments: map[string]interface{}{
+						"collection": "documents",
+						"field":      "version",
+						"filter":     filter,
+						"set":        map[string]interface{}{"category": "guides"},
+					},
+				})
+				require.NoError(t, err)
+				assert.True(t, response.IsError, "%s with %v", name, filter)
+				assert.Contains(t, response.Content[0].Text, "Invalid 'filter' parameter", name)
+			}
+		}
+	})
+
+	t.Run("CallTool_TimeHistogram", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		date := func(day int, hour int) time.Time {
+			return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC)
+		}
+		for _, doc := range []*mcp.Document{
+			{ID: "1", CreatedAt: date(4, 9)},   // Monday
+			{ID: "2", CreatedAt: date(4, 23)},  // Monday
+			{ID: "3", CreatedAt: date(10, 12)}, // Sunday, same week
+			{ID: "4", CreatedAt: date(11, 0)},  // Monday, next week
+			{ID: "5", CreatedAt: time.Date(2024, time.April, 3, 8, 0, 0, 0, time.UTC)},
+			{ID: "6", CreatedAt: date(4, 10).In(time.FixedZone("UTC-3", -3*3600))}, // March 4 in UTC
+			{ID: "7"}, // no creation date
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_time_histogram", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+		histogram := func(bucket string) *mcp.TimeHistogram {
+			response := call(map[string]interface{}{"collection": "documents", "bucket": bucket})
+			require.False(t, response.IsError, response.Content[0].Text)
+			return response.StructuredContent.(*mcp.TimeHistogram)
+		}
+
+		// The bucket defaults to day and the field to created_at
+		response := call(map[string]interface{}{"collection": "documents"})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, &mcp.TimeHistogram{Field: "created_at", Bucket: "day", Counts: []mcp.TimeBucketCount{
+			{Bucket: date(4, 0), Count: 3},
+			{Bucket: date(10, 0), Count: 1},
+			{Bucket: date(11, 0), Count: 1},
+			{Bucket: time.Date(2024, time.April, 3, 0, 0, 0, 0, time.UTC), Count: 1},
+		}}, response.StructuredContent)
+		assert.Contains(t, response.Content[0].Text, "Documents per day of 'created_at'")
+		assert.Contains(t, response.Content[0].Text, "2024-03-04: 3\n")
+
+		assert.Equal(t, []mcp.TimeBucketCount{
+			{Bucket: date(4, 0), Count: 4},
+			{Bucket: date(11, 0), Count: 1},
+			{Bucket: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), Count: 1},
+		}, histogram("week").Counts)
+
+		assert.Equal(t, []mcp.TimeBucketCount{
+			{Bucket: date(1, 0), Count: 5},
+			{Bucket: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), Count: 1},
+		}, histogram("month").Counts)
+
+		// The structured result matches the declared output schema
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		histogramTool := findTool(tools, "db_time_histogram")
+		require.NotNil(t, histogramTool)
+		structured, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(histogramTool.OutputSchema, structured))
+
+		response = call(map[string]interface{}{"collection": "documents", "field": "updated_at"})
+		require.False(t, response.IsError)
+		assert.Empty(t, response.StructuredContent.(*mcp.TimeHistogram).Counts)
+		assert.Contains(t, response.Content[0].Text, "No documents with a date in 'updated_at'")
+
+		response = call(map[string]interface{}{"collection": "documents", "bucket": "year"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "invalid bucket")
+
+		response = call(map[string]interface{}{"collection": "documents", "field": "$where"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "invalid field path")
+	})
+
+	t.Run("CallTool_FieldStats", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		for _, doc := range []*mcp.Document{
+			{ID: "1", Category: "guides", Metadata: map[string]interface{}{"words": 120.0}},
+			{ID: "2", Category: "guides", Metadata: map[string]interface{}{"words": 300.0}},
+			{ID: "3", Category: "guides", Metadata: map[string]interface{}{"words": 60}},
+			{ID: "4", Category: "guides", Metadata: map[string]interface{}{"words": "many"}},
+			{ID: "5", Category: "guides"},
+			{ID: "6", Category: "notes", Metadata: map[string]interface{}{"words": 5000.0}},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_field_stats", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		response := call(map[string]interface{}{
+			"collection": "documents",
+			"field":      "metadata.words",
+			"filter":     map[string]interface{}{"category": "guides"},
+		})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Contains(t, response.Content[0].Text, "3 of 5 matching documents have a numeric value")
+		assert.Contains(t, response.Content[0].Text, "Avg: 160")
+		assert.Equal(t, &mcp.FieldStats{
+			Field: "metadata.words", Matched: 5, Count: 3, Sum: 480, Avg: 160, Min: 60, Max: 300,
+		}, response.StructuredContent)
+
+		// The structured result matches the declared output schema
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		statsTool := findTool(tools, "db_field_stats")
+		require.NotNil(t, statsTool)
+		structured, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(statsTool.OutputSchema, structured))
+
+		response = call(map[string]interface{}{"collection": "documents", "field": "metadata.missing"})
+		require.False(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "No numeric values for 'metadata.missing' in 6 matching documents")
+
+		response = call(map[string]interface{}{"collection": "documents"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "'field'")
+
+		response = call(map[string]interface{}{"collection": "documents", "field": "$where"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "invalid field path")
+	})
+
+	t.Run("CallTool_DistinctCount", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		for _, doc := range []*mcp.Document{
+			{ID: "1", Category: "guides", Tags: []string{"go", "mongodb"}},
+			{ID: "2", Category: "guides", Tags: []string{"go"}},
+			{ID: "3", Category: "notes", Tags: []string{"mcp"}},
+			{ID: "4", Category: "reference"},
+			{ID: "5", Category: "faq", Tags: []string{"go", "mcp"}},
+			{ID: "6", Category: "notes"},
+			{ID: "7"},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_distinct_count", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		// Four categories in use; the document without one is not counted
+		response := call(map[string]interface{}{"collection": "documents", "field": "category"})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Contains(t, response.Content[0].Text, "Found 4 distinct values of 'category'")
+		assert.Equal(t, map[string]interface{}{
+			"collection": "documents", "field": "category", "count": int64(4),
+		}, response.StructuredContent)
+
+		// The structured result matches the declared output schema
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		countTool := findTool(tools, "db_distinct_count")
+		require.NotNil(t, countTool)
+		structured, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(countTool.OutputSchema, structured))
+
+		// Array elements count individually
+		response = call(map[string]interface{}{"collection": "documents", "field": "tags"})
+		require.False(t, response.IsError)
+		assert.Equal(t, int64(3), response.StructuredContent.(map[string]interface{})["count"])
+
+		response = call(map[string]interface{}{
+			"collection": "documents",
+			"field":      "tags",
+			"filter":     map[string]interface{}{"category": "guides"},
+		})
+		require.False(t, response.IsError)
+		assert.Equal(t, int64(2), response.StructuredContent.(map[string]interface{})["count"])
+
+		response = call(map[string]interface{}{
+			"collection": "documents",
+			"field":      "category",
+			"filter":     map[string]interface{}{"category": "archive"},
+		})
+		require.False(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Found 0 distinct values")
+
+		response = call(map[string]interface{}{"collection": "documents"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "'field'")
+
+		response = call(map[string]interface{}{"collection": "documents", "field": "$where"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "invalid field path")
+
+		response = call(map[string]interface{}{
+			"collection": "documents",
+			"field":      "category",
+			"filter":     map[string]interface{}{"$where": "true"},
+		})
+		assert.True(t, response.IsError)
+	})
+
+	t.Run("CallTool_CollectionAccess", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		mockDB.documents["1"] = &mcp.Document{ID: "1", Title: "Tenant A"}
+		tool := NewDatabaseTool(mockDB)
+		ctx := mcp.ContextWithCollectionAccess(context.Background(), func(collection string) bool {
+			return collection == "tenant_a"
+		})
+		call := func(name string, args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(ctx, mcp.ToolCallRequest{Name: name, Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		response := call("db_count_documents", map[string]interface{}{"collection": "tenant_a"})
+		assert.False(t, response.IsError, response.Content[0].Text)
+		response = call("db_get_document", map[string]interface{}{"collection": "tenant_a", "id": "1"})
+		assert.False(t, response.IsError, response.Content[0].Text)
+
+		for _, denied := range []struct {
+			name string
+			args map[string]interface{}
+		}{
+			{"db_count_documents", map[string]interface{}{"collection": "tenant_b"}},
+			{"db_get_document", map[string]interface{}{"collection": "tenant_b", "id": "1"}},
+			{"db_move_document", map[string]interface{}{"collection": "tenant_a", "target_collection": "tenant_b", "id": "1"}},
+			{"db_diff_documents", map[string]interface{}{"collection": "tenant_a", "id": "1", "other_collection": "tenant_b", "other_id": "1"}},
+		} {
+			response := call(denied.name, denied.args)
+			assert.True(t, response.IsError, denied.name)
+			assert.Equal(t, "Access denied to collection 'tenant_b'", response.Content[0].Text, denied.name)
+		}
+
+		// The document is still where it was
+		_, ok := mockDB.documents["1"]
+		assert.True(t, ok)
+
+		// Tools defaulting to a collection check it too
+		response = call("db_find_by_tags", map[string]interface{}{"tags": []interface{}{"go"}})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Access denied to collection 'documents'")
+	})
+
+	t.Run("CallTool_GetDocuments", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		for _, id := range []string{"a", "b", "c"} {
+			mockDB.documents[id] = &mcp.Document{ID: id, Title: "Doc " + id}
+		}
+		call := func(ids ...interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "db_get_documents",
+				Arguments: map[string]interface{}{"collection": "documents", "ids": ids},
+			})
+			require.NoError(t, err)
+			return response
+		}
+
+		// All found, in the requested order
+		response := call("c", "a", "b")
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, "Found 3 of 3 requested documents in collection 'documents'", response.Content[0].Text)
+		assert.Contains(t, response.Content[1].Text, "1. **Doc c**")
+		assert.Contains(t, response.Content[2].Text, "2. **Doc a**")
+		assert.Contains(t, response.Content[3].Text, "3. **Doc b**")
+
+		// Partial: missing IDs are listed once
+		response = call("b", "missing", "a", "gone", "missing")
+		require.False(t, response.IsError)
+		assert.Equal(t, "Found 2 of 4 requested documents in collection 'documents'\nNot found: missing, gone", response.Content[0].Text)
+		assert.Contains(t, response.Content[1].Text, "**Doc b**")
+		assert.Contains(t, response.Content[2].Text, "**Doc a**")
+
+		// None found
+		response = call("x", "y")
+		require.False(t, response.IsError)
+		assert.Equal(t, "Found 0 of 2 requested documents in collection 'documents'\nNot found: x, y", response.Content[0].Text)
+
+		response = call()
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "'ids'")
+
+		tooMany := make([]interface{}, maxGetDocumentsIDs+1)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf("id-%d", i)
+		}
+		response = call(tooMany...)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Too many 'ids'")
+	})
+
+	t.Run("CallTool_ClearCollection", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		for _, id := range []string{"1", "2", "3"} {
+			mockDB.documents[id] = &mcp.Document{ID: id, Title: "Doc " + id}
+		}
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_clear_collection", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		// Without confirm: true nothing is dropped
+		for _, args := range []map[string]interface{}{
+			{"collection": "scratch"},
+			{"collection": "scratch", "confirm": false},
+			{"collection": "scratch", "confirm": "yes"},
+		} {
+			response := call(args)
+			assert.True(t, response.IsError, "%v", args)
+			assert.Contains(t, response.Content[0].Text, "Refusing to clear collection 'scratch'")
+		}
+		assert.Empty(t, mockDB.dropped)
+		assert.Len(t, mockDB.documents, 3)
+
+		response := call(map[string]interface{}{"collection": "scratch", "confirm": true})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, "Cleared collection 'scratch': 3 documents removed", response.Content[0].Text)
+		assert.Equal(t, []string{"scratch"}, mockDB.dropped)
+		assert.Empty(t, mockDB.documents)
+	})
+
+	t.Run("CallTool_ExplainQuery", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		mockDB.documents["doc-1"] = &mcp.Document{ID: "doc-1", Title: "Pods"}
+		mockDB.documents["doc-2"] = &mcp.Document{ID: "doc-2", Title: "Services"}
+		tool := NewDatabaseTool(mockDB)
+
+		explain := func(t *testing.T, args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_explain_query", Arguments: args})
+			require.NoError(t, err)
+			require.False(t, response.IsError, response.Content[0].Text)
+			require.Len(t, response.Content, 2)
+			return response
+		}
+
+		response := explain(t, map[string]interface{}{"collection": "documents", "filter": map[string]interface{}{"category": "Kubernetes"}})
+		assert.Equal(t, "Query on 'documents' used a collection scan: examined 2 documents and 0 index keys to return 0 documents", response.Content[0].Text)
+
+		response = explain(t, map[string]interface{}{
+			"collection": "documents",
+			"filter":     map[string]interface{}{"title": "Pods"},
+			"sort":       map[string]interface{}{"created_at": -1},
+			"limit":      5,
+		})
+		assert.Equal(t, "Query on 'documents' used index 'title_1': examined 1 documents and 1 index keys to return 1 documents", response.Content[0].Text)
+		var summary map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(response.Content[1].Text), &summary))
+		assert.Equal(t, false, summary["collection_scan"])
+		assert.Equal(t, "FETCH > IXSCAN", summary["stages"])
+
+		// The query is passed on as db_query_documents would run it
+		assert.Equal(t, 5, mockDB.lastQuery.Limit)
+		assert.Equal(t, map[string]interface{}{"created_at": -1}, mockDB.lastQuery.Sort)
+
+		mockDB.err = assert.AnError
+		errResponse, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_explain_query", Arguments: map[string]interface{}{"collection": "documents"}})
+		require.NoError(t, err)
+		assert.True(t, errResponse.IsError)
+		assert.Contains(t, errResponse.Content[0].Text, "Explain failed")
+	})
+
+	t.Run("CallTool_FindByTags", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		for _, doc := range []*mcp.Document{
+			{ID: "1", Title: "Go on Kubernetes", Tags: []string{"go", "kubernetes"}},
+			{ID: "2", Title: "Go basics", Tags: []string{"go", "tutorial"}},
+			{ID: "3", Title: "Helm charts", Tags: []string{"kubernetes", "helm"}},
+			{ID: "4", Title: "Untagged"},
+		} {
+			mockDB.documents[doc.ID] = doc
+		}
+
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "db_find_by_tags", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+		foundIDs := func() []string {
+			var ids []string
+			for _, doc := range mockDB.documents {
+				if matchesTags(doc, mockDB.lastQuery.Filter["tags"]) {
+					ids = append(ids, doc.ID)
+				}
+			}
+			return ids
+		}
+
+		// Any is the default: documents with at least one of the tags
+		response := call(map[string]interface{}{"tags": []interface{}{"go", "kubernetes"}})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Contains(t, response.Content[0].Text, "Found 3 documents in collection 'documents' tagged with any of: go, kubernetes")
+		assert.Equal(t, "documents", mockDB.lastQuery.Collection)
+		assert.Equal(t, map[string]interface{}{"tags": map[string]interface{}{"$in": []string{"go", "kubernetes"}}}, mockDB.lastQuery.Filter)
+		assert.ElementsMatch(t, []string{"1", "2", "3"}, foundIDs())
+
+		// All: only documents with every tag
+		response = call(map[string]interface{}{
+			"collection": "knowledgebase",
+			"tags":       []interface{}{"go", "kubernetes"},
+			"match":      "all",
+			"limit":      5,
+			"sort":       map[string]interface{}{"created_at": "desc"},
+		})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Contains(t, response.Content[0].Text, "Found 1 documents in collection 'knowledgebase' tagged with all of: go, kubernetes")
+		assert.Contains(t, response.Content[1].Text, "Go on Kubernetes")
+		assert.Equal(t, map[string]interface{}{"tags": map[string]interface{}{"$all": []string{"go", "kubernetes"}}}, mockDB.lastQuery.Filter)
+		assert.Equal(t, 5, mockDB.lastQuery.Limit)
+		assert.Equal(t, map[string]interface{}{"created_at": -1}, mockDB.lastQuery.Sort)
+		assert.Equal(t, []string{"1"}, foundIDs())
+
+		response = call(map[string]interface{}{"tags": []interface{}{}})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "'tags'")
+
+		response = call(map[string]interface{}{"tags": []interface{}{"go"}, "match": "some"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Invalid 'match' parameter")
 	})
 
 	t.Run("CallTool_HealthCheck_Success", func(t *testing.T) {
@@ -480,37 +2544,138 @@ func TestDatabaseTool(t *testing.T) {
 		assert.True(t, response.IsError)
 		assert.Contains(t, response.Content[0].Text, "Failed to create document")
 	})
+
+	t.Run("CallTool_InvalidCollectionName", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+
+		toolNames := []string{
+			"db_create_document",
+			"db_get_document",
+			"db_get_documents",
+			"db_document_exists",
+			"db_update_document",
+			"db_update_many",
+			"db_delete_document",
+			"db_clear_collection",
+			"db_move_document",
+			"db_query_documents",
+			"db_explain_query",
+			"db_find_by_tags",
+			"db_search_documents",
+			"db_related_documents",
+			"db_ensure_text_index",
+			"db_list_indexes",
+			"db_count_documents",
+			"db_field_stats",
+			"db_distinct_count",
+			"db_time_histogram",
+			"db_find_by_title",
+			"db_recent_documents",
+			"db_diff_documents",
+			"db_export_collection",
+		}
+		collections := []string{"system.indexes", "$cmd", "bad\x00name", strings.Repeat("c", 200)}
+
+		for _, name := range toolNames {
+			for _, collection := range collections {
+				request := mcp.ToolCallRequest{
+					Name: name,
+					Arguments: map[string]interface{}{
+						"collection": collection,
+						"id":         "doc-1",
+						"title":      "Test",
+						"content":    "Test content",
+						"query":      "test",
+					},
+				}
+
+				response, err := tool.CallTool(context.Background(), request)
+				require.NoError(t, err)
+				assert.True(t, response.IsError, "%s with %q", name, collection)
+				assert.Contains(t, response.Content[0].Text, "Invalid 'collection' parameter")
+			}
+		}
+		assert.Empty(t, mockDB.documents)
+	})
 }
 
-// Test helper functions
-func TestDatabaseTool_Helpers(t *testing.T) {
+func TestDatabaseTool_Complete(t *testing.T) {
 	mockDB := NewMockMongoDB(true, nil)
+	mockDB.collections = []string{"documents", "drafts", "knowledgebase"}
+	for _, doc := range []*mcp.Document{
+		{ID: "1", Category: "Kubernetes"},
+		{ID: "2", Category: "kafka"},
+		{ID: "3", Category: "Kubernetes"},
+		{ID: "4", Category: "Golang"},
+		{ID: "5"},
+	} {
+		mockDB.documents[doc.ID] = doc
+	}
 	tool := NewDatabaseTool(mockDB)
 
-	t.Run("toInt", func(t *testing.T) {
-		testCases := []struct {
-			input    interface{}
-			expected int
-			hasError bool
-		}{
-			{5, 5, false},
-			{5.7, 5, false},
-			{"10", 10, false},
-			{"invalid", 0, true},
-			{true, 0, true},
+	complete := func(argument, value string, resolved map[string]string) []string {
+		request := mcp.CompleteRequest{
+			Ref:      mcp.CompletionReference{Type: mcp.CompletionRefResource, URI: "mongodb://documents"},
+			Argument: mcp.CompletionArgument{Name: argument, Value: value},
 		}
-
-		for _, tc := range testCases {
-			result, err := tool.toInt(tc.input)
-			if tc.hasError {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tc.expected, result)
-			}
+		if resolved != nil {
+			request.Context = &mcp.CompletionContext{Arguments: resolved}
 		}
+		values, err := tool.Complete(context.Background(), request)
+		require.NoError(t, err)
+		return values
+	}
+
+	t.Run("CategoryPrefix", func(t *testing.T) {
+		values := complete("category", "k", map[string]string{"collection": "documents"})
+		assert.ElementsMatch(t, []string{"Kubernetes", "kafka"}, values)
+
+		assert.Equal(t, []string{"Golang"}, complete("category", "Go", nil))
+		assert.Empty(t, complete("category", "rust", nil))
+	})
+
+	t.Run("CollectionPrefix", func(t *testing.T) {
+		assert.Equal(t, []string{"documents", "drafts"}, complete("collection", "d", nil))
+		assert.Len(t, complete("collection", "", nil), 3)
 	})
 
+	t.Run("RestrictedCollections", func(t *testing.T) {
+		ctx := mcp.ContextWithCollectionAccess(context.Background(), func(collection string) bool {
+			return collection != "drafts"
+		})
+		values, err := tool.Complete(ctx, mcp.CompleteRequest{
+			Argument: mcp.CompletionArgument{Name: "collection", Value: "d"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"documents"}, values)
+
+		values, err = tool.Complete(ctx, mcp.CompleteRequest{
+			Argument: mcp.CompletionArgument{Name: "category", Value: "k"},
+			Context:  &mcp.CompletionContext{Arguments: map[string]string{"collection": "drafts"}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("UnknownArgument", func(t *testing.T) {
+		assert.Nil(t, complete("title", "", nil))
+	})
+
+	t.Run("DatabaseError", func(t *testing.T) {
+		failing := NewDatabaseTool(NewMockMongoDB(false, assert.AnError))
+		_, err := failing.Complete(context.Background(), mcp.CompleteRequest{
+			Argument: mcp.CompletionArgument{Name: "category"},
+		})
+		assert.Error(t, err)
+	})
+}
+
+// Test helper functions
+func TestDatabaseTool_Helpers(t *testing.T) {
+	mockDB := NewMockMongoDB(true, nil)
+	tool := NewDatabaseTool(mockDB)
+
 	t.Run("truncateString", func(t *testing.T) {
 		longString := "This is a very long string that should be truncated"
 		
@@ -530,3 +2695,44 @@ func TestDatabaseTool_Helpers(t *testing.T) {
 		assert.Equal(t, "test error message", response.Content[0].Text)
 	})
 }
+
+// recentStore holds documents per collection and returns them sorted by a
+// query's OrderedSort on updated_at
+type recentStore struct {
+	*MockMongoDB
+	collections map[string][]*mcp.Document
+}
+
+func (s recentStore) QueryDocuments(ctx context.Context, query mcp.DatabaseQuery) ([]*mcp.Document, error) {
+	s.lastQuery = query
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	docs := append([]*mcp.Document{}, s.collections[query.Collection]...)
+	for _, field := range query.OrderedSort {
+		if field.Field == "updated_at" {
+			sort.Slice(docs, func(i, j int) bool {
+				if field.Direction < 0 {
+					return docs[i].UpdatedAt.After(docs[j].UpdatedAt)
+				}
+				return docs[i].UpdatedAt.Before(docs[j].UpdatedAt)
+			})
+		}
+	}
+	if query.Limit > 0 && len(docs) > query.Limit {
+		docs = docs[:query.Limit]
+	}
+	return docs, nil
+}
+
+// allowlistStore checks filters against its own configuration, such as an
+// operator allowlist
+type allowlistStore struct {
+	*MockMongoDB
+	config database.Config
+}
+
+func (s allowlistStore) CheckFilter(filter map[string]interface{}) database.FilterCheck {
+	return s.config.CheckFilter(filter)
+}
@@ -0,0 +1,324 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// maxDiffLines bounds the changed region, in lines of each side, that
+// lineDiff aligns line by line; a larger one is shown as wholly replaced
+const maxDiffLines = 1000
+
+// diffContextLines is how many unchanged lines surround each change in a
+// content diff
+const diffContextLines = 2
+
+// Kinds of fieldChange
+const (
+	fieldAdded   = "added"
+	fieldRemoved = "removed"
+	fieldChanged = "changed"
+)
+
+// fieldChange is one difference between two documents, as reported by
+// db_diff_documents
+type fieldChange struct {
+	Field   string      `json:"field"`  // title, content, category, tags or metadata.<path>
+	Change  string      `json:"change"` // added, removed or changed
+	Old     interface{} `json:"old,omitempty"`
+	New     interface{} `json:"new,omitempty"`
+	Added   []string    `json:"added,omitempty"`   // tags only
+	Removed []string    `json:"removed,omitempty"` // tags only
+	Diff    string      `json:"diff,omitempty"`    // content only: a unified line diff instead of old and new
+}
+
+// diffDocuments returns the differences of the client-editable fields of two
+// documents, from a to b: title, content, category, tags (ignoring their
+// order) and each metadata value
+func diffDocuments(a, b *mcp.Document) []fieldChange {
+	changes := []fieldChange{}
+	if change, ok := diffString("title", a.Title, b.Title); ok {
+		changes = append(changes, change)
+	}
+	if a.Content != b.Content {
+		changes = append(changes, fieldChange{
+			Field:  "content",
+			Change: fieldChanged,
+			Diff:   lineDiff(a.Content, b.Content),
+		})
+	}
+	if change, ok := diffString("category", a.Category, b.Category); ok {
+		changes = append(changes, change)
+	}
+	if added, removed := diffTags(a.Tags, b.Tags); len(added) > 0 || len(removed) > 0 {
+		changes = append(changes, fieldChange{Field: "tags", Change: fieldChanged, Added: added, Removed: removed})
+	}
+	return diffMetadata(changes, "metadata", a.Metadata, b.Metadata)
+}
+
+// diffString compares an optional string field, empty meaning absent
+func diffString(field, a, b string) (fieldChange, bool) {
+	switch {
+	case a == b:
+		return fieldChange{}, false
+	case a == "":
+		return fieldChange{Field: field, Change: fieldAdded, New: b}, true
+	case b == "":
+		return fieldChange{Field: field, Change: fieldRemoved, Old: a}, true
+	}
+	return fieldChange{Field: field, Change: fieldChanged, Old: a, New: b}, true
+}
+
+// diffTags returns the tags only in b and those only in a, each sorted
+func diffTags(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, tag := range a {
+		inA[tag] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, tag := range b {
+		inB[tag] = true
+		if !inA[tag] {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range a {
+		if !inB[tag] {
+			removed = append(removed, tag)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// diffMetadata appends the differences between two metadata maps to changes,
+// descending into nested maps so each change names the path of a single
+// value, e.g. metadata.review.status
+func diffMetadata(changes []fieldChange, path string, a, b map[string]interface{}) []fieldChange {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := path + "." + key
+		oldValue, inA := a[key]
+		newValue, inB := b[key]
+		switch {
+		case !inA:
+			changes = append(changes, fieldChange{Field: field, Change: fieldAdded, New: newValue})
+		case !inB:
+			changes = append(changes, fieldChange{Field: field, Change: fieldRemoved, Old: oldValue})
+		default:
+			oldMap, oldIsMap := oldValue.(map[string]interface{})
+			newMap, newIsMap := newValue.(map[string]interface{})
+			if oldIsMap && newIsMap {
+				changes = diffMetadata(changes, field, oldMap, newMap)
+			} else if !sameValue(oldValue, newValue) {
+				changes = append(changes, fieldChange{Field: field, Change: fieldChanged, Old: oldValue, New: newValue})
+			}
+		}
+	}
+	return changes
+}
+
+// sameValue reports whether two metadata values are equal, comparing numbers
+// by value whatever their Go type, since stored and decoded numbers may differ
+// in width
+func sameValue(a, b interface{}) bool {
+	aNumber, aOK := numberValue(a)
+	bNumber, bOK := numberValue(b)
+	if aOK && bOK {
+		return aNumber == bNumber
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// numberValue returns the value of a number of any Go type, but not of a
+// string that looks like one
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// diffOp is one line of a line diff: kept (' '), removed ('-') or added ('+')
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// lineDiff returns a unified diff of the lines of a and b, with
+// diffContextLines unchanged lines around each change, or "" when they are
+// equal
+func lineDiff(a, b string) string {
+	return unifiedDiff(diffLines(strings.Split(a, "\n"), strings.Split(b, "\n")), diffContextLines)
+}
+
+// diffLines aligns the lines of a and b: the lines they share at the start and
+// end are kept, and the region between them is aligned on its longest common
+// subsequence
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, alignLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// alignLines diffs a and b on their longest common subsequence of lines,
+// unless either exceeds maxDiffLines, in which case all of a is removed and
+// all of b added
+func alignLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int32, len(a)+1)
+	for i := range common {
+		common[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff formats ops as hunks of changed lines with up to context
+// unchanged lines around them, each headed by "@@ -start,count +start,count @@".
+// Changes closer than twice the context share a hunk.
+func unifiedDiff(ops []diffOp, context int) string {
+	// oldLine[i] and newLine[i] count the lines of each side before ops[i]
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.kind != '+' {
+			oldLine[i+1]++
+		}
+		if op.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for next := 0; next < len(ops); {
+		first := next
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		end := first
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			unchanged := end
+			for unchanged < len(ops) && ops[unchanged].kind == ' ' {
+				unchanged++
+			}
+			if unchanged == len(ops) || unchanged-end > 2*context {
+				break
+			}
+			end = unchanged
+		}
+
+		start := max(first-context, next)
+		next = min(end+context, len(ops))
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldLine[next]), hunkRange(newLine[start], newLine[next]))
+		for _, op := range ops[start:next] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines from (0-based) to end of one side of a hunk as
+// "start,count", start being 1-based, or the line before an empty range
+func hunkRange(from, end int) string {
+	if from == end {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, end-from)
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineDiff(t *testing.T) {
+	lines := func(n int, edit func(i int) string) string {
+		var out []string
+		for i := 1; i <= n; i++ {
+			out = append(out, edit(i))
+		}
+		return strings.Join(out, "\n")
+	}
+	original := lines(12, func(i int) string { return fmt.Sprintf("line %d", i) })
+
+	t.Run("Equal", func(t *testing.T) {
+		assert.Empty(t, lineDiff(original, original))
+	})
+
+	t.Run("SeparateHunks", func(t *testing.T) {
+		edited := lines(12, func(i int) string {
+			if i == 2 || i == 11 {
+				return fmt.Sprintf("line %d edited", i)
+			}
+			return fmt.Sprintf("line %d", i)
+		})
+		assert.Equal(t, "@@ -1,4 +1,4 @@\n line 1\n-line 2\n+line 2 edited\n line 3\n line 4\n"+
+			"@@ -9,4 +9,4 @@\n line 9\n line 10\n-line 11\n+line 11 edited\n line 12\n", lineDiff(original, edited))
+	})
+
+	t.Run("NearbyChangesShareAHunk", func(t *testing.T) {
+		edited := lines(12, func(i int) string {
+			if i == 4 || i == 8 {
+				return "changed"
+			}
+			return fmt.Sprintf("line %d", i)
+		})
+		diff := lineDiff(original, edited)
+		assert.Equal(t, 1, strings.Count(diff, "@@ -"))
+		assert.True(t, strings.HasPrefix(diff, "@@ -2,9 +2,9 @@\n"), diff)
+	})
+
+	t.Run("InsertionsAndDeletions", func(t *testing.T) {
+		assert.Equal(t, "@@ -1,2 +1,3 @@\n a\n+b\n c\n", lineDiff("a\nc", "a\nb\nc"))
+		assert.Equal(t, "@@ -1,3 +1,2 @@\n a\n-b\n c\n", lineDiff("a\nb\nc", "a\nc"))
+		assert.Equal(t, "@@ -0,0 +1,1 @@\n+first\n", unifiedDiff(diffLines(nil, []string{"first"}), diffContextLines))
+	})
+
+	t.Run("MovedLine", func(t *testing.T) {
+		assert.Equal(t, "@@ -1,3 +1,3 @@\n-a\n b\n c\n+a\n", lineDiff("a\nb\nc", "b\nc\na"))
+	})
+
+	t.Run("LargeRegionIsReplaced", func(t *testing.T) {
+		a := lines(maxDiffLines+1, func(i int) string { return fmt.Sprintf("a%d", i) })
+		b := lines(maxDiffLines+1, func(i int) string { return fmt.Sprintf("b%d", i) })
+		ops := diffLines(strings.Split("keep\n"+a, "\n"), strings.Split("keep\n"+b, "\n"))
+		assert.Len(t, ops, 1+2*(maxDiffLines+1))
+		assert.Equal(t, diffOp{' ', "keep"}, ops[0])
+		assert.Equal(t, diffOp{'-', "a1"}, ops[1])
+		assert.Equal(t, diffOp{'+', "b1"}, ops[maxDiffLines+2])
+	})
+}
@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// ExportURIScheme is the URI scheme of collection exports served as resources
+const ExportURIScheme = "export"
+
+// maxRetainedExports bounds the exports an ExportStore keeps at once; the
+// oldest is dropped to make room for a new one
+const maxRetainedExports = 20
+
+// errExportTooLarge is returned by ExportStore.create when a collection
+// exceeds the store's size limit
+var errExportTooLarge = errors.New("export too large")
+
+// CollectionExporter writes every document of a collection to w as
+// newline-delimited JSON
+type CollectionExporter interface {
+	ExportCollection(ctx context.Context, collection string, w io.Writer) error
+}
+
+// collectionExport is a collection exported by db_export_collection, kept
+// until it expires
+type collectionExport struct {
+	uri        string
+	collection string
+	data       []byte
+	documents  int
+	createdAt  time.Time
+	expiresAt  time.Time // zero when exports do not expire
+}
+
+// ExportStore materializes collection exports in memory and serves them as
+// MCP resources with URIs like export://<collection>/<timestamp>.ndjson, so
+// clients can fetch a large export with resources/read instead of receiving
+// it in a tool response
+type ExportStore struct {
+	exporter CollectionExporter
+	ttl      time.Duration
+	maxBytes int
+
+	mu      sync.Mutex
+	exports map[string]*collectionExport
+}
+
+// NewExportStore creates an ExportStore keeping each export for ttl, or until
+// newer exports evict it when ttl is 0. Exports larger than maxBytes are
+// refused; 0 means no limit.
+func NewExportStore(exporter CollectionExporter, ttl time.Duration, maxBytes int) *ExportStore {
+	return &ExportStore{
+		exporter: exporter,
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		exports:  make(map[string]*collectionExport),
+	}
+}
+
+// create exports collection and keeps the result as a new resource
+func (s *ExportStore) create(ctx context.Context, collection string) (*collectionExport, error) {
+	out := &limitedBuffer{max: s.maxBytes}
+	if err := s.exporter.ExportCollection(ctx, collection, out); err != nil {
+		if errors.Is(err, errExportTooLarge) {
+			return nil, fmt.Errorf("collection is larger than %d bytes", s.maxBytes)
+		}
+		return nil, err
+	}
+	data := out.Bytes()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	s.expire(now)
+
+	// Exports of one collection made within the same nanosecond get distinct URIs
+	created := now
+	uri := exportURI(collection, created)
+	for s.exports[uri] != nil {
+		created = created.Add(time.Nanosecond)
+		uri = exportURI(collection, created)
+	}
+
+	export := &collectionExport{
+		uri:        uri,
+		collection: collection,
+		data:       data,
+		documents:  bytes.Count(data, []byte("\n")),
+		createdAt:  now,
+	}
+	if s.ttl > 0 {
+		export.expiresAt = now.Add(s.ttl)
+	}
+	if len(s.exports) >= maxRetainedExports {
+		delete(s.exports, s.sorted()[0].uri)
+	}
+	s.exports[uri] = export
+	return export, nil
+}
+
+// ListResources lists the exports that have not expired, oldest first,
+// leaving out those of collections the client may not access
+func (s *ExportStore) ListResources(ctx context.Context) ([]mcp.Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+
+	resources := []mcp.Resource{}
+	for _, export := range s.sorted() {
+		if !mcp.CollectionAllowed(ctx, export.collection) {
+			continue
+		}
+		resource := mcp.Resource{
+			URI:         export.uri,
+			Name:        fmt.Sprintf("Export of %s", export.collection),
+			Description: fmt.Sprintf("%d documents exported at %s", export.documents, export.createdAt.Format(time.RFC3339)),
+			MimeType:    "application/x-ndjson",
+		}
+		if !export.expiresAt.IsZero() {
+			resource.Meta = map[string]interface{}{"expiresAt": export.expiresAt.Format(time.RFC3339)}
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// ReadResource returns the export at uri as NDJSON text
+func (s *ExportStore) ReadResource(ctx context.Context, uri string) (*mcp.ResourceReadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+
+	// An export the client may not access is reported as missing, like one
+	// that never existed
+	export, ok := s.exports[uri]
+	if !ok || !mcp.CollectionAllowed(ctx, export.collection) {
+		return nil, fmt.Errorf("export not found or expired: %s", uri)
+	}
+	return &mcp.ResourceReadResponse{
+		Contents: []mcp.ResourceContent{
+			{
+				URI:      uri,
+				MimeType: "application/x-ndjson",
+				Text:     string(export.data),
+			},
+		},
+	}, nil
+}
+
+// expire drops the exports expired at now; the caller must hold s.mu
+func (s *ExportStore) expire(now time.Time) {
+	for uri, export := range s.exports {
+		if !export.expiresAt.IsZero() && !now.Before(export.expiresAt) {
+			delete(s.exports, uri)
+		}
+	}
+}
+
+// sorted returns the exports oldest first; the caller must hold s.mu
+func (s *ExportStore) sorted() []*collectionExport {
+	exports := make([]*collectionExport, 0, len(s.exports))
+	for _, export := range s.exports {
+		exports = append(exports, export)
+	}
+	sort.Slice(exports, func(i, j int) bool {
+		if !exports[i].createdAt.Equal(exports[j].createdAt) {
+			return exports[i].createdAt.Before(exports[j].createdAt)
+		}
+		return exports[i].uri < exports[j].uri
+	})
+	return exports
+}
+
+// exportURI returns the resource URI of an export of collection made at created
+func exportURI(collection string, created time.Time) string {
+	return fmt.Sprintf("%s://%s/%s.ndjson", ExportURIScheme, collection, created.UTC().Format("20060102T150405.000000000Z"))
+}
+
+// limitedBuffer is a bytes.Buffer refusing writes past max bytes, unless max is 0
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && b.Len()+len(p) > b.max {
+		return 0, errExportTooLarge
+	}
+	return b.Buffer.Write(p)
+}
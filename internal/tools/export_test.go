@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExporter writes count JSON lines for each collection, or fails with err
+type fakeExporter struct {
+	counts map[string]int
+	err    error
+}
+
+func (e *fakeExporter) ExportCollection(ctx context.Context, collection string, w io.Writer) error {
+	if e.err != nil {
+		return e.err
+	}
+	for i := 0; i < e.counts[collection]; i++ {
+		line, _ := json.Marshal(map[string]interface{}{"_id": fmt.Sprintf("%s-%d", collection, i), "title": "Document"})
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write document: %w", err)
+		}
+	}
+	return nil
+}
+
+func TestExportStore(t *testing.T) {
+	exporter := &fakeExporter{counts: map[string]int{"knowledgebase": 37, "documents": 2, "empty": 0}}
+	newTool := func(store *ExportStore) *DatabaseTool {
+		tool := NewDatabaseTool(NewMockMongoDB(true, nil))
+		tool.SetExportStore(store)
+		return tool
+	}
+	export := func(t *testing.T, tool *DatabaseTool, collection string) *mcp.ToolCallResponse {
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+			Name:      "db_export_collection",
+			Arguments: map[string]interface{}{"collection": collection},
+		})
+		require.NoError(t, err)
+		return response
+	}
+
+	t.Run("ExportIsReadableAsResource", func(t *testing.T) {
+		store := NewExportStore(exporter, time.Minute, 0)
+		tool := newTool(store)
+
+		response := export(t, tool, "knowledgebase")
+		require.False(t, response.IsError, response.Content[0].Text)
+		structured := response.StructuredContent.(map[string]interface{})
+		uri := structured["uri"].(string)
+		assert.Regexp(t, `^export://knowledgebase/\d{8}T\d{6}\.\d{9}Z\.ndjson$`, uri)
+		assert.Equal(t, 37, structured["documents"])
+		assert.Contains(t, response.Content[0].Text, "Exported 37 documents")
+		assert.Contains(t, response.Content[0].Text, uri)
+
+		read, err := store.ReadResource(context.Background(), uri)
+		require.NoError(t, err)
+		require.Len(t, read.Contents, 1)
+		assert.Equal(t, "application/x-ndjson", read.Contents[0].MimeType)
+		assert.Equal(t, structured["bytes"], len(read.Contents[0].Text))
+		lines := strings.Split(strings.TrimSuffix(read.Contents[0].Text, "\n"), "\n")
+		require.Len(t, lines, 37)
+		var first map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, "knowledgebase-0", first["_id"])
+
+		resources, err := store.ListResources(context.Background())
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		assert.Equal(t, uri, resources[0].URI)
+		assert.Contains(t, resources[0].Meta, "expiresAt")
+
+		tools, err := tool.ListTools(context.Background())
+		require.NoError(t, err)
+		exportTool := findTool(tools, "db_export_collection")
+		require.NotNil(t, exportTool)
+		normalized, err := mcp.NormalizeJSON(response.StructuredContent)
+		require.NoError(t, err)
+		assert.NoError(t, mcp.ValidateSchema(exportTool.OutputSchema, normalized))
+	})
+
+	t.Run("EachExportIsASeparateResource", func(t *testing.T) {
+		store := NewExportStore(exporter, time.Minute, 0)
+		tool := newTool(store)
+
+		first := export(t, tool, "documents").StructuredContent.(map[string]interface{})["uri"]
+		second := export(t, tool, "documents").StructuredContent.(map[string]interface{})["uri"]
+		empty := export(t, tool, "empty").StructuredContent.(map[string]interface{})
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, 0, empty["documents"])
+
+		resources, err := store.ListResources(context.Background())
+		require.NoError(t, err)
+		require.Len(t, resources, 3)
+		assert.Equal(t, first, resources[0].URI)
+		assert.Equal(t, empty["uri"], resources[2].URI)
+	})
+
+	t.Run("OldestEvicted", func(t *testing.T) {
+		store := NewExportStore(exporter, 0, 0)
+		tool := newTool(store)
+
+		first := export(t, tool, "documents").StructuredContent.(map[string]interface{})
+		assert.NotContains(t, first, "expires_at")
+		for i := 0; i < maxRetainedExports; i++ {
+			export(t, tool, "documents")
+		}
+		resources, err := store.ListResources(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, resources, maxRetainedExports)
+		_, err = store.ReadResource(context.Background(), first["uri"].(string))
+		assert.Error(t, err)
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		store := NewExportStore(exporter, 20*time.Millisecond, 0)
+		uri := export(t, newTool(store), "documents").StructuredContent.(map[string]interface{})["uri"].(string)
+
+		time.Sleep(50 * time.Millisecond)
+		_, err := store.ReadResource(context.Background(), uri)
+		assert.EqualError(t, err, "export not found or expired: "+uri)
+		resources, err := store.ListResources(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, resources)
+	})
+
+	t.Run("HiddenFromOtherTenants", func(t *testing.T) {
+		store := NewExportStore(exporter, time.Minute, 0)
+		uri := export(t, newTool(store), "knowledgebase").StructuredContent.(map[string]interface{})["uri"].(string)
+		export(t, newTool(store), "documents")
+
+		ctx := mcp.ContextWithCollectionAccess(context.Background(), func(collection string) bool {
+			return collection == "documents"
+		})
+		resources, err := store.ListResources(ctx)
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		assert.Contains(t, resources[0].URI, "export://documents/")
+
+		_, err = store.ReadResource(ctx, uri)
+		assert.EqualError(t, err, "export not found or expired: "+uri)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		response := export(t, newTool(NewExportStore(exporter, time.Minute, 100)), "knowledgebase")
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Failed to export collection 'knowledgebase': collection is larger than 100 bytes", response.Content[0].Text)
+
+		failing := &fakeExporter{err: errors.New("connection refused")}
+		response = export(t, newTool(NewExportStore(failing, time.Minute, 0)), "knowledgebase")
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Failed to export collection 'knowledgebase': connection refused", response.Content[0].Text)
+
+		response = export(t, newTool(nil), "knowledgebase")
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Collection exports are not enabled on this server", response.Content[0].Text)
+	})
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// titlePrefixLength is how many leading characters of each query word the
+// candidate regex requires; shorter words are used whole
+const titlePrefixLength = 3
+
+// maxTitleCandidates bounds the documents db_find_by_title ranks
+const maxTitleCandidates = 500
+
+// titleMatch is a document ranked by how closely its title matches a query
+type titleMatch struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Category string  `json:"category,omitempty"`
+	Score    float64 `json:"score"` // 1 for an exact match, down to 0
+}
+
+// titleWords splits text into lower-case words of letters and digits
+func titleWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// titleCandidatePattern returns a case-insensitive regular expression matching
+// titles that contain the first few characters of any query word, so titles
+// with a typo later in a word are still candidates. It returns "" when the
+// query has no words.
+func titleCandidatePattern(query string) string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, word := range titleWords(query) {
+		if runes := []rune(word); len(runes) > titlePrefixLength {
+			word = string(runes[:titlePrefixLength])
+		}
+		if !seen[word] {
+			seen[word] = true
+			prefixes = append(prefixes, regexp.QuoteMeta(word))
+		}
+	}
+	return strings.Join(prefixes, "|")
+}
+
+// titleSimilarity scores how closely title matches query, ignoring case and
+// punctuation: the edit-distance similarity of the whole title, or slightly
+// less than that of the best run of title words as long as the query, so a
+// query naming part of a long title still scores high
+func titleSimilarity(query, title string) float64 {
+	queryWords := titleWords(query)
+	words := titleWords(title)
+	if len(queryWords) == 0 || len(words) == 0 {
+		return 0
+	}
+
+	q := strings.Join(queryWords, " ")
+	best := similarity(q, strings.Join(words, " "))
+	for i := 0; i+len(queryWords) <= len(words); i++ {
+		window := strings.Join(words[i:i+len(queryWords)], " ")
+		if score := 0.95 * similarity(q, window); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// similarity is 1 minus the Levenshtein distance of a and b relative to the
+// longer of the two
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of single-rune insertions, deletions and
+// substitutions that turn a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// rankTitleMatches scores candidates against query and returns the top limit
+// scoring at least minScore, best first
+func rankTitleMatches(query string, candidates []*mcp.Document, minScore float64, limit int) []titleMatch {
+	matches := []titleMatch{}
+	for _, doc := range candidates {
+		score := titleSimilarity(query, doc.Title)
+		if score < minScore {
+			continue
+		}
+		matches = append(matches, titleMatch{
+			ID:       doc.ID,
+			Title:    doc.Title,
+			Category: doc.Category,
+			Score:    score,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Title < matches[j].Title
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
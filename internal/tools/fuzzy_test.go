@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTitleSimilarity(t *testing.T) {
+	t.Run("Levenshtein", func(t *testing.T) {
+		for _, c := range []struct {
+			a, b     string
+			distance int
+		}{
+			{"", "", 0},
+			{"kitten", "sitting", 3},
+			{"flaw", "lawn", 2},
+			{"", "abc", 3},
+			{"café", "cafe", 1},
+		} {
+			assert.Equal(t, c.distance, levenshtein([]rune(c.a), []rune(c.b)), "%q %q", c.a, c.b)
+			assert.Equal(t, c.distance, levenshtein([]rune(c.b), []rune(c.a)), "%q %q", c.b, c.a)
+		}
+	})
+
+	t.Run("Scores", func(t *testing.T) {
+		assert.Equal(t, 1.0, titleSimilarity("Kubernetes RBAC", "kubernetes rbac"))
+		assert.Equal(t, 1.0, titleSimilarity("kubernetes: rbac", "Kubernetes RBAC"))
+		assert.InDelta(t, 0.93, titleSimilarity("kubernets rbac", "Kubernetes RBAC"), 0.01)
+		// Part of a longer title scores just below an exact match
+		assert.Equal(t, 0.95, titleSimilarity("rbac", "Kubernetes RBAC"))
+		assert.Less(t, titleSimilarity("docker", "Kubernetes RBAC"), 0.5)
+		assert.Zero(t, titleSimilarity("", "Kubernetes"))
+		assert.Zero(t, titleSimilarity("kubernetes", "--"))
+	})
+
+	t.Run("CandidatePattern", func(t *testing.T) {
+		assert.Equal(t, "kub|rba", titleCandidatePattern("Kubernetes RBAC"))
+		// Punctuation separates words and repeated prefixes are listed once
+		assert.Equal(t, "go|c|and|caf", titleCandidatePattern("Go, C++ and go café"))
+		assert.Equal(t, "", titleCandidatePattern(" !? "))
+	})
+}
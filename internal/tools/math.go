@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"strconv"
@@ -17,12 +18,32 @@ func NewMathToolProvider() *MathToolProvider {
 	return &MathToolProvider{}
 }
 
+// Name returns the provider name used to namespace conflicting tool names
+func (m *MathToolProvider) Name() string {
+	return "math"
+}
+
+// mathResultSchema is the output schema shared by the math tools
+var mathResultSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"result": map[string]interface{}{
+			"type":        "number",
+			"description": "Result of the operation",
+		},
+	},
+	"required":             []string{"result"},
+	"additionalProperties": false,
+}
+
 // ListTools returns the list of available math tools
 func (m *MathToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 	return []mcp.Tool{
 		{
 			Name:        "add",
 			Description: "Add two numbers together",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -37,10 +58,13 @@ func (m *MathToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 				},
 				"required": []string{"a", "b"},
 			},
+			OutputSchema: mathResultSchema,
 		},
 		{
 			Name:        "multiply",
 			Description: "Multiply two numbers",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -55,10 +79,13 @@ func (m *MathToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 				},
 				"required": []string{"a", "b"},
 			},
+			OutputSchema: mathResultSchema,
 		},
 		{
 			Name:        "power",
 			Description: "Calculate a number raised to a power",
+			Annotations: mcp.ReadOnlyAnnotations(false),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -73,6 +100,7 @@ func (m *MathToolProvider) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 				},
 				"required": []string{"base", "exponent"},
 			},
+			OutputSchema: mathResultSchema,
 		},
 	}, nil
 }
@@ -111,14 +139,7 @@ func (m *MathToolProvider) handleAdd(request mcp.ToolCallRequest) (*mcp.ToolCall
 	}
 
 	result := a + b
-	return &mcp.ToolCallResponse{
-		Content: []mcp.Content{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("%.2f + %.2f = %.2f", a, b, result),
-			},
-		},
-	}, nil
+	return formatResult(fmt.Sprintf("%.2f + %.2f", a, b), result), nil
 }
 
 func (m *MathToolProvider) handleMultiply(request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
@@ -133,14 +154,7 @@ func (m *MathToolProvider) handleMultiply(request mcp.ToolCallRequest) (*mcp.Too
 	}
 
 	result := a * b
-	return &mcp.ToolCallResponse{
-		Content: []mcp.Content{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("%.2f × %.2f = %.2f", a, b, result),
-			},
-		},
-	}, nil
+	return formatResult(fmt.Sprintf("%.2f × %.2f", a, b), result), nil
 }
 
 func (m *MathToolProvider) handlePower(request mcp.ToolCallRequest) (*mcp.ToolCallResponse, error) {
@@ -155,14 +169,7 @@ func (m *MathToolProvider) handlePower(request mcp.ToolCallRequest) (*mcp.ToolCa
 	}
 
 	result := math.Pow(base, exponent)
-	return &mcp.ToolCallResponse{
-		Content: []mcp.Content{
-			{
-				Type: "text",
-				Text: fmt.Sprintf("%.2f^%.2f = %.2f", base, exponent, result),
-			},
-		},
-	}, nil
+	return formatResult(fmt.Sprintf("%.2f^%.2f", base, exponent), result), nil
 }
 
 // Helper functions
@@ -177,6 +184,12 @@ func getNumberArg(args map[string]interface{}, key string) (float64, error) {
 		return v, nil
 	case int:
 		return float64(v), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid number format for %s: %s", key, v)
+		}
+		return f, nil
 	case string:
 		f, err := strconv.ParseFloat(v, 64)
 		if err != nil {
@@ -188,14 +201,26 @@ func getNumberArg(args map[string]interface{}, key string) (float64, error) {
 	}
 }
 
-func errorResponse(message string) *mcp.ToolCallResponse {
+// formatResult returns the text and structured result of a math tool, the
+// text being expression = result. Every handler reports its result through
+// it, so that an overflow (an infinite result) or an undefined result such as
+// 0/0 (NaN), neither of which JSON can represent, is an error naming the
+// expression rather than a "+Inf" or "NaN" answer.
+func formatResult(expression string, result float64) *mcp.ToolCallResponse {
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return errorResponse(fmt.Sprintf("%s: result is not a finite number", expression))
+	}
 	return &mcp.ToolCallResponse{
 		Content: []mcp.Content{
 			{
 				Type: "text",
-				Text: message,
+				Text: fmt.Sprintf("%s = %.2f", expression, result),
 			},
 		},
-		IsError: true,
+		StructuredContent: map[string]interface{}{"result": result},
 	}
 }
+
+func errorResponse(message string) *mcp.ToolCallResponse {
+	return mcp.NewToolError(message, nil)
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMathToolProvider(t *testing.T) {
+	provider := NewMathToolProvider()
+	call := func(t *testing.T, name string, args map[string]interface{}) *mcp.ToolCallResponse {
+		response, err := provider.CallTool(context.Background(), mcp.ToolCallRequest{Name: name, Arguments: args})
+		require.NoError(t, err)
+		require.NotEmpty(t, response.Content)
+		return response
+	}
+
+	t.Run("FiniteResults", func(t *testing.T) {
+		response := call(t, "power", map[string]interface{}{"base": 2.0, "exponent": 10.0})
+		assert.False(t, response.IsError)
+		assert.Equal(t, "2.00^10.00 = 1024.00", response.Content[0].Text)
+		assert.Equal(t, map[string]interface{}{"result": 1024.0}, response.StructuredContent)
+
+		response = call(t, "add", map[string]interface{}{"a": 1.5, "b": "2"})
+		assert.False(t, response.IsError)
+		assert.Equal(t, "1.50 + 2.00 = 3.50", response.Content[0].Text)
+	})
+
+	t.Run("NonFiniteResults", func(t *testing.T) {
+		tests := []struct {
+			name string
+			tool string
+			args map[string]interface{}
+			text string
+		}{
+			{"PowerOverflow", "power", map[string]interface{}{"base": 10.0, "exponent": 400.0}, "10.00^400.00"},
+			// There is no divide tool; a negative power of zero divides by zero
+			{"DivisionByZero", "power", map[string]interface{}{"base": 0.0, "exponent": -1.0}, "0.00^-1.00"},
+			{"MultiplyOverflow", "multiply", map[string]interface{}{"a": 1e308, "b": 10.0}, ""},
+			{"AddOverflow", "add", map[string]interface{}{"a": 1.7e308, "b": 1.7e308}, ""},
+			{"Undefined", "power", map[string]interface{}{"base": -8.0, "exponent": 1.0 / 3}, "-8.00^0.33"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				response := call(t, tc.tool, tc.args)
+				assert.True(t, response.IsError)
+				assert.Nil(t, response.StructuredContent)
+				text := response.Content[0].Text
+				assert.Contains(t, text, "result is not a finite number")
+				assert.NotContains(t, text, "Inf")
+				assert.NotContains(t, text, "NaN")
+				if tc.text != "" {
+					assert.Equal(t, tc.text+": result is not a finite number", text)
+				}
+			})
+		}
+	})
+}
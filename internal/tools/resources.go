@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kringen/go-mcp-server/internal/database"
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// DocumentURITemplate is the URI template of documents served as resources
+const DocumentURITemplate = "mongodb://{collection}/{id}"
+
+// DocumentResourceProvider serves database documents as MCP resources. Documents
+// are not listed; clients build their URIs from DocumentURITemplate.
+type DocumentResourceProvider struct {
+	db database.DocumentStore
+}
+
+// NewDocumentResourceProvider creates a new DocumentResourceProvider
+func NewDocumentResourceProvider(db database.DocumentStore) *DocumentResourceProvider {
+	return &DocumentResourceProvider{
+		db: db,
+	}
+}
+
+// ListResources returns no resources: a collection can hold any number of
+// documents, so they are only reachable through the resource template
+func (p *DocumentResourceProvider) ListResources(ctx context.Context) ([]mcp.Resource, error) {
+	return []mcp.Resource{}, nil
+}
+
+// ListResourceTemplates returns the document URI template
+func (p *DocumentResourceProvider) ListResourceTemplates(ctx context.Context) ([]mcp.ResourceTemplate, error) {
+	return []mcp.ResourceTemplate{
+		{
+			URITemplate: DocumentURITemplate,
+			Name:        "MongoDB document",
+			Description: "A document by collection and ID, as JSON",
+			MimeType:    "application/json",
+		},
+	}, nil
+}
+
+// ReadResource returns the document addressed by uri as JSON, with an ETag
+// for conditional reads
+func (p *DocumentResourceProvider) ReadResource(ctx context.Context, uri string) (*mcp.ResourceReadResponse, error) {
+	values, ok := mcp.MatchURITemplate(DocumentURITemplate, uri)
+	if !ok {
+		return nil, fmt.Errorf("not a document URI: %s", uri)
+	}
+
+	collection := values["collection"]
+	if err := p.db.ValidateCollectionName(collection); err != nil {
+		return nil, fmt.Errorf("invalid collection: %v", err)
+	}
+	if !mcp.CollectionAllowed(ctx, collection) {
+		return nil, fmt.Errorf("access denied to collection %s", collection)
+	}
+
+	doc, err := p.db.GetDocument(ctx, collection, values["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode document: %w", err)
+	}
+
+	return &mcp.ResourceReadResponse{
+		Contents: []mcp.ResourceContent{
+			{
+				URI:      uri,
+				MimeType: "application/json",
+				Text:     string(data),
+				ETag:     documentETag(doc),
+			},
+		},
+	}, nil
+}
+
+// documentETag returns a validator for the current version of a document. It
+// changes whenever the document is written, since every write bumps its version
+// and updated_at.
+func documentETag(doc *mcp.Document) string {
+	return fmt.Sprintf(`"%d-%x"`, doc.Version, doc.UpdatedAt.UnixNano())
+}
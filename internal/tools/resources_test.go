@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentResourceProvider(t *testing.T) {
+	mockDB := NewMockMongoDB(true, nil)
+	mockDB.documents["doc-1"] = &mcp.Document{ID: "doc-1", Title: "Pods", Category: "Kubernetes"}
+	provider := NewDocumentResourceProvider(mockDB)
+
+	t.Run("ListResourceTemplates", func(t *testing.T) {
+		templates, err := provider.ListResourceTemplates(context.Background())
+		require.NoError(t, err)
+		require.Len(t, templates, 1)
+		assert.Equal(t, "mongodb://{collection}/{id}", templates[0].URITemplate)
+		assert.Equal(t, "application/json", templates[0].MimeType)
+
+		data, err := json.Marshal(templates[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"uriTemplate":"mongodb://{collection}/{id}"`)
+	})
+
+	t.Run("ListResources", func(t *testing.T) {
+		resources, err := provider.ListResources(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, resources)
+	})
+
+	t.Run("ReadResource", func(t *testing.T) {
+		response, err := provider.ReadResource(context.Background(), "mongodb://documents/doc-1")
+		require.NoError(t, err)
+		require.Len(t, response.Contents, 1)
+		assert.Equal(t, "mongodb://documents/doc-1", response.Contents[0].URI)
+
+		var doc mcp.Document
+		require.NoError(t, json.Unmarshal([]byte(response.Contents[0].Text), &doc))
+		assert.Equal(t, "Pods", doc.Title)
+	})
+
+	t.Run("ReadResource_ETag", func(t *testing.T) {
+		read := func() string {
+			response, err := provider.ReadResource(context.Background(), "mongodb://documents/doc-1")
+			require.NoError(t, err)
+			require.Len(t, response.Contents, 1)
+			require.NotEmpty(t, response.Contents[0].ETag)
+			return response.Contents[0].ETag
+		}
+
+		etag := read()
+		assert.Equal(t, etag, read(), "unchanged document")
+
+		// A write bumps the version and updated_at
+		doc := mockDB.documents["doc-1"]
+		doc.Version++
+		doc.UpdatedAt = doc.UpdatedAt.Add(time.Second)
+		assert.NotEqual(t, etag, read(), "modified document")
+	})
+
+	t.Run("ReadResource_Invalid", func(t *testing.T) {
+		for _, uri := range []string{
+			"mongodb://documents",
+			"mongodb://system.users/doc-1",
+			"mongodb://documents/missing",
+		} {
+			_, err := provider.ReadResource(context.Background(), uri)
+			assert.Error(t, err, uri)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Field types a CollectionSchema can require. FieldTypeAny only requires the
+// field to be present.
+const (
+	FieldTypeAny     = "any"
+	FieldTypeString  = "string"
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeArray   = "array"
+	FieldTypeObject  = "object"
+)
+
+// documentFieldTypes are the document fields a schema can require besides
+// metadata entries, with the only type each can have
+var documentFieldTypes = map[string]string{
+	"title":    FieldTypeString,
+	"content":  FieldTypeString,
+	"category": FieldTypeString,
+	"tags":     FieldTypeArray,
+}
+
+// CollectionSchema lists the fields every document of a collection must
+// have, mapped to their type: title, content, category and tags, or metadata
+// entries as "metadata.<key>", with dots for nested keys
+type CollectionSchema map[string]string
+
+// CollectionSchemas maps collection names to the schema of their documents.
+// Collections without an entry accept any document.
+type CollectionSchemas map[string]CollectionSchema
+
+// ParseCollectionSchemas parses collection schemas from JSON, e.g.
+// {"knowledgebase": {"category": "string", "metadata.author": "string"}}
+func ParseCollectionSchemas(data []byte) (CollectionSchemas, error) {
+	var schemas CollectionSchemas
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, fmt.Errorf("invalid collection schemas: %w", err)
+	}
+	for collection, schema := range schemas {
+		if err := schema.check(); err != nil {
+			return nil, fmt.Errorf("invalid schema for collection %s: %w", collection, err)
+		}
+	}
+	return schemas, nil
+}
+
+// LoadCollectionSchemas reads collection schemas from a JSON file. An empty
+// path loads none.
+func LoadCollectionSchemas(path string) (CollectionSchemas, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection schemas: %w", err)
+	}
+	return ParseCollectionSchemas(data)
+}
+
+// check rejects fields documents cannot have and unknown types
+func (s CollectionSchema) check() error {
+	for field, fieldType := range s {
+		switch fieldType {
+		case FieldTypeAny, FieldTypeString, FieldTypeNumber, FieldTypeBoolean, FieldTypeArray, FieldTypeObject:
+		default:
+			return fmt.Errorf("field %s has unknown type %q", field, fieldType)
+		}
+
+		if key, ok := strings.CutPrefix(field, "metadata."); ok {
+			for _, name := range strings.Split(key, ".") {
+				if name == "" {
+					return fmt.Errorf("invalid field %q", field)
+				}
+			}
+			continue
+		}
+		native, ok := documentFieldTypes[field]
+		if !ok {
+			return fmt.Errorf("unknown field %q: expected title, content, category, tags or metadata.<key>", field)
+		}
+		if fieldType != FieldTypeAny && fieldType != native {
+			return fmt.Errorf("field %s is always a %s", field, native)
+		}
+	}
+	return nil
+}
+
+// Validate reports the first field, in name order, that doc is missing or
+// that has the wrong type
+func (s CollectionSchema) Validate(doc *mcp.Document) error {
+	fields := make([]string, 0, len(s))
+	for field := range s {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		value, ok := documentField(doc, field)
+		if !ok {
+			return fmt.Errorf("missing required field %s", field)
+		}
+		if want := s[field]; want != FieldTypeAny && fieldType(value) != want {
+			return fmt.Errorf("field %s must be of type %s", field, want)
+		}
+	}
+	return nil
+}
+
+// ValidateSet reports the first field, in name order, that a bulk $set of
+// fields would remove or give the wrong type, whether it sets the field
+// itself, an object holding it, or an entry inside it. Fields it does not
+// touch are left as they are, so they are not checked.
+func (s CollectionSchema) ValidateSet(fields map[string]interface{}) error {
+	schemaFields := make([]string, 0, len(s))
+	for field := range s {
+		schemaFields = append(schemaFields, field)
+	}
+	sort.Strings(schemaFields)
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, field := range schemaFields {
+		want := s[field]
+		for _, path := range paths {
+			value := fields[path]
+			switch {
+			case path == field:
+			case strings.HasPrefix(field, path+"."):
+				// An object replaced as a whole must still hold the field
+				var ok bool
+				for _, name := range strings.Split(strings.TrimPrefix(field, path+"."), ".") {
+					if value, ok = lookupKey(value, name); !ok {
+						return fmt.Errorf("missing required field %s", field)
+					}
+				}
+			case strings.HasPrefix(path, field+"."):
+				// Setting an entry inside the field makes it an object
+				if want != FieldTypeAny && want != FieldTypeObject {
+					return fmt.Errorf("field %s must be of type %s", field, want)
+				}
+				continue
+			default:
+				continue
+			}
+
+			if missingValue(field, value) {
+				return fmt.Errorf("missing required field %s", field)
+			}
+			if want != FieldTypeAny && fieldType(value) != want {
+				return fmt.Errorf("field %s must be of type %s", field, want)
+			}
+		}
+	}
+	return nil
+}
+
+// missingValue reports whether field counts as missing when set to value:
+// nil does, and so do empty strings and lists, as in documentField, for the
+// document fields other than metadata
+func missingValue(field string, value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if _, ok := documentFieldTypes[field]; !ok {
+		return false
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// documentField returns the value of a schema field in doc; empty strings
+// and tag lists count as missing
+func documentField(doc *mcp.Document, field string) (interface{}, bool) {
+	switch field {
+	case "title":
+		return doc.Title, doc.Title != ""
+	case "content":
+		return doc.Content, doc.Content != ""
+	case "category":
+		return doc.Category, doc.Category != ""
+	case "tags":
+		return doc.Tags, len(doc.Tags) > 0
+	}
+
+	key, _ := strings.CutPrefix(field, "metadata.")
+	var value interface{} = doc.Metadata
+	for _, name := range strings.Split(key, ".") {
+		var ok bool
+		if value, ok = lookupKey(value, name); !ok {
+			return nil, false
+		}
+	}
+	return value, value != nil
+}
+
+// lookupKey returns the entry name of an object value: a map with string
+// keys, or a bson.D decoded from the database
+func lookupKey(object interface{}, name string) (interface{}, bool) {
+	if d, ok := object.(bson.D); ok {
+		for _, element := range d {
+			if element.Key == name {
+				return element.Value, true
+			}
+		}
+		return nil, false
+	}
+
+	m := reflect.ValueOf(object)
+	if m.Kind() != reflect.Map || m.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	entry := m.MapIndex(reflect.ValueOf(name).Convert(m.Type().Key()))
+	if !entry.IsValid() {
+		return nil, false
+	}
+	return entry.Interface(), true
+}
+
+// fieldType names the JSON type of value, whether it came from a tool call
+// or was decoded from the database
+func fieldType(value interface{}) string {
+	switch value.(type) {
+	case json.Number:
+		return FieldTypeNumber
+	case bson.D:
+		return FieldTypeObject
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return FieldTypeString
+	case reflect.Bool:
+		return FieldTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return FieldTypeNumber
+	case reflect.Slice, reflect.Array:
+		return FieldTypeArray
+	case reflect.Map:
+		return FieldTypeObject
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCollectionSchemas(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		schemas, err := ParseCollectionSchemas([]byte(`{
+			"knowledgebase": {"category": "string", "tags": "any", "metadata.review.score": "number"}
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, CollectionSchemas{
+			"knowledgebase": {"category": "string", "tags": "any", "metadata.review.score": "number"},
+		}, schemas)
+
+		invalid := []string{
+			`["knowledgebase"]`,
+			`{"knowledgebase": {"category": "text"}}`,
+			`{"knowledgebase": {"author": "string"}}`,
+			`{"knowledgebase": {"title": "number"}}`,
+			`{"knowledgebase": {"metadata..author": "string"}}`,
+		}
+		for _, data := range invalid {
+			_, err := ParseCollectionSchemas([]byte(data))
+			assert.Error(t, err, data)
+		}
+	})
+
+	t.Run("Load", func(t *testing.T) {
+		schemas, err := LoadCollectionSchemas("")
+		require.NoError(t, err)
+		assert.Nil(t, schemas)
+
+		path := filepath.Join(t.TempDir(), "schemas.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"knowledgebase": {"category": "string"}}`), 0o600))
+		schemas, err = LoadCollectionSchemas(path)
+		require.NoError(t, err)
+		assert.Equal(t, CollectionSchemas{"knowledgebase": {"category": "string"}}, schemas)
+
+		_, err = LoadCollectionSchemas(filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorContains(t, err, "failed to read collection schemas")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		schema := CollectionSchema{"category": "string", "metadata.review.score": "number", "metadata.draft": "boolean"}
+		valid := []map[string]interface{}{
+			{"review": map[string]interface{}{"score": 4.5}, "draft": false},
+			{"review": map[string]interface{}{"score": json.Number("4")}, "draft": true},
+			// As decoded from the database
+			{"review": bson.D{{Key: "score", Value: int32(4)}}, "draft": false},
+		}
+		for _, metadata := range valid {
+			assert.NoError(t, schema.Validate(&mcp.Document{Category: "guides", Metadata: metadata}), "%v", metadata)
+		}
+
+		err := schema.Validate(&mcp.Document{Metadata: valid[0]})
+		assert.EqualError(t, err, "missing required field category")
+		err = schema.Validate(&mcp.Document{Category: "guides", Metadata: map[string]interface{}{"draft": false}})
+		assert.EqualError(t, err, "missing required field metadata.review.score")
+		err = schema.Validate(&mcp.Document{Category: "guides", Metadata: map[string]interface{}{
+			"review": map[string]interface{}{"score": "great"}, "draft": false,
+		}})
+		assert.EqualError(t, err, "field metadata.review.score must be of type number")
+	})
+
+	t.Run("ValidateSet", func(t *testing.T) {
+		schema := CollectionSchema{"category": "string", "metadata.review.score": "number", "metadata.extra": "object"}
+		valid := []map[string]interface{}{
+			{"title": "Renamed"},
+			{"category": "guides"},
+			{"metadata.review.score": 4},
+			{"metadata.review": map[string]interface{}{"score": json.Number("4")}},
+			{"metadata": map[string]interface{}{"review": map[string]interface{}{"score": 4.5}, "extra": map[string]interface{}{}}},
+			{"metadata.extra.source": "import"},
+			{"metadata.author": nil},
+		}
+		for _, fields := range valid {
+			assert.NoError(t, schema.ValidateSet(fields), "%v", fields)
+		}
+
+		rejected := []struct {
+			fields  map[string]interface{}
+			message string
+		}{
+			{map[string]interface{}{"category": nil}, "missing required field category"},
+			{map[string]interface{}{"category": ""}, "missing required field category"},
+			{map[string]interface{}{"category": 7}, "field category must be of type string"},
+			{map[string]interface{}{"metadata.review.score": "great"}, "field metadata.review.score must be of type number"},
+			{map[string]interface{}{"metadata.review.score": nil}, "missing required field metadata.review.score"},
+			{map[string]interface{}{"metadata.review": map[string]interface{}{"stars": 4}}, "missing required field metadata.review.score"},
+			{map[string]interface{}{"metadata": map[string]interface{}{"extra": map[string]interface{}{}}}, "missing required field metadata.review.score"},
+			{map[string]interface{}{"metadata.review.score.value": 4}, "field metadata.review.score must be of type number"},
+		}
+		for _, c := range rejected {
+			assert.EqualError(t, schema.ValidateSet(c.fields), c.message, "%v", c.fields)
+		}
+
+		err := CollectionSchema{"tags": "array"}.ValidateSet(map[string]interface{}{"tags": []interface{}{}})
+		assert.EqualError(t, err, "missing required field tags")
+	})
+
+	t.Run("EnforcedByTools", func(t *testing.T) {
+		mockDB := NewMockMongoDB(true, nil)
+		tool := NewDatabaseTool(mockDB)
+		tool.SetCollectionSchemas(CollectionSchemas{"knowledgebase": {"category": "string"}})
+		call := func(name string, args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: name, Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+		document := func(collection, category string) map[string]interface{} {
+			args := map[string]interface{}{"collection": collection, "title": "SSL", "content": "Certificates"}
+			if category != "" {
+				args["category"] = category
+			}
+			return args
+		}
+
+		response := call("db_create_document", document("knowledgebase", ""))
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Invalid document: missing required field category", response.Content[0].Text)
+		assert.Empty(t, mockDB.documents)
+
+		response = call("db_create_document", document("knowledgebase", "Security"))
+		require.False(t, response.IsError, response.Content[0].Text)
+		require.Len(t, mockDB.documents, 1)
+		var id string
+		for docID, doc := range mockDB.documents {
+			id = docID
+			assert.Equal(t, "Security", doc.Category)
+		}
+
+		// Other collections accept documents without a category
+		response = call("db_create_document", document("notes", ""))
+		assert.False(t, response.IsError, response.Content[0].Text)
+
+		response = call("db_update_document", map[string]interface{}{"collection": "knowledgebase", "id": id, "category": "TLS"})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, "TLS", mockDB.documents[id].Category)
+
+		// An update cannot leave a document without its required fields
+		mockDB.documents["legacy"] = &mcp.Document{ID: "legacy", Title: "Old", Content: "Uncategorized"}
+		response = call("db_update_document", map[string]interface{}{"collection": "knowledgebase", "id": "legacy", "title": "Renamed"})
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Invalid document: missing required field category", response.Content[0].Text)
+
+		// Nor can a bulk update remove them or change their type
+		for _, set := range []map[string]interface{}{{"category": nil}, {"category": 42}} {
+			response = call("db_update_many", map[string]interface{}{
+				"collection": "knowledgebase",
+				"filter":     map[string]interface{}{"category": "TLS"},
+				"set":        set,
+			})
+			assert.True(t, response.IsError, "%v", set)
+			assert.Contains(t, response.Content[0].Text, "Invalid 'set' parameter: ")
+			assert.Equal(t, "TLS", mockDB.documents[id].Category)
+		}
+		response = call("db_update_many", map[string]interface{}{
+			"collection": "knowledgebase",
+			"filter":     map[string]interface{}{"category": "TLS"},
+			"set":        map[string]interface{}{"category": "Security"},
+		})
+		require.False(t, response.IsError, response.Content[0].Text)
+		assert.Equal(t, "Security", mockDB.documents[id].Category)
+		response = call("db_update_many", map[string]interface{}{
+			"collection": "notes",
+			"filter":     map[string]interface{}{"title": "SSL"},
+			"set":        map[string]interface{}{"category": nil},
+		})
+		assert.False(t, response.IsError, response.Content[0].Text)
+	})
+}
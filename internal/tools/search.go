@@ -2,18 +2,24 @@ package tools
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kringen/go-mcp-server/internal/search"
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 )
 
+// Chunk sizes for web_search content streamed with stream_content
+const (
+	defaultStreamChunkSize = 8192
+	minStreamChunkSize     = 256
+)
+
 // SearchTool provides web search capabilities as an MCP tool
 type SearchTool struct {
-	searcher search.WebSearcher
+	searcher   search.WebSearcher
+	prettyJSON bool
 }
 
 // NewSearchTool creates a new SearchTool
@@ -23,12 +29,24 @@ func NewSearchTool(searcher search.WebSearcher) *SearchTool {
 	}
 }
 
+// SetPrettyJSON switches the JSON embedded in tool results between indented and compact
+func (s *SearchTool) SetPrettyJSON(pretty bool) {
+	s.prettyJSON = pretty
+}
+
+// Name returns the provider name used to namespace conflicting tool names
+func (s *SearchTool) Name() string {
+	return "search"
+}
+
 // ListTools returns the available search tools
 func (s *SearchTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 	return []mcp.Tool{
 		{
 			Name:        "web_search",
 			Description: "Search the web for information",
+			Annotations: mcp.ReadOnlyAnnotations(true),
+			Cacheable:   true,
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -42,9 +60,22 @@ func (s *SearchTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 						"minimum":     1,
 						"maximum":     50,
 					},
+					"page_token": map[string]interface{}{
+						"type":        "string",
+						"description": "Token from a previous result's next page line, to continue that search",
+					},
 					"include_content": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Whether to fetch full content from result pages (default: false)",
+						"description": "Whether to fetch full content and a preview image (og:image or favicon) from result pages (default: false)",
+					},
+					"stream_content": map[string]interface{}{
+						"type":        "boolean",
+						"description": "With include_content, send each page's full content as notifications/tools/content chunks tied to the request's progress token, leaving only its length in the response (default: false; needs a progressToken in the request's _meta)",
+					},
+					"chunk_size": map[string]interface{}{
+						"type":        "integer",
+						"description": fmt.Sprintf("Largest streamed chunk in bytes (default: %d)", defaultStreamChunkSize),
+						"minimum":     minStreamChunkSize,
 					},
 					"language": map[string]interface{}{
 						"type":        "string",
@@ -52,19 +83,75 @@ func (s *SearchTool) ListTools(ctx context.Context) ([]mcp.Tool, error) {
 					},
 					"region": map[string]interface{}{
 						"type":        "string",
-						"description": "Region preference for search results",
+						"description": "Region preference for search results, e.g. us-en (default: the server's configured region, if any)",
 					},
 					"safe_search": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Enable safe search filtering (default: true)",
 					},
+					"no_cache": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Skip cached results and search afresh, refreshing the cache, e.g. for breaking news (default: false)",
+					},
+					"filters": map[string]interface{}{
+						"type":        "object",
+						"description": "Post-filters applied to results. include_domain / exclude_domain: regular expressions matched against the result host; title_contains: case-insensitive text the title must contain",
+						"properties": map[string]interface{}{
+							search.FilterIncludeDomain: map[string]interface{}{"type": "string"},
+							search.FilterExcludeDomain: map[string]interface{}{"type": "string"},
+							search.FilterTitleContains: map[string]interface{}{"type": "string"},
+						},
+						"additionalProperties": false,
+					},
 				},
 				"required": []string{"query"},
 			},
 		},
+		{
+			Name:        "summarize_search",
+			Description: "Search the web and summarize the results with the client's own model (requires a client that supports sampling)",
+			Annotations: mcp.ReadOnlyAnnotations(true),
+			Cacheable:   false, // summaries depend on each client's model
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "The search query",
+					},
+					"max_results": map[string]interface{}{
+						"type":        "integer",
+						"description": "Search results to summarize (default: 5, max: 20)",
+						"minimum":     1,
+						"maximum":     20,
+					},
+					"max_tokens": map[string]interface{}{
+						"type":        "integer",
+						"description": "Longest summary the client's model may write, in tokens (default: 500)",
+						"minimum":     1,
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+		{
+			Name:        "search_clear_cache",
+			Description: "Evict cached web search results for a query, or the whole search cache when no query is given",
+			Annotations: &mcp.ToolAnnotations{IdempotentHint: true},
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Query whose cached results are evicted, compared without regard to case (default: clear every cached result)",
+					},
+				},
+			},
+		},
 		{
 			Name:        "search_health_check",
 			Description: "Check if the web search service is healthy",
+			Annotations: mcp.ReadOnlyAnnotations(true),
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{},
@@ -78,6 +165,10 @@ func (s *SearchTool) CallTool(ctx context.Context, request mcp.ToolCallRequest)
 	switch request.Name {
 	case "web_search":
 		return s.webSearch(ctx, request.Arguments)
+	case "summarize_search":
+		return s.summarizeSearch(ctx, request.Arguments)
+	case "search_clear_cache":
+		return s.clearCache(request.Arguments)
 	case "search_health_check":
 		return s.healthCheck(ctx)
 	default:
@@ -93,9 +184,9 @@ func (s *SearchTool) CallTool(ctx context.Context, request mcp.ToolCallRequest)
 	}
 }
 
-func (s *SearchTool) webSearch(ctx context.Context, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
+func (s *SearchTool) webSearch(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
 	// Extract query
-	queryStr, ok := args["query"].(string)
+	queryStr, ok := args.String("query")
 	if !ok || queryStr == "" {
 		return s.errorResponse("Missing or invalid 'query' parameter"), nil
 	}
@@ -108,39 +199,48 @@ func (s *SearchTool) webSearch(ctx context.Context, args map[string]interface{})
 	}
 
 	// Extract optional parameters
-	if maxResults, ok := args["max_results"]; ok {
-		if mr, err := s.toInt(maxResults); err == nil && mr > 0 && mr <= 50 {
-			searchQuery.MaxResults = mr
-		}
+	if mr, ok := args.Int("max_results"); ok && mr > 0 && mr <= 50 {
+		searchQuery.MaxResults = mr
 	}
 
-	if lang, ok := args["language"].(string); ok {
+	if lang, ok := args.String("language"); ok {
 		searchQuery.Language = lang
 	}
 
-	if region, ok := args["region"].(string); ok {
+	if region, ok := args.String("region"); ok {
 		searchQuery.Region = region
 	}
 
-	if safeSearch, ok := args["safe_search"].(bool); ok {
-		searchQuery.SafeSearch = safeSearch
+	searchQuery.SafeSearch = args.Bool("safe_search", searchQuery.SafeSearch)
+	searchQuery.NoCache = args.Bool("no_cache", false)
+
+	if args.Has("filters") {
+		filters, err := s.parseFilters(args["filters"])
+		if err != nil {
+			return s.errorResponse(fmt.Sprintf("Invalid 'filters' parameter: %v", err)), nil
+		}
+		searchQuery.Filters = filters
 	}
 
-	includeContent := false
-	if ic, ok := args["include_content"].(bool); ok {
-		includeContent = ic
+	if token, ok := args.String("page_token"); ok {
+		offset, err := search.DecodePageToken(searchQuery, token)
+		if err != nil {
+			return s.errorResponse(fmt.Sprintf("Invalid 'page_token' parameter: %v", err)), nil
+		}
+		searchQuery.Offset = offset
 	}
 
+	includeContent := args.Bool("include_content", false)
+
 	// Perform search
 	var results []*mcp.SearchResult
+	var nextPage string
 	var err error
 
-	if includeContent {
-		if contentSearcher, ok := s.searcher.(*search.CollySearcher); ok {
-			results, err = contentSearcher.SearchWithContent(ctx, searchQuery)
-		} else {
-			// Fallback to regular search
-			results, err = s.searcher.Search(ctx, searchQuery)
+	if pagedSearcher, ok := s.searcher.(search.PagedSearcher); ok {
+		var page *mcp.SearchPage
+		if page, err = pagedSearcher.SearchPage(ctx, searchQuery); err == nil {
+			results, nextPage = page.Results, page.NextPage
 		}
 	} else {
 		results, err = s.searcher.Search(ctx, searchQuery)
@@ -150,9 +250,43 @@ func (s *SearchTool) webSearch(ctx context.Context, args map[string]interface{})
 		return s.errorResponse(fmt.Sprintf("Search failed: %v", err)), nil
 	}
 
+	if includeContent {
+		if contentSearcher, ok := s.searcher.(*search.CollySearcher); ok {
+			if err := contentSearcher.AddContent(ctx, results); err != nil {
+				return s.errorResponse(fmt.Sprintf("Search failed: %v", err)), nil
+			}
+		}
+	}
+
 	// Format results
 	content := []mcp.Content{}
 
+	// streamed holds the length of each result's streamed content
+	var streamed []int
+	if includeContent && args.Bool("stream_content", false) {
+		streamer, ok := mcp.ContentStreamerFromContext(ctx)
+		if !ok {
+			content = append(content, mcp.Content{
+				Type: "text",
+				Text: "Page content is included below instead of streamed: streaming needs a progressToken in the request's _meta.\n",
+			})
+		} else {
+			chunkSize := defaultStreamChunkSize
+			if cs, ok := args.Int("chunk_size"); ok && cs >= minStreamChunkSize {
+				chunkSize = cs
+			}
+			var total, chunks int
+			var err error
+			if results, streamed, total, chunks, err = streamResultContent(ctx, streamer, results, chunkSize); err != nil {
+				return s.errorResponse(fmt.Sprintf("Streaming content failed: %v", err)), nil
+			}
+			content = append(content, mcp.Content{
+				Type: "text",
+				Text: fmt.Sprintf("Streamed %d bytes of page content in %d chunks.\n", total, chunks),
+			})
+		}
+	}
+
 	if len(results) == 0 {
 		content = append(content, mcp.Content{
 			Type: "text",
@@ -166,11 +300,14 @@ func (s *SearchTool) webSearch(ctx context.Context, args map[string]interface{})
 		})
 
 		// Individual results
+		// Numbering continues across pages
 		for i, result := range results {
 			resultText := fmt.Sprintf("%d. **%s**\n   URL: %s\n   Description: %s\n",
-				i+1, result.Title, result.URL, result.Description)
+				searchQuery.Offset+i+1, result.Title, result.URL, result.Description)
 
-			if includeContent && result.Content != "" {
+			if streamed != nil {
+				resultText += fmt.Sprintf("   Content: %d bytes, streamed\n", streamed[i])
+			} else if includeContent && result.Content != "" {
 				// Truncate content for display
 				content := result.Content
 				if len(content) > 500 {
@@ -179,6 +316,10 @@ func (s *SearchTool) webSearch(ctx context.Context, args map[string]interface{})
 				resultText += fmt.Sprintf("   Content: %s\n", content)
 			}
 
+			if includeContent && result.ImageURL != "" {
+				resultText += fmt.Sprintf("   Image: %s\n", result.ImageURL)
+			}
+
 			resultText += fmt.Sprintf("   Timestamp: %s\n\n", result.Timestamp.Format(time.RFC3339))
 
 			content = append(content, mcp.Content{
@@ -188,18 +329,146 @@ func (s *SearchTool) webSearch(ctx context.Context, args map[string]interface{})
 		}
 
 		// Add JSON data for programmatic access
-		jsonData, _ := json.Marshal(results)
+		jsonData, _ := mcp.FormatJSON(results, s.prettyJSON)
 		content = append(content, mcp.Content{
 			Type: "text",
 			Text: fmt.Sprintf("Raw JSON data:\n```json\n%s\n```", string(jsonData)),
 		})
 	}
 
+	if nextPage != "" {
+		content = append(content, mcp.Content{
+			Type: "text",
+			Text: fmt.Sprintf("More results are available. Next page token: %s", nextPage),
+		})
+	}
+
 	return &mcp.ToolCallResponse{
 		Content: content,
 	}, nil
 }
 
+// streamResultContent sends the content of each result with content to the
+// client in chunks of at most chunkSize bytes. It returns copies of the
+// results without their content, the length of each result's content, and
+// the bytes and chunks sent.
+func streamResultContent(ctx context.Context, streamer mcp.ContentStreamer, results []*mcp.SearchResult, chunkSize int) (stripped []*mcp.SearchResult, lengths []int, total, chunks int, err error) {
+	stripped = make([]*mcp.SearchResult, len(results))
+	lengths = make([]int, len(results))
+	for i, result := range results {
+		if result.Content != "" {
+			sent, err := mcp.StreamText(ctx, streamer, result.URL, result.Content, chunkSize)
+			chunks += sent
+			if err != nil {
+				return nil, nil, total, chunks, err
+			}
+			lengths[i] = len(result.Content)
+			total += len(result.Content)
+		}
+		copied := *result
+		copied.Content = ""
+		stripped[i] = &copied
+	}
+	return stripped, lengths, total, chunks, nil
+}
+
+// summarizeSearch runs a web search and asks the calling client's model, through
+// sampling, to summarize the results
+func (s *SearchTool) summarizeSearch(ctx context.Context, args mcp.Args) (*mcp.ToolCallResponse, error) {
+	queryStr, ok := args.String("query")
+	if !ok || queryStr == "" {
+		return s.errorResponse("Missing or invalid 'query' parameter"), nil
+	}
+
+	maxResults := 5 // default
+	if mr, ok := args.Int("max_results"); ok && mr > 0 && mr <= 20 {
+		maxResults = mr
+	}
+
+	maxTokens := 500 // default
+	if mt, ok := args.Int("max_tokens"); ok && mt > 0 {
+		maxTokens = mt
+	}
+
+	sampler, ok := mcp.SamplerFromContext(ctx)
+	if !ok {
+		return s.errorResponse("Summarizing requires a client that supports sampling"), nil
+	}
+
+	results, err := s.searcher.Search(ctx, mcp.SearchQuery{
+		Query:      queryStr,
+		MaxResults: maxResults,
+		SafeSearch: true,
+	})
+	if err != nil {
+		return s.errorResponse(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+	if len(results) == 0 {
+		return &mcp.ToolCallResponse{
+			Content: []mcp.Content{{Type: "text", Text: "No search results found."}},
+		}, nil
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Summarize what these web search results say about %q. Be concise and cite the results by number.\n\n", queryStr)
+	var sources strings.Builder
+	for i, result := range results {
+		fmt.Fprintf(&prompt, "%d. %s\n   %s\n   %s\n", i+1, result.Title, result.URL, result.Description)
+		fmt.Fprintf(&sources, "%d. %s (%s)\n", i+1, result.Title, result.URL)
+	}
+
+	message, err := sampler.CreateMessage(ctx, mcp.CreateMessageRequest{
+		Messages: []mcp.SamplingMessage{
+			{Role: "user", Content: mcp.Content{Type: "text", Text: prompt.String()}},
+		},
+		SystemPrompt: "You summarize web search results accurately, without adding facts they do not contain.",
+		MaxTokens:    maxTokens,
+	})
+	if err != nil {
+		return s.errorResponse(fmt.Sprintf("Summarizing failed: %v", err)), nil
+	}
+	if message.Content.Type != "text" || message.Content.Text == "" {
+		return s.errorResponse("Summarizing failed: the client's model returned no text"), nil
+	}
+
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: message.Content.Text,
+			},
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Sources:\n%s", sources.String()),
+			},
+		},
+	}, nil
+}
+
+// clearCache evicts cached search results, for one query or all of them
+func (s *SearchTool) clearCache(args mcp.Args) (*mcp.ToolCallResponse, error) {
+	clearer, ok := s.searcher.(search.CacheClearer)
+	if !ok {
+		return s.errorResponse("Search results are not cached"), nil
+	}
+
+	query, _ := args.String("query")
+	evicted := clearer.ClearCache(query)
+
+	text := fmt.Sprintf("Cleared %d cached search result pages", evicted)
+	if strings.TrimSpace(query) != "" {
+		text = fmt.Sprintf("Cleared %d cached search result pages for: %s", evicted, query)
+	}
+	return &mcp.ToolCallResponse{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}, nil
+}
+
 func (s *SearchTool) healthCheck(ctx context.Context) (*mcp.ToolCallResponse, error) {
 	err := s.searcher.HealthCheck(ctx)
 	if err != nil {
@@ -224,30 +493,28 @@ func (s *SearchTool) healthCheck(ctx context.Context) (*mcp.ToolCallResponse, er
 	}, nil
 }
 
-func (s *SearchTool) toInt(value interface{}) (int, error) {
-	switch v := value.(type) {
-	case int:
-		return v, nil
-	case float64:
-		return int(v), nil
-	case string:
-		return strconv.Atoi(v)
-	case json.Number:
-		i, err := v.Int64()
-		return int(i), err
-	default:
-		return 0, fmt.Errorf("cannot convert %T to int", value)
+// parseFilters converts the 'filters' argument into SearchQuery.Filters
+func (s *SearchTool) parseFilters(value interface{}) (map[string]string, error) {
+	raw, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	filters := make(map[string]string, len(raw))
+	for key, v := range raw {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("filter %q must be a string", key)
+		}
+		filters[key] = str
 	}
+
+	if err := search.ValidateFilters(filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
 }
 
 func (s *SearchTool) errorResponse(message string) *mcp.ToolCallResponse {
-	return &mcp.ToolCallResponse{
-		IsError: true,
-		Content: []mcp.Content{
-			{
-				Type: "text",
-				Text: message,
-			},
-		},
-	}
+	return mcp.NewToolError(message, nil)
 }
@@ -2,6 +2,9 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,7 +38,7 @@ func TestSearchTool(t *testing.T) {
 
 		tools, err := tool.ListTools(context.Background())
 		require.NoError(t, err)
-		assert.Len(t, tools, 2)
+		assert.Len(t, tools, 4)
 
 		// Check web_search tool
 		webSearchTool := findTool(tools, "web_search")
@@ -48,6 +51,61 @@ func TestSearchTool(t *testing.T) {
 		healthTool := findTool(tools, "search_health_check")
 		require.NotNil(t, healthTool)
 		assert.Equal(t, "search_health_check", healthTool.Name)
+
+		// The tools only read, but reach out to the web. Clearing the cache
+		// stays local but changes what later searches return.
+		for _, tool := range tools {
+			require.NotNil(t, tool.Annotations, tool.Name)
+			assert.Equal(t, tool.Name != "search_clear_cache", tool.Annotations.ReadOnlyHint, tool.Name)
+			assert.False(t, tool.Annotations.DestructiveHint, tool.Name)
+			assert.Equal(t, tool.Name != "search_clear_cache", tool.Annotations.OpenWorldHint, tool.Name)
+		}
+
+		// Only search results are cached: health changes over time and
+		// summaries depend on the calling client's model
+		assert.True(t, webSearchTool.Cacheable)
+		assert.False(t, healthTool.Cacheable)
+		summarizeTool := findTool(tools, "summarize_search")
+		require.NotNil(t, summarizeTool)
+		assert.False(t, summarizeTool.Cacheable)
+	})
+
+	t.Run("CallTool_SummarizeSearch", func(t *testing.T) {
+		tool := NewSearchTool(search.NewMockSearcher(mockResults, nil))
+		request := mcp.ToolCallRequest{
+			Name:      "summarize_search",
+			Arguments: map[string]interface{}{"query": "golang", "max_tokens": 200},
+		}
+
+		// Without sampling support the tool refuses before searching
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "supports sampling")
+
+		sampler := &cannedSampler{text: "Go is a programming language [1], documented at pkg.go.dev [2]."}
+		ctx := mcp.ContextWithSampler(context.Background(), sampler)
+		response, err = tool.CallTool(ctx, request)
+		require.NoError(t, err)
+		require.False(t, response.IsError, response.Content[0].Text)
+		require.Len(t, response.Content, 2)
+		assert.Equal(t, sampler.text, response.Content[0].Text)
+		assert.Contains(t, response.Content[1].Text, "1. Go Programming Language (https://golang.org)")
+
+		// The client's model was given the results to summarize
+		require.Len(t, sampler.requests, 1)
+		sent := sampler.requests[0]
+		assert.Equal(t, 200, sent.MaxTokens)
+		require.Len(t, sent.Messages, 1)
+		assert.Equal(t, "user", sent.Messages[0].Role)
+		assert.Contains(t, sent.Messages[0].Content.Text, `"golang"`)
+		assert.Contains(t, sent.Messages[0].Content.Text, "2. Go Documentation")
+
+		sampler.err = assert.AnError
+		response, err = tool.CallTool(ctx, request)
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Summarizing failed")
 	})
 
 	t.Run("CallTool_WebSearch_Success", func(t *testing.T) {
@@ -97,6 +155,179 @@ func TestSearchTool(t *testing.T) {
 		assert.False(t, response.IsError)
 	})
 
+	t.Run("CallTool_WebSearch_StreamContent", func(t *testing.T) {
+		long := []*mcp.SearchResult{
+			{Title: "Long page", URL: "https://example.com/long", Content: strings.Repeat("Go is fun. ", 100)},
+			{Title: "No content", URL: "https://example.com/empty"},
+		}
+		tool := NewSearchTool(search.NewMockSearcher(long, nil))
+		request := mcp.ToolCallRequest{
+			Name: "web_search",
+			Arguments: map[string]interface{}{
+				"query":           "golang",
+				"include_content": true,
+				"stream_content":  true,
+				"chunk_size":      300,
+			},
+		}
+
+		streamer := &recordingStreamer{}
+		ctx := mcp.ContextWithContentStreamer(context.Background(), streamer)
+		response, err := tool.CallTool(ctx, request)
+		require.NoError(t, err)
+		require.False(t, response.IsError)
+
+		// The content arrives in chunks that add up to all of it
+		require.Greater(t, len(streamer.chunks), 1)
+		var received strings.Builder
+		for i, chunk := range streamer.chunks {
+			assert.Equal(t, i, chunk.Index)
+			assert.Equal(t, "https://example.com/long", chunk.URI)
+			assert.LessOrEqual(t, len(chunk.Text), 300)
+			received.WriteString(chunk.Text)
+		}
+		assert.Equal(t, long[0].Content, received.String())
+		assert.True(t, streamer.chunks[len(streamer.chunks)-1].Final)
+
+		// The response only gives lengths, and the searcher's results are untouched
+		text := response.Content[0].Text
+		assert.Equal(t, fmt.Sprintf("Streamed 1100 bytes of page content in %d chunks.\n", len(streamer.chunks)), text)
+		for _, content := range response.Content {
+			assert.NotContains(t, content.Text, "Go is fun.")
+		}
+		assert.Contains(t, response.Content[2].Text, "Content: 1100 bytes, streamed")
+		assert.NotEmpty(t, long[0].Content)
+
+		// Without a progress token the content stays in the response
+		response, err = tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		require.False(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "instead of streamed")
+		assert.Contains(t, response.Content[2].Text, "Go is fun.")
+
+		streamer.err = errors.New("connection closed")
+		response, err = tool.CallTool(ctx, request)
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Streaming content failed: connection closed", response.Content[0].Text)
+	})
+
+	t.Run("CallTool_WebSearch_Filters", func(t *testing.T) {
+		searcher := search.NewMockSearcher(mockResults, nil)
+		tool := NewSearchTool(searcher)
+
+		request := mcp.ToolCallRequest{
+			Name: "web_search",
+			Arguments: map[string]interface{}{
+				"query": "golang",
+				"filters": map[string]interface{}{
+					"include_domain": `go\.dev$`,
+					"title_contains": "documentation",
+				},
+			},
+		}
+
+		response, err := tool.CallTool(context.Background(), request)
+		require.NoError(t, err)
+		require.False(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Found 1 search results")
+		assert.Contains(t, response.Content[1].Text, "https://pkg.go.dev")
+	})
+
+	t.Run("CallTool_WebSearch_NextPage", func(t *testing.T) {
+		tool := NewSearchTool(search.NewMockSearcher(mockResults, nil))
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "web_search", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		response := call(map[string]interface{}{"query": "golang", "max_results": 1})
+		require.False(t, response.IsError)
+		assert.Contains(t, response.Content[1].Text, "1. **Go Programming Language**")
+		last := response.Content[len(response.Content)-1].Text
+		token := search.EncodePageToken(mcp.SearchQuery{Query: "golang", SafeSearch: true}, 1)
+		assert.Equal(t, "More results are available. Next page token: "+token, last)
+
+		response = call(map[string]interface{}{"query": "golang", "max_results": 1, "page_token": token})
+		require.False(t, response.IsError)
+		assert.Contains(t, response.Content[1].Text, "2. **Go Documentation**")
+		for _, c := range response.Content {
+			assert.NotContains(t, c.Text, "Next page token")
+		}
+
+		response = call(map[string]interface{}{"query": "golang", "page_token": "%%%"})
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Invalid 'page_token' parameter")
+
+		response = call(map[string]interface{}{"query": "rust", "max_results": 1, "page_token": token})
+		assert.True(t, response.IsError)
+		assert.Equal(t, "Invalid 'page_token' parameter: page token belongs to a different query", response.Content[0].Text)
+	})
+
+	t.Run("CallTool_WebSearch_NoCache", func(t *testing.T) {
+		searcher := &cachingSearcher{MockSearcher: search.NewMockSearcher(mockResults, nil)}
+		tool := NewSearchTool(searcher)
+		call := func(args map[string]interface{}) {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "web_search", Arguments: args})
+			require.NoError(t, err)
+			require.False(t, response.IsError, response.Content[0].Text)
+		}
+
+		call(map[string]interface{}{"query": "golang"})
+		call(map[string]interface{}{"query": "golang", "no_cache": true})
+		require.Len(t, searcher.queries, 2)
+		assert.False(t, searcher.queries[0].NoCache)
+		assert.True(t, searcher.queries[1].NoCache)
+	})
+
+	t.Run("CallTool_ClearCache", func(t *testing.T) {
+		searcher := &cachingSearcher{MockSearcher: search.NewMockSearcher(mockResults, nil), cached: 3}
+		tool := NewSearchTool(searcher)
+		call := func(args map[string]interface{}) *mcp.ToolCallResponse {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "search_clear_cache", Arguments: args})
+			require.NoError(t, err)
+			return response
+		}
+
+		response := call(map[string]interface{}{"query": "golang"})
+		require.False(t, response.IsError)
+		assert.Equal(t, "Cleared 3 cached search result pages for: golang", response.Content[0].Text)
+
+		response = call(nil)
+		require.False(t, response.IsError)
+		assert.Equal(t, "Cleared 3 cached search result pages", response.Content[0].Text)
+		assert.Equal(t, []string{"golang", ""}, searcher.cleared)
+
+		// Searchers without a cache have nothing to clear
+		tool = NewSearchTool(search.NewMockSearcher(mockResults, nil))
+		response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{Name: "search_clear_cache"})
+		require.NoError(t, err)
+		assert.True(t, response.IsError)
+		assert.Contains(t, response.Content[0].Text, "Search results are not cached")
+	})
+
+	t.Run("CallTool_WebSearch_InvalidFilters", func(t *testing.T) {
+		searcher := search.NewMockSearcher(mockResults, nil)
+		tool := NewSearchTool(searcher)
+
+		invalid := []interface{}{
+			"golang.org",
+			map[string]interface{}{"include_domain": 42},
+			map[string]interface{}{"exclude_domain": "("},
+			map[string]interface{}{"site": "golang.org"},
+		}
+		for _, filters := range invalid {
+			response, err := tool.CallTool(context.Background(), mcp.ToolCallRequest{
+				Name:      "web_search",
+				Arguments: map[string]interface{}{"query": "golang", "filters": filters},
+			})
+			require.NoError(t, err)
+			assert.True(t, response.IsError, "filters %v", filters)
+			assert.Contains(t, response.Content[0].Text, "Invalid 'filters' parameter")
+		}
+	})
+
 	t.Run("CallTool_WebSearch_InvalidQuery", func(t *testing.T) {
 		searcher := search.NewMockSearcher(mockResults, nil)
 		tool := NewSearchTool(searcher)
@@ -258,30 +489,6 @@ func TestSearchTool_Helpers(t *testing.T) {
 	searcher := search.NewMockSearcher(nil, nil)
 	tool := NewSearchTool(searcher)
 
-	t.Run("toInt", func(t *testing.T) {
-		testCases := []struct {
-			input    interface{}
-			expected int
-			hasError bool
-		}{
-			{5, 5, false},
-			{5.7, 5, false},
-			{"10", 10, false},
-			{"invalid", 0, true},
-			{true, 0, true},
-		}
-
-		for _, tc := range testCases {
-			result, err := tool.toInt(tc.input)
-			if tc.hasError {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tc.expected, result)
-			}
-		}
-	})
-
 	t.Run("errorResponse", func(t *testing.T) {
 		response := tool.errorResponse("test error message")
 		assert.True(t, response.IsError)
@@ -290,3 +497,55 @@ func TestSearchTool_Helpers(t *testing.T) {
 		assert.Equal(t, "test error message", response.Content[0].Text)
 	})
 }
+
+// cachingSearcher is a MockSearcher that records the queries it is given and
+// the cache clears asked of it, each reported as evicting cached entries
+type cachingSearcher struct {
+	*search.MockSearcher
+	cached  int
+	queries []mcp.SearchQuery
+	cleared []string
+}
+
+func (s *cachingSearcher) SearchPage(ctx context.Context, query mcp.SearchQuery) (*mcp.SearchPage, error) {
+	s.queries = append(s.queries, query)
+	return s.MockSearcher.SearchPage(ctx, query)
+}
+
+func (s *cachingSearcher) ClearCache(query string) int {
+	s.cleared = append(s.cleared, query)
+	return s.cached
+}
+
+// recordingStreamer records the content chunks a tool streams, or fails with err
+type recordingStreamer struct {
+	chunks []mcp.ContentChunk
+	err    error
+}
+
+func (s *recordingStreamer) StreamContent(ctx context.Context, chunk mcp.ContentChunk) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.chunks = append(s.chunks, chunk)
+	return nil
+}
+
+// cannedSampler stands in for a client's model, answering every request with text
+type cannedSampler struct {
+	text     string
+	err      error
+	requests []mcp.CreateMessageRequest
+}
+
+func (s *cannedSampler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	s.requests = append(s.requests, request)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &mcp.CreateMessageResult{
+		Role:    "assistant",
+		Content: mcp.Content{Type: "text", Text: s.text},
+		Model:   "canned",
+	}, nil
+}
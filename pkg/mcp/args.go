@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// Args wraps tool call arguments with typed accessors. JSON decoding produces
+// float64 for every number, so the numeric accessors coerce between number
+// representations; the ok result is false when a key is absent or its value
+// cannot be converted.
+type Args map[string]interface{}
+
+// Has reports whether key is present, even if its value is null
+func (a Args) Has(key string) bool {
+	_, ok := a[key]
+	return ok
+}
+
+// String returns the string value of key
+func (a Args) String(key string) (string, bool) {
+	s, ok := a[key].(string)
+	return s, ok
+}
+
+// Int returns the value of key as an int. Floats are truncated and numeric
+// strings are parsed.
+func (a Args) Int(key string) (int, bool) {
+	value, ok := a[key]
+	if !ok {
+		return 0, false
+	}
+	return ToInt(value)
+}
+
+// Float returns the value of key as a float64, parsing numeric strings
+func (a Args) Float(key string) (float64, bool) {
+	switch v := a[key].(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Bool returns the boolean value of key, or def when it is absent or not a boolean
+func (a Args) Bool(key string, def bool) bool {
+	if b, ok := a[key].(bool); ok {
+		return b
+	}
+	return def
+}
+
+// StringSlice returns the string elements of an array value, skipping
+// elements that are not strings
+func (a Args) StringSlice(key string) ([]string, bool) {
+	switch v := a[key].(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items, true
+	default:
+		return nil, false
+	}
+}
+
+// Map returns the object value of key
+func (a Args) Map(key string) (map[string]interface{}, bool) {
+	m, ok := a[key].(map[string]interface{})
+	return m, ok
+}
+
+// ToInt converts a decoded JSON value to an int. Floats are truncated, numeric
+// strings are parsed, and values outside the int range are rejected.
+func ToInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		// -math.MinInt is the first value past math.MaxInt, and unlike
+		// math.MaxInt it is exact as a float64
+		if math.IsNaN(v) || v >= -math.MinInt || v < math.MinInt {
+			return 0, false
+		}
+		return int(v), true
+	case float32:
+		return ToInt(float64(v))
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i), true
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return ToInt(f)
+	case string:
+		i, err := strconv.Atoi(v)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgs(t *testing.T) {
+	args := Args{
+		"name":    "widget",
+		"count":   float64(5),
+		"ratio":   2.5,
+		"numeric": "10",
+		"enabled": true,
+		"empty":   nil,
+		"tags":    []interface{}{"a", 1, "b"},
+		"labels":  []string{"x", "y"},
+		"filter":  map[string]interface{}{"status": "active"},
+	}
+
+	t.Run("Has", func(t *testing.T) {
+		assert.True(t, args.Has("name"))
+		assert.True(t, args.Has("empty"))
+		assert.False(t, args.Has("missing"))
+	})
+
+	t.Run("String", func(t *testing.T) {
+		s, ok := args.String("name")
+		assert.True(t, ok)
+		assert.Equal(t, "widget", s)
+
+		_, ok = args.String("count")
+		assert.False(t, ok)
+
+		_, ok = args.String("missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("Int", func(t *testing.T) {
+		i, ok := args.Int("count")
+		assert.True(t, ok)
+		assert.Equal(t, 5, i)
+
+		i, ok = args.Int("ratio")
+		assert.True(t, ok)
+		assert.Equal(t, 2, i)
+
+		i, ok = args.Int("numeric")
+		assert.True(t, ok)
+		assert.Equal(t, 10, i)
+
+		_, ok = args.Int("name")
+		assert.False(t, ok)
+
+		_, ok = args.Int("missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("Float", func(t *testing.T) {
+		f, ok := args.Float("ratio")
+		assert.True(t, ok)
+		assert.Equal(t, 2.5, f)
+
+		f, ok = args.Float("numeric")
+		assert.True(t, ok)
+		assert.Equal(t, 10.0, f)
+
+		_, ok = args.Float("enabled")
+		assert.False(t, ok)
+	})
+
+	t.Run("Bool", func(t *testing.T) {
+		assert.True(t, args.Bool("enabled", false))
+		assert.True(t, args.Bool("missing", true))
+		assert.False(t, args.Bool("name", false))
+	})
+
+	t.Run("StringSlice", func(t *testing.T) {
+		items, ok := args.StringSlice("tags")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"a", "b"}, items)
+
+		items, ok = args.StringSlice("labels")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"x", "y"}, items)
+
+		_, ok = args.StringSlice("name")
+		assert.False(t, ok)
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		m, ok := args.Map("filter")
+		assert.True(t, ok)
+		assert.Equal(t, "active", m["status"])
+
+		_, ok = args.Map("tags")
+		assert.False(t, ok)
+	})
+}
+
+func TestToInt(t *testing.T) {
+	testCases := []struct {
+		input    interface{}
+		expected int
+		ok       bool
+	}{
+		{5, 5, true},
+		{int64(7), 7, true},
+		{5.7, 5, true},
+		{-5.7, -5, true},
+		{float32(3.2), 3, true},
+		{json.Number("42"), 42, true},
+		{json.Number("4.9"), 4, true},
+		{json.Number("abc"), 0, false},
+		{"10", 10, true},
+		{"invalid", 0, false},
+		{"1.5", 0, false},
+		{true, 0, false},
+		{nil, 0, false},
+		{math.NaN(), 0, false},
+		{1e300, 0, false},
+		{float64(1 << 63), 0, false},
+		{json.Number("9223372036854775808"), 0, false},
+		{float64(math.MinInt), math.MinInt, true},
+	}
+
+	for _, tc := range testCases {
+		result, ok := ToInt(tc.input)
+		assert.Equal(t, tc.ok, ok, "input %#v", tc.input)
+		if tc.ok {
+			assert.Equal(t, tc.expected, result, "input %#v", tc.input)
+		}
+	}
+}
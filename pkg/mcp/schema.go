@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ValidateSchema checks value against a JSON Schema. Only the keywords the
+// server's own schemas use are supported: type, properties, required, items,
+// enum and additionalProperties: false. value must be JSON-shaped data such as
+// the result of NormalizeJSON.
+func ValidateSchema(schema map[string]interface{}, value interface{}) error {
+	return validateSchema(schema, value, "$")
+}
+
+// NormalizeJSON round-trips v through encoding/json so structs and typed slices
+// become the maps, slices and float64s ValidateSchema expects
+func NormalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func validateSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if types := schemaStrings(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %v, got %s", path, joinTypes(types), jsonType(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range schemaStrings(schema["required"]) {
+			if _, ok := v[key]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, key)
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			propertySchema, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if additional, isBool := schema["additionalProperties"].(bool); isBool && !additional {
+					return fmt.Errorf("%s: unexpected property %q", path, key)
+				}
+				continue
+			}
+			if err := validateSchema(propertySchema, v[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// schemaStrings reads a keyword that holds a string or a list of strings
+func schemaStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func matchesType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return false
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprint(types)
+}
@@ -0,0 +1,65 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"count": map[string]interface{}{"type": "integer"},
+			"name":  map[string]interface{}{"type": "string"},
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+			"status": map[string]interface{}{"enum": []interface{}{"active", "archived"}},
+		},
+		"required":             []string{"count"},
+		"additionalProperties": false,
+	}
+
+	testCases := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{"Valid", map[string]interface{}{"count": 3.0, "name": "a", "tags": []interface{}{"x"}, "status": "active"}, ""},
+		{"MissingRequired", map[string]interface{}{"name": "a"}, `missing required property "count"`},
+		{"WrongType", map[string]interface{}{"count": "3"}, "$.count: expected integer, got string"},
+		{"NotInteger", map[string]interface{}{"count": 2.5}, "$.count: expected integer"},
+		{"BadItem", map[string]interface{}{"count": 1.0, "tags": []interface{}{"x", 2.0}}, "$.tags[1]: expected string, got number"},
+		{"UnexpectedProperty", map[string]interface{}{"count": 1.0, "extra": true}, `unexpected property "extra"`},
+		{"NotInEnum", map[string]interface{}{"count": 1.0, "status": "deleted"}, "is not one of"},
+		{"NotObject", []interface{}{}, "$: expected object, got array"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSchema(schema, tc.value)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	t.Run("TypeList", func(t *testing.T) {
+		nullable := map[string]interface{}{"type": []interface{}{"string", "null"}}
+		assert.NoError(t, ValidateSchema(nullable, nil))
+		assert.NoError(t, ValidateSchema(nullable, "x"))
+		assert.Error(t, ValidateSchema(nullable, 1.0))
+	})
+
+	t.Run("NormalizeJSON", func(t *testing.T) {
+		value, err := NormalizeJSON(map[string]interface{}{"count": int64(4), "tags": []string{"a"}})
+		require.NoError(t, err)
+		assert.NoError(t, ValidateSchema(schema, value))
+	})
+}
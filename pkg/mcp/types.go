@@ -1,8 +1,13 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"time"
+	"unicode/utf8"
 )
 
 // Protocol version
@@ -24,11 +29,17 @@ const (
 	MethodListTools          = "tools/list"
 	MethodCallTool           = "tools/call"
 	MethodListResources      = "resources/list"
+	MethodListResourceTemplates = "resources/templates/list"
 	MethodReadResource       = "resources/read"
+	MethodSubscribeResource  = "resources/subscribe"
+	MethodUnsubscribeResource = "resources/unsubscribe"
 	MethodListPrompts        = "prompts/list"
 	MethodGetPrompt          = "prompts/get"
 	MethodListRoots          = "roots/list"
+	MethodCreateMessage      = "sampling/createMessage"
+	MethodComplete           = "completion/complete"
 	MethodNotificationRootsListChanged = "notifications/roots/list_changed"
+	MethodNotificationToolContent      = "notifications/tools/content"
 )
 
 // Base message structure
@@ -39,6 +50,70 @@ type Message struct {
 	Params  interface{} `json:"params,omitempty"`
 	Result  interface{} `json:"result,omitempty"`
 	Error   *Error      `json:"error,omitempty"`
+
+	nullID bool // "id" was present but null
+}
+
+// UnmarshalJSON decodes a message, keeping track of whether the id was
+// explicitly null so it can be told apart from an absent id (a notification)
+func (m *Message) UnmarshalJSON(data []byte) error {
+	return m.decode(data, false)
+}
+
+// DecodeMessage decodes a message. With useNumber, numbers in the id and params
+// are kept as json.Number instead of float64, so integers above 2^53 keep
+// their exact value.
+func DecodeMessage(data []byte, useNumber bool) (*Message, error) {
+	var m Message
+	if err := m.decode(data, useNumber); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// DecodeJSON decodes data into v like json.Unmarshal, keeping numbers as
+// json.Number when useNumber is set
+func DecodeJSON(data []byte, v interface{}, useNumber bool) error {
+	if !useNumber {
+		return json.Unmarshal(data, v)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after top-level value")
+	}
+	return nil
+}
+
+func (m *Message) decode(data []byte, useNumber bool) error {
+	type message Message
+	aux := struct {
+		*message
+		ID json.RawMessage `json:"id"`
+	}{message: (*message)(m)}
+
+	if err := DecodeJSON(data, &aux, useNumber); err != nil {
+		return err
+	}
+
+	m.ID = nil
+	m.nullID = false
+	if len(aux.ID) == 0 {
+		return nil
+	}
+	if string(bytes.TrimSpace(aux.ID)) == "null" {
+		m.nullID = true
+		return nil
+	}
+	return DecodeJSON(aux.ID, &m.ID, useNumber)
+}
+
+// HasNullID reports whether the decoded message carried "id": null
+func (m *Message) HasNullID() bool {
+	return m.nullID
 }
 
 // Request represents an MCP request
@@ -106,6 +181,7 @@ type ServerCapabilities struct {
 	Prompts      *PromptsCapability     `json:"prompts,omitempty"`
 	Resources    *ResourcesCapability   `json:"resources,omitempty"`
 	Tools        *ToolsCapability       `json:"tools,omitempty"`
+	Completions  *CompletionsCapability `json:"completions,omitempty"`
 	Experimental map[string]interface{} `json:"experimental,omitempty"`
 }
 
@@ -113,8 +189,64 @@ type RootsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+// Root is a filesystem root the client exposes to the server, such as a
+// project directory
+type Root struct {
+	URI  string `json:"uri"` // a file:// URI
+	Name string `json:"name,omitempty"`
+}
+
+// ListRootsResponse is the client's result for a roots/list request
+type ListRootsResponse struct {
+	Roots []Root `json:"roots"`
+}
+
 type SamplingCapability struct{}
 
+// SamplingMessage is one message of the conversation sent with a
+// sampling/createMessage request
+type SamplingMessage struct {
+	Role    string  `json:"role"` // "user" or "assistant"
+	Content Content `json:"content"`
+}
+
+// CreateMessageRequest asks the client's model to generate a message
+type CreateMessageRequest struct {
+	Messages     []SamplingMessage `json:"messages"`
+	SystemPrompt string            `json:"systemPrompt,omitempty"`
+	MaxTokens    int               `json:"maxTokens"`
+}
+
+// CreateMessageResult is the message the client's model generated
+type CreateMessageResult struct {
+	Role       string  `json:"role"`
+	Content    Content `json:"content"`
+	Model      string  `json:"model,omitempty"`
+	StopReason string  `json:"stopReason,omitempty"`
+}
+
+// Sampler requests a completion from the model of the client whose request is
+// being handled
+type Sampler interface {
+	CreateMessage(ctx context.Context, request CreateMessageRequest) (*CreateMessageResult, error)
+}
+
+type samplerKey struct{}
+
+// ContextWithSampler returns a context through which a tool can ask the calling
+// client's model for a completion
+func ContextWithSampler(ctx context.Context, sampler Sampler) context.Context {
+	return context.WithValue(ctx, samplerKey{}, sampler)
+}
+
+// SamplerFromContext returns the sampler of the client whose request is being
+// handled with ctx. It reports false outside a request or when the client did
+// not declare the sampling capability.
+func SamplerFromContext(ctx context.Context) (Sampler, bool) {
+	sampler, ok := ctx.Value(samplerKey{}).(Sampler)
+	return sampler, ok
+}
+
 type LoggingCapability struct{}
 
 type PromptsCapability struct {
@@ -130,11 +262,45 @@ type ToolsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type CompletionsCapability struct{}
+
 type ClientInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
 }
 
+type clientInfoKey struct{}
+
+// ContextWithClientInfo returns a context carrying the client info the calling
+// client sent in its initialize request
+func ContextWithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFromContext returns the info of the client whose request is being
+// handled with ctx. It reports false outside a request or when the client sent
+// no clientInfo.
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+type collectionAccessKey struct{}
+
+// ContextWithCollectionAccess returns a context restricting the client whose
+// request is handled with it to the collections for which allowed is true
+func ContextWithCollectionAccess(ctx context.Context, allowed func(collection string) bool) context.Context {
+	return context.WithValue(ctx, collectionAccessKey{}, allowed)
+}
+
+// CollectionAllowed reports whether the client whose request is being handled
+// with ctx may access collection. Every collection is allowed when ctx carries
+// no restriction.
+func CollectionAllowed(ctx context.Context, collection string) bool {
+	allowed, ok := ctx.Value(collectionAccessKey{}).(func(string) bool)
+	return !ok || allowed(collection)
+}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -145,16 +311,147 @@ type Tool struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description,omitempty"`
 	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations *ToolAnnotations       `json:"annotations,omitempty"`
+
+	// OutputSchema describes StructuredContent for tools that return it
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+
+	// Cacheable lets the server's tool result cache store the tool's results,
+	// keyed by its arguments. Only set it on read-only tools whose results
+	// depend on nothing but their arguments and the data they read, not on
+	// the time or the caller, unlike health checks. It is not sent to clients.
+	Cacheable bool `json:"-"`
+
+	// RequiresAllowlist hides the tool unless the connection's tool profile
+	// names it exactly; patterns such as "*", the built-in profiles and
+	// connections without a profile do not expose it. Set it on tools too
+	// dangerous to offer every client. It is not sent to clients.
+	RequiresAllowlist bool `json:"-"`
+}
+
+// ToolAnnotations describe how a tool behaves so clients can decide, for example,
+// whether a call needs user approval. They are hints, not guarantees.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`    // the tool does not modify its environment
+	DestructiveHint bool   `json:"destructiveHint"` // the tool may overwrite or delete data
+	IdempotentHint  bool   `json:"idempotentHint"`  // repeating a call with the same arguments has no further effect
+	OpenWorldHint   bool   `json:"openWorldHint"`   // the tool reaches systems outside the server, such as the web
+}
+
+// ReadOnlyAnnotations returns the annotations of a tool that only reads data
+func ReadOnlyAnnotations(openWorld bool) *ToolAnnotations {
+	return &ToolAnnotations{
+		ReadOnlyHint:   true,
+		IdempotentHint: true,
+		OpenWorldHint:  openWorld,
+	}
+}
+
+// ListToolsRequest holds the optional pagination cursor for tools/list
+type ListToolsRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// ListToolsResponse is one page of tools; NextCursor is set when more remain
+type ListToolsResponse struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type ToolCallRequest struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the metadata a client attaches to a request
+type RequestMeta struct {
+	// ProgressToken identifies the request in the notifications the server
+	// sends about it while it runs
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+// ContentChunk is one piece of a tool's content, sent to the client as a
+// notifications/tools/content notification while the tool runs instead of in
+// its response. A text is split into chunks numbered from 0; its last chunk
+// is marked Final.
+type ContentChunk struct {
+	ProgressToken interface{} `json:"progressToken"` // the tool call's, set by the server
+	URI           string      `json:"uri,omitempty"` // where the text comes from, e.g. a web page
+	Index         int         `json:"index"`
+	Offset        int         `json:"offset"` // of the chunk's first byte in the text
+	Text          string      `json:"text"`
+	Final         bool        `json:"final,omitempty"`
+	Total         int         `json:"total,omitempty"` // length of the text in bytes, set on the final chunk
+}
+
+// ContentStreamer sends a tool's content to the client whose request is being
+// handled, in chunks
+type ContentStreamer interface {
+	StreamContent(ctx context.Context, chunk ContentChunk) error
+}
+
+type contentStreamerKey struct{}
+
+// ContextWithContentStreamer returns a context through which a tool can stream
+// content to the calling client
+func ContextWithContentStreamer(ctx context.Context, streamer ContentStreamer) context.Context {
+	return context.WithValue(ctx, contentStreamerKey{}, streamer)
+}
+
+// ContentStreamerFromContext returns the content streamer of the tool call
+// being handled with ctx. It reports false outside a tool call or when the
+// client sent no progress token to tie the chunks to.
+func ContentStreamerFromContext(ctx context.Context) (ContentStreamer, bool) {
+	streamer, ok := ctx.Value(contentStreamerKey{}).(ContentStreamer)
+	return streamer, ok
+}
+
+// StreamText sends text through streamer as chunks of at most chunkSize
+// bytes, never splitting a UTF-8 character, and returns how many it sent
+func StreamText(ctx context.Context, streamer ContentStreamer, uri, text string, chunkSize int) (int, error) {
+	if chunkSize < utf8.UTFMax {
+		chunkSize = utf8.UTFMax
+	}
+
+	chunks := 0
+	for offset := 0; ; chunks++ {
+		end := offset + chunkSize
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			for !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+
+		final := end == len(text)
+		chunk := ContentChunk{URI: uri, Index: chunks, Offset: offset, Text: text[offset:end], Final: final}
+		if final {
+			chunk.Total = len(text)
+		}
+		if err := streamer.StreamContent(ctx, chunk); err != nil {
+			return chunks, err
+		}
+		if final {
+			return chunks + 1, nil
+		}
+		offset = end
+	}
 }
 
 type ToolCallResponse struct {
 	Content []Content `json:"content"`
 	IsError bool      `json:"isError,omitempty"`
+
+	// StructuredContent is the machine-readable result of a tool that declares
+	// an OutputSchema; Content still carries a text rendering of it
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+
+	// Meta carries metadata about the call rather than its result, such as the
+	// tool name and duration added by the server's response metadata option
+	Meta map[string]interface{} `json:"_meta,omitempty"`
 }
 
 // Resource definitions
@@ -166,12 +463,36 @@ type Resource struct {
 	Meta        map[string]interface{} `json:"meta,omitempty"`
 }
 
+// ResourceTemplate describes a family of resources whose URIs clients build by
+// filling in the variables of URITemplate, e.g. "mongodb://{collection}/{id}"
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourceTemplatesResponse is the result of resources/templates/list
+type ListResourceTemplatesResponse struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
 type ResourceReadRequest struct {
 	URI string `json:"uri"`
+	// IfNoneMatch makes the read conditional: when it equals the ETag of the
+	// resource's contents, the response has NotModified set and no contents
+	IfNoneMatch string `json:"ifNoneMatch,omitempty"`
+}
+
+type ResourceSubscribeRequest struct {
+	URI string `json:"uri"`
 }
 
 type ResourceReadResponse struct {
 	Contents []ResourceContent `json:"contents"`
+	// NotModified answers a conditional read whose validator still matches:
+	// the client's cached contents are current
+	NotModified bool `json:"notModified,omitempty"`
 }
 
 type ResourceContent struct {
@@ -179,6 +500,50 @@ type ResourceContent struct {
 	MimeType string `json:"mimeType,omitempty"`
 	Text     string `json:"text,omitempty"`
 	Blob     string `json:"blob,omitempty"`
+	// ETag identifies this version of the content, for conditional reads
+	ETag string `json:"etag,omitempty"`
+}
+
+// Completion reference types
+const (
+	CompletionRefPrompt   = "ref/prompt"
+	CompletionRefResource = "ref/resource"
+)
+
+// CompletionReference names the prompt or resource whose argument is being completed
+type CompletionReference struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"` // ref/prompt
+	URI  string `json:"uri,omitempty"`  // ref/resource
+}
+
+// CompletionArgument is the argument being completed and its partial value
+type CompletionArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CompletionContext carries arguments the client has already resolved, so a
+// completion can depend on them (e.g. categories of the chosen collection)
+type CompletionContext struct {
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type CompleteRequest struct {
+	Ref      CompletionReference `json:"ref"`
+	Argument CompletionArgument  `json:"argument"`
+	Context  *CompletionContext  `json:"context,omitempty"`
+}
+
+// Completion holds candidate values; Total and HasMore describe values left out
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+type CompleteResponse struct {
+	Completion Completion `json:"completion"`
 }
 
 // Content types
@@ -189,21 +554,66 @@ type Content struct {
 }
 
 // Interfaces for implementing MCP components
+
+// ToolProvider exposes a set of tools. CallTool should report problems the tool
+// encountered while running (bad arguments, backend failures) as a response built
+// with NewToolError rather than a Go error, so the client's model can see and react
+// to them. A returned Go error is still surfaced to the client as an IsError result;
+// only failures to dispatch the call at all (e.g. an unknown tool) become JSON-RPC
+// protocol errors.
 type ToolProvider interface {
 	ListTools(ctx context.Context) ([]Tool, error)
 	CallTool(ctx context.Context, request ToolCallRequest) (*ToolCallResponse, error)
 }
 
+// JSONFormatter is implemented by tool providers that embed JSON in their text
+// content. The server calls SetPrettyJSON with its PrettyJSON setting when the
+// provider is registered.
+type JSONFormatter interface {
+	SetPrettyJSON(pretty bool)
+}
+
+// FormatJSON encodes v for embedding in tool content: indented with two spaces
+// when pretty is set, compact otherwise
+func FormatJSON(v interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// NamedToolProvider is a ToolProvider with a short name, used to namespace its
+// tools when their names collide with another provider's
+type NamedToolProvider interface {
+	ToolProvider
+	Name() string
+}
+
 type ResourceProvider interface {
 	ListResources(ctx context.Context) ([]Resource, error)
 	ReadResource(ctx context.Context, uri string) (*ResourceReadResponse, error)
 }
 
+// ResourceTemplateProvider is a ResourceProvider that also serves resources
+// through URI templates. ReadResource must accept any URI matching one of its
+// templates, whether or not ListResources returns it.
+type ResourceTemplateProvider interface {
+	ResourceProvider
+	ListResourceTemplates(ctx context.Context) ([]ResourceTemplate, error)
+}
+
+// CompletionProvider suggests values for a prompt or resource argument. It
+// returns the candidates starting with the argument's partial value, or nil
+// when it has nothing to offer for the reference or argument.
+type CompletionProvider interface {
+	Complete(ctx context.Context, request CompleteRequest) ([]string, error)
+}
+
 // Server interface
 type Server interface {
 	Start(ctx context.Context, addr string) error
 	Stop(ctx context.Context) error
-	RegisterToolProvider(provider ToolProvider)
+	RegisterToolProvider(provider ToolProvider) error
 	RegisterResourceProvider(provider ResourceProvider)
 }
 
@@ -237,6 +647,32 @@ func NewErrorResponse(id interface{}, code int, message string, data interface{}
 	}
 }
 
+// NewToolError builds the result for a tool that ran but failed. The message is
+// returned as text content; data, when non-nil, is appended as JSON so clients
+// can inspect structured details.
+func NewToolError(message string, data interface{}) *ToolCallResponse {
+	response := &ToolCallResponse{
+		IsError: true,
+		Content: []Content{
+			{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}
+
+	if data != nil {
+		if jsonData, err := json.Marshal(data); err == nil {
+			response.Content = append(response.Content, Content{
+				Type: "text",
+				Text: string(jsonData),
+			})
+		}
+	}
+
+	return response
+}
+
 func NewNotification(method string, params interface{}) *Notification {
 	return &Notification{
 		JSONRPC: "2.0",
@@ -253,6 +689,7 @@ type SearchResult struct {
 	URL         string            `json:"url"`
 	Description string            `json:"description"`
 	Content     string            `json:"content,omitempty"`
+	ImageURL    string            `json:"image_url,omitempty"` // og:image or favicon, set when content is fetched
 	Timestamp   time.Time         `json:"timestamp"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
 }
@@ -268,15 +705,52 @@ type Document struct {
 	CreatedAt   time.Time              `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at" bson:"updated_at"`
 	Version     int                    `json:"version" bson:"version"`
+
+	// Score is the text-search relevance of the document; it is only set on
+	// search results and never stored
+	Score float64 `json:"score,omitempty" bson:"-"`
 }
 
 // DatabaseQuery represents a database query
 type DatabaseQuery struct {
-	Collection string                 `json:"collection"`
-	Filter     map[string]interface{} `json:"filter,omitempty"`
-	Sort       map[string]interface{} `json:"sort,omitempty"`
-	Limit      int                    `json:"limit,omitempty"`
-	Skip       int                    `json:"skip,omitempty"`
+	Collection  string                 `json:"collection"`
+	Filter      map[string]interface{} `json:"filter,omitempty"`
+	Sort        map[string]interface{} `json:"sort,omitempty"`
+	OrderedSort []SortField            `json:"ordered_sort,omitempty"` // takes precedence over Sort and preserves key order
+	Limit       int                    `json:"limit,omitempty"`
+	Skip        int                    `json:"skip,omitempty"`
+}
+
+// FieldStats summarizes the numeric values of one document field. Documents
+// whose value is missing or not a number count towards Matched only, and the
+// other statistics are zero when Count is zero.
+type FieldStats struct {
+	Field   string  `json:"field"`
+	Matched int64   `json:"matched"` // documents matching the filter
+	Count   int64   `json:"count"`   // documents with a numeric value
+	Sum     float64 `json:"sum"`
+	Avg     float64 `json:"avg"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+// TimeHistogram counts documents per time bucket of a date field
+type TimeHistogram struct {
+	Field  string            `json:"field"`
+	Bucket string            `json:"bucket"` // day, week or month
+	Counts []TimeBucketCount `json:"counts"` // in time order; empty buckets are left out
+}
+
+// TimeBucketCount is the number of documents whose date falls in one bucket
+type TimeBucketCount struct {
+	Bucket time.Time `json:"bucket"` // start of the bucket, in UTC
+	Count  int64     `json:"count"`
+}
+
+// SortField is one key of an ordered sort specification
+type SortField struct {
+	Field     string `json:"field"`
+	Direction int    `json:"direction"` // 1 for ascending, -1 for descending
 }
 
 // SearchQuery represents a web search query
@@ -288,4 +762,13 @@ type SearchQuery struct {
 	SafeSearch  bool              `json:"safe_search,omitempty"`
 	TimeRange   string            `json:"time_range,omitempty"`
 	Filters     map[string]string `json:"filters,omitempty"`
+	Offset      int               `json:"offset,omitempty"` // results to skip, for fetching later pages
+	NoCache     bool              `json:"no_cache,omitempty"` // bypass cached results and refresh them
+}
+
+// SearchPage is one page of search results. NextPage is an opaque token for
+// the following page, empty when there are no more results.
+type SearchPage struct {
+	Results  []*SearchResult `json:"results"`
+	NextPage string          `json:"next_page,omitempty"`
 }
@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewToolError(t *testing.T) {
+	t.Run("MessageOnly", func(t *testing.T) {
+		response := NewToolError("something went wrong", nil)
+
+		assert.True(t, response.IsError)
+		require.Len(t, response.Content, 1)
+		assert.Equal(t, "text", response.Content[0].Type)
+		assert.Equal(t, "something went wrong", response.Content[0].Text)
+	})
+
+	t.Run("WithData", func(t *testing.T) {
+		response := NewToolError("validation failed", map[string]interface{}{"field": "a"})
+
+		assert.True(t, response.IsError)
+		require.Len(t, response.Content, 2)
+		assert.Equal(t, "validation failed", response.Content[0].Text)
+		assert.JSONEq(t, `{"field":"a"}`, response.Content[1].Text)
+	})
+}
+
+func TestMessage_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		raw      string
+		id       interface{}
+		nullID   bool
+		hasParam bool
+	}{
+		{"AbsentID", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, nil, false, false},
+		{"NullID", `{"jsonrpc":"2.0","id":null,"method":"tools/list"}`, nil, true, false},
+		{"NumberID", `{"jsonrpc":"2.0","id":3,"method":"tools/list","params":{}}`, float64(3), false, true},
+		{"StringID", `{"jsonrpc":"2.0","id":"abc","method":"tools/list"}`, "abc", false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var message Message
+			require.NoError(t, json.Unmarshal([]byte(tc.raw), &message))
+
+			assert.Equal(t, "2.0", message.JSONRPC)
+			assert.NotEmpty(t, message.Method)
+			assert.Equal(t, tc.id, message.ID)
+			assert.Equal(t, tc.nullID, message.HasNullID())
+			assert.Equal(t, tc.hasParam, message.Params != nil)
+		})
+	}
+
+	t.Run("Reuse", func(t *testing.T) {
+		var message Message
+		require.NoError(t, json.Unmarshal([]byte(`{"jsonrpc":"2.0","id":null,"method":"a"}`), &message))
+		require.NoError(t, json.Unmarshal([]byte(`{"jsonrpc":"2.0","id":1,"method":"b"}`), &message))
+		assert.False(t, message.HasNullID())
+		assert.Equal(t, float64(1), message.ID)
+	})
+}
+
+func TestDecodeMessage(t *testing.T) {
+	raw := `{"jsonrpc":"2.0","id":9007199254740993,"method":"tools/call","params":{"arguments":{"n":9007199254740993,"x":1.5}}}`
+
+	t.Run("UseNumber", func(t *testing.T) {
+		message, err := DecodeMessage([]byte(raw), true)
+		require.NoError(t, err)
+
+		assert.Equal(t, json.Number("9007199254740993"), message.ID)
+		arguments := message.Params.(map[string]interface{})["arguments"].(map[string]interface{})
+		assert.Equal(t, json.Number("9007199254740993"), arguments["n"])
+		assert.Equal(t, json.Number("1.5"), arguments["x"])
+
+		encoded, err := json.Marshal(NewResponse(message.ID, nil))
+		require.NoError(t, err)
+		assert.Contains(t, string(encoded), `"id":9007199254740993`)
+	})
+
+	t.Run("Float64", func(t *testing.T) {
+		message, err := DecodeMessage([]byte(raw), false)
+		require.NoError(t, err)
+		assert.Equal(t, float64(9007199254740992), message.ID)
+	})
+
+	t.Run("NullID", func(t *testing.T) {
+		message, err := DecodeMessage([]byte(`{"jsonrpc":"2.0","id":null,"method":"tools/list"}`), true)
+		require.NoError(t, err)
+		assert.Nil(t, message.ID)
+		assert.True(t, message.HasNullID())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := DecodeMessage([]byte(`{"jsonrpc":"2.0"} {}`), true)
+		assert.Error(t, err)
+		_, err = DecodeMessage([]byte(`"text"`), true)
+		assert.Error(t, err)
+	})
+}
+
+func TestToolAnnotations(t *testing.T) {
+	t.Run("SerializesEveryHint", func(t *testing.T) {
+		tool := Tool{
+			Name:        "delete",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Annotations: &ToolAnnotations{DestructiveHint: true},
+		}
+
+		data, err := json.Marshal(tool)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"name": "delete",
+			"inputSchema": {"type": "object"},
+			"annotations": {
+				"readOnlyHint": false,
+				"destructiveHint": true,
+				"idempotentHint": false,
+				"openWorldHint": false
+			}
+		}`, string(data))
+	})
+
+	t.Run("OmittedWhenUnset", func(t *testing.T) {
+		data, err := json.Marshal(Tool{Name: "plain"})
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "annotations")
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		annotations := ReadOnlyAnnotations(true)
+		assert.True(t, annotations.ReadOnlyHint)
+		assert.False(t, annotations.DestructiveHint)
+		assert.True(t, annotations.IdempotentHint)
+		assert.True(t, annotations.OpenWorldHint)
+	})
+}
+
+func TestClientInfoFromContext(t *testing.T) {
+	_, ok := ClientInfoFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := ContextWithClientInfo(context.Background(), ClientInfo{Name: "inspector", Version: "1.2.0"})
+	info, ok := ClientInfoFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, ClientInfo{Name: "inspector", Version: "1.2.0"}, info)
+}
+
+// chunkRecorder is a ContentStreamer keeping the chunks it is sent
+type chunkRecorder []ContentChunk
+
+func (r *chunkRecorder) StreamContent(ctx context.Context, chunk ContentChunk) error {
+	*r = append(*r, chunk)
+	return nil
+}
+
+func TestStreamText(t *testing.T) {
+	_, ok := ContentStreamerFromContext(context.Background())
+	assert.False(t, ok)
+
+	// Multi-byte characters are never split across chunks
+	text := strings.Repeat("héllo wörld ", 20)
+	var chunks chunkRecorder
+	streamer, ok := ContentStreamerFromContext(ContextWithContentStreamer(context.Background(), &chunks))
+	require.True(t, ok)
+	sent, err := StreamText(context.Background(), streamer, "test://text", text, 10)
+	require.NoError(t, err)
+	assert.Equal(t, len(chunks), sent)
+	assert.Greater(t, sent, 1)
+
+	var joined strings.Builder
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Index)
+		assert.Equal(t, joined.Len(), chunk.Offset)
+		assert.LessOrEqual(t, len(chunk.Text), 10)
+		assert.True(t, utf8.ValidString(chunk.Text), chunk.Text)
+		assert.Equal(t, i == sent-1, chunk.Final)
+		joined.WriteString(chunk.Text)
+	}
+	assert.Equal(t, text, joined.String())
+	assert.Equal(t, len(text), chunks[sent-1].Total)
+
+	// Empty text is a single, final chunk
+	chunks = nil
+	sent, err = StreamText(context.Background(), &chunks, "", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, chunkRecorder{{Final: true}}, chunks)
+}
+
+func TestFormatJSON(t *testing.T) {
+	value := map[string]interface{}{"id": "1", "tags": []string{"a"}}
+
+	compact, err := FormatJSON(value, false)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"1","tags":["a"]}`, string(compact))
+
+	pretty, err := FormatJSON(value, true)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"id\": \"1\",\n  \"tags\": [\n    \"a\"\n  ]\n}", string(pretty))
+}
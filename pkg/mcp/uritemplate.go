@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MatchURITemplate matches uri against a resource URI template made of literal
+// text and simple {name} variables, such as "mongodb://{collection}/{id}". Each
+// variable matches a non-empty run of characters up to the next literal, and
+// never a "/", so a variable covers exactly one path segment. It returns the
+// percent-decoded variable values.
+func MatchURITemplate(template, uri string) (map[string]string, bool) {
+	values := make(map[string]string)
+	rest := uri
+
+	for template != "" {
+		open := strings.Index(template, "{")
+		if open < 0 {
+			return values, rest == template
+		}
+
+		literal := template[:open]
+		if !strings.HasPrefix(rest, literal) {
+			return nil, false
+		}
+		rest = rest[len(literal):]
+
+		end := strings.Index(template[open:], "}")
+		if end < 0 {
+			return nil, false
+		}
+		name := template[open+1 : open+end]
+		template = template[open+end+1:]
+
+		// The variable runs up to the next literal, or to the end of the URI
+		next := template
+		if i := strings.Index(next, "{"); i >= 0 {
+			next = next[:i]
+		}
+		var raw string
+		if next == "" {
+			raw, rest = rest, ""
+		} else {
+			i := strings.Index(rest, next)
+			if i < 0 {
+				return nil, false
+			}
+			raw, rest = rest[:i], rest[i:]
+		}
+		if raw == "" || strings.Contains(raw, "/") {
+			return nil, false
+		}
+
+		value, err := url.PathUnescape(raw)
+		if err != nil {
+			return nil, false
+		}
+		values[name] = value
+	}
+
+	return values, rest == ""
+}
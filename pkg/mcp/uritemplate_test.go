@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchURITemplate(t *testing.T) {
+	const template = "mongodb://{collection}/{id}"
+
+	values, ok := MatchURITemplate(template, "mongodb://documents/65f1c0ffee")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"collection": "documents", "id": "65f1c0ffee"}, values)
+
+	values, ok = MatchURITemplate(template, "mongodb://my.notes/a%20b")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"collection": "my.notes", "id": "a b"}, values)
+
+	values, ok = MatchURITemplate("docs://static", "docs://static")
+	assert.True(t, ok)
+	assert.Empty(t, values)
+
+	for _, uri := range []string{
+		"mongodb://documents",
+		"mongodb://documents/",
+		"mongodb:///65f1",
+		"mongodb://documents/a/b",
+		"file://documents/1",
+		"mongodb://documents/%zz",
+	} {
+		_, ok := MatchURITemplate(template, uri)
+		assert.False(t, ok, uri)
+	}
+
+	_, ok = MatchURITemplate("docs://{broken", "docs://x")
+	assert.False(t, ok)
+}
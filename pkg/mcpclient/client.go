@@ -0,0 +1,344 @@
+// Package mcpclient is a client for MCP servers reachable over WebSocket. It
+// correlates responses with requests by id, decodes them into the types of
+// package mcp and reconnects, with backoff, when the connection drops.
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+var (
+	// ErrNotConnected is returned by calls made before Connect
+	ErrNotConnected = errors.New("mcpclient: not connected")
+	// ErrClosed is returned by calls made after Close
+	ErrClosed = errors.New("mcpclient: client closed")
+	// ErrConnectionLost is returned by calls whose connection dropped before
+	// they were answered. They are not retried, since the server may have
+	// handled them.
+	ErrConnectionLost = errors.New("mcpclient: connection lost")
+)
+
+// RPCError is a JSON-RPC error returned by the server
+type RPCError struct {
+	Method  string
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s failed: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
+// Options configure a Client
+type Options struct {
+	// ClientInfo identifies the client in initialize
+	ClientInfo mcp.ClientInfo
+	// Header is sent with the WebSocket handshake, e.g. for authentication
+	Header http.Header
+	// Dialer opens the WebSocket connection; nil uses websocket.DefaultDialer
+	Dialer *websocket.Dialer
+
+	// MaxReconnectAttempts is how many times a call that finds the connection
+	// dropped tries to reconnect before failing; 0 disables reconnecting
+	MaxReconnectAttempts int
+	// ReconnectBackoff is the delay before the second attempt, doubled for
+	// every later one up to MaxReconnectBackoff. The first attempt is immediate.
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+}
+
+// DefaultOptions returns options that reconnect up to 5 times, backing off
+// from 100ms to 5s
+func DefaultOptions() Options {
+	return Options{
+		ClientInfo:           mcp.ClientInfo{Name: "mcpclient", Version: "1.0.0"},
+		MaxReconnectAttempts: 5,
+		ReconnectBackoff:     100 * time.Millisecond,
+		MaxReconnectBackoff:  5 * time.Second,
+	}
+}
+
+// Client talks to an MCP server over WebSocket. It is safe for concurrent use;
+// calls made at the same time are sent without waiting for each other.
+type Client struct {
+	url     string
+	options Options
+
+	dialMu sync.Mutex // held while connecting, so only one call reconnects
+
+	mu          sync.Mutex // guards the fields below
+	conn        *connection
+	nextID      int64
+	connected   bool // Connect succeeded once
+	closed      bool
+	initialized bool
+	server      *mcp.InitializeResponse
+	sessionID   string
+}
+
+// New creates a client for the server at url, such as ws://localhost:8080/mcp.
+// It does not connect until Connect is called.
+func New(url string, options Options) *Client {
+	return &Client{url: url, options: options}
+}
+
+// Connect opens the connection to the server
+func (c *Client) Connect(ctx context.Context) error {
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	c.mu.Lock()
+	closed, current := c.closed, c.conn
+	c.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	if current != nil && !current.isDone() {
+		return nil
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.connected = true
+	c.mu.Unlock()
+	return nil
+}
+
+// Initialize runs the initialize handshake and sends the initialized
+// notification. After a reconnect it runs again on the new connection,
+// resuming the server session when the server supports it.
+func (c *Client) Initialize(ctx context.Context) (*mcp.InitializeResponse, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.initialize(ctx, conn)
+}
+
+// ServerInfo returns the server's answer to the last initialize, or nil before
+// Initialize
+func (c *Client) ServerInfo() *mcp.InitializeResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.server
+}
+
+// ListTools lists every tool of the server, following tools/list pagination
+func (c *Client) ListTools(ctx context.Context) ([]mcp.Tool, error) {
+	tools := []mcp.Tool{}
+	request := mcp.ListToolsRequest{}
+	for {
+		var page mcp.ListToolsResponse
+		if err := c.Call(ctx, mcp.MethodListTools, request, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		request.Cursor = page.NextCursor
+	}
+}
+
+// CallTool calls the named tool. A tool that ran but failed is not an error:
+// its response has IsError set.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*mcp.ToolCallResponse, error) {
+	var response mcp.ToolCallResponse
+	if err := c.Call(ctx, mcp.MethodCallTool, mcp.ToolCallRequest{Name: name, Arguments: args}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Call sends a request and decodes its result into result, unless result is
+// nil. An error response is returned as an *RPCError.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return c.roundTrip(ctx, conn, method, params, result)
+}
+
+// Close closes the connection; later calls fail with ErrClosed
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.closed = true
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.ws.Close()
+}
+
+// connection returns the live connection, reconnecting when it dropped
+func (c *Client) connection(ctx context.Context) (*connection, error) {
+	c.mu.Lock()
+	conn, connected, closed := c.conn, c.connected, c.closed
+	c.mu.Unlock()
+	switch {
+	case closed:
+		return nil, ErrClosed
+	case !connected:
+		return nil, ErrNotConnected
+	case conn != nil && !conn.isDone():
+		return conn, nil
+	case c.options.MaxReconnectAttempts <= 0:
+		return nil, ErrConnectionLost
+	}
+	return c.reconnect(ctx)
+}
+
+// reconnect dials the server again, backing off between attempts, and
+// repeats the initialize handshake when the client had initialized
+func (c *Client) reconnect(ctx context.Context) (*connection, error) {
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	// Another call may have reconnected while this one waited
+	c.mu.Lock()
+	conn, closed, initialized := c.conn, c.closed, c.initialized
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	if conn != nil && !conn.isDone() {
+		return conn, nil
+	}
+
+	backoff := c.options.ReconnectBackoff
+	var lastErr error
+	for attempt := 0; attempt < c.options.MaxReconnectAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if c.options.MaxReconnectBackoff > 0 && backoff > c.options.MaxReconnectBackoff {
+				backoff = c.options.MaxReconnectBackoff
+			}
+		}
+
+		conn, lastErr = c.dial(ctx)
+		if lastErr != nil {
+			continue
+		}
+		if initialized {
+			if _, lastErr = c.initialize(ctx, conn); lastErr != nil {
+				conn.ws.Close()
+				continue
+			}
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.ws.Close()
+			return nil, ErrClosed
+		}
+		c.conn = conn
+		c.mu.Unlock()
+		return conn, nil
+	}
+	return nil, fmt.Errorf("%w: reconnecting failed after %d attempts: %v", ErrConnectionLost, c.options.MaxReconnectAttempts, lastErr)
+}
+
+func (c *Client) dial(ctx context.Context) (*connection, error) {
+	dialer := c.options.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	ws, _, err := dialer.DialContext(ctx, c.url, c.options.Header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.url, err)
+	}
+
+	conn := newConnection(ws)
+	go conn.readLoop()
+	return conn, nil
+}
+
+// initialize runs the handshake on conn
+func (c *Client) initialize(ctx context.Context, conn *connection) (*mcp.InitializeResponse, error) {
+	c.mu.Lock()
+	request := mcp.InitializeRequest{
+		ProtocolVersion: mcp.ProtocolVersion,
+		ClientInfo:      c.options.ClientInfo,
+	}
+	if c.sessionID != "" {
+		request.Meta = map[string]interface{}{"sessionId": c.sessionID}
+	}
+	c.mu.Unlock()
+
+	var response mcp.InitializeResponse
+	if err := c.roundTrip(ctx, conn, mcp.MethodInitialize, request, &response); err != nil {
+		return nil, err
+	}
+	if err := conn.write(mcp.NewNotification(mcp.MethodInitialized, nil)); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.initialized = true
+	c.server = &response
+	if sessionID, ok := response.Meta["sessionId"].(string); ok {
+		c.sessionID = sessionID
+	}
+	c.mu.Unlock()
+	return &response, nil
+}
+
+// roundTrip sends a request on conn and waits for its response
+func (c *Client) roundTrip(ctx context.Context, conn *connection, method string, params, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	responses, err := conn.expect(id)
+	if err != nil {
+		return err
+	}
+	defer conn.forget(id)
+
+	if err := conn.write(mcp.NewRequest(id, method, params)); err != nil {
+		return fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.done:
+		return conn.lostError()
+	case response := <-responses:
+		if response.Error != nil {
+			return &RPCError{Method: method, Code: response.Error.Code, Message: response.Error.Message, Data: response.Error.Data}
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,356 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request is a request or notification received by the mock server
+type request struct {
+	ID     interface{}     `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// mockServer is a WebSocket MCP server whose answers are set by handle.
+// handle returns the result or error of a request, or nil to leave it
+// unanswered.
+type mockServer struct {
+	*httptest.Server
+	handle func(conn *websocket.Conn, req request) *mcp.Response
+
+	writeMu     sync.Mutex
+	mu          sync.Mutex
+	connections []*websocket.Conn
+	received    []request
+}
+
+func newMockServer(t *testing.T, handle func(conn *websocket.Conn, req request) *mcp.Response) *mockServer {
+	m := &mockServer{handle: handle}
+	upgrader := websocket.Upgrader{}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		m.connections = append(m.connections, conn)
+		m.mu.Unlock()
+
+		for {
+			var req request
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			m.mu.Lock()
+			m.received = append(m.received, req)
+			m.mu.Unlock()
+			go func() {
+				if response := m.handle(conn, req); response != nil {
+					m.write(conn, response)
+				}
+			}()
+		}
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockServer) url() string {
+	return "ws" + strings.TrimPrefix(m.URL, "http")
+}
+
+func (m *mockServer) write(conn *websocket.Conn, v interface{}) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	return conn.WriteJSON(v)
+}
+
+// dropConnections closes every connection from the server side
+func (m *mockServer) dropConnections() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, conn := range m.connections {
+		conn.Close()
+	}
+	m.connections = nil
+}
+
+// requests returns the received requests for method
+func (m *mockServer) requests(method string) []request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matching []request
+	for _, req := range m.received {
+		if req.Method == method {
+			matching = append(matching, req)
+		}
+	}
+	return matching
+}
+
+// answer handles initialize, a two-page tools/list and tools/call of echo
+func answer(conn *websocket.Conn, req request) *mcp.Response {
+	switch req.Method {
+	case mcp.MethodInitialize:
+		var params mcp.InitializeRequest
+		json.Unmarshal(req.Params, &params)
+		sessionID := "session-1"
+		if resumed, ok := params.Meta["sessionId"].(string); ok {
+			sessionID = resumed
+		}
+		return mcp.NewResponse(req.ID, mcp.InitializeResponse{
+			ProtocolVersion: mcp.ProtocolVersion,
+			ServerInfo:      mcp.ServerInfo{Name: "mock", Version: "1.0.0"},
+			Meta:            map[string]interface{}{"sessionId": sessionID},
+		})
+	case mcp.MethodListTools:
+		var params mcp.ListToolsRequest
+		json.Unmarshal(req.Params, &params)
+		if params.Cursor == "" {
+			return mcp.NewResponse(req.ID, mcp.ListToolsResponse{Tools: []mcp.Tool{{Name: "echo"}}, NextCursor: "page-2"})
+		}
+		return mcp.NewResponse(req.ID, mcp.ListToolsResponse{Tools: []mcp.Tool{{Name: "add"}}})
+	case mcp.MethodCallTool:
+		var params mcp.ToolCallRequest
+		json.Unmarshal(req.Params, &params)
+		if params.Name != "echo" {
+			return mcp.NewErrorResponse(req.ID, mcp.ErrorCodeInvalidParams, "Unknown tool: "+params.Name, nil)
+		}
+		text, _ := params.Arguments["text"].(string)
+		return mcp.NewResponse(req.ID, mcp.ToolCallResponse{Content: []mcp.Content{{Type: "text", Text: text}}})
+	}
+	if req.ID == nil || req.Method == "" {
+		// Notifications and the client's responses are not answered
+		return nil
+	}
+	return mcp.NewErrorResponse(req.ID, mcp.ErrorCodeMethodNotFound, "Method not found", nil)
+}
+
+func testOptions() Options {
+	options := DefaultOptions()
+	options.ReconnectBackoff = time.Millisecond
+	options.MaxReconnectBackoff = 5 * time.Millisecond
+	return options
+}
+
+func connect(t *testing.T, server *mockServer, options Options) *Client {
+	client := New(server.url(), options)
+	require.NoError(t, client.Connect(context.Background()))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Initialize", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		client := connect(t, server, testOptions())
+
+		response, err := client.Initialize(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "mock", response.ServerInfo.Name)
+		assert.Equal(t, response, client.ServerInfo())
+
+		require.Eventually(t, func() bool { return len(server.requests(mcp.MethodInitialized)) == 1 }, time.Second, 10*time.Millisecond)
+		var params mcp.InitializeRequest
+		require.NoError(t, json.Unmarshal(server.requests(mcp.MethodInitialize)[0].Params, &params))
+		assert.Equal(t, mcp.ProtocolVersion, params.ProtocolVersion)
+		assert.Equal(t, "mcpclient", params.ClientInfo.Name)
+		assert.Empty(t, params.Meta)
+	})
+
+	t.Run("ListTools", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		client := connect(t, server, testOptions())
+
+		tools, err := client.ListTools(ctx)
+		require.NoError(t, err)
+		require.Len(t, tools, 2)
+		assert.Equal(t, "echo", tools[0].Name)
+		assert.Equal(t, "add", tools[1].Name)
+		assert.Len(t, server.requests(mcp.MethodListTools), 2)
+	})
+
+	t.Run("CallTool", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		client := connect(t, server, testOptions())
+
+		response, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "hello"})
+		require.NoError(t, err)
+		require.Len(t, response.Content, 1)
+		assert.Equal(t, "hello", response.Content[0].Text)
+
+		_, err = client.CallTool(ctx, "missing", nil)
+		var rpcErr *RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		assert.Equal(t, mcp.ErrorCodeInvalidParams, rpcErr.Code)
+		assert.Equal(t, "Unknown tool: missing", rpcErr.Message)
+		assert.EqualError(t, err, "tools/call failed: Unknown tool: missing (code -32602)")
+	})
+
+	t.Run("ConcurrentCalls", func(t *testing.T) {
+		// Answer the calls in reverse order of arrival, once all have arrived
+		const calls = 5
+		var mu sync.Mutex
+		var waiting []func()
+		server := newMockServer(t, func(conn *websocket.Conn, req request) *mcp.Response {
+			done := make(chan struct{})
+			mu.Lock()
+			waiting = append(waiting, func() { close(done) })
+			if len(waiting) == calls {
+				for i := len(waiting) - 1; i >= 0; i-- {
+					waiting[i]()
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			mu.Unlock()
+			<-done
+			return answer(conn, req)
+		})
+		client := connect(t, server, testOptions())
+
+		var wg sync.WaitGroup
+		texts := []string{"a", "b", "c", "d", "e"}
+		results := make([]string, calls)
+		for i, text := range texts {
+			wg.Add(1)
+			go func(i int, text string) {
+				defer wg.Done()
+				response, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": text})
+				if assert.NoError(t, err) {
+					results[i] = response.Content[0].Text
+				}
+			}(i, text)
+		}
+		wg.Wait()
+		assert.Equal(t, texts, results)
+	})
+
+	t.Run("ServerRequestsAreRejected", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		client := connect(t, server, testOptions())
+		_, err := client.Initialize(ctx)
+		require.NoError(t, err)
+
+		server.mu.Lock()
+		conn := server.connections[0]
+		server.mu.Unlock()
+		require.NoError(t, server.write(conn, mcp.NewRequest("srv-1", "roots/list", nil)))
+
+		// The client answers with an error response, which has no method
+		require.Eventually(t, func() bool {
+			for _, req := range server.requests("") {
+				if req.ID == "srv-1" {
+					return true
+				}
+			}
+			return false
+		}, time.Second, 10*time.Millisecond)
+
+		// The client still works afterwards
+		_, err = client.CallTool(ctx, "echo", map[string]interface{}{"text": "still here"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		client := connect(t, server, testOptions())
+		_, err := client.Initialize(ctx)
+		require.NoError(t, err)
+
+		server.dropConnections()
+		require.Eventually(t, func() bool {
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			return client.conn.isDone()
+		}, time.Second, 10*time.Millisecond)
+
+		response, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "again"})
+		require.NoError(t, err)
+		assert.Equal(t, "again", response.Content[0].Text)
+
+		// The new connection was initialized, resuming the session
+		initializes := server.requests(mcp.MethodInitialize)
+		require.Len(t, initializes, 2)
+		var params mcp.InitializeRequest
+		require.NoError(t, json.Unmarshal(initializes[1].Params, &params))
+		assert.Equal(t, "session-1", params.Meta["sessionId"])
+	})
+
+	t.Run("PendingCallsFailWhenConnectionDrops", func(t *testing.T) {
+		arrived := make(chan struct{})
+		server := newMockServer(t, func(conn *websocket.Conn, req request) *mcp.Response {
+			close(arrived)
+			return nil
+		})
+		client := connect(t, server, testOptions())
+
+		go func() {
+			<-arrived
+			server.dropConnections()
+		}()
+		_, err := client.CallTool(ctx, "echo", nil)
+		assert.ErrorIs(t, err, ErrConnectionLost)
+	})
+
+	t.Run("ReconnectDisabled", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		options := testOptions()
+		options.MaxReconnectAttempts = 0
+		client := connect(t, server, options)
+
+		server.dropConnections()
+		require.Eventually(t, func() bool {
+			_, err := client.CallTool(ctx, "echo", nil)
+			return errors.Is(err, ErrConnectionLost)
+		}, time.Second, 10*time.Millisecond)
+		assert.Len(t, server.requests(mcp.MethodCallTool), 0)
+	})
+
+	t.Run("ReconnectAttemptsExhausted", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		options := testOptions()
+		options.MaxReconnectAttempts = 3
+		client := connect(t, server, options)
+
+		server.dropConnections()
+		server.Close()
+		require.Eventually(t, func() bool {
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			return client.conn.isDone()
+		}, time.Second, 10*time.Millisecond)
+
+		_, err := client.CallTool(ctx, "echo", nil)
+		assert.ErrorIs(t, err, ErrConnectionLost)
+		assert.ErrorContains(t, err, "reconnecting failed after 3 attempts")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		server := newMockServer(t, answer)
+		client := New(server.url(), testOptions())
+
+		_, err := client.ListTools(ctx)
+		assert.ErrorIs(t, err, ErrNotConnected)
+
+		require.NoError(t, client.Connect(ctx))
+		require.NoError(t, client.Close())
+		_, err = client.ListTools(ctx)
+		assert.ErrorIs(t, err, ErrClosed)
+		assert.ErrorIs(t, client.Connect(ctx), ErrClosed)
+	})
+}
@@ -0,0 +1,121 @@
+package mcpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/kringen/go-mcp-server/pkg/mcp"
+)
+
+// incoming is a message from the server, with its parts left undecoded until
+// it is known what it is
+type incoming struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *mcp.Error      `json:"error"`
+}
+
+// connection is one WebSocket connection and the requests awaiting a
+// response on it. When it drops, done is closed and those requests fail.
+type connection struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan *incoming
+	err     error // why the connection dropped
+	done    chan struct{}
+}
+
+func newConnection(ws *websocket.Conn) *connection {
+	return &connection{
+		ws:      ws,
+		pending: make(map[int64]chan *incoming),
+		done:    make(chan struct{}),
+	}
+}
+
+func (c *connection) isDone() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// lostError reports why the connection dropped
+func (c *connection) lostError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Errorf("%w: %v", ErrConnectionLost, c.err)
+}
+
+// expect registers a request id, returning the channel its response arrives on
+func (c *connection) expect(id int64) (chan *incoming, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isDone() {
+		return nil, fmt.Errorf("%w: %v", ErrConnectionLost, c.err)
+	}
+	responses := make(chan *incoming, 1)
+	c.pending[id] = responses
+	return responses, nil
+}
+
+func (c *connection) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *connection) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteJSON(v)
+}
+
+// readLoop delivers responses to the requests awaiting them until the
+// connection drops. Notifications are ignored, and requests from the server,
+// such as roots/list, are answered with method not found.
+func (c *connection) readLoop() {
+	var err error
+	for {
+		var message incoming
+		if err = c.ws.ReadJSON(&message); err != nil {
+			break
+		}
+
+		var id int64
+		hasID := len(message.ID) > 0 && string(message.ID) != "null"
+		if message.Method != "" {
+			if hasID {
+				var serverID interface{}
+				json.Unmarshal(message.ID, &serverID)
+				go c.write(mcp.NewErrorResponse(serverID, mcp.ErrorCodeMethodNotFound,
+					fmt.Sprintf("Method not supported by client: %s", message.Method), nil))
+			}
+			continue
+		}
+		if !hasID || json.Unmarshal(message.ID, &id) != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		responses, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok {
+			responses <- &message
+		}
+	}
+
+	c.mu.Lock()
+	c.err = err
+	close(c.done)
+	c.mu.Unlock()
+	c.ws.Close()
+}
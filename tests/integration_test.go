@@ -12,6 +12,7 @@ import (
 	"github.com/kringen/go-mcp-server/internal/database"
 	"github.com/kringen/go-mcp-server/internal/search"
 	"github.com/kringen/go-mcp-server/internal/server"
+	"github.com/kringen/go-mcp-server/internal/tools"
 	"github.com/kringen/go-mcp-server/pkg/mcp"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
@@ -51,15 +52,22 @@ func TestMCPServerIntegration(t *testing.T) {
 
 	// Create and start MCP server
 	serverConfig := server.DefaultConfig()
-	serverConfig.Port = 8081 // Use different port for testing
-	mcpServer := server.NewServer(serverConfig, db, searcher)
+	serverConfig.Addr = "localhost:8081" // Use different port for testing
+	mcpServer := server.NewServerWithConfig(serverConfig)
+	for _, provider := range []mcp.ToolProvider{
+		tools.NewMathToolProvider(),
+		tools.NewSearchTool(searcher),
+		tools.NewDatabaseTool(db),
+	} {
+		require.NoError(t, mcpServer.RegisterToolProvider(provider))
+	}
 
 	// Start server
 	serverCtx, serverCancel := context.WithCancel(ctx)
 	defer serverCancel()
 
 	go func() {
-		err := mcpServer.Start(serverCtx, "localhost:8081")
+		err := mcpServer.Start(serverCtx, "")
 		if err != nil {
 			t.Logf("Server error: %v", err)
 		}